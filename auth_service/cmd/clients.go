@@ -0,0 +1,64 @@
+package main
+
+import (
+	"auth_service/internal/repository"
+	"auth_service/internal/service"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const clientsUsage = "usage: clients add [-public] <name> <redirect uri>... | show <client id>"
+
+// runClientsCommand registers the applications that sign in through the OpenID provider
+// endpoints. The secret of a new client is printed once; only its hash is stored.
+func runClientsCommand(ctx context.Context, repo repository.DBOAuthClientRepos, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf(clientsUsage)
+	}
+	switch args[0] {
+	case "add":
+		flags := flag.NewFlagSet("clients add", flag.ContinueOnError)
+		flags.SetOutput(out)
+		public := flags.Bool("public", false, "register a client that cannot keep a secret, such as a single page or mobile app")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() < 2 {
+			return fmt.Errorf(clientsUsage)
+		}
+		client, secret, err := service.NewOAuthClient(flags.Arg(0), flags.Args()[1:], *public)
+		if err != nil {
+			return err
+		}
+		response := repo.CreateClient(ctx, client)
+		if !response.Success {
+			return response.Errors
+		}
+		fmt.Fprintf(out, "client_id: %s\n", client.ClientID)
+		if secret != "" {
+			fmt.Fprintf(out, "client_secret: %s\n", secret)
+		}
+		return nil
+	case "show":
+		if len(args) != 2 {
+			return fmt.Errorf(clientsUsage)
+		}
+		response := repo.GetClient(ctx, args[1])
+		if !response.Success {
+			return response.Errors
+		}
+		clientData, ok := response.Data.(repository.DBOAuthClientResponseData)
+		if !ok {
+			return fmt.Errorf("unexpected data type from repository: %T", response.Data)
+		}
+		client := clientData.Client
+		fmt.Fprintf(out, "client_id: %s\nname: %s\npublic: %t\nredirect_uris: %s\ncreated_at: %s\n",
+			client.ClientID, client.Name, client.Public, strings.Join(client.RedirectURIs, " "), client.CreatedAt.Format(time.RFC3339))
+		return nil
+	}
+	return fmt.Errorf("unknown clients command %q; %s", args[0], clientsUsage)
+}
@@ -3,17 +3,23 @@ package main
 import (
 	"auth_service/configs"
 	"auth_service/internal/api"
+	"auth_service/internal/jwtkeys"
 	"auth_service/internal/kafka"
+	"auth_service/internal/metrics"
+	"auth_service/internal/password"
 	"auth_service/internal/repository"
 	"auth_service/internal/server"
 	"auth_service/internal/service"
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"shared/migrate"
 	"strings"
 	"syscall"
 	"time"
@@ -22,6 +28,8 @@ import (
 )
 
 func main() {
+	migrateOnStart := flag.Bool("migrate", false, "apply pending database migrations before starting the service")
+	flag.Parse()
 
 	_, filename, _, ok := runtime.Caller(0)
 	if !ok {
@@ -56,6 +64,50 @@ func main() {
 		return
 	}
 	defer dbInterface.Close(db)
+	migrator, err := migrate.New(db, repository.Migrations, repository.MigrationsDir)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+		return
+	}
+	if flag.Arg(0) == "migrate" {
+		if err := migrate.RunCommand(context.Background(), migrator, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatalf("Migration command failed: %v", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "clients" {
+		if err := runClientsCommand(context.Background(), repository.NewOAuthClientPostgres(db), flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatalf("Clients command failed: %v", err)
+		}
+		return
+	}
+	if *migrateOnStart {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			log.Fatalf("Failed to apply migrations: %v", err)
+			return
+		}
+	}
+
+	err = config.Kafka.Validate()
+	if err != nil {
+		log.Fatalf("Invalid Kafka configuration: %v", err)
+	}
+	err = config.RateLimit.Validate()
+	if err != nil {
+		log.Fatalf("Invalid rate limit configuration: %v", err)
+	}
+	err = config.SocialLogin.Validate()
+	if err != nil {
+		log.Fatalf("Invalid social login configuration: %v", err)
+	}
+	err = config.OIDCProvider.Validate()
+	if err != nil {
+		log.Fatalf("Invalid OpenID provider configuration: %v", err)
+	}
+	passwords, err := password.NewPolicy(config.PasswordHashing)
+	if err != nil {
+		log.Fatalf("Invalid password hashing configuration: %v", err)
+	}
 	rdb, redisInterface, err := repository.ConnectToRedis(config)
 	if err != nil {
 		log.Fatalf("Failed to connect to Redis: %v", err)
@@ -64,16 +116,69 @@ func main() {
 	defer redisInterface.Close(rdb)
 	brokersString := config.Kafka.BootstrapServers
 	brokers := strings.Split(brokersString, ",")
-	kafkaProducer, err := kafka.NewKafkaProducer(brokers)
+	if config.Kafka.CreateTopics {
+		err = kafka.EnsureTopics(brokers, config.Kafka.Topics.Names(), config.Kafka.Partitions, config.Kafka.ReplicationFactor)
+		if err != nil {
+			log.Fatalf("Failed to create Kafka topics: %v", err)
+			return
+		}
+	}
+	kafkaProducer, err := kafka.NewKafkaProducer(brokers, config.Kafka.Producer)
 	if err != nil {
 		log.Fatalf("Failed to create Kafka producer: %v", err)
 		return
 	}
-	defer kafkaProducer.Close()
 	repositories := repository.NewRepository(db, rdb)
 
-	service := service.NewService(repositories, kafkaProducer)
+	outboxRelay := service.NewOutboxRelay(repositories.DBOutboxRepos, kafkaProducer, service.OutboxRelayConfig{
+		PollInterval:    config.Outbox.PollInterval,
+		BatchSize:       config.Outbox.BatchSize,
+		Lease:           config.Outbox.Lease,
+		MinBackoff:      config.Outbox.MinBackoff,
+		MaxBackoff:      config.Outbox.MaxBackoff,
+		Retention:       config.Outbox.Retention,
+		CleanupInterval: config.Outbox.CleanupInterval,
+	})
+	relayCtx, relayCancel := context.WithCancel(context.Background())
+	defer relayCancel()
+	relayDone := make(chan struct{})
+	go func() {
+		outboxRelay.Run(relayCtx)
+		close(relayDone)
+	}()
+
+	signingKeys, err := jwtkeys.NewManager(config.Tokens)
+	if err != nil {
+		log.Fatalf("Failed to load token signing keys: %v", err)
+		return
+	}
+	go signingKeys.Run(relayCtx)
+
+	service := service.NewService(repositories, service.AuthServiceConfig{
+		Topics:        config.Kafka.Topics,
+		Tokens:        config.Tokens,
+		Login:         config.Login,
+		Verification:  config.Verification,
+		PasswordReset: config.PasswordReset,
+		MFA:           config.MFA,
+		WebAuthn:      config.WebAuthn,
+		SocialLogin:   config.SocialLogin,
+		OIDCProvider:  config.OIDCProvider,
+	}, config.RateLimit, passwords, signingKeys)
 	handlers := api.NewHandler(service)
+	var handler http.Handler = handlers.InitRoutes()
+	if config.Server.MetricsPath != "" {
+		for _, err := range []error{metrics.RegisterDB(db), metrics.RegisterRedis(rdb), metrics.RegisterKafkaProducer(kafkaProducer)} {
+			if err != nil {
+				log.Fatalf("Failed to register metrics: %v", err)
+			}
+		}
+		// Scrapes bypass the API middleware, so they are neither rate limited nor counted as requests.
+		root := http.NewServeMux()
+		root.Handle(config.Server.MetricsPath, metrics.Handler())
+		root.Handle("/", handler)
+		handler = root
+	}
 	srv := &server.Server{}
 
 	port := viper.GetString("server.port")
@@ -84,7 +189,7 @@ func main() {
 	serverError := make(chan error, 1)
 	go func() {
 
-		if err := srv.Run(port, handlers.InitRoutes()); err != nil {
+		if err := srv.Run(port, handler); err != nil {
 			serverError <- fmt.Errorf("server run failed: %w", err)
 			return
 		}
@@ -110,6 +215,16 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("Server shutdown error: %v", err)
 	}
+	relayCancel()
+	select {
+	case <-relayDone:
+	case <-ctx.Done():
+		log.Printf("Outbox relay did not stop in time: %v", ctx.Err())
+	}
+	// The relay has stopped handing over events, whatever is still queued is flushed now.
+	if err := kafkaProducer.Close(); err != nil {
+		log.Printf("Kafka producer close error: %v", err)
+	}
 
 	log.Println("Service has shutted down successfully")
 
@@ -1,14 +1,34 @@
 package configs
 
+import (
+	"fmt"
+	"net/url"
+	"shared/events"
+	"time"
+)
+
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	Kafka    KafkaConfig    `mapstructure:"kafka"`
+	Server          ServerConfig          `mapstructure:"server"`
+	Database        DatabaseConfig        `mapstructure:"database"`
+	Redis           RedisConfig           `mapstructure:"redis"`
+	Kafka           KafkaConfig           `mapstructure:"kafka"`
+	Outbox          OutboxConfig          `mapstructure:"outbox"`
+	Tokens          TokenConfig           `mapstructure:"tokens"`
+	Login           LoginConfig           `mapstructure:"login"`
+	RateLimit       RateLimitConfig       `mapstructure:"rate_limit"`
+	Verification    VerificationConfig    `mapstructure:"verification"`
+	PasswordReset   PasswordResetConfig   `mapstructure:"password_reset"`
+	MFA             MFAConfig             `mapstructure:"mfa"`
+	WebAuthn        WebAuthnConfig        `mapstructure:"webauthn"`
+	SocialLogin     SocialLoginConfig     `mapstructure:"social_login"`
+	OIDCProvider    OIDCProviderConfig    `mapstructure:"oidc_provider"`
+	PasswordHashing PasswordHashingConfig `mapstructure:"password_hashing"`
 }
 
 type ServerConfig struct {
 	Port string `mapstructure:"port"`
+	// MetricsPath serves the Prometheus metrics next to the API; empty turns them off.
+	MetricsPath string `mapstructure:"metrics_path"`
 }
 
 type DatabaseConfig struct {
@@ -28,14 +48,260 @@ type RedisConfig struct {
 	DB       int    `mapstructure:"db"`
 }
 type KafkaConfig struct {
-	BootstrapServers string      `mapstructure:"bootstrap_servers"`
-	Topics           KafkaTopics `mapstructure:"topics"`
-	GroupID          string      `mapstructure:"group_id"`
+	BootstrapServers  string              `mapstructure:"bootstrap_servers"`
+	Topics            events.Topics       `mapstructure:"topics"`
+	GroupID           string              `mapstructure:"group_id"`
+	CreateTopics      bool                `mapstructure:"create_topics"`
+	Partitions        int                 `mapstructure:"partitions"`
+	ReplicationFactor int                 `mapstructure:"replication_factor"`
+	Producer          KafkaProducerConfig `mapstructure:"producer"`
+}
+
+func (c KafkaConfig) Validate() error {
+	return c.Topics.Validate()
+}
+
+// KafkaProducerConfig tunes publishing. With Async set, messages wait in an in-memory queue of
+// QueueSize and are written in batches of up to BatchSize, at the latest BatchTimeout after the
+// first one was queued; a full queue rejects new messages. Acks is none, one or all and
+// Compression none, gzip, snappy, lz4 or zstd. On shutdown, queued messages are flushed for at
+// most FlushTimeout.
+type KafkaProducerConfig struct {
+	Async        bool          `mapstructure:"async"`
+	QueueSize    int           `mapstructure:"queue_size"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	BatchTimeout time.Duration `mapstructure:"batch_timeout"`
+	Acks         string        `mapstructure:"acks"`
+	Compression  string        `mapstructure:"compression"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	FlushTimeout time.Duration `mapstructure:"flush_timeout"`
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	Lease        time.Duration `mapstructure:"lease"`
+	MinBackoff   time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	// Sent events are deleted once they are older than Retention, checked every CleanupInterval.
+	Retention       time.Duration `mapstructure:"retention"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+}
+
+type TokenConfig struct {
+	AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl"`
+	Issuer          string        `mapstructure:"issuer"`
+	// Algorithm of generated signing keys: EdDSA or RS256. Keys loaded from KeysDir keep their own type.
+	Algorithm string `mapstructure:"algorithm"`
+	// KeysDir holds PKCS#8 PEM private keys named <kid>.pem. Every key in it is published in the JWKS,
+	// the one whose kid sorts last signs. When empty, keys are generated in memory and rotated every
+	// KeyRotationInterval, which only works for a single replica.
+	KeysDir             string        `mapstructure:"keys_dir"`
+	KeyRotationInterval time.Duration `mapstructure:"key_rotation_interval"`
+}
+
+// LoginConfig controls brute-force protection of the login endpoints. Failures are counted per
+// account and per client IP inside FailureWindow; reaching the limit locks the subject for
+// BaseLockout, doubled with every further failure up to MaxLockout.
+type LoginConfig struct {
+	MaxAccountFailures int           `mapstructure:"max_account_failures"`
+	MaxIPFailures      int           `mapstructure:"max_ip_failures"`
+	FailureWindow      time.Duration `mapstructure:"failure_window"`
+	BaseLockout        time.Duration `mapstructure:"base_lockout"`
+	MaxLockout         time.Duration `mapstructure:"max_lockout"`
+}
+
+// VerificationConfig controls email verification. Verification links stay valid for TokenTTL.
+// With RequireVerified set, the authorized routes listed in Routes (mux path templates) answer
+// 403 until the user has verified the email.
+type VerificationConfig struct {
+	TokenTTL        time.Duration `mapstructure:"token_ttl"`
+	RequireVerified bool          `mapstructure:"require_verified"`
+	Routes          []string      `mapstructure:"routes"`
+}
+
+// PasswordResetConfig controls password reset links. LinkURL is the page of the frontend that
+// receives the token as the "token" query parameter. A reset request is answered no sooner than
+// MinResponseTime, which should exceed the time it takes to store a token and queue the link.
+type PasswordResetConfig struct {
+	TokenTTL        time.Duration `mapstructure:"token_ttl"`
+	LinkURL         string        `mapstructure:"link_url"`
+	MinResponseTime time.Duration `mapstructure:"min_response_time"`
+}
+
+const (
+	RateLimitTokenBucket   = "token_bucket"
+	RateLimitSlidingWindow = "sliding_window"
+)
+
+// RateLimitConfig throttles requests per client IP. Routes are matched by their mux path template;
+// a route without its own entry falls back to Default, and a zero Default leaves it unlimited.
+type RateLimitConfig struct {
+	Enabled bool         `mapstructure:"enabled"`
+	Default RouteLimit   `mapstructure:"default"`
+	Routes  []RouteLimit `mapstructure:"routes"`
+}
+
+// RouteLimit allows Limit requests per Period. For token_bucket, Burst is the bucket size and
+// defaults to Limit; sliding_window ignores it.
+type RouteLimit struct {
+	Path      string        `mapstructure:"path"`
+	Algorithm string        `mapstructure:"algorithm"`
+	Limit     int           `mapstructure:"limit"`
+	Period    time.Duration `mapstructure:"period"`
+	Burst     int           `mapstructure:"burst"`
+}
+
+func (c RateLimitConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Default.Limit != 0 {
+		if err := c.Default.validate(); err != nil {
+			return fmt.Errorf("rate_limit.default: %w", err)
+		}
+	}
+	seen := make(map[string]bool, len(c.Routes))
+	for i, route := range c.Routes {
+		if route.Path == "" {
+			return fmt.Errorf("rate_limit.routes[%d]: path is required", i)
+		}
+		if seen[route.Path] {
+			return fmt.Errorf("rate_limit.routes[%d]: duplicate path %s", i, route.Path)
+		}
+		seen[route.Path] = true
+		if err := route.validate(); err != nil {
+			return fmt.Errorf("rate_limit.routes[%d] (%s): %w", i, route.Path, err)
+		}
+	}
+	return nil
+}
+
+func (l RouteLimit) validate() error {
+	if l.Algorithm != RateLimitTokenBucket && l.Algorithm != RateLimitSlidingWindow {
+		return fmt.Errorf("unknown algorithm %q", l.Algorithm)
+	}
+	if l.Limit <= 0 || l.Period <= 0 {
+		return fmt.Errorf("limit and period must be positive")
+	}
+	if l.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+	return nil
+}
+
+// MFAConfig controls TOTP two-factor authentication. Issuer is the name authenticator apps show
+// for the account. After the password of an enrolled user is accepted, the code has to follow
+// within ChallengeTTL. Skew is the number of 30 second steps a code may be off by.
+type MFAConfig struct {
+	Issuer        string        `mapstructure:"issuer"`
+	ChallengeTTL  time.Duration `mapstructure:"challenge_ttl"`
+	RecoveryCodes int           `mapstructure:"recovery_codes"`
+	Skew          int           `mapstructure:"skew"`
+}
+
+// WebAuthnConfig describes the relying party for passkeys. RPID is the domain passkeys are
+// bound to and Origins the exact origins of the pages that run the ceremonies. A ceremony has
+// to be finished within ChallengeTTL.
+type WebAuthnConfig struct {
+	RPID         string        `mapstructure:"rp_id"`
+	RPName       string        `mapstructure:"rp_name"`
+	Origins      []string      `mapstructure:"origins"`
+	ChallengeTTL time.Duration `mapstructure:"challenge_ttl"`
+}
+
+const (
+	IdentityProviderOIDC   = "oidc"
+	IdentityProviderGitHub = "github"
+)
+
+// SocialLoginConfig configures sign-in with external identity providers. The callback of a
+// provider is <CallbackURL>/<name>/callback and has to be registered with the provider as the
+// redirect URI. A sign-in has to come back within StateTTL. Providers without a client id are
+// left out, so a provider can stay in the file until it has credentials.
+type SocialLoginConfig struct {
+	CallbackURL string                   `mapstructure:"callback_url"`
+	StateTTL    time.Duration            `mapstructure:"state_ttl"`
+	Providers   []IdentityProviderConfig `mapstructure:"providers"`
+}
+
+// IdentityProviderConfig describes one provider. Type is oidc or github. For oidc, Issuer is
+// the OpenID issuer whose discovery document lists the endpoints; for github it optionally
+// points at a GitHub Enterprise server. Scopes default to what the type needs for the email.
+type IdentityProviderConfig struct {
+	Name         string   `mapstructure:"name"`
+	Type         string   `mapstructure:"type"`
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	Scopes       []string `mapstructure:"scopes"`
+}
+
+func (c SocialLoginConfig) Validate() error {
+	seen := make(map[string]bool, len(c.Providers))
+	for i, provider := range c.Providers {
+		if provider.ClientID == "" {
+			continue
+		}
+		if provider.Name == "" {
+			return fmt.Errorf("social_login.providers[%d]: name is required", i)
+		}
+		if seen[provider.Name] {
+			return fmt.Errorf("social_login.providers[%d]: duplicate name %s", i, provider.Name)
+		}
+		seen[provider.Name] = true
+		switch provider.Type {
+		case IdentityProviderOIDC:
+			if provider.Issuer == "" {
+				return fmt.Errorf("social_login.providers[%d] (%s): issuer is required", i, provider.Name)
+			}
+		case IdentityProviderGitHub:
+		default:
+			return fmt.Errorf("social_login.providers[%d] (%s): unknown type %q", i, provider.Name, provider.Type)
+		}
+	}
+	if len(seen) > 0 && c.CallbackURL == "" {
+		return fmt.Errorf("social_login.callback_url is required")
+	}
+	return nil
+}
+
+// OIDCProviderConfig lets registered applications sign their users in through this service
+// with OpenID Connect. Issuer is the public URL of the service; the discovery document is
+// served under it and ID tokens carry it as iss. A browser without a session is sent to
+// LoginURL with the authorization request as its query, and that page posts the credentials
+// back to /oauth2/authorize. Authorization codes have to be redeemed within CodeTTL.
+type OIDCProviderConfig struct {
+	Issuer   string        `mapstructure:"issuer"`
+	LoginURL string        `mapstructure:"login_url"`
+	CodeTTL  time.Duration `mapstructure:"code_ttl"`
+}
+
+func (c OIDCProviderConfig) Validate() error {
+	if c.Issuer == "" {
+		return nil
+	}
+	issuer, err := url.Parse(c.Issuer)
+	if err != nil || issuer.Scheme == "" || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" {
+		return fmt.Errorf("oidc_provider.issuer must be an absolute URL without query or fragment")
+	}
+	if c.LoginURL != "" {
+		login, err := url.Parse(c.LoginURL)
+		if err != nil || login.Scheme == "" || login.Host == "" {
+			return fmt.Errorf("oidc_provider.login_url must be an absolute URL")
+		}
+	}
+	return nil
 }
 
-type KafkaTopics struct {
-	SessionStarted  string `mapstructure:"session_started"`
-	UserRegistered  string `mapstructure:"user_registered"`
-	UserLoggedOut   string `mapstructure:"user_logged_out"`
-	UserDeleteTopic string `mapstructure:"user_delete_topic"`
+// PasswordHashingConfig picks the algorithm new password hashes are made with: argon2id or
+// bcrypt. Hashes made with the other algorithm or with other parameters keep verifying and are
+// replaced on the next successful login. Argon2Memory is in KiB.
+type PasswordHashingConfig struct {
+	Algorithm         string `mapstructure:"algorithm"`
+	BcryptCost        int    `mapstructure:"bcrypt_cost"`
+	Argon2Memory      uint32 `mapstructure:"argon2_memory"`
+	Argon2Iterations  uint32 `mapstructure:"argon2_iterations"`
+	Argon2Parallelism uint8  `mapstructure:"argon2_parallelism"`
 }
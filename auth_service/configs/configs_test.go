@@ -0,0 +1,78 @@
+package configs
+
+import (
+	"shared/events"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaConfig_Validate(t *testing.T) {
+	config := KafkaConfig{Topics: events.Topics{UserAuthenticate: "only-one-topic"}}
+	assert.ErrorContains(t, config.Validate(), "missing Kafka topic names", "Темы должны проверяться")
+}
+
+func TestRateLimitConfig_Validate(t *testing.T) {
+	cfg := RateLimitConfig{
+		Enabled: true,
+		Default: RouteLimit{Algorithm: RateLimitTokenBucket, Limit: 100, Period: time.Minute},
+		Routes: []RouteLimit{
+			{Path: "/reg", Algorithm: RateLimitSlidingWindow, Limit: 5, Period: time.Minute},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Routes = append(cfg.Routes, RouteLimit{Path: "/auth", Algorithm: "leaky_bucket", Limit: 5, Period: time.Minute})
+	assert.EqualError(t, cfg.Validate(), `rate_limit.routes[1] (/auth): unknown algorithm "leaky_bucket"`)
+
+	cfg.Routes[1] = RouteLimit{Path: "/reg", Algorithm: RateLimitSlidingWindow, Limit: 5, Period: time.Minute}
+	assert.EqualError(t, cfg.Validate(), "rate_limit.routes[1]: duplicate path /reg")
+
+	cfg.Routes = nil
+	cfg.Default.Period = 0
+	assert.EqualError(t, cfg.Validate(), "rate_limit.default: limit and period must be positive")
+
+	cfg.Enabled = false
+	assert.NoError(t, cfg.Validate(), "Выключенный лимит не проверяется")
+}
+
+func TestSocialLoginConfig_Validate(t *testing.T) {
+	cfg := SocialLoginConfig{
+		CallbackURL: "http://localhost:8081/oauth",
+		Providers: []IdentityProviderConfig{
+			{Name: "google", Type: IdentityProviderOIDC, Issuer: "https://accounts.google.com", ClientID: "client"},
+			{Name: "github", Type: IdentityProviderGitHub, ClientID: "client"},
+			{Name: "gitlab", Type: "saml"},
+		},
+	}
+	assert.NoError(t, cfg.Validate(), "Провайдер без client_id не проверяется")
+
+	cfg.Providers[2].ClientID = "client"
+	assert.EqualError(t, cfg.Validate(), `social_login.providers[2] (gitlab): unknown type "saml"`)
+
+	cfg.Providers[2] = IdentityProviderConfig{Name: "google", Type: IdentityProviderOIDC, ClientID: "client"}
+	assert.EqualError(t, cfg.Validate(), "social_login.providers[2]: duplicate name google")
+
+	cfg.Providers = cfg.Providers[:2]
+	cfg.Providers[0].Issuer = ""
+	assert.EqualError(t, cfg.Validate(), "social_login.providers[0] (google): issuer is required")
+
+	cfg.Providers[0].Issuer = "https://accounts.google.com"
+	cfg.CallbackURL = ""
+	assert.EqualError(t, cfg.Validate(), "social_login.callback_url is required")
+}
+
+func TestOIDCProviderConfig_Validate(t *testing.T) {
+	assert.NoError(t, OIDCProviderConfig{}.Validate(), "Без issuer провайдер выключен")
+
+	cfg := OIDCProviderConfig{Issuer: "https://auth.example.com", LoginURL: "https://app.example.com/login", CodeTTL: time.Minute}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Issuer = "auth_service"
+	assert.EqualError(t, cfg.Validate(), "oidc_provider.issuer must be an absolute URL without query or fragment")
+
+	cfg.Issuer = "https://auth.example.com"
+	cfg.LoginURL = "/login"
+	assert.EqualError(t, cfg.Validate(), "oidc_provider.login_url must be an absolute URL")
+}
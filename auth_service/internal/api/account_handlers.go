@@ -0,0 +1,102 @@
+package api
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/service"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// UpdateProfileRequest uses pointers so that a field missing from the body stays unchanged.
+type UpdateProfileRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+type ProfileResponse struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+}
+
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, sessionID, ok := sessionFromRequestContext(w, r, maparesponse)
+	if !ok {
+		return
+	}
+	var request ChangePasswordRequest
+	if !readJSONBody(w, r, &request) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.ChangePassword(ctx, sessionID, userID, request.CurrentPassword, request.NewPassword)
+	if !response.Success {
+		log.Printf("Error during password change: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), accountFailureStatus(response))
+		return
+	}
+	log.Printf("Person with id: %v has changed the password", userID)
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID})
+}
+
+func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, ok := getUserIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the UserId from the request context")
+		maparesponse["UserId"] = erro.ErrorGetUserId.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return
+	}
+	var request UpdateProfileRequest
+	if !readJSONBody(w, r, &request) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.UpdateProfile(ctx, userID, request.Name, request.Email)
+	if !response.Success {
+		log.Printf("Error during profile update: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), accountFailureStatus(response))
+		return
+	}
+	log.Printf("Person with id: %v has updated the profile", userID)
+	httpResponse := HTTPResponse{Success: true, UserID: userID}
+	if response.Profile != nil {
+		httpResponse.Profile = &ProfileResponse{
+			ID:            response.Profile.Id,
+			Name:          response.Profile.Name,
+			Email:         response.Profile.Email,
+			EmailVerified: response.Profile.EmailVerified,
+		}
+	}
+	goodResponse(w, httpResponse)
+}
+
+// accountFailureStatus maps the errors of the account endpoints to a status code: bad input is
+// 400, a wrong current password 403 and a taken email 409.
+func accountFailureStatus(response *service.ServiceResponse) int {
+	for field, err := range response.Errors {
+		switch {
+		case errors.Is(err, erro.ErrorInvalidPassword):
+			return http.StatusForbidden
+		case errors.Is(err, erro.ErrorUniqueEmail):
+			return http.StatusConflict
+		case field == "Name" || field == "Email" || field == "Password" || errors.Is(err, erro.ErrorNothingToUpdate):
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusInternalServerError
+}
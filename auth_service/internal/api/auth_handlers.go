@@ -2,14 +2,18 @@ package api
 
 import (
 	"auth_service/internal/erro"
+	"auth_service/internal/metrics"
 	"auth_service/internal/model"
 	"auth_service/internal/service"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -52,6 +56,7 @@ func (h *Handler) Registration(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
+	metrics.Registrations.Inc()
 
 	addCookie(w, regresponse.SessionId, regresponse.ExpirationTime)
 	w.Header().Set("Content-Type", jsonResponseType)
@@ -102,14 +107,19 @@ func (h *Handler) Authentication(w http.ResponseWriter, r *http.Request) {
 	}
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	auresponse := h.services.AuthenticateAndLogin(ctx, &newperk)
+	auresponse := h.services.AuthenticateAndLogin(ctx, &newperk, clientIP(r))
+	recordLogin("password", auresponse)
 	if !auresponse.Success {
 		stringMap := convertErrorToString(auresponse)
 		log.Printf("Error during user authentication: %v", auresponse.Errors)
-		badResponse(w, stringMap, http.StatusBadRequest)
+		badResponse(w, stringMap, loginFailureStatus(w, auresponse))
 
 		return
 	}
+	if auresponse.MFAChallenge != "" {
+		mfaChallengeResponse(w, auresponse)
+		return
+	}
 
 	w.Header().Set("Content-Type", jsonResponseType)
 	w.WriteHeader(http.StatusOK)
@@ -139,7 +149,7 @@ func (h *Handler) Authorization(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
-	cookie, err := r.Cookie("session_id")
+	sessionID, err := h.sessionIDFromRequest(r)
 	if err != nil {
 		log.Println("The person's session was not found")
 		if err == http.ErrNoCookie {
@@ -152,10 +162,9 @@ func (h *Handler) Authorization(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
-	sessionID := cookie.Value
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	response := h.services.Authorization(ctx, sessionID)
+	response := h.authorize(ctx, sessionID)
 	if !response.Success {
 		stringMap := convertErrorToString(response)
 
@@ -195,11 +204,13 @@ func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
-	cookie, err := r.Cookie("session_id")
-	if err != nil {
-		log.Printf("Unexpected error getting session cookie (should have been validated by middleware): %v", err)
+	sessionID, ok := getSessionIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the SessionId from the request context")
+		maparesponse["SessionId"] = erro.ErrorInvalidSessionID.Error()
+		badResponse(w, maparesponse, http.StatusUnauthorized)
+		return
 	}
-	sessionID := cookie.Value
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 	response := h.services.Logout(ctx, sessionID, userID)
@@ -244,11 +255,13 @@ func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
 		badResponse(w, maparesponse, http.StatusMethodNotAllowed)
 		return
 	}
-	cookie, err := r.Cookie("session_id")
-	if err != nil {
-		log.Printf("Unexpected error getting session cookie (should have been validated by middleware): %v", err)
+	sessionID, ok := getSessionIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the SessionId from the request context")
+		maparesponse["SessionId"] = erro.ErrorInvalidSessionID.Error()
+		badResponse(w, maparesponse, http.StatusUnauthorized)
+		return
 	}
-	sessionID := cookie.Value
 	password, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("ReadAll Error: %v", err)
@@ -334,6 +347,30 @@ func deleteCookie(w http.ResponseWriter) {
 
 	http.SetCookie(w, cookie)
 }
+
+// loginFailureStatus picks the status of a failed login and sets Retry-After while the login is locked.
+func loginFailureStatus(w http.ResponseWriter, response *service.ServiceResponse) int {
+	for _, err := range response.Errors {
+		if errors.Is(err, erro.ErrorTooManyAttempts) {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(response.RetryAfter)))
+			return http.StatusTooManyRequests
+		}
+		if errors.Is(err, erro.ErrorInvalidCredentials) || errors.Is(err, erro.ErrorInvalidMFACode) || errors.Is(err, erro.ErrorInvalidMFAChallenge) {
+			return http.StatusUnauthorized
+		}
+	}
+	return http.StatusBadRequest
+}
+
+// clientIP is the peer address of the request. X-Forwarded-For is not trusted here, otherwise
+// any client could pick the IP its failed attempts are counted against.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
 func convertErrorToString(mapa *service.ServiceResponse) map[string]string {
 	stringMap := make(map[string]string)
 	for key, err := range mapa.Errors {
@@ -345,14 +382,3 @@ func convertErrorToString(mapa *service.ServiceResponse) map[string]string {
 	}
 	return stringMap
 }
-func getUserIDFromRequestContext(r *http.Request) (uuid.UUID, bool) {
-	return getUserIDFromContext(r.Context())
-}
-func getUserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
-	userID, ok := ctx.Value("userID").(uuid.UUID)
-	if !ok {
-
-		return uuid.Nil, false
-	}
-	return userID, true
-}
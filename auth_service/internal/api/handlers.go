@@ -15,9 +15,17 @@ type Handler struct {
 	services *service.Service
 }
 type HTTPResponse struct {
-	Success bool              `json:"success"`
-	Errors  map[string]string `json:"errors"`
-	UserID  uuid.UUID         `json:"data"`
+	Success  bool              `json:"success"`
+	Errors   map[string]string `json:"errors"`
+	UserID   uuid.UUID         `json:"data"`
+	Tokens   *TokenResponse    `json:"tokens,omitempty"`
+	Sessions []SessionResponse `json:"sessions,omitempty"`
+	Profile  *ProfileResponse  `json:"profile,omitempty"`
+	MFA      *MFAResponse      `json:"mfa,omitempty"`
+	Passkey  *PasskeyResponse  `json:"passkey,omitempty"`
+	// RedirectURL is the page the client has to open: an identity provider, or the client
+	// application returning from /oauth2/authorize.
+	RedirectURL string `json:"redirect_url,omitempty"`
 }
 
 func NewHandler(services *service.Service) *Handler {
@@ -28,5 +36,40 @@ func (h *Handler) InitRoutes() *mux.Router {
 	m.HandleFunc("/reg", h.NonAuthorizedMiddleware(h.Registration)).Methods("POST")
 	m.HandleFunc("/auth", h.NonAuthorizedMiddleware(h.Authentication)).Methods("POST")
 	m.HandleFunc("/check-session", h.Authorization).Methods("GET")
+	m.HandleFunc("/logout", h.AuthorizedMiddleware(h.Logout)).Methods("POST")
+	m.HandleFunc("/account", h.AuthorizedMiddleware(h.Delete)).Methods("DELETE")
+	m.HandleFunc("/account", h.AuthorizedMiddleware(h.UpdateProfile)).Methods("PATCH")
+	m.HandleFunc("/account/password", h.AuthorizedMiddleware(h.ChangePassword)).Methods("PUT")
+	m.HandleFunc("/auth/mfa", h.VerifyMFA).Methods("POST")
+	m.HandleFunc("/token", h.IssueToken).Methods("POST")
+	m.HandleFunc("/token/mfa", h.VerifyMFAToken).Methods("POST")
+	m.HandleFunc("/token/refresh", h.RefreshToken).Methods("POST")
+	m.HandleFunc("/.well-known/jwks.json", h.JWKS).Methods("GET")
+	m.HandleFunc("/sessions", h.AuthorizedMiddleware(h.ListSessions)).Methods("GET")
+	m.HandleFunc("/sessions", h.AuthorizedMiddleware(h.RevokeAllSessions)).Methods("DELETE")
+	m.HandleFunc("/sessions/{id}", h.AuthorizedMiddleware(h.RevokeSession)).Methods("DELETE")
+	m.HandleFunc("/verify", h.VerifyEmail).Methods("GET")
+	m.HandleFunc("/verify/resend", h.AuthorizedMiddleware(h.ResendVerification)).Methods("POST")
+	m.HandleFunc("/password/forgot", h.ForgotPassword).Methods("POST")
+	m.HandleFunc("/password/reset", h.ResetPassword).Methods("POST")
+	m.HandleFunc("/mfa/totp", h.AuthorizedMiddleware(h.EnrollTOTP)).Methods("POST")
+	m.HandleFunc("/mfa/totp/confirm", h.AuthorizedMiddleware(h.ConfirmTOTP)).Methods("POST")
+	m.HandleFunc("/mfa/totp", h.AuthorizedMiddleware(h.DisableTOTP)).Methods("DELETE")
+	m.HandleFunc("/webauthn/register/begin", h.AuthorizedMiddleware(h.BeginPasskeyRegistration)).Methods("POST")
+	m.HandleFunc("/webauthn/register/finish", h.AuthorizedMiddleware(h.FinishPasskeyRegistration)).Methods("POST")
+	m.HandleFunc("/webauthn/login/begin", h.BeginPasskeyLogin).Methods("POST")
+	m.HandleFunc("/webauthn/login/finish", h.FinishPasskeyLogin).Methods("POST")
+	m.HandleFunc("/oauth/{provider}/login", h.NonAuthorizedMiddleware(h.ExternalLogin)).Methods("GET")
+	m.HandleFunc("/oauth/{provider}/link", h.AuthorizedMiddleware(h.LinkExternalAccount)).Methods("POST")
+	m.HandleFunc("/oauth/{provider}/callback", h.ExternalLoginCallback).Methods("GET")
+	m.HandleFunc("/.well-known/openid-configuration", h.OpenIDConfiguration).Methods("GET")
+	m.HandleFunc("/oauth2/authorize", h.Authorize).Methods("GET")
+	m.HandleFunc("/oauth2/authorize", h.AuthorizeLogin).Methods("POST")
+	m.HandleFunc("/oauth2/token", h.ClientToken).Methods("POST")
+	m.HandleFunc("/oauth2/userinfo", h.AuthorizedMiddleware(h.UserInfo)).Methods("GET")
+	m.Use(h.MetricsMiddleware)
+	if h.services.RateLimiting != nil {
+		m.Use(h.RateLimitMiddleware)
+	}
 	return m
 }
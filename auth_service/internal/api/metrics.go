@@ -0,0 +1,57 @@
+package api
+
+import (
+	"auth_service/internal/metrics"
+	"auth_service/internal/service"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// statusRecorder remembers the status code written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// MetricsMiddleware counts requests and their latency per route template, so paths with ids
+// such as /sessions/{id} stay one series.
+func (handler *Handler) MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		labels := []string{routeTemplate(r), r.Method, strconv.Itoa(rec.status)}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// recordLogin counts a finished login attempt. A failure is counted once per error key of the
+// response; a passed first factor that still needs an MFA code is not counted either way.
+func recordLogin(method string, response *service.ServiceResponse) {
+	if !response.Success {
+		for reason := range response.Errors {
+			metrics.LoginFailures.WithLabelValues(method, reason).Inc()
+		}
+		return
+	}
+	if response.MFAChallenge == "" {
+		metrics.Logins.WithLabelValues(method).Inc()
+	}
+}
+
+// authorize checks the session and counts its renewal, which the service reports.
+func (handler *Handler) authorize(ctx context.Context, sessionID string) *service.ServiceResponse {
+	response := handler.services.Authorization(ctx, sessionID)
+	if response.Success && response.SessionRenewed {
+		metrics.SessionRenewals.Inc()
+	}
+	return response
+}
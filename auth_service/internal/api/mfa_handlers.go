@@ -0,0 +1,169 @@
+package api
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/service"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+type MFAVerifyRequest struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code"`
+}
+type TOTPConfirmRequest struct {
+	Code string `json:"code"`
+}
+type TOTPDisableRequest struct {
+	Password string `json:"password"`
+}
+
+// MFAResponse carries whatever a step of the two-factor flow hands to the client: the pending
+// login challenge, the enrollment secret, or the recovery codes.
+type MFAResponse struct {
+	Challenge     string     `json:"challenge,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Secret        string     `json:"secret,omitempty"`
+	URI           string     `json:"otpauth_uri,omitempty"`
+	RecoveryCodes []string   `json:"recovery_codes,omitempty"`
+}
+
+// VerifyMFA finishes a cookie login with the code of the second factor.
+func (h *Handler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var request MFAVerifyRequest
+	if !readJSONBody(w, r, &request) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.CompleteMFALogin(ctx, request.Challenge, request.Code, clientIP(r))
+	recordLogin("mfa", response)
+	if !response.Success {
+		log.Printf("Error during two-factor authentication: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), loginFailureStatus(w, response))
+		return
+	}
+	log.Printf("Person with id: %v has successfully authenticated with a second factor", response.UserId)
+	addCookie(w, response.SessionId, response.ExpirationTime)
+	goodResponse(w, HTTPResponse{Success: true, UserID: response.UserId})
+}
+
+// VerifyMFAToken finishes a token login with the code of the second factor.
+func (h *Handler) VerifyMFAToken(w http.ResponseWriter, r *http.Request) {
+	var request MFAVerifyRequest
+	if !readJSONBody(w, r, &request) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.CompleteMFATokens(ctx, request.Challenge, request.Code, clientIP(r))
+	recordLogin("mfa", response)
+	if !response.Success {
+		log.Printf("Error during two-factor authentication: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), loginFailureStatus(w, response))
+		return
+	}
+	log.Printf("Person with id: %v has received tokens with a second factor", response.UserId)
+	tokenResponse(w, response)
+}
+
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, ok := getUserIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the UserId from the request context")
+		maparesponse["UserId"] = erro.ErrorGetUserId.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.EnrollTOTP(ctx, userID)
+	if !response.Success {
+		log.Printf("Error during TOTP enrollment: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), mfaFailureStatus(response))
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID, MFA: &MFAResponse{Secret: response.TOTPSecret, URI: response.TOTPURI}})
+}
+
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, ok := getUserIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the UserId from the request context")
+		maparesponse["UserId"] = erro.ErrorGetUserId.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return
+	}
+	var request TOTPConfirmRequest
+	if !readJSONBody(w, r, &request) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.ConfirmTOTP(ctx, userID, request.Code)
+	if !response.Success {
+		log.Printf("Error during TOTP confirmation: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), mfaFailureStatus(response))
+		return
+	}
+	log.Printf("Person with id: %v has enabled two-factor authentication", userID)
+	w.Header().Set("Cache-Control", "no-store")
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID, MFA: &MFAResponse{RecoveryCodes: response.RecoveryCodes}})
+}
+
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, ok := getUserIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the UserId from the request context")
+		maparesponse["UserId"] = erro.ErrorGetUserId.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return
+	}
+	var request TOTPDisableRequest
+	if !readJSONBody(w, r, &request) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.DisableTOTP(ctx, userID, request.Password)
+	if !response.Success {
+		log.Printf("Error during TOTP removal: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), mfaFailureStatus(response))
+		return
+	}
+	log.Printf("Person with id: %v has disabled two-factor authentication", userID)
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID})
+}
+
+// mfaChallengeResponse answers a correct password of an enrolled user: 202 with the challenge
+// the code has to be sent with, and no session yet.
+func mfaChallengeResponse(w http.ResponseWriter, response *service.ServiceResponse) {
+	expiresAt := response.ExpirationTime
+	sucresponse := HTTPResponse{
+		Success: true,
+		MFA:     &MFAResponse{Challenge: response.MFAChallenge, ExpiresAt: &expiresAt},
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, sucresponse, http.StatusAccepted)
+}
+
+func mfaFailureStatus(response *service.ServiceResponse) int {
+	for _, err := range response.Errors {
+		switch {
+		case errors.Is(err, erro.ErrorInvalidPassword), errors.Is(err, erro.ErrorInvalidMFACode):
+			return http.StatusForbidden
+		case errors.Is(err, erro.ErrorMFAAlreadyEnabled):
+			return http.StatusConflict
+		case errors.Is(err, erro.ErrorMFANotEnrolled):
+			return http.StatusNotFound
+		}
+	}
+	return http.StatusInternalServerError
+}
@@ -1,13 +1,27 @@
 package api
 
 import (
+	"auth_service/internal/erro"
+	"context"
 	"log"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type contextKey string
+
+const (
+	userIDKey    contextKey = "userID"
+	sessionIDKey contextKey = "sessionID"
 )
 
 func (handler *Handler) NonAuthorizedMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session_id")
+		sessionID, err := handler.sessionIDFromRequest(r)
 		if err != nil {
 			if err == http.ErrNoCookie {
 				next.ServeHTTP(w, r)
@@ -19,8 +33,7 @@ func (handler *Handler) NonAuthorizedMiddleware(next http.HandlerFunc) http.Hand
 				return
 			}
 		}
-		sessionID := cookie.Value
-		response := handler.services.Authorization(r.Context(), sessionID)
+		response := handler.authorize(r.Context(), sessionID)
 		if response.Success {
 			stringMap := convertErrorToString(response)
 			badResponse(w, stringMap, http.StatusForbidden)
@@ -29,3 +42,97 @@ func (handler *Handler) NonAuthorizedMiddleware(next http.HandlerFunc) http.Hand
 		next.ServeHTTP(w, r)
 	}
 }
+
+func (handler *Handler) AuthorizedMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maparesponse := make(map[string]string)
+		sessionID, err := handler.sessionIDFromRequest(r)
+		if err != nil {
+			if err == http.ErrNoCookie {
+				log.Println("The person's session was not found")
+				maparesponse["SessionId"] = erro.ErrorInvalidSessionID.Error()
+				badResponse(w, maparesponse, http.StatusUnauthorized)
+				return
+			}
+			log.Printf("Error reading cookie: %v", err)
+			maparesponse["SessionId"] = erro.ErrorInternalServer.Error()
+			badResponse(w, maparesponse, http.StatusInternalServerError)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		response := handler.authorize(ctx, sessionID)
+		if !response.Success {
+			stringMap := convertErrorToString(response)
+			badResponse(w, stringMap, http.StatusUnauthorized)
+			return
+		}
+		verified := handler.services.RequireVerifiedEmail(ctx, routeTemplate(r), response.UserId)
+		if !verified.Success {
+			stringMap := convertErrorToString(verified)
+			statusCode := http.StatusInternalServerError
+			if verified.Errors["VerificationError"] == erro.ErrorEmailNotVerified {
+				statusCode = http.StatusForbidden
+			}
+			badResponse(w, stringMap, statusCode)
+			return
+		}
+		reqCtx := context.WithValue(r.Context(), userIDKey, response.UserId)
+		reqCtx = context.WithValue(reqCtx, sessionIDKey, response.SessionId)
+		next.ServeHTTP(w, r.WithContext(reqCtx))
+	}
+}
+
+// sessionIDFromRequest reads the session cookie used by browsers and falls back to the
+// "Authorization: Bearer <access token>" header used by mobile clients, taking the session
+// from the verified token. http.ErrNoCookie is returned when the request carries neither.
+func (handler *Handler) sessionIDFromRequest(r *http.Request) (string, error) {
+	cookie, err := r.Cookie("session_id")
+	if err == nil {
+		return cookie.Value, nil
+	}
+	if err != http.ErrNoCookie {
+		return "", err
+	}
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		return "", http.ErrNoCookie
+	}
+	claims, err := handler.services.VerifyAccessToken(r.Context(), strings.TrimSpace(token))
+	if err != nil {
+		// An invalid token is treated like a missing session; the caller answers 401.
+		log.Printf("Access token rejected: %v", err)
+		return "", http.ErrNoCookie
+	}
+	return claims.SessionID, nil
+}
+
+// routeTemplate is the mux path template of the matched route, e.g. /sessions/{id}.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+func getUserIDFromRequestContext(r *http.Request) (uuid.UUID, bool) {
+	return getUserIDFromContext(r.Context())
+}
+func getUserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(userIDKey).(uuid.UUID)
+	if !ok {
+
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+func getSessionIDFromRequestContext(r *http.Request) (string, bool) {
+	sessionID, ok := r.Context().Value(sessionIDKey).(string)
+	if !ok || sessionID == "" {
+		return "", false
+	}
+	return sessionID, true
+}
@@ -0,0 +1,525 @@
+package api_test
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/api"
+	"auth_service/internal/erro"
+	"auth_service/internal/metrics"
+	"auth_service/internal/model"
+	"auth_service/internal/service"
+	"auth_service/internal/webauthn"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"shared/jwtverify"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAuthentication struct {
+	sessions   map[string]uuid.UUID
+	loggedOut  []string
+	unverified map[uuid.UUID]bool
+	// renewing is a session reported as renewed by Authorization.
+	renewing string
+}
+
+func (f *fakeAuthentication) RegistrateAndLogin(ctx context.Context, user *model.Person) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: false}
+}
+func (f *fakeAuthentication) AuthenticateAndLogin(ctx context.Context, user *model.Person, clientIP string) *service.ServiceResponse {
+	if user.Email == "admin@example.com" {
+		return &service.ServiceResponse{Success: true, MFAChallenge: "pending", ExpirationTime: time.Now().Add(time.Minute)}
+	}
+	if user.Email == "tester@example.com" && user.Password == "password123" {
+		return &service.ServiceResponse{Success: true, UserId: uuid.New(), SessionId: "login-session", ExpirationTime: time.Now().Add(time.Hour)}
+	}
+	return &service.ServiceResponse{Success: false, Errors: map[string]error{"LoginError": erro.ErrorInvalidCredentials}}
+}
+func (f *fakeAuthentication) Authorization(ctx context.Context, sessionID string) *service.ServiceResponse {
+	userID, ok := f.sessions[sessionID]
+	if !ok {
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"GetSessionError": erro.ErrorInvalidSessionID}}
+	}
+	return &service.ServiceResponse{Success: true, UserId: userID, SessionId: sessionID, SessionRenewed: sessionID == f.renewing}
+}
+func (f *fakeAuthentication) Logout(ctx context.Context, sessionID string, userId uuid.UUID) *service.ServiceResponse {
+	if f.sessions[sessionID] != userId {
+		return &service.ServiceResponse{Success: false}
+	}
+	f.loggedOut = append(f.loggedOut, sessionID)
+	return &service.ServiceResponse{Success: true}
+}
+func (f *fakeAuthentication) DeleteAccount(ctx context.Context, sessionID string, userid uuid.UUID, password string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true}
+}
+
+func (f *fakeAuthentication) IssueTokens(ctx context.Context, user *model.Person, clientIP string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: false}
+}
+func (f *fakeAuthentication) RefreshTokens(ctx context.Context, refreshToken string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: false}
+}
+
+func (f *fakeAuthentication) ListSessions(ctx context.Context, userID uuid.UUID) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true, UserId: userID}
+}
+func (f *fakeAuthentication) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true, UserId: userID}
+}
+func (f *fakeAuthentication) RevokeAllSessions(ctx context.Context, userID uuid.UUID, keepSessionID string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true, UserId: userID}
+}
+func (f *fakeAuthentication) VerifyEmail(ctx context.Context, token string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: false, Errors: map[string]error{"VerificationError": erro.ErrorInvalidVerificationToken}}
+}
+func (f *fakeAuthentication) ResendVerification(ctx context.Context, userID uuid.UUID) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true, UserId: userID}
+}
+func (f *fakeAuthentication) RequireVerifiedEmail(ctx context.Context, route string, userID uuid.UUID) *service.ServiceResponse {
+	if route == "/sessions" && f.unverified[userID] {
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"VerificationError": erro.ErrorEmailNotVerified}}
+	}
+	return &service.ServiceResponse{Success: true, UserId: userID}
+}
+func (f *fakeAuthentication) ForgotPassword(ctx context.Context, email string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true}
+}
+func (f *fakeAuthentication) ResetPassword(ctx context.Context, token, password string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: false, Errors: map[string]error{"ResetError": erro.ErrorInvalidResetToken}}
+}
+func (f *fakeAuthentication) CompleteMFALogin(ctx context.Context, challenge, code, clientIP string) *service.ServiceResponse {
+	if challenge != "pending" || code != "123456" {
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"MFAError": erro.ErrorInvalidMFACode}}
+	}
+	return &service.ServiceResponse{Success: true, UserId: uuid.New(), SessionId: "mfa-session", ExpirationTime: time.Now().Add(time.Hour)}
+}
+func (f *fakeAuthentication) CompleteMFATokens(ctx context.Context, challenge, code, clientIP string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: false, Errors: map[string]error{"MFAError": erro.ErrorInvalidMFAChallenge}}
+}
+func (f *fakeAuthentication) EnrollTOTP(ctx context.Context, userID uuid.UUID) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true, UserId: userID, TOTPSecret: "JBSWY3DPEHPK3PXP", TOTPURI: "otpauth://totp/test"}
+}
+func (f *fakeAuthentication) ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: false, Errors: map[string]error{"MFAError": erro.ErrorInvalidMFACode}}
+}
+func (f *fakeAuthentication) DisableTOTP(ctx context.Context, userID uuid.UUID, password string) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: false, Errors: map[string]error{"MFAError": erro.ErrorMFANotEnrolled}}
+}
+func (f *fakeAuthentication) BeginPasskeyRegistration(ctx context.Context, userID uuid.UUID) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true, UserId: userID, PasskeyCreation: &webauthn.CreationOptions{Challenge: []byte("challenge")}}
+}
+func (f *fakeAuthentication) FinishPasskeyRegistration(ctx context.Context, userID uuid.UUID, name string, response webauthn.RegistrationResponse) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: false, Errors: map[string]error{"PasskeyError": erro.ErrorPasskeyExists}}
+}
+func (f *fakeAuthentication) BeginPasskeyLogin(ctx context.Context) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true, PasskeyRequest: &webauthn.RequestOptions{Challenge: []byte("challenge")}}
+}
+func (f *fakeAuthentication) FinishPasskeyLogin(ctx context.Context, response webauthn.AuthenticationResponse) *service.ServiceResponse {
+	if response.ID != "known" {
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"PasskeyError": erro.ErrorInvalidPasskey}}
+	}
+	return &service.ServiceResponse{Success: true, UserId: uuid.New(), SessionId: "passkey-session", ExpirationTime: time.Now().Add(time.Hour)}
+}
+func (f *fakeAuthentication) BeginExternalLogin(ctx context.Context, provider string, linkUserID uuid.UUID) *service.ServiceResponse {
+	if provider != "stub" {
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"IdentityError": erro.ErrorUnknownIdentityProvider}}
+	}
+	return &service.ServiceResponse{Success: true, UserId: linkUserID, RedirectURL: "https://idp.example/authorize?state=state-1", OAuthState: "state-1", ExpirationTime: time.Now().Add(time.Minute)}
+}
+func (f *fakeAuthentication) CompleteExternalLogin(ctx context.Context, provider, code, state string) *service.ServiceResponse {
+	switch code {
+	case "good":
+		return &service.ServiceResponse{Success: true, UserId: uuid.New(), SessionId: "external-session", ExpirationTime: time.Now().Add(time.Hour)}
+	case "taken":
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"IdentityError": erro.ErrorIdentityEmailTaken}}
+	}
+	return &service.ServiceResponse{Success: false, Errors: map[string]error{"IdentityError": erro.ErrorIdentityProvider}}
+}
+func (f *fakeAuthentication) OpenIDConfiguration() *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true, OpenIDConfiguration: &model.OpenIDConfiguration{Issuer: "https://auth.example"}}
+}
+func (f *fakeAuthentication) CheckAuthorizationRequest(ctx context.Context, request model.AuthorizationRequest) *service.ServiceResponse {
+	switch {
+	case request.ClientID != "app":
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"OIDCError": erro.ErrorUnknownOAuthClient}}
+	case request.RedirectURI != "https://app.example/cb":
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"OIDCError": erro.ErrorInvalidRedirectURI}}
+	case request.CodeChallenge == "":
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"OIDCError": erro.ErrorInvalidAuthRequest}}
+	}
+	return &service.ServiceResponse{Success: true, RedirectURL: "https://app.example/login?client_id=app"}
+}
+func (f *fakeAuthentication) Authorize(ctx context.Context, request model.AuthorizationRequest, userID uuid.UUID) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true, UserId: userID, RedirectURL: request.RedirectURI + "?code=code-1&state=" + request.State}
+}
+func (f *fakeAuthentication) ExchangeClientToken(ctx context.Context, request model.TokenRequest) *service.ServiceResponse {
+	if request.ClientID != "app" || request.ClientSecret != "secret" {
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"OIDCError": erro.ErrorInvalidClientCredentials}}
+	}
+	if request.Code != "code-1" {
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"OIDCError": erro.ErrorInvalidAuthCode}}
+	}
+	return &service.ServiceResponse{Success: true, UserId: uuid.New(), AccessToken: "access", RefreshToken: "refresh", IDToken: "id-token", ExpirationTime: time.Now().Add(time.Hour)}
+}
+func (f *fakeAuthentication) UserInfo(ctx context.Context, userID uuid.UUID) *service.ServiceResponse {
+	return &service.ServiceResponse{Success: true, UserId: userID, Profile: &model.Profile{Id: userID, Name: "tester", Email: "tester@example.com", EmailVerified: true}}
+}
+func (f *fakeAuthentication) ChangePassword(ctx context.Context, sessionID string, userID uuid.UUID, currentPassword, newPassword string) *service.ServiceResponse {
+	if currentPassword != "password123" {
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"CurrentPassword": erro.ErrorInvalidPassword}}
+	}
+	return &service.ServiceResponse{Success: true, UserId: userID}
+}
+func (f *fakeAuthentication) UpdateProfile(ctx context.Context, userID uuid.UUID, name, email *string) *service.ServiceResponse {
+	if email != nil && *email == "taken@example.com" {
+		return &service.ServiceResponse{Success: false, Errors: map[string]error{"UpdateError": erro.ErrorUniqueEmail}}
+	}
+	return &service.ServiceResponse{Success: true, UserId: userID, Profile: &model.Profile{Id: userID, Name: "tester", Email: "tester@example.com"}}
+}
+
+// fakeAccessTokens accepts tokens of the form "jwt:<session id>".
+type fakeAccessTokens struct{}
+
+func (fakeAccessTokens) VerifyAccessToken(ctx context.Context, token string) (jwtverify.Claims, error) {
+	sessionID, ok := strings.CutPrefix(token, "jwt:")
+	if !ok {
+		return jwtverify.Claims{}, jwtverify.ErrMalformedToken
+	}
+	return jwtverify.Claims{SessionID: sessionID}, nil
+}
+func (fakeAccessTokens) JWKS() jwtverify.JWKS { return jwtverify.JWKS{} }
+
+func TestAuthorizedMiddleware(t *testing.T) {
+	userID := uuid.New()
+	unverifiedID := uuid.New()
+	fake := &fakeAuthentication{
+		sessions:   map[string]uuid.UUID{"valid-session": userID, "unverified-session": unverifiedID},
+		unverified: map[uuid.UUID]bool{unverifiedID: true},
+	}
+	router := api.NewHandler(&service.Service{UserAuthentication: fake, AccessTokens: fakeAccessTokens{}}).InitRoutes()
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		cookie         string
+		bearer         string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Logout Without Cookie", method: http.MethodPost, path: "/logout", expectedStatus: http.StatusUnauthorized},
+		{name: "Logout With Unknown Session", method: http.MethodPost, path: "/logout", cookie: "unknown", expectedStatus: http.StatusUnauthorized},
+		{name: "Logout With Valid Session", method: http.MethodPost, path: "/logout", cookie: "valid-session", expectedStatus: http.StatusOK},
+		{name: "Delete Without Cookie", method: http.MethodDelete, path: "/account", expectedStatus: http.StatusUnauthorized},
+		{name: "Delete With Valid Session", method: http.MethodDelete, path: "/account", cookie: "valid-session", expectedStatus: http.StatusOK},
+		{name: "List Sessions Without Cookie", method: http.MethodGet, path: "/sessions", expectedStatus: http.StatusUnauthorized},
+		{name: "List Sessions With Valid Session", method: http.MethodGet, path: "/sessions", cookie: "valid-session", expectedStatus: http.StatusOK},
+		{name: "Revoke Session Without Cookie", method: http.MethodDelete, path: "/sessions/other", expectedStatus: http.StatusUnauthorized},
+		{name: "Delete With Bearer Token", method: http.MethodDelete, path: "/account", bearer: "jwt:valid-session", expectedStatus: http.StatusOK},
+		{name: "Delete With Opaque Bearer Token", method: http.MethodDelete, path: "/account", bearer: "valid-session", expectedStatus: http.StatusUnauthorized},
+		{name: "Delete With Unknown Bearer Token", method: http.MethodDelete, path: "/account", bearer: "jwt:unknown", expectedStatus: http.StatusUnauthorized},
+		{name: "List Sessions With Unverified Email", method: http.MethodGet, path: "/sessions", cookie: "unverified-session", expectedStatus: http.StatusForbidden},
+		{name: "Resend Verification With Unverified Email", method: http.MethodPost, path: "/verify/resend", cookie: "unverified-session", expectedStatus: http.StatusOK},
+		{name: "Verify With Invalid Token", method: http.MethodGet, path: "/verify?token=bad", expectedStatus: http.StatusBadRequest},
+		{name: "Forgot Password", method: http.MethodPost, path: "/password/forgot", body: `{"email":"tester@example.com"}`, expectedStatus: http.StatusAccepted},
+		{name: "Reset Password With Invalid Token", method: http.MethodPost, path: "/password/reset", body: `{"token":"bad","password":"newpassword123"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Change Password Without Cookie", method: http.MethodPut, path: "/account/password", body: `{"current_password":"password123","new_password":"newpassword123"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "Change Password", method: http.MethodPut, path: "/account/password", cookie: "valid-session", body: `{"current_password":"password123","new_password":"newpassword123"}`, expectedStatus: http.StatusOK},
+		{name: "Change Password With Wrong Current Password", method: http.MethodPut, path: "/account/password", cookie: "valid-session", body: `{"current_password":"wrong","new_password":"newpassword123"}`, expectedStatus: http.StatusForbidden},
+		{name: "Update Profile", method: http.MethodPatch, path: "/account", cookie: "valid-session", body: `{"name":"tester"}`, expectedStatus: http.StatusOK},
+		{name: "Update Profile With Taken Email", method: http.MethodPatch, path: "/account", cookie: "valid-session", body: `{"email":"taken@example.com"}`, expectedStatus: http.StatusConflict},
+		{name: "Login With Second Factor", method: http.MethodPost, path: "/auth", body: `{"email":"admin@example.com","password":"password123"}`, expectedStatus: http.StatusAccepted},
+		{name: "Verify Second Factor", method: http.MethodPost, path: "/auth/mfa", body: `{"challenge":"pending","code":"123456"}`, expectedStatus: http.StatusOK},
+		{name: "Verify Second Factor With Wrong Code", method: http.MethodPost, path: "/auth/mfa", body: `{"challenge":"pending","code":"000000"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "Verify Token Second Factor With Unknown Challenge", method: http.MethodPost, path: "/token/mfa", body: `{"challenge":"gone","code":"123456"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "Enroll TOTP Without Cookie", method: http.MethodPost, path: "/mfa/totp", expectedStatus: http.StatusUnauthorized},
+		{name: "Enroll TOTP", method: http.MethodPost, path: "/mfa/totp", cookie: "valid-session", expectedStatus: http.StatusOK},
+		{name: "Confirm TOTP With Wrong Code", method: http.MethodPost, path: "/mfa/totp/confirm", cookie: "valid-session", body: `{"code":"000000"}`, expectedStatus: http.StatusForbidden},
+		{name: "Disable TOTP Without Enrollment", method: http.MethodDelete, path: "/mfa/totp", cookie: "valid-session", body: `{"password":"password123"}`, expectedStatus: http.StatusNotFound},
+		{name: "Begin Passkey Registration Without Cookie", method: http.MethodPost, path: "/webauthn/register/begin", expectedStatus: http.StatusUnauthorized},
+		{name: "Begin Passkey Registration", method: http.MethodPost, path: "/webauthn/register/begin", cookie: "valid-session", expectedStatus: http.StatusOK},
+		{name: "Finish Passkey Registration Twice", method: http.MethodPost, path: "/webauthn/register/finish", cookie: "valid-session", body: `{"name":"laptop","credential":{"id":"known","rawId":"a25vd24","type":"public-key"}}`, expectedStatus: http.StatusConflict},
+		{name: "Begin Passkey Login", method: http.MethodPost, path: "/webauthn/login/begin", expectedStatus: http.StatusOK},
+		{name: "Finish Passkey Login", method: http.MethodPost, path: "/webauthn/login/finish", body: `{"id":"known","rawId":"a25vd24","type":"public-key"}`, expectedStatus: http.StatusOK},
+		{name: "Finish Passkey Login With Unknown Passkey", method: http.MethodPost, path: "/webauthn/login/finish", body: `{"id":"unknown","rawId":"dW5rbm93bg","type":"public-key"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "Finish Passkey Login With Malformed Body", method: http.MethodPost, path: "/webauthn/login/finish", body: `{"rawId":"not base64!"}`, expectedStatus: http.StatusBadRequest},
+		{name: "External Login", method: http.MethodGet, path: "/oauth/stub/login", expectedStatus: http.StatusFound},
+		{name: "External Login With Unknown Provider", method: http.MethodGet, path: "/oauth/other/login", expectedStatus: http.StatusNotFound},
+		{name: "Link External Account Without Cookie", method: http.MethodPost, path: "/oauth/stub/link", expectedStatus: http.StatusUnauthorized},
+		{name: "Link External Account", method: http.MethodPost, path: "/oauth/stub/link", cookie: "valid-session", expectedStatus: http.StatusOK},
+		{name: "External Login Callback Without State Cookie", method: http.MethodGet, path: "/oauth/stub/callback?code=good&state=state-1", expectedStatus: http.StatusBadRequest},
+		{name: "OpenID Discovery", method: http.MethodGet, path: "/.well-known/openid-configuration", expectedStatus: http.StatusOK},
+		{name: "User Info Without Token", method: http.MethodGet, path: "/oauth2/userinfo", expectedStatus: http.StatusUnauthorized},
+		{name: "User Info With Bearer Token", method: http.MethodGet, path: "/oauth2/userinfo", bearer: "jwt:valid-session", expectedStatus: http.StatusOK},
+		{name: "Reset Password With Malformed Body", method: http.MethodPost, path: "/password/reset", body: `{`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "session_id", Value: tc.cookie})
+			}
+			if tc.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.bearer)
+			}
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code, "Статус ответа должен совпадать")
+		})
+	}
+	assert.Equal(t, []string{"valid-session"}, fake.loggedOut, "Logout должен получить сессию из middleware")
+}
+
+func TestExternalLoginCallback(t *testing.T) {
+	router := api.NewHandler(&service.Service{UserAuthentication: &fakeAuthentication{}, AccessTokens: fakeAccessTokens{}}).InitRoutes()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/oauth/stub/login", nil))
+	assert.Equal(t, "https://idp.example/authorize?state=state-1", rec.Header().Get("Location"), "Браузер должен уходить к провайдеру")
+	stateCookie := rec.Result().Cookies()[0]
+	assert.Equal(t, "state-1", stateCookie.Value, "State должен сохраняться в cookie")
+	assert.Equal(t, http.SameSiteLaxMode, stateCookie.SameSite, "Cookie должен приходить в callback с сайта провайдера")
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedCookie string
+	}{
+		{name: "Signed In", query: "code=good&state=state-1", expectedStatus: http.StatusOK, expectedCookie: "external-session"},
+		{name: "Foreign State", query: "code=good&state=state-2", expectedStatus: http.StatusBadRequest},
+		{name: "Provider Error", query: "error=access_denied&state=state-1", expectedStatus: http.StatusUnauthorized},
+		{name: "Email Taken", query: "code=taken&state=state-1", expectedStatus: http.StatusConflict},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/oauth/stub/callback?"+tc.query, nil)
+			req.AddCookie(stateCookie)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code, "Статус ответа должен совпадать")
+			var session string
+			for _, cookie := range rec.Result().Cookies() {
+				switch cookie.Name {
+				case "session_id":
+					session = cookie.Value
+				case "oauth_state":
+					assert.Negative(t, cookie.MaxAge, "State cookie должен удаляться")
+				}
+			}
+			assert.Equal(t, tc.expectedCookie, session, "Сессия должна выдаваться только после входа")
+		})
+	}
+}
+
+func TestOIDCProvider(t *testing.T) {
+	fake := &fakeAuthentication{sessions: map[string]uuid.UUID{"valid-session": uuid.New()}}
+	router := api.NewHandler(&service.Service{UserAuthentication: fake, AccessTokens: fakeAccessTokens{}}).InitRoutes()
+	const authorize = "/oauth2/authorize?client_id=app&redirect_uri=https%3A%2F%2Fapp.example%2Fcb&response_type=code&scope=openid&state=s1&code_challenge=c1&code_challenge_method=S256"
+
+	authorizeCases := []struct {
+		name             string
+		path             string
+		cookie           string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{name: "With Session", path: authorize, cookie: "valid-session", expectedStatus: http.StatusFound, expectedLocation: "https://app.example/cb?code=code-1&state=s1"},
+		{name: "Without Session", path: authorize, expectedStatus: http.StatusFound, expectedLocation: "https://app.example/login?client_id=app"},
+		{name: "Forced Login", path: authorize + "&prompt=login", cookie: "valid-session", expectedStatus: http.StatusFound, expectedLocation: "https://app.example/login?client_id=app"},
+		{name: "Silent Without Session", path: authorize + "&prompt=none", expectedStatus: http.StatusFound, expectedLocation: "https://app.example/cb?error=login_required&state=s1"},
+		{name: "Without PKCE", path: strings.Replace(authorize, "&code_challenge=c1", "", 1), expectedStatus: http.StatusFound, expectedLocation: "https://app.example/cb?error=invalid_request&state=s1"},
+		{name: "Unregistered Redirect", path: strings.Replace(authorize, "app.example", "evil.example", 1), expectedStatus: http.StatusBadRequest},
+		{name: "Unknown Client", path: strings.Replace(authorize, "client_id=app", "client_id=other", 1), expectedStatus: http.StatusBadRequest},
+	}
+	for _, tc := range authorizeCases {
+		t.Run("Authorize "+tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "session_id", Value: tc.cookie})
+			}
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code, "Статус ответа должен совпадать")
+			assert.Equal(t, tc.expectedLocation, rec.Header().Get("Location"), "Браузер должен уходить по нужному адресу")
+		})
+	}
+
+	loginCases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedCookie string
+	}{
+		{name: "Signed In", body: `{"email":"tester@example.com","password":"password123"}`, expectedStatus: http.StatusOK, expectedCookie: "login-session"},
+		{name: "Wrong Password", body: `{"email":"tester@example.com","password":"wrong"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "Second Factor", body: `{"email":"admin@example.com","password":"password123"}`, expectedStatus: http.StatusAccepted},
+	}
+	for _, tc := range loginCases {
+		t.Run("Login "+tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, authorize, strings.NewReader(tc.body)))
+
+			assert.Equal(t, tc.expectedStatus, rec.Code, "Статус ответа должен совпадать")
+			var session string
+			for _, cookie := range rec.Result().Cookies() {
+				if cookie.Name == "session_id" {
+					session = cookie.Value
+				}
+			}
+			assert.Equal(t, tc.expectedCookie, session, "Сессия должна выдаваться только после входа")
+			if tc.expectedStatus == http.StatusOK {
+				assert.Contains(t, rec.Body.String(), `"redirect_url":"https://app.example/cb?code=code-1\u0026state=s1"`, "Страница входа получает адрес возврата к клиенту")
+			}
+		})
+	}
+
+	tokenCases := []struct {
+		name           string
+		form           string
+		basic          bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Secret In Form", form: "grant_type=authorization_code&client_id=app&client_secret=secret&code=code-1", expectedStatus: http.StatusOK, expectedBody: `"id_token":"id-token"`},
+		{name: "Secret In Basic Auth", form: "grant_type=authorization_code&code=code-1", basic: true, expectedStatus: http.StatusOK, expectedBody: `"token_type":"Bearer"`},
+		{name: "Wrong Secret", form: "grant_type=authorization_code&client_id=app&client_secret=wrong&code=code-1", expectedStatus: http.StatusUnauthorized, expectedBody: `"error":"invalid_client"`},
+		{name: "Unknown Code", form: "grant_type=authorization_code&client_id=app&client_secret=secret&code=code-2", expectedStatus: http.StatusBadRequest, expectedBody: `"error":"invalid_grant"`},
+	}
+	for _, tc := range tokenCases {
+		t.Run("Token "+tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(tc.form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.basic {
+				req.SetBasicAuth("app", "secret")
+			}
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code, "Статус ответа должен совпадать")
+			assert.Contains(t, rec.Body.String(), tc.expectedBody, "Ответ должен быть в формате RFC 6749")
+			assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"), "Токены не должны кэшироваться")
+		})
+	}
+}
+
+// fakeRateLimiting allows a fixed number of requests per route and client.
+type fakeRateLimiting struct {
+	limit  int
+	counts map[string]int
+	err    error
+	routes []string
+}
+
+func (f *fakeRateLimiting) AllowRequest(ctx context.Context, route, subject string) (service.RateLimitDecision, error) {
+	f.routes = append(f.routes, route)
+	if f.err != nil {
+		return service.RateLimitDecision{}, f.err
+	}
+	f.counts[route+":"+subject]++
+	used := f.counts[route+":"+subject]
+	decision := service.RateLimitDecision{
+		Allowed:   used <= f.limit,
+		Limit:     f.limit,
+		Remaining: max(f.limit-used, 0),
+		Reset:     30 * time.Second,
+		Policy:    configs.RouteLimit{Limit: f.limit, Period: time.Minute},
+	}
+	if !decision.Allowed {
+		decision.RetryAfter = 1500 * time.Millisecond
+	}
+	return decision, nil
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	userID := uuid.New()
+	limiter := &fakeRateLimiting{limit: 2, counts: make(map[string]int)}
+	fake := &fakeAuthentication{sessions: map[string]uuid.UUID{"valid-session": userID}}
+	router := api.NewHandler(&service.Service{UserAuthentication: fake, AccessTokens: fakeAccessTokens{}, RateLimiting: limiter}).InitRoutes()
+
+	send := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, path, nil)
+		req.RemoteAddr = remoteAddr
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: "valid-session"})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("/sessions/a", "192.0.2.1:1000")
+	assert.Equal(t, http.StatusOK, first.Code, "Статус ответа должен совпадать")
+	assert.Equal(t, "2", first.Header().Get("RateLimit-Limit"), "RateLimit-Limit должен совпадать")
+	assert.Equal(t, "1", first.Header().Get("RateLimit-Remaining"), "RateLimit-Remaining должен совпадать")
+	assert.Equal(t, "30", first.Header().Get("RateLimit-Reset"), "RateLimit-Reset должен совпадать")
+	assert.Equal(t, "2;w=60", first.Header().Get("RateLimit-Policy"), "RateLimit-Policy должен совпадать")
+
+	assert.Equal(t, http.StatusOK, send("/sessions/b", "192.0.2.1:1001").Code, "Статус ответа должен совпадать")
+	limited := send("/sessions/c", "192.0.2.1:1002")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code, "Статус ответа должен совпадать")
+	assert.Equal(t, "2", limited.Header().Get("Retry-After"), "Retry-After должен совпадать")
+	assert.Equal(t, "0", limited.Header().Get("RateLimit-Remaining"), "RateLimit-Remaining должен совпадать")
+
+	assert.Equal(t, http.StatusOK, send("/sessions/d", "192.0.2.2:1000").Code, "Другой клиент не должен ограничиваться")
+	assert.Equal(t, "/sessions/{id}", limiter.routes[0], "Лимит должен выбираться по шаблону маршрута")
+
+	limiter.err = erro.ErrorRateLimit
+	assert.Equal(t, http.StatusOK, send("/sessions/e", "192.0.2.1:1003").Code, "Ошибка Redis не должна блокировать запросы")
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	router := api.NewHandler(&service.Service{UserAuthentication: &fakeAuthentication{sessions: map[string]uuid.UUID{}}, AccessTokens: fakeAccessTokens{}}).InitRoutes()
+	requests := func(route, status string) float64 {
+		return testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(route, http.MethodPost, status))
+	}
+	logins, failures := testutil.ToFloat64(metrics.Logins.WithLabelValues("password")), testutil.ToFloat64(metrics.LoginFailures.WithLabelValues("password", "LoginError"))
+	okBefore, failedBefore, challengedBefore := requests("/auth", "200"), requests("/auth", "401"), requests("/auth", "202")
+	revokeBefore := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/sessions/{id}", http.MethodDelete, "401"))
+
+	for _, body := range []string{
+		`{"email":"tester@example.com","password":"password123"}`,
+		`{"email":"tester@example.com","password":"wrong"}`,
+		`{"email":"admin@example.com","password":"password123"}`,
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/auth", strings.NewReader(body)))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/sessions/"+uuid.NewString(), nil))
+
+	assert.Equal(t, okBefore+1, requests("/auth", "200"), "Запросы должны считаться по маршруту и статусу")
+	assert.Equal(t, failedBefore+1, requests("/auth", "401"))
+	assert.Equal(t, challengedBefore+1, requests("/auth", "202"))
+	assert.Equal(t, revokeBefore+1, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/sessions/{id}", http.MethodDelete, "401")),
+		"Путь с идентификатором должен считаться по шаблону маршрута")
+	assert.Equal(t, logins+1, testutil.ToFloat64(metrics.Logins.WithLabelValues("password")), "Вход с запросом MFA не должен считаться завершённым")
+	assert.Equal(t, failures+1, testutil.ToFloat64(metrics.LoginFailures.WithLabelValues("password", "LoginError")), "Ошибка должна считаться по ключу ответа")
+}
+
+func TestSessionRenewalsAreCounted(t *testing.T) {
+	userID := uuid.New()
+	services := &fakeAuthentication{sessions: map[string]uuid.UUID{"valid-session": userID, "old-session": userID}, renewing: "old-session"}
+	router := api.NewHandler(&service.Service{UserAuthentication: services, AccessTokens: fakeAccessTokens{}}).InitRoutes()
+	before := testutil.ToFloat64(metrics.SessionRenewals)
+
+	for _, sessionID := range []string{"valid-session", "old-session"} {
+		req := httptest.NewRequest(http.MethodGet, "/sessions", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.SessionRenewals), "Продление должно считаться по ответу сервиса")
+}
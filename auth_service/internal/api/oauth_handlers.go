@@ -0,0 +1,140 @@
+package api
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/service"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// oauthStateCookie binds a sign-in to the browser that started it, so a callback URL with
+// someone else's code cannot sign the victim into the attacker's account. It has to be Lax:
+// the callback is a top-level navigation coming from the provider's site.
+const oauthStateCookie = "oauth_state"
+
+// ExternalLogin sends the browser to the provider: GET /oauth/{provider}/login.
+func (h *Handler) ExternalLogin(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.BeginExternalLogin(ctx, mux.Vars(r)["provider"], uuid.Nil)
+	if !response.Success {
+		log.Printf("Error starting external login: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), oauthFailureStatus(response))
+		return
+	}
+	addOAuthStateCookie(w, response.OAuthState, response.ExpirationTime)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, response.RedirectURL, http.StatusFound)
+}
+
+// LinkExternalAccount starts linking a provider to the signed in user. It answers with the URL
+// instead of a redirect so that the request can stay a POST.
+func (h *Handler) LinkExternalAccount(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, ok := getUserIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the UserId from the request context")
+		maparesponse["UserId"] = erro.ErrorGetUserId.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.BeginExternalLogin(ctx, mux.Vars(r)["provider"], userID)
+	if !response.Success {
+		log.Printf("Error starting account linking: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), oauthFailureStatus(response))
+		return
+	}
+	addOAuthStateCookie(w, response.OAuthState, response.ExpirationTime)
+	w.Header().Set("Cache-Control", "no-store")
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID, RedirectURL: response.RedirectURL})
+}
+
+// ExternalLoginCallback is the redirect URI registered with the provider:
+// GET /oauth/{provider}/callback?code=&state=. It sets the same session cookie as a password
+// login, or only confirms the link when the flow came from LinkExternalAccount.
+func (h *Handler) ExternalLoginCallback(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	query := r.URL.Query()
+	cookie, err := r.Cookie(oauthStateCookie)
+	deleteOAuthStateCookie(w)
+	if err != nil || query.Get("state") == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		log.Println("OAuth callback without the matching state cookie")
+		maparesponse["IdentityError"] = erro.ErrorInvalidOAuthState.Error()
+		badResponse(w, maparesponse, http.StatusBadRequest)
+		return
+	}
+	if providerError := query.Get("error"); providerError != "" {
+		log.Printf("Identity provider answered with %s: %s", providerError, query.Get("error_description"))
+		maparesponse["IdentityError"] = erro.ErrorIdentityProvider.Error()
+		badResponse(w, maparesponse, http.StatusUnauthorized)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	response := h.services.CompleteExternalLogin(ctx, mux.Vars(r)["provider"], query.Get("code"), query.Get("state"))
+	// A callback that only linked an account to the signed-in user is not a login.
+	if !response.Success || response.SessionId != "" || response.MFAChallenge != "" {
+		recordLogin("oauth", response)
+	}
+	if !response.Success {
+		log.Printf("Error during external login: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), oauthFailureStatus(response))
+		return
+	}
+	if response.MFAChallenge != "" {
+		mfaChallengeResponse(w, response)
+		return
+	}
+	if response.SessionId != "" {
+		log.Printf("Person with id: %v has successfully authenticated with %s", response.UserId, mux.Vars(r)["provider"])
+		addCookie(w, response.SessionId, response.ExpirationTime)
+	}
+	goodResponse(w, HTTPResponse{Success: true, UserID: response.UserId})
+}
+
+func addOAuthStateCookie(w http.ResponseWriter, state string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/oauth",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  expires,
+	})
+}
+
+func deleteOAuthStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    "",
+		Path:     "/oauth",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+}
+
+func oauthFailureStatus(response *service.ServiceResponse) int {
+	for _, err := range response.Errors {
+		switch {
+		case errors.Is(err, erro.ErrorUnknownIdentityProvider):
+			return http.StatusNotFound
+		case errors.Is(err, erro.ErrorInvalidOAuthState), errors.Is(err, erro.ErrorIdentityEmailMissing):
+			return http.StatusBadRequest
+		case errors.Is(err, erro.ErrorIdentityProvider):
+			return http.StatusUnauthorized
+		case errors.Is(err, erro.ErrorIdentityLinked), errors.Is(err, erro.ErrorIdentityEmailTaken):
+			return http.StatusConflict
+		}
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/service"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OIDCTokenResponse is the token endpoint response of RFC 6749 with the ID token of OpenID
+// Connect. It differs from TokenResponse because client libraries expect exactly this shape.
+type OIDCTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type UserInfoResponse struct {
+	Subject       uuid.UUID `json:"sub"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Name          string    `json:"name"`
+}
+
+// OpenIDConfiguration serves the discovery document: GET /.well-known/openid-configuration.
+func (h *Handler) OpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	response := h.services.OpenIDConfiguration()
+	if !response.Success {
+		badResponse(w, convertErrorToString(response), http.StatusNotFound)
+		return
+	}
+	jsonResponse, err := json.Marshal(response.OpenIDConfiguration)
+	if err != nil {
+		log.Printf("Marshal Error: %v", err)
+		badResponse(w, map[string]string{"Marshal": erro.ErrorMarshal.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", jsonResponseType)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, string(jsonResponse))
+}
+
+// Authorize is the authorization endpoint: GET /oauth2/authorize. A browser with a session is
+// sent straight back to the client with a code. Otherwise it is sent to the login page, which
+// posts the credentials to AuthorizeLogin with the same query. The session cookie is
+// SameSite=Strict, so it only arrives here from clients on the same site; others always pass
+// through the login page.
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
+	request := authorizationRequestFromQuery(r.URL.Query())
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	check := h.services.CheckAuthorizationRequest(ctx, request)
+	if !check.Success {
+		authorizationFailure(w, r, request, check)
+		return
+	}
+	if request.Prompt != "login" {
+		if sessionID, err := h.sessionIDFromRequest(r); err == nil {
+			if session := h.authorize(ctx, sessionID); session.Success {
+				h.redirectWithCode(ctx, w, r, request, session.UserId)
+				return
+			}
+		}
+	}
+	if request.Prompt == "none" {
+		redirectWithError(w, r, request, "login_required")
+		return
+	}
+	if check.RedirectURL == "" {
+		log.Println("Authorization request without a session and no login page configured")
+		badResponse(w, map[string]string{"SessionId": erro.ErrorInvalidSessionID.Error()}, http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, check.RedirectURL, http.StatusFound)
+}
+
+// AuthorizeLogin is the login step of the authorization endpoint: POST /oauth2/authorize with
+// the authorization request as query and the credentials as body. It signs in like /auth and
+// answers with the redirect back to the client. A user with TOTP gets the challenge instead,
+// finishes it at /auth/mfa and then opens the authorization endpoint again with the session.
+func (h *Handler) AuthorizeLogin(w http.ResponseWriter, r *http.Request) {
+	request := authorizationRequestFromQuery(r.URL.Query())
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	check := h.services.CheckAuthorizationRequest(ctx, request)
+	if !check.Success {
+		log.Printf("Invalid authorization request: %v", check.Errors)
+		badResponse(w, convertErrorToString(check), oidcFailureStatus(check))
+		return
+	}
+	var person model.Person
+	if !readJSONBody(w, r, &person) {
+		return
+	}
+	login := h.services.AuthenticateAndLogin(ctx, &person, clientIP(r))
+	recordLogin("password", login)
+	if !login.Success {
+		log.Printf("Error during user authentication: %v", login.Errors)
+		badResponse(w, convertErrorToString(login), loginFailureStatus(w, login))
+		return
+	}
+	if login.MFAChallenge != "" {
+		mfaChallengeResponse(w, login)
+		return
+	}
+	addCookie(w, login.SessionId, login.ExpirationTime)
+	authorized := h.services.Authorize(ctx, request, login.UserId)
+	if !authorized.Success {
+		log.Printf("Error during authorization: %v", authorized.Errors)
+		badResponse(w, convertErrorToString(authorized), oidcFailureStatus(authorized))
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	goodResponse(w, HTTPResponse{Success: true, UserID: login.UserId, RedirectURL: authorized.RedirectURL})
+}
+
+// ClientToken is the token endpoint: POST /oauth2/token with a form body. Clients authenticate
+// with HTTP Basic or client_id and client_secret in the form; public clients send only
+// client_id.
+func (h *Handler) ClientToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		log.Printf("ParseForm Error: %v", err)
+		oauthErrorResponse(w, "invalid_request", erro.ErrorUnmarshal.Error(), http.StatusBadRequest)
+		return
+	}
+	request := model.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		ClientID:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+	}
+	// The credentials of HTTP Basic are form encoded, RFC 6749 section 2.3.1.
+	if username, password, ok := r.BasicAuth(); ok {
+		clientID, idErr := url.QueryUnescape(username)
+		clientSecret, secretErr := url.QueryUnescape(password)
+		if idErr != nil || secretErr != nil {
+			oauthErrorResponse(w, "invalid_client", erro.ErrorInvalidClientCredentials.Error(), http.StatusUnauthorized)
+			return
+		}
+		request.ClientID, request.ClientSecret = clientID, clientSecret
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.ExchangeClientToken(ctx, request)
+	if !response.Success {
+		log.Printf("Error during client token exchange: %v", response.Errors)
+		tokenFailure(w, response)
+		return
+	}
+	log.Printf("Client %s has received tokens for %v", request.ClientID, response.UserId)
+	jsonResponse, err := json.Marshal(OIDCTokenResponse{
+		AccessToken:  response.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(response.ExpirationTime).Seconds()),
+		RefreshToken: response.RefreshToken,
+		IDToken:      response.IDToken,
+	})
+	if err != nil {
+		log.Printf("Marshal Error: %v", err)
+		oauthErrorResponse(w, "server_error", erro.ErrorMarshal.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", jsonResponseType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, string(jsonResponse))
+}
+
+// UserInfo is the userinfo endpoint: GET /oauth2/userinfo with the access token as bearer.
+func (h *Handler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	userID, ok := getUserIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the UserId from the request context")
+		badResponse(w, map[string]string{"UserId": erro.ErrorGetUserId.Error()}, http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.UserInfo(ctx, userID)
+	if !response.Success {
+		log.Printf("Error getting user info: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), oidcFailureStatus(response))
+		return
+	}
+	jsonResponse, err := json.Marshal(UserInfoResponse{
+		Subject:       response.Profile.Id,
+		Email:         response.Profile.Email,
+		EmailVerified: response.Profile.EmailVerified,
+		Name:          response.Profile.Name,
+	})
+	if err != nil {
+		log.Printf("Marshal Error: %v", err)
+		badResponse(w, map[string]string{"Marshal": erro.ErrorMarshal.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", jsonResponseType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, string(jsonResponse))
+}
+
+func (h *Handler) redirectWithCode(ctx context.Context, w http.ResponseWriter, r *http.Request, request model.AuthorizationRequest, userID uuid.UUID) {
+	authorized := h.services.Authorize(ctx, request, userID)
+	if !authorized.Success {
+		log.Printf("Error during authorization: %v", authorized.Errors)
+		authorizationFailure(w, r, request, authorized)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, authorized.RedirectURL, http.StatusFound)
+}
+
+func authorizationRequestFromQuery(query url.Values) model.AuthorizationRequest {
+	return model.AuthorizationRequest{
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		ResponseType:        query.Get("response_type"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+		Prompt:              query.Get("prompt"),
+	}
+}
+
+// authorizationFailure reports a failed authorization to the client through its redirect URI,
+// unless the client or the redirect URI itself is the problem: then the browser must stay here.
+func authorizationFailure(w http.ResponseWriter, r *http.Request, request model.AuthorizationRequest, response *service.ServiceResponse) {
+	for _, err := range response.Errors {
+		if errors.Is(err, erro.ErrorInvalidAuthRequest) {
+			redirectWithError(w, r, request, "invalid_request")
+			return
+		}
+	}
+	log.Printf("Authorization request rejected: %v", response.Errors)
+	badResponse(w, convertErrorToString(response), oidcFailureStatus(response))
+}
+
+func redirectWithError(w http.ResponseWriter, r *http.Request, request model.AuthorizationRequest, code string) {
+	redirect, err := url.Parse(request.RedirectURI)
+	if err != nil {
+		badResponse(w, map[string]string{"OIDCError": erro.ErrorInvalidRedirectURI.Error()}, http.StatusBadRequest)
+		return
+	}
+	query := redirect.Query()
+	query.Set("error", code)
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	redirect.RawQuery = query.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// tokenFailure answers the token endpoint with the error codes of RFC 6749 section 5.2.
+func tokenFailure(w http.ResponseWriter, response *service.ServiceResponse) {
+	for _, err := range response.Errors {
+		switch {
+		case errors.Is(err, erro.ErrorInvalidClientCredentials):
+			oauthErrorResponse(w, "invalid_client", err.Error(), http.StatusUnauthorized)
+			return
+		case errors.Is(err, erro.ErrorInvalidAuthCode), errors.Is(err, erro.ErrorInvalidRefreshToken), errors.Is(err, erro.ErrorRefreshTokenReused):
+			oauthErrorResponse(w, "invalid_grant", err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, erro.ErrorUnsupportedGrantType):
+			oauthErrorResponse(w, "unsupported_grant_type", err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, erro.ErrorOIDCProviderDisabled):
+			oauthErrorResponse(w, "invalid_request", err.Error(), http.StatusNotFound)
+			return
+		}
+	}
+	oauthErrorResponse(w, "server_error", erro.ErrorInternalServer.Error(), http.StatusInternalServerError)
+}
+
+func oauthErrorResponse(w http.ResponseWriter, code, description string, statusCode int) {
+	jsonResponse, err := json.Marshal(OAuthErrorResponse{Error: code, ErrorDescription: description})
+	if err != nil {
+		log.Printf("Marshal Error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", jsonResponseType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	fmt.Fprint(w, string(jsonResponse))
+}
+
+func oidcFailureStatus(response *service.ServiceResponse) int {
+	for _, err := range response.Errors {
+		switch {
+		case errors.Is(err, erro.ErrorOIDCProviderDisabled):
+			return http.StatusNotFound
+		case errors.Is(err, erro.ErrorUnknownOAuthClient), errors.Is(err, erro.ErrorInvalidRedirectURI), errors.Is(err, erro.ErrorInvalidAuthRequest):
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/service"
+	"auth_service/internal/webauthn"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+type PasskeyRegistrationRequest struct {
+	Name       string                        `json:"name"`
+	Credential webauthn.RegistrationResponse `json:"credential"`
+}
+
+// PasskeyResponse carries the options the client passes to navigator.credentials as the
+// publicKey member.
+type PasskeyResponse struct {
+	PublicKey interface{} `json:"publicKey"`
+}
+
+func (h *Handler) BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, ok := getUserIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the UserId from the request context")
+		maparesponse["UserId"] = erro.ErrorGetUserId.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.BeginPasskeyRegistration(ctx, userID)
+	if !response.Success {
+		log.Printf("Error starting passkey registration: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), passkeyFailureStatus(response, http.StatusBadRequest))
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID, Passkey: &PasskeyResponse{PublicKey: response.PasskeyCreation}})
+}
+
+func (h *Handler) FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, ok := getUserIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the UserId from the request context")
+		maparesponse["UserId"] = erro.ErrorGetUserId.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return
+	}
+	var request PasskeyRegistrationRequest
+	if !readJSONBody(w, r, &request) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.FinishPasskeyRegistration(ctx, userID, request.Name, request.Credential)
+	if !response.Success {
+		log.Printf("Error during passkey registration: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), passkeyFailureStatus(response, http.StatusBadRequest))
+		return
+	}
+	log.Printf("Person with id: %v has registered a passkey", userID)
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID})
+}
+
+func (h *Handler) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.BeginPasskeyLogin(ctx)
+	if !response.Success {
+		log.Printf("Error starting passkey login: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	goodResponse(w, HTTPResponse{Success: true, Passkey: &PasskeyResponse{PublicKey: response.PasskeyRequest}})
+}
+
+// FinishPasskeyLogin sets the same session cookie as a password login.
+func (h *Handler) FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	var request webauthn.AuthenticationResponse
+	if !readJSONBody(w, r, &request) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.FinishPasskeyLogin(ctx, request)
+	recordLogin("passkey", response)
+	if !response.Success {
+		log.Printf("Error during passkey login: %v", response.Errors)
+		badResponse(w, convertErrorToString(response), passkeyFailureStatus(response, http.StatusUnauthorized))
+		return
+	}
+	log.Printf("Person with id: %v has successfully authenticated with a passkey", response.UserId)
+	addCookie(w, response.SessionId, response.ExpirationTime)
+	goodResponse(w, HTTPResponse{Success: true, UserID: response.UserId})
+}
+
+// passkeyFailureStatus answers a response that did not verify with rejected, which is 401 for
+// logins and 400 for registrations of an already authenticated user.
+func passkeyFailureStatus(response *service.ServiceResponse, rejected int) int {
+	for _, err := range response.Errors {
+		switch {
+		case errors.Is(err, erro.ErrorInvalidPasskey), errors.Is(err, erro.ErrorInvalidPasskeyChallenge):
+			return rejected
+		case errors.Is(err, erro.ErrorPasskeyExists):
+			return http.StatusConflict
+		}
+	}
+	return http.StatusInternalServerError
+}
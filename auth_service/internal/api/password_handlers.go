@@ -0,0 +1,79 @@
+package api
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ForgotPassword answers 202 for every well-formed email, registered or not.
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var request ForgotPasswordRequest
+	if !readJSONBody(w, r, &request) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.ForgotPassword(ctx, request.Email)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		statusCode := http.StatusInternalServerError
+		if response.Errors["Email"] != nil {
+			statusCode = http.StatusBadRequest
+		}
+		badResponse(w, stringMap, statusCode)
+		return
+	}
+	writeJSON(w, HTTPResponse{Success: true}, http.StatusAccepted)
+}
+
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var request ResetPasswordRequest
+	if !readJSONBody(w, r, &request) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.ResetPassword(ctx, request.Token, request.Password)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		log.Printf("Error during password reset: %v", response.Errors)
+		statusCode := http.StatusInternalServerError
+		if response.Errors["ResetError"] == erro.ErrorInvalidResetToken || response.Errors["Password"] != nil {
+			statusCode = http.StatusBadRequest
+		}
+		badResponse(w, stringMap, statusCode)
+		return
+	}
+	log.Printf("Person with id: %v has reset the password", response.UserId)
+	deleteCookie(w)
+	goodResponse(w, HTTPResponse{Success: true, UserID: response.UserId})
+}
+
+// readJSONBody decodes the request body into dst and answers the request itself when it cannot.
+func readJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("ReadAll Error: %v", err)
+		badResponse(w, map[string]string{"ReadAll": erro.ErrorReadAll.Error()}, http.StatusInternalServerError)
+		return false
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		log.Printf("Unmarshal Error: %v", err)
+		badResponse(w, map[string]string{"Unmarshal": erro.ErrorUnmarshal.Error()}, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
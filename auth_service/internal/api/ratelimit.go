@@ -0,0 +1,46 @@
+package api
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitMiddleware applies the limit configured for the matched route to the client IP.
+// The answer carries the RateLimit-* headers of the IETF draft; rejected requests get 429 with
+// Retry-After. When Redis cannot be asked the request is let through rather than failing the API.
+func (handler *Handler) RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+		defer cancel()
+		decision, err := handler.services.AllowRequest(ctx, route, clientIP(r))
+		if err != nil {
+			log.Printf("Rate limit check failed for %s, letting the request through: %v", route, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if decision.Limit > 0 {
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", decision.Policy.Limit, ceilSeconds(decision.Policy.Period)))
+		}
+		if !decision.Allowed {
+			log.Printf("Rate limit exceeded on %s by %s", route, clientIP(r))
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+			badResponse(w, map[string]string{"RateLimit": erro.ErrorRateLimitExceeded.Error()}, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
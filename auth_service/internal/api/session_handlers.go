@@ -0,0 +1,139 @@
+package api
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type SessionResponse struct {
+	SessionID      string    `json:"session_id"`
+	CreatedAt      time.Time `json:"created_at,omitempty"`
+	ExpirationTime time.Time `json:"expires_at"`
+	Kind           string    `json:"kind"`
+	Current        bool      `json:"current"`
+}
+
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, currentSessionID, ok := sessionFromRequestContext(w, r, maparesponse)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.ListSessions(ctx, userID)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		badResponse(w, stringMap, http.StatusInternalServerError)
+		return
+	}
+	sessions := make([]SessionResponse, 0, len(response.Sessions))
+	for _, session := range response.Sessions {
+		kind := "cookie"
+		if session.FamilyID != "" {
+			kind = "token"
+		}
+		sessions = append(sessions, SessionResponse{
+			SessionID:      session.SessionID,
+			CreatedAt:      session.CreatedAt,
+			ExpirationTime: session.ExpirationTime,
+			Kind:           kind,
+			Current:        session.SessionID == currentSessionID,
+		})
+	}
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID, Sessions: sessions})
+}
+
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, currentSessionID, ok := sessionFromRequestContext(w, r, maparesponse)
+	if !ok {
+		return
+	}
+	sessionID := mux.Vars(r)["id"]
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.RevokeSession(ctx, userID, sessionID)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		statusCode := http.StatusInternalServerError
+		if response.Errors["SessionId"] == erro.ErrorInvalidSessionID {
+			statusCode = http.StatusNotFound
+		}
+		badResponse(w, stringMap, statusCode)
+		return
+	}
+	log.Printf("Person with id: %v has revoked session %s", userID, sessionID)
+	if sessionID == currentSessionID {
+		deleteCookie(w)
+	}
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID})
+}
+
+// RevokeAllSessions logs the user out everywhere. With ?keep_current=true the calling session survives.
+func (h *Handler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, currentSessionID, ok := sessionFromRequestContext(w, r, maparesponse)
+	if !ok {
+		return
+	}
+	keepSessionID := ""
+	if r.URL.Query().Get("keep_current") == "true" {
+		keepSessionID = currentSessionID
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.RevokeAllSessions(ctx, userID, keepSessionID)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		badResponse(w, stringMap, http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Person with id: %v has logged out everywhere", userID)
+	if keepSessionID == "" {
+		deleteCookie(w)
+	}
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID})
+}
+
+func sessionFromRequestContext(w http.ResponseWriter, r *http.Request, maparesponse map[string]string) (uuid.UUID, string, bool) {
+	userID, ok := getUserIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the UserId from the request context")
+		maparesponse["UserId"] = erro.ErrorGetUserId.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return uuid.Nil, "", false
+	}
+	sessionID, ok := getSessionIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the SessionId from the request context")
+		maparesponse["SessionId"] = erro.ErrorInvalidSessionID.Error()
+		badResponse(w, maparesponse, http.StatusUnauthorized)
+		return uuid.Nil, "", false
+	}
+	return userID, sessionID, true
+}
+
+func goodResponse(w http.ResponseWriter, response HTTPResponse) {
+	writeJSON(w, response, http.StatusOK)
+}
+
+func writeJSON(w http.ResponseWriter, response HTTPResponse, statusCode int) {
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Marshal Error: %v", err)
+		badResponse(w, map[string]string{"Marshal": erro.ErrorMarshal.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", jsonResponseType)
+	w.WriteHeader(statusCode)
+	fmt.Fprint(w, string(jsonResponse))
+}
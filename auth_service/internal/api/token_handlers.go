@@ -0,0 +1,140 @@
+package api
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/service"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+type TokenResponse struct {
+	AccessToken           string    `json:"access_token"`
+	AccessExpirationTime  time.Time `json:"access_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshExpirationTime time.Time `json:"refresh_expires_at"`
+	TokenType             string    `json:"token_type"`
+}
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (h *Handler) IssueToken(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	if r.Method != http.MethodPost {
+		log.Printf("Invalid request method(expected Post but it was sent %v)", r.Method)
+		maparesponse["Method"] = erro.ErrorNotPost.Error()
+		badResponse(w, maparesponse, http.StatusMethodNotAllowed)
+		return
+	}
+	datafromperson, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("ReadAll Error: %v", err)
+		maparesponse["ReadAll"] = erro.ErrorReadAll.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return
+	}
+	var newperk model.Person
+	err = json.Unmarshal(datafromperson, &newperk)
+	if err != nil {
+		log.Printf("Unmarshal Error: %v", err)
+		maparesponse["Unmarshal"] = erro.ErrorUnmarshal.Error()
+		badResponse(w, maparesponse, http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.IssueTokens(ctx, &newperk, clientIP(r))
+	recordLogin("token", response)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		log.Printf("Error during token issuing: %v", response.Errors)
+		badResponse(w, stringMap, loginFailureStatus(w, response))
+		return
+	}
+	if response.MFAChallenge != "" {
+		mfaChallengeResponse(w, response)
+		return
+	}
+	log.Printf("Person with id: %v has successfully received tokens", response.UserId)
+	tokenResponse(w, response)
+}
+
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	if r.Method != http.MethodPost {
+		log.Printf("Invalid request method(expected Post but it was sent %v)", r.Method)
+		maparesponse["Method"] = erro.ErrorNotPost.Error()
+		badResponse(w, maparesponse, http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("ReadAll Error: %v", err)
+		maparesponse["ReadAll"] = erro.ErrorReadAll.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return
+	}
+	var request RefreshRequest
+	err = json.Unmarshal(data, &request)
+	if err != nil {
+		log.Printf("Unmarshal Error: %v", err)
+		maparesponse["Unmarshal"] = erro.ErrorUnmarshal.Error()
+		badResponse(w, maparesponse, http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.RefreshTokens(ctx, request.RefreshToken)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		log.Printf("Error during token refresh: %v", response.Errors)
+		badResponse(w, stringMap, http.StatusUnauthorized)
+		return
+	}
+	log.Printf("Person with id: %v has successfully refreshed tokens", response.UserId)
+	tokenResponse(w, response)
+}
+
+func tokenResponse(w http.ResponseWriter, response *service.ServiceResponse) {
+	sucresponse := HTTPResponse{
+		Success: true,
+		UserID:  response.UserId,
+		Tokens: &TokenResponse{
+			AccessToken:           response.AccessToken,
+			AccessExpirationTime:  response.ExpirationTime,
+			RefreshToken:          response.RefreshToken,
+			RefreshExpirationTime: response.RefreshExpirationTime,
+			TokenType:             "Bearer",
+		},
+	}
+	jsonResponse, err := json.Marshal(sucresponse)
+	if err != nil {
+		log.Printf("Marshal Error: %v", err)
+		badResponse(w, map[string]string{"Marshal": erro.ErrorMarshal.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", jsonResponseType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, string(jsonResponse))
+}
+
+// JWKS publishes the access token verification keys for other services.
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	jsonResponse, err := json.Marshal(h.services.JWKS())
+	if err != nil {
+		log.Printf("Marshal Error: %v", err)
+		badResponse(w, map[string]string{"Marshal": erro.ErrorMarshal.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", jsonResponseType)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, string(jsonResponse))
+}
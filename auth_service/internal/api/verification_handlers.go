@@ -0,0 +1,53 @@
+package api
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// VerifyEmail confirms the email from the link of the verification event: GET /verify?token=.
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.VerifyEmail(ctx, r.URL.Query().Get("token"))
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		log.Printf("Error during email verification: %v", response.Errors)
+		statusCode := http.StatusInternalServerError
+		if response.Errors["VerificationError"] == erro.ErrorInvalidVerificationToken {
+			statusCode = http.StatusBadRequest
+		}
+		badResponse(w, stringMap, statusCode)
+		return
+	}
+	log.Printf("Person with id: %v has verified the email", response.UserId)
+	goodResponse(w, HTTPResponse{Success: true, UserID: response.UserId})
+}
+
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	userID, ok := getUserIDFromRequestContext(r)
+	if !ok {
+		log.Println("Error getting the UserId from the request context")
+		maparesponse["UserId"] = erro.ErrorGetUserId.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.ResendVerification(ctx, userID)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		statusCode := http.StatusInternalServerError
+		if response.Errors["VerificationError"] == erro.ErrorEmailAlreadyVerified {
+			statusCode = http.StatusConflict
+		}
+		badResponse(w, stringMap, statusCode)
+		return
+	}
+	log.Printf("Person with id: %v has requested a new verification email", userID)
+	goodResponse(w, HTTPResponse{Success: true, UserID: userID})
+}
@@ -30,4 +30,51 @@ var (
 	ErrorGetUserId                = errors.New("Error getting the UserId from the request context")
 	ErrorContextTimeout           = errors.New("The timeout context has expired")
 	ErrorSendKafkaMessage         = errors.New("Error Kafka Message")
+	ErrorAddOutboxEvent           = errors.New("Error add event to outbox")
+	ErrorGetOutboxEvents          = errors.New("Error get events from outbox")
+	ErrorUpdateOutboxEvent        = errors.New("Error update outbox event")
+	ErrorDeleteOutboxEvents       = errors.New("Error delete events from outbox")
+	ErrorSetRefreshToken          = errors.New("Error set refresh token")
+	ErrorGetRefreshToken          = errors.New("Error get refresh token")
+	ErrorInvalidRefreshToken      = errors.New("Refresh token is invalid or expired")
+	ErrorRefreshTokenReused       = errors.New("Refresh token has already been used")
+	ErrorRevokeTokenFamily        = errors.New("Error revoke token family")
+	ErrorGenerateToken            = errors.New("Error generate token")
+	ErrorGetUserSessions          = errors.New("Error get user sessions")
+	ErrorRevokeSessions           = errors.New("Error revoke sessions")
+	ErrorInvalidCredentials       = errors.New("Invalid email or password")
+	ErrorTooManyAttempts          = errors.New("Too many failed login attempts, try again later")
+	ErrorLoginAttempts            = errors.New("Error count login attempts")
+	ErrorRateLimit                = errors.New("Error check rate limit")
+	ErrorRateLimitExceeded        = errors.New("Too many requests, try again later")
+	ErrorSetOneTimeToken          = errors.New("Error set one-time token")
+	ErrorInvalidOneTimeToken      = errors.New("Token is invalid or expired")
+	ErrorInvalidVerificationToken = errors.New("Verification token is invalid or expired")
+	ErrorEmailAlreadyVerified     = errors.New("Email is already verified")
+	ErrorEmailNotVerified         = errors.New("Email is not verified")
+	ErrorInvalidResetToken        = errors.New("Password reset token is invalid or expired")
+	ErrorSamePassword             = errors.New("New password must differ from the current one")
+	ErrorNothingToUpdate          = errors.New("Nothing to update")
+	ErrorMFANotEnrolled           = errors.New("Two-factor authentication is not set up")
+	ErrorMFAAlreadyEnabled        = errors.New("Two-factor authentication is already enabled")
+	ErrorInvalidMFACode           = errors.New("Invalid two-factor authentication code")
+	ErrorInvalidMFAChallenge      = errors.New("Two-factor challenge is invalid or expired")
+	ErrorPasskeyExists            = errors.New("This passkey is already registered")
+	ErrorPasskeyNotFound          = errors.New("Passkey not found")
+	ErrorInvalidPasskey           = errors.New("Passkey verification failed")
+	ErrorInvalidPasskeyChallenge  = errors.New("Passkey challenge is invalid or expired")
+	ErrorUnknownIdentityProvider  = errors.New("Unknown identity provider")
+	ErrorInvalidOAuthState        = errors.New("Sign-in state is invalid or expired")
+	ErrorIdentityProvider         = errors.New("Sign-in with the identity provider failed")
+	ErrorIdentityNotFound         = errors.New("External account is not linked")
+	ErrorIdentityLinked           = errors.New("This external account is already linked to a user")
+	ErrorIdentityEmailMissing     = errors.New("Identity provider did not share an email")
+	ErrorIdentityEmailTaken       = errors.New("An account with this email already exists, sign in and link the provider to it")
+	ErrorOIDCProviderDisabled     = errors.New("OpenID provider is not enabled")
+	ErrorUnknownOAuthClient       = errors.New("Unknown client")
+	ErrorInvalidRedirectURI       = errors.New("Redirect URI is not registered for this client")
+	ErrorInvalidAuthRequest       = errors.New("Authorization request is invalid")
+	ErrorInvalidClientCredentials = errors.New("Client authentication failed")
+	ErrorInvalidAuthCode          = errors.New("Authorization code is invalid or expired")
+	ErrorUnsupportedGrantType     = errors.New("Unsupported grant type")
 )
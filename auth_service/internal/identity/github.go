@@ -0,0 +1,94 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	githubAuthorizeURL = "https://github.com/login/oauth/authorize"
+	githubTokenURL     = "https://github.com/login/oauth/access_token"
+	githubAPIURL       = "https://api.github.com"
+)
+
+var defaultGitHubScopes = []string{"read:user", "user:email"}
+
+// GitHub signs in with a GitHub OAuth app. GitHub has no ID token, so the identity is read from
+// the REST API with the access token; the subject is the numeric account id, which survives
+// renames. Issuer, when set, replaces https://github.com for GitHub Enterprise; its API is then
+// expected under <issuer>/api/v3.
+type GitHub struct {
+	cfg          Config
+	client       *http.Client
+	authorizeURL string
+	tokenURL     string
+	apiURL       string
+}
+
+func NewGitHub(cfg Config, client *http.Client) *GitHub {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = defaultGitHubScopes
+	}
+	provider := &GitHub{cfg: cfg, client: client, authorizeURL: githubAuthorizeURL, tokenURL: githubTokenURL, apiURL: githubAPIURL}
+	if issuer := strings.TrimSuffix(cfg.Issuer, "/"); issuer != "" {
+		provider.authorizeURL = issuer + "/login/oauth/authorize"
+		provider.tokenURL = issuer + "/login/oauth/access_token"
+		provider.apiURL = issuer + "/api/v3"
+	}
+	return provider
+}
+
+func (p *GitHub) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL ignores nonce: without an ID token there is nothing to bind it to, and the state
+// already ties the callback to the browser that started the login.
+func (p *GitHub) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	return authCodeURL(p.authorizeURL, p.cfg, state, codeChallenge, nil), nil
+}
+
+type githubUser struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func (p *GitHub) Exchange(ctx context.Context, code, codeVerifier, nonce string) (Identity, error) {
+	token, err := exchangeCode(ctx, p.client, p.tokenURL, p.cfg, code, codeVerifier)
+	if err != nil {
+		return Identity{}, err
+	}
+	var user githubUser
+	if err := getJSON(ctx, p.client, p.apiURL+"/user", token.AccessToken, &user); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrProfile, err)
+	}
+	if user.ID == 0 {
+		return Identity{}, fmt.Errorf("%w: missing account id", ErrProfile)
+	}
+	// The profile email is whatever the user chose to make public; the primary address of
+	// /user/emails is the one GitHub vouches for.
+	var emails []githubEmail
+	if err := getJSON(ctx, p.client, p.apiURL+"/user/emails", token.AccessToken, &emails); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrProfile, err)
+	}
+	identity := Identity{Provider: p.cfg.Name, Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email, identity.EmailVerified = email.Email, email.Verified
+		}
+	}
+	return identity, nil
+}
@@ -0,0 +1,186 @@
+// Package identity signs users in through external identity providers with the OAuth 2.0
+// authorization code flow and PKCE. OIDC covers any OpenID Connect provider (Google among them)
+// and GitHub the plain OAuth 2.0 API of GitHub; both satisfy Provider.
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider type")
+	ErrExchange        = errors.New("authorization code exchange failed")
+	ErrIDToken         = errors.New("invalid id token")
+	ErrProfile         = errors.New("fetching the user profile failed")
+)
+
+// Identity is the account a user holds at a provider. Subject is stable and unique per
+// provider; the email may change and is only trustworthy when EmailVerified is set.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider is one configured identity provider.
+type Provider interface {
+	Name() string
+	// AuthCodeURL is where the browser is sent to sign in. state and nonce come back in the
+	// callback and the ID token; codeChallenge is the S256 PKCE challenge.
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems the code from the callback and returns the signed in identity.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (Identity, error)
+}
+
+// Config describes a provider. RedirectURL must be registered with the provider exactly.
+type Config struct {
+	Name         string
+	Type         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// New builds the provider of cfg.Type. client may be nil.
+func New(cfg Config, client *http.Client) (Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	switch cfg.Type {
+	case TypeOIDC:
+		if cfg.Issuer == "" {
+			return nil, fmt.Errorf("identity provider %s: issuer is required", cfg.Name)
+		}
+		return NewOIDC(cfg, client), nil
+	case TypeGitHub:
+		return NewGitHub(cfg, client), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, cfg.Type)
+}
+
+// NewRandom returns 32 random bytes in base64url, suitable for state, nonce and PKCE verifiers.
+func NewRandom() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CodeChallenge derives the S256 PKCE challenge of verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authCodeURL(endpoint string, cfg Config, state, codeChallenge string, extra url.Values) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {cfg.RedirectURL},
+		"scope":                 {strings.Join(cfg.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	for key, values := range extra {
+		query[key] = values
+	}
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	return endpoint + separator + query.Encode()
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+}
+
+// exchangeCode posts the code to the token endpoint. GitHub answers errors with status 200 and
+// an "error" member, so both are checked.
+func exchangeCode(ctx context.Context, client *http.Client, endpoint string, cfg Config, code, codeVerifier string) (tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"client_id":     {cfg.ClientID},
+		"client_secret": {cfg.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var token tokenResponse
+	status, err := doJSON(client, req, &token)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("%w: %v", ErrExchange, err)
+	}
+	if status != http.StatusOK || token.Error != "" || token.AccessToken == "" {
+		return tokenResponse{}, fmt.Errorf("%w: status %d %s", ErrExchange, status, token.Error)
+	}
+	return token, nil
+}
+
+// getJSON fetches endpoint, authenticated with accessToken when it is not empty.
+func getJSON(ctx context.Context, client *http.Client, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	status, err := doJSON(client, req, out)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", endpoint, status)
+	}
+	return nil
+}
+
+func doJSON(client *http.Client, req *http.Request, out interface{}) (int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil && resp.StatusCode == http.StatusOK {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
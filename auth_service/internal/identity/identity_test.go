@@ -0,0 +1,118 @@
+package identity_test
+
+import (
+	"auth_service/internal/identity"
+	"auth_service/internal/identity/identitytest"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "http://localhost:8081/oauth/stub/callback"
+
+type flow struct {
+	state    string
+	nonce    string
+	verifier string
+	callback url.Values
+}
+
+func authorize(t *testing.T, stub *identitytest.Provider, provider identity.Provider) flow {
+	var f flow
+	var err error
+	f.state, err = identity.NewRandom()
+	require.NoError(t, err)
+	f.nonce, err = identity.NewRandom()
+	require.NoError(t, err)
+	f.verifier, err = identity.NewRandom()
+	require.NoError(t, err)
+	authURL, err := provider.AuthCodeURL(context.Background(), f.state, f.nonce, identity.CodeChallenge(f.verifier))
+	require.NoError(t, err)
+	f.callback, err = stub.Authorize(authURL)
+	require.NoError(t, err)
+	return f
+}
+
+func TestOIDC_Exchange(t *testing.T) {
+	stub := identitytest.NewProvider("client-1", "secret-1")
+	defer stub.Close()
+	stub.SignIn(identitytest.User{Subject: "external-1", Email: "tester@example.com", EmailVerified: true, Name: "Tester"})
+	provider, err := identity.New(stub.Config("stub", redirectURL), nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	f := authorize(t, stub, provider)
+	assert.Equal(t, f.state, f.callback.Get("state"), "State должен вернуться в callback")
+	signedIn, err := provider.Exchange(ctx, f.callback.Get("code"), f.verifier, f.nonce)
+	require.NoError(t, err)
+	assert.Equal(t, identity.Identity{Provider: "stub", Subject: "external-1", Email: "tester@example.com", EmailVerified: true, Name: "Tester"}, signedIn)
+
+	_, err = provider.Exchange(ctx, f.callback.Get("code"), f.verifier, f.nonce)
+	assert.ErrorIs(t, err, identity.ErrExchange, "Код используется один раз")
+
+	f = authorize(t, stub, provider)
+	_, err = provider.Exchange(ctx, f.callback.Get("code"), "wrong-verifier", f.nonce)
+	assert.ErrorIs(t, err, identity.ErrExchange, "Код без верного PKCE verifier не должен приниматься")
+
+	f = authorize(t, stub, provider)
+	_, err = provider.Exchange(ctx, f.callback.Get("code"), f.verifier, "other-nonce")
+	assert.ErrorIs(t, err, identity.ErrIDToken, "ID token с чужим nonce не должен приниматься")
+
+	other, err := identity.New(identity.Config{Name: "stub", Type: identity.TypeOIDC, Issuer: stub.URL, ClientID: "client-2", ClientSecret: "secret-1", RedirectURL: redirectURL}, nil)
+	require.NoError(t, err)
+	f = authorize(t, stub, provider)
+	_, err = other.Exchange(ctx, f.callback.Get("code"), f.verifier, f.nonce)
+	assert.ErrorIs(t, err, identity.ErrExchange, "Код другого клиента не должен приниматься")
+}
+
+func TestGitHub_Exchange(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("code") != "gh-code" || r.PostForm.Get("code_verifier") == "" {
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "gh-token", "token_type": "bearer"})
+	})
+	mux.HandleFunc("/api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "login": "octocat", "email": "public@example.com"})
+	})
+	mux.HandleFunc("/api/v3/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"email": "public@example.com", "primary": false, "verified": true},
+			{"email": "octocat@example.com", "primary": true, "verified": true},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider, err := identity.New(identity.Config{Name: "github", Type: identity.TypeGitHub, Issuer: server.URL, ClientID: "gh-client", ClientSecret: "gh-secret", RedirectURL: redirectURL}, server.Client())
+	require.NoError(t, err)
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", identity.CodeChallenge("verifier"))
+	require.NoError(t, err)
+	assert.Contains(t, authURL, server.URL+"/login/oauth/authorize?", "URL авторизации должен вести на GitHub")
+	assert.Contains(t, authURL, "code_challenge_method=S256", "Должен использоваться PKCE")
+
+	signedIn, err := provider.Exchange(context.Background(), "gh-code", "verifier", "")
+	require.NoError(t, err)
+	assert.Equal(t, identity.Identity{Provider: "github", Subject: "42", Email: "octocat@example.com", EmailVerified: true, Name: "octocat"}, signedIn, "Должен браться основной email")
+
+	_, err = provider.Exchange(context.Background(), "wrong-code", "verifier", "")
+	assert.ErrorIs(t, err, identity.ErrExchange, "Ошибка в ответе со статусом 200 должна распознаваться")
+}
+
+func TestNew_UnknownType(t *testing.T) {
+	_, err := identity.New(identity.Config{Name: "saml", Type: "saml"}, nil)
+	assert.ErrorIs(t, err, identity.ErrUnknownProvider)
+
+	_, err = identity.New(identity.Config{Name: "google", Type: identity.TypeOIDC}, nil)
+	assert.Error(t, err, "OIDC без issuer не должен создаваться")
+}
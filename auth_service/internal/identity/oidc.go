@@ -0,0 +1,189 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"shared/jwtverify"
+	"strings"
+	"sync"
+	"time"
+)
+
+var defaultOIDCScopes = []string{"openid", "email", "profile"}
+
+// OIDC is an OpenID Connect provider. The endpoints are read from the discovery document of
+// the issuer on first use, and the ID token is checked against the keys it publishes.
+type OIDC struct {
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	verifier  *jwtverify.Verifier
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func NewOIDC(cfg Config, client *http.Client) *OIDC {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = defaultOIDCScopes
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &OIDC{cfg: cfg, client: client, now: time.Now}
+}
+
+func (p *OIDC) Name() string {
+	return p.cfg.Name
+}
+
+func (p *OIDC) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return authCodeURL(discovery.AuthorizationEndpoint, p.cfg, state, codeChallenge, url.Values{"nonce": {nonce}}), nil
+}
+
+func (p *OIDC) Exchange(ctx context.Context, code, codeVerifier, nonce string) (Identity, error) {
+	discovery, verifier, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+	token, err := exchangeCode(ctx, p.client, discovery.TokenEndpoint, p.cfg, code, codeVerifier)
+	if err != nil {
+		return Identity{}, err
+	}
+	if token.IDToken == "" {
+		return Identity{}, fmt.Errorf("%w: missing from the token response", ErrIDToken)
+	}
+	payload, err := verifier.VerifySignature(ctx, token.IDToken)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrIDToken, err)
+	}
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrIDToken, err)
+	}
+	if err := p.validate(claims, discovery.Issuer, nonce); err != nil {
+		return Identity{}, err
+	}
+	return Identity{
+		Provider:      p.cfg.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// validate applies the ID token checks of OpenID Connect Core 3.1.3.7.
+func (p *OIDC) validate(claims idTokenClaims, issuer, nonce string) error {
+	now := p.now()
+	switch {
+	case claims.Issuer != issuer:
+		return fmt.Errorf("%w: unexpected issuer %q", ErrIDToken, claims.Issuer)
+	case !claims.Audience.contains(p.cfg.ClientID):
+		return fmt.Errorf("%w: issued for another client", ErrIDToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID:
+		return fmt.Errorf("%w: unexpected authorized party %q", ErrIDToken, claims.AuthorizedParty)
+	case claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(jwtverify.DefaultLeeway)):
+		return fmt.Errorf("%w: expired", ErrIDToken)
+	case time.Unix(claims.IssuedAt, 0).After(now.Add(jwtverify.DefaultLeeway)):
+		return fmt.Errorf("%w: issued in the future", ErrIDToken)
+	case nonce == "" || claims.Nonce != nonce:
+		return fmt.Errorf("%w: nonce mismatch", ErrIDToken)
+	case claims.Subject == "":
+		return fmt.Errorf("%w: missing subject", ErrIDToken)
+	}
+	return nil
+}
+
+// discover fetches the discovery document once; a failed fetch is retried on the next call.
+func (p *OIDC) discover(ctx context.Context) (*discoveryDocument, *jwtverify.Verifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, p.verifier, nil
+	}
+	var discovery discoveryDocument
+	if err := getJSON(ctx, p.client, p.cfg.Issuer+"/.well-known/openid-configuration", "", &discovery); err != nil {
+		return nil, nil, fmt.Errorf("discover %s: %w", p.cfg.Issuer, err)
+	}
+	if discovery.Issuer != p.cfg.Issuer {
+		return nil, nil, fmt.Errorf("discover %s: document is for issuer %q", p.cfg.Issuer, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, nil, fmt.Errorf("discover %s: incomplete document", p.cfg.Issuer)
+	}
+	p.discovery = &discovery
+	p.verifier = jwtverify.NewVerifier(jwtverify.NewRemoteKeySet(discovery.JWKSURI, p.client), "")
+	return p.discovery, p.verifier, nil
+}
+
+type idTokenClaims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        audience `json:"aud"`
+	AuthorizedParty string   `json:"azp"`
+	ExpiresAt       int64    `json:"exp"`
+	IssuedAt        int64    `json:"iat"`
+	Nonce           string   `json:"nonce"`
+	Email           string   `json:"email"`
+	EmailVerified   flexBool `json:"email_verified"`
+	Name            string   `json:"name"`
+}
+
+// audience is a single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flexBool accepts true and "true": some providers send email_verified as a string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		*b = flexBool(v == "true")
+	default:
+		*b = false
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package jwtkeys
+
+import (
+	"auth_service/configs"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"shared/jwtverify"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rsaKeyBits                 = 2048
+	defaultKeyRotationInterval = 24 * time.Hour
+)
+
+var ErrNoSigningKey = errors.New("no signing key available")
+
+type signingKey struct {
+	kid      string
+	signer   crypto.Signer
+	jwk      jwtverify.JWK
+	retireAt time.Time
+}
+
+// Manager owns the access token signing keys. The newest key signs; older keys stay published
+// in the JWKS until every token they signed has expired, so rotation never invalidates a live token.
+type Manager struct {
+	mu        sync.RWMutex
+	keys      []signingKey
+	algorithm string
+	dir       string
+	interval  time.Duration
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewManager(cfg configs.TokenConfig) (*Manager, error) {
+	algorithm := cfg.Algorithm
+	if algorithm == "" {
+		algorithm = jwtverify.AlgEdDSA
+	}
+	if algorithm != jwtverify.AlgEdDSA && algorithm != jwtverify.AlgRS256 {
+		return nil, fmt.Errorf("%w: %s", jwtverify.ErrUnsupportedAlgorithm, algorithm)
+	}
+	interval := cfg.KeyRotationInterval
+	if interval <= 0 {
+		interval = defaultKeyRotationInterval
+	}
+	m := &Manager{
+		algorithm: algorithm,
+		dir:       cfg.KeysDir,
+		interval:  interval,
+		retention: cfg.AccessTokenTTL + jwtverify.DefaultLeeway,
+		now:       time.Now,
+	}
+	if m.dir != "" {
+		return m, m.Reload()
+	}
+	log.Printf("No keys_dir configured, generating in-memory %s signing keys", algorithm)
+	return m, m.Rotate()
+}
+
+// Sign issues a token with the current signing key.
+func (m *Manager) Sign(claims jwtverify.Claims) (string, error) {
+	return m.SignPayload(claims)
+}
+
+// SignPayload signs any claim set, such as an ID token, with the current signing key, so relying
+// parties verify it against the same JWKS.
+func (m *Manager) SignPayload(payload interface{}) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.keys) == 0 {
+		return "", ErrNoSigningKey
+	}
+	current := m.keys[len(m.keys)-1]
+	return jwtverify.SignPayload(payload, current.kid, current.signer)
+}
+
+// Key implements jwtverify.KeySource so the service can verify its own tokens.
+func (m *Manager) Key(ctx context.Context, kid string) (jwtverify.JWK, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := m.now()
+	for _, key := range m.keys {
+		if key.kid == kid && (key.retireAt.IsZero() || now.Before(key.retireAt)) {
+			return key.jwk, nil
+		}
+	}
+	return jwtverify.JWK{}, fmt.Errorf("%w: %s", jwtverify.ErrUnknownKey, kid)
+}
+
+// JWKS returns every key that may still have live tokens, current key first.
+func (m *Manager) JWKS() jwtverify.JWKS {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := m.now()
+	set := jwtverify.JWKS{Keys: make([]jwtverify.JWK, 0, len(m.keys))}
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if m.keys[i].retireAt.IsZero() || now.Before(m.keys[i].retireAt) {
+			set.Keys = append(set.Keys, m.keys[i].jwk)
+		}
+	}
+	return set
+}
+
+// Rotate generates a new signing key and schedules the previous one for retirement.
+func (m *Manager) Rotate() error {
+	signer, err := generateKey(m.algorithm)
+	if err != nil {
+		return fmt.Errorf("generate signing key: %w", err)
+	}
+	key, err := newSigningKey(keyID(signer.Public()), signer)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if len(m.keys) > 0 {
+		m.keys[len(m.keys)-1].retireAt = now.Add(m.retention)
+	}
+	kept := m.keys[:0]
+	for _, old := range m.keys {
+		if old.retireAt.IsZero() || now.Before(old.retireAt) {
+			kept = append(kept, old)
+		}
+	}
+	m.keys = append(kept, key)
+	log.Printf("Signing key rotated, current kid=%s, published keys=%d", key.kid, len(m.keys))
+	return nil
+}
+
+// Reload re-reads KeysDir. Operators rotate by adding a key whose name sorts last and delete
+// the old file once AccessTokenTTL has passed.
+func (m *Manager) Reload() error {
+	paths, err := filepath.Glob(filepath.Join(m.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	keys := make([]signingKey, 0, len(paths))
+	for _, path := range paths {
+		signer, err := readPrivateKey(path)
+		if err != nil {
+			return fmt.Errorf("load signing key %s: %w", path, err)
+		}
+		key, err := newSigningKey(strings.TrimSuffix(filepath.Base(path), ".pem"), signer)
+		if err != nil {
+			return fmt.Errorf("load signing key %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("%w in %s", ErrNoSigningKey, m.dir)
+	}
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+	log.Printf("Loaded %d signing keys from %s, current kid=%s", len(keys), m.dir, keys[len(keys)-1].kid)
+	return nil
+}
+
+// Run reloads KeysDir or rotates the generated keys every rotation interval until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var err error
+			if m.dir != "" {
+				err = m.Reload()
+			} else {
+				err = m.Rotate()
+			}
+			if err != nil {
+				log.Printf("Signing key rotation failed, keeping current keys: %v", err)
+			}
+		}
+	}
+}
+
+func newSigningKey(kid string, signer crypto.Signer) (signingKey, error) {
+	jwk, err := jwtverify.NewJWK(kid, signer.Public())
+	if err != nil {
+		return signingKey{}, err
+	}
+	return signingKey{kid: kid, signer: signer, jwk: jwk}, nil
+}
+
+func generateKey(algorithm string) (crypto.Signer, error) {
+	if algorithm == jwtverify.AlgRS256 {
+		return rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	return key, err
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: key type %T", jwtverify.ErrUnsupportedAlgorithm, key)
+	}
+	return signer, nil
+}
+
+// keyID derives a stable id from the public key so replicas loading the same key agree on it.
+func keyID(pub crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
@@ -0,0 +1,68 @@
+package jwtkeys
+
+import (
+	"auth_service/configs"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"shared/jwtverify"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_RotateKeepsPreviousKeyUntilTokensExpire(t *testing.T) {
+	m, err := NewManager(configs.TokenConfig{AccessTokenTTL: time.Minute})
+	require.NoError(t, err)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	verifier := jwtverify.NewVerifier(m, "")
+	claims := jwtverify.Claims{Subject: uuid.NewString(), ExpiresAt: now.Add(time.Minute).Unix()}
+
+	oldToken, err := m.Sign(claims)
+	require.NoError(t, err)
+	require.NoError(t, m.Rotate())
+	newToken, err := m.Sign(claims)
+	require.NoError(t, err)
+
+	assert.Len(t, m.JWKS().Keys, 2, "После ротации должны публиковаться оба ключа")
+	_, err = verifier.Verify(context.Background(), oldToken)
+	assert.NoError(t, err, "Токен старого ключа должен проверяться до истечения")
+	_, err = verifier.Verify(context.Background(), newToken)
+	assert.NoError(t, err)
+
+	now = now.Add(time.Minute + jwtverify.DefaultLeeway + time.Second)
+	assert.Len(t, m.JWKS().Keys, 1, "Старый ключ должен сниматься с публикации")
+	require.NoError(t, m.Rotate())
+	assert.Len(t, m.keys, 2, "Просроченные ключи должны удаляться при ротации")
+}
+
+func TestManager_LoadsKeysDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, kid := range []string{"2026-01", "2026-02"} {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+		data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600))
+	}
+
+	m, err := NewManager(configs.TokenConfig{KeysDir: dir})
+	require.NoError(t, err)
+
+	set := m.JWKS()
+	require.Len(t, set.Keys, 2)
+	assert.Equal(t, "2026-02", set.Keys[0].Kid, "Подписывать должен последний по имени ключ")
+	assert.Equal(t, jwtverify.AlgEdDSA, set.Keys[0].Alg)
+
+	_, err = NewManager(configs.TokenConfig{KeysDir: t.TempDir()})
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+}
@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// EnsureTopics creates the missing topics through the admin API. Topics that already exist are left untouched.
+// The topics are keyed by their config key, as events.Topics.Names returns them.
+func EnsureTopics(brokers []string, topics map[string]string, partitions, replicationFactor int) error {
+	if partitions <= 0 {
+		partitions = 1
+	}
+	if replicationFactor <= 0 {
+		replicationFactor = 1
+	}
+	client := &kafka.Client{
+		Addr:    kafka.TCP(brokers...),
+		Timeout: 10 * time.Second,
+	}
+	configs := make([]kafka.TopicConfig, 0, len(topics))
+	for _, topic := range topics {
+		configs = append(configs, kafka.TopicConfig{
+			Topic:             topic,
+			NumPartitions:     partitions,
+			ReplicationFactor: replicationFactor,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	response, err := client.CreateTopics(ctx, &kafka.CreateTopicsRequest{Topics: configs})
+	if err != nil {
+		return fmt.Errorf("failed to create topics: %w", err)
+	}
+	for topic, topicErr := range response.Errors {
+		if topicErr == nil {
+			log.Printf("Topic %s was created", topic)
+			continue
+		}
+		if errors.Is(topicErr, kafka.TopicAlreadyExists) {
+			continue
+		}
+		return fmt.Errorf("failed to create topic %s: %w", topic, topicErr)
+	}
+	return nil
+}
@@ -1,55 +1,358 @@
 package kafka
 
 import (
+	"auth_service/configs"
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"shared/memkafka"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
+const (
+	defaultQueueSize    = 10000
+	defaultBatchSize    = 100
+	defaultBatchTimeout = 10 * time.Millisecond
+	defaultWriteTimeout = 10 * time.Second
+	defaultFlushTimeout = 5 * time.Second
+
+	// writerBatchTimeout is how long the writer holds back an incomplete batch. Batches are
+	// collected before WriteMessages is called, so waiting there would only add latency.
+	writerBatchTimeout = time.Millisecond
+)
+
+var (
+	ErrQueueFull      = errors.New("kafka producer queue is full")
+	ErrProducerClosed = errors.New("kafka producer is closed")
+)
+
+// Delivery is the outcome of an asynchronous send; Err is nil once the broker acknowledged the message.
+type Delivery struct {
+	Topic string
+	Key   string
+	Err   error
+}
+
+type DeliveryCallback func(Delivery)
+
+// ProducerStats are counters since the producer was created, Queued is the current queue length.
+type ProducerStats struct {
+	Queued    int
+	Enqueued  uint64
+	Delivered uint64
+	Failed    uint64
+	Dropped   uint64
+}
+
 type KafkaProducer interface {
-	SendMessage(topic string, key string, value []byte) error
+	// SendMessage writes the message and waits for the broker, bounded by ctx.
+	SendMessage(ctx context.Context, topic string, key string, value []byte) error
+	// SendMessageAsync hands the message over and reports the result to onDelivery. When it returns
+	// an error the message was not accepted and onDelivery is not called.
+	SendMessageAsync(ctx context.Context, topic string, key string, value []byte, onDelivery DeliveryCallback) error
+	Stats() ProducerStats
+	// Close flushes the queued messages and closes the connections.
+	Close() error
+}
+
+// messageWriter is the part of kafka.Writer the producer uses.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
 	Close() error
 }
 
+type pendingMessage struct {
+	message    kafka.Message
+	onDelivery DeliveryCallback
+}
+
 type kafkaProducer struct {
-	writer *kafka.Writer
+	writer messageWriter
+	config configs.KafkaProducerConfig
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan pendingMessage
+	done   chan struct{}
+	// aborted is cancelled when Close gives up on flushing; it interrupts the write in progress
+	// and makes run fail the rest of the queue instead of writing it.
+	aborted context.Context
+	abort   context.CancelFunc
+
+	enqueued  atomic.Uint64
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
 }
 
-func NewKafkaProducer(brokers []string) (KafkaProducer, error) {
+func NewKafkaProducer(brokers []string, cfg configs.KafkaProducerConfig) (KafkaProducer, error) {
+	acks, err := requiredAcks(cfg.Acks)
+	if err != nil {
+		return nil, err
+	}
+	compression, err := compressionCodec(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	cfg = withProducerDefaults(cfg)
 	w := &kafka.Writer{
-		Addr:         kafka.TCP(brokers...), // Pass brokers as a slice of strings
-		Topic:        "",                    // Topic is specified in SendMessage
-		Balancer:     &kafka.LeastBytes{},   // Use LeastBytes balancer for even distribution
-		WriteTimeout: 10 * time.Second,      // Set a write timeout
+		Addr: kafka.TCP(brokers...),
+		// Messages of one key land on one partition, so a batch keeps the order of a user's events.
+		Balancer:     &kafka.Hash{},
+		BatchSize:    cfg.BatchSize,
+		BatchTimeout: writerBatchTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		RequiredAcks: acks,
+		Compression:  compression,
 	}
+	return newProducer(w, cfg), nil
+}
 
-	return &kafkaProducer{writer: w}, nil
+// NewMemoryProducer publishes to an in-process broker with the same queueing and batching as
+// NewKafkaProducer, for tests and running without Kafka.
+func NewMemoryProducer(broker *memkafka.Broker, cfg configs.KafkaProducerConfig) KafkaProducer {
+	return newProducer(broker.Writer(), withProducerDefaults(cfg))
 }
 
-func (kp *kafkaProducer) SendMessage(topic string, key string, value []byte) error {
-	msg := kafka.Message{
-		Topic: topic,
-		Key:   []byte(key),
-		Value: value,
+func newProducer(writer messageWriter, cfg configs.KafkaProducerConfig) *kafkaProducer {
+	kp := &kafkaProducer{writer: writer, config: cfg}
+	kp.aborted, kp.abort = context.WithCancel(context.Background())
+	if cfg.Async {
+		kp.queue = make(chan pendingMessage, cfg.QueueSize)
+		kp.done = make(chan struct{})
+		go kp.run()
 	}
+	return kp
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Add context
-	defer cancel()
+func withProducerDefaults(cfg configs.KafkaProducerConfig) configs.KafkaProducerConfig {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = defaultBatchTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultWriteTimeout
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = defaultFlushTimeout
+	}
+	return cfg
+}
+
+func requiredAcks(acks string) (kafka.RequiredAcks, error) {
+	switch acks {
+	case "", "all":
+		return kafka.RequireAll, nil
+	case "one":
+		return kafka.RequireOne, nil
+	case "none":
+		return kafka.RequireNone, nil
+	}
+	return 0, fmt.Errorf("unsupported kafka.producer.acks %q", acks)
+}
 
-	err := kp.writer.WriteMessages(ctx, msg) // Use WriteMessages with context
+func compressionCodec(name string) (kafka.Compression, error) {
+	switch name {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	}
+	return 0, fmt.Errorf("unsupported kafka.producer.compression %q", name)
+}
+
+func (kp *kafkaProducer) SendMessage(ctx context.Context, topic string, key string, value []byte) error {
+	kp.mu.RLock()
+	closed := kp.closed
+	kp.mu.RUnlock()
+	if closed {
+		return ErrProducerClosed
+	}
+	err := kp.writer.WriteMessages(ctx, kafka.Message{Topic: topic, Key: []byte(key), Value: value})
+	kp.record(err)
 	if err != nil {
 		return fmt.Errorf("failed to write messages: %w", err)
 	}
-
 	return nil
 }
 
+// SendMessageAsync queues the message in async mode; a full queue is reported as ErrQueueFull
+// instead of blocking the caller. Without async mode the message is written right away and
+// onDelivery runs before SendMessageAsync returns.
+func (kp *kafkaProducer) SendMessageAsync(ctx context.Context, topic string, key string, value []byte, onDelivery DeliveryCallback) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if !kp.config.Async {
+		err := kp.SendMessage(ctx, topic, key, value)
+		if errors.Is(err, ErrProducerClosed) {
+			return err
+		}
+		if onDelivery != nil {
+			onDelivery(Delivery{Topic: topic, Key: key, Err: err})
+		}
+		return nil
+	}
+	kp.mu.RLock()
+	defer kp.mu.RUnlock()
+	if kp.closed {
+		return ErrProducerClosed
+	}
+	pending := pendingMessage{message: kafka.Message{Topic: topic, Key: []byte(key), Value: value}, onDelivery: onDelivery}
+	select {
+	case kp.queue <- pending:
+		kp.enqueued.Add(1)
+		return nil
+	default:
+		kp.dropped.Add(1)
+		return ErrQueueFull
+	}
+}
+
+func (kp *kafkaProducer) Stats() ProducerStats {
+	return ProducerStats{
+		Queued:    len(kp.queue),
+		Enqueued:  kp.enqueued.Load(),
+		Delivered: kp.delivered.Load(),
+		Failed:    kp.failed.Load(),
+		Dropped:   kp.dropped.Load(),
+	}
+}
+
+// Close stops accepting messages and waits up to FlushTimeout for the queue to be written.
+// After that the write in progress is cancelled and the messages still queued are reported to
+// their callbacks as failed with ErrProducerClosed before the writer is closed.
 func (kp *kafkaProducer) Close() error {
-	err := kp.writer.Close()
-	if err != nil {
+	kp.mu.Lock()
+	if kp.closed {
+		kp.mu.Unlock()
+		return nil
+	}
+	kp.closed = true
+	if kp.queue != nil {
+		close(kp.queue)
+	}
+	kp.mu.Unlock()
+
+	var flushErr error
+	if kp.done != nil {
+		select {
+		case <-kp.done:
+		case <-time.After(kp.config.FlushTimeout):
+			flushErr = fmt.Errorf("flush timed out with %d messages queued", len(kp.queue))
+			kp.abort()
+			<-kp.done
+		}
+	}
+	kp.abort()
+	if err := kp.writer.Close(); err != nil {
 		return fmt.Errorf("failed to close writer: %w", err)
 	}
-	return nil
+	return flushErr
+}
+
+// run collects queued messages into batches until the queue is closed and drained.
+func (kp *kafkaProducer) run() {
+	defer close(kp.done)
+	batch := make([]pendingMessage, 0, kp.config.BatchSize)
+	// The linger timer runs only while a batch is open: the first message of a batch arms it and
+	// a flush, whatever triggered it, disarms it.
+	timer := time.NewTimer(kp.config.BatchTimeout)
+	stopTimer(timer)
+	defer timer.Stop()
+	for {
+		var pending pendingMessage
+		var ok bool
+		if len(batch) == 0 {
+			pending, ok = <-kp.queue
+		} else {
+			select {
+			case pending, ok = <-kp.queue:
+			case <-timer.C:
+				kp.flush(batch)
+				batch = batch[:0]
+				continue
+			}
+		}
+		if !ok {
+			if len(batch) > 0 {
+				kp.flush(batch)
+			}
+			return
+		}
+		if len(batch) == 0 {
+			timer.Reset(kp.config.BatchTimeout)
+		}
+		batch = append(batch, pending)
+		if len(batch) >= kp.config.BatchSize {
+			stopTimer(timer)
+			kp.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// stopTimer stops timer and drains a tick it may already have delivered, so the next Reset
+// starts a full period.
+func stopTimer(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
+
+func (kp *kafkaProducer) flush(batch []pendingMessage) {
+	messages := make([]kafka.Message, len(batch))
+	for i, pending := range batch {
+		messages[i] = pending.message
+	}
+	var err error
+	if kp.aborted.Err() != nil {
+		err = ErrProducerClosed
+	} else {
+		ctx, cancel := context.WithTimeout(kp.aborted, kp.config.WriteTimeout)
+		err = kp.writer.WriteMessages(ctx, messages...)
+		cancel()
+		if err != nil {
+			log.Printf("Error writing a batch of %d messages: %v", len(batch), err)
+		}
+	}
+	var writeErrors kafka.WriteErrors
+	perMessage := errors.As(err, &writeErrors) && len(writeErrors) == len(batch)
+	for i, pending := range batch {
+		messageErr := err
+		if perMessage {
+			messageErr = writeErrors[i]
+		}
+		kp.record(messageErr)
+		if pending.onDelivery != nil {
+			pending.onDelivery(Delivery{Topic: pending.message.Topic, Key: string(pending.message.Key), Err: messageErr})
+		}
+	}
+}
+
+func (kp *kafkaProducer) record(err error) {
+	if err != nil {
+		kp.failed.Add(1)
+		return
+	}
+	kp.delivered.Add(1)
 }
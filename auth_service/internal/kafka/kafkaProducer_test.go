@@ -0,0 +1,176 @@
+package kafka
+
+import (
+	"auth_service/configs"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWriter struct {
+	mu      sync.Mutex
+	batches [][]kafka.Message
+	failKey string
+	block   chan struct{}
+	closed  bool
+}
+
+func (f *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, msgs)
+	var writeErrors kafka.WriteErrors
+	for i, msg := range msgs {
+		if string(msg.Key) == f.failKey {
+			if writeErrors == nil {
+				writeErrors = make(kafka.WriteErrors, len(msgs))
+			}
+			writeErrors[i] = errors.New("broker is not available")
+		}
+	}
+	if writeErrors != nil {
+		return writeErrors
+	}
+	return nil
+}
+
+func (f *fakeWriter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func TestKafkaProducer_AsyncBatches(t *testing.T) {
+	writer := &fakeWriter{failKey: "bad"}
+	producer := newProducer(writer, withProducerDefaults(configs.KafkaProducerConfig{Async: true, BatchSize: 3, BatchTimeout: time.Hour}))
+
+	var mu sync.Mutex
+	deliveries := make(map[string]error)
+	onDelivery := func(delivery Delivery) {
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries[delivery.Key] = delivery.Err
+	}
+	ctx := context.Background()
+	for _, key := range []string{"a", "bad", "c", "d"} {
+		require.NoError(t, producer.SendMessageAsync(ctx, "topic", key, []byte("value"), onDelivery))
+	}
+
+	require.NoError(t, producer.Close(), "Close должен дожидаться отправки очереди")
+	require.Len(t, writer.batches, 2, "Сообщения должны отправляться пачками")
+	assert.Len(t, writer.batches[0], 3, "Полная пачка должна уходить сразу")
+	assert.Len(t, writer.batches[1], 1, "Остаток должен отправляться при закрытии")
+	assert.True(t, writer.closed, "Writer должен закрываться")
+
+	assert.Len(t, deliveries, 4, "О каждом сообщении должен приходить результат")
+	assert.NoError(t, deliveries["a"])
+	assert.Error(t, deliveries["bad"], "Ошибка должна относиться к своему сообщению")
+	assert.NoError(t, deliveries["d"])
+	assert.Equal(t, ProducerStats{Enqueued: 4, Delivered: 3, Failed: 1}, producer.Stats())
+
+	assert.ErrorIs(t, producer.SendMessageAsync(ctx, "topic", "e", nil, onDelivery), ErrProducerClosed, "После закрытия сообщения не принимаются")
+}
+
+// A batch flushed because it is full must not leave its linger timer behind to cut the next
+// batch short.
+func TestKafkaProducer_LingerRestartsAfterFullBatch(t *testing.T) {
+	writer := &fakeWriter{}
+	linger := 100 * time.Millisecond
+	producer := newProducer(writer, withProducerDefaults(configs.KafkaProducerConfig{Async: true, BatchSize: 2, BatchTimeout: linger}))
+	defer producer.Close()
+	batches := func() int {
+		writer.mu.Lock()
+		defer writer.mu.Unlock()
+		return len(writer.batches)
+	}
+	ctx := context.Background()
+
+	require.NoError(t, producer.SendMessageAsync(ctx, "topic", "a", nil, nil))
+	require.NoError(t, producer.SendMessageAsync(ctx, "topic", "b", nil, nil))
+	require.Eventually(t, func() bool { return batches() == 1 }, time.Second, time.Millisecond, "Полная пачка должна уходить сразу")
+	time.Sleep(linger + linger/2)
+
+	sent := time.Now()
+	require.NoError(t, producer.SendMessageAsync(ctx, "topic", "c", nil, nil))
+	require.Eventually(t, func() bool { return batches() == 2 }, time.Second, time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(sent), linger-10*time.Millisecond, "Следующая пачка должна ждать полный batch_timeout")
+}
+
+func TestKafkaProducer_QueueFull(t *testing.T) {
+	writer := &fakeWriter{block: make(chan struct{})}
+	producer := newProducer(writer, withProducerDefaults(configs.KafkaProducerConfig{Async: true, QueueSize: 1, BatchSize: 1}))
+	ctx := context.Background()
+
+	// The first message is taken by the batching goroutine, which then blocks in the writer.
+	require.NoError(t, producer.SendMessageAsync(ctx, "topic", "a", nil, nil))
+	require.Eventually(t, func() bool { return producer.Stats().Queued == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, producer.SendMessageAsync(ctx, "topic", "b", nil, nil))
+	assert.ErrorIs(t, producer.SendMessageAsync(ctx, "topic", "c", nil, nil), ErrQueueFull, "Переполненная очередь должна отклонять сообщения")
+	assert.Equal(t, uint64(1), producer.Stats().Dropped)
+
+	close(writer.block)
+	require.NoError(t, producer.Close())
+	assert.Equal(t, uint64(2), producer.Stats().Delivered)
+}
+
+func TestKafkaProducer_CloseFailsUnflushed(t *testing.T) {
+	writer := &fakeWriter{block: make(chan struct{})}
+	producer := newProducer(writer, withProducerDefaults(configs.KafkaProducerConfig{Async: true, BatchSize: 1, FlushTimeout: 50 * time.Millisecond}))
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	deliveries := make(map[string]error)
+	onDelivery := func(delivery Delivery) {
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries[delivery.Key] = delivery.Err
+	}
+	require.NoError(t, producer.SendMessageAsync(ctx, "topic", "a", nil, onDelivery))
+	require.Eventually(t, func() bool { return producer.Stats().Queued == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, producer.SendMessageAsync(ctx, "topic", "b", nil, onDelivery))
+
+	assert.Error(t, producer.Close(), "Close должен сообщать о неотправленной очереди")
+	assert.True(t, writer.closed, "Writer должен закрываться")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ErrorIs(t, deliveries["a"], context.Canceled, "Запись, не завершённая к таймауту, должна прерываться")
+	assert.ErrorIs(t, deliveries["b"], ErrProducerClosed, "Оставшиеся в очереди сообщения должны получать ошибку")
+	assert.Equal(t, uint64(2), producer.Stats().Failed)
+}
+
+func TestKafkaProducer_SyncMode(t *testing.T) {
+	writer := &fakeWriter{failKey: "bad"}
+	producer := newProducer(writer, withProducerDefaults(configs.KafkaProducerConfig{}))
+	ctx := context.Background()
+
+	var delivered Delivery
+	require.NoError(t, producer.SendMessageAsync(ctx, "topic", "bad", nil, func(delivery Delivery) { delivered = delivery }))
+	assert.Error(t, delivered.Err, "Без async результат должен приходить сразу")
+	assert.NoError(t, producer.SendMessage(ctx, "topic", "a", nil))
+	assert.Equal(t, ProducerStats{Delivered: 1, Failed: 1}, producer.Stats())
+	require.NoError(t, producer.Close())
+}
+
+func TestNewKafkaProducer_Config(t *testing.T) {
+	_, err := NewKafkaProducer([]string{"localhost:9092"}, configs.KafkaProducerConfig{Acks: "some"})
+	assert.Error(t, err, "Неизвестное значение acks должно отклоняться")
+	_, err = NewKafkaProducer([]string{"localhost:9092"}, configs.KafkaProducerConfig{Compression: "brotli"})
+	assert.Error(t, err, "Неизвестный алгоритм сжатия должен отклоняться")
+	producer, err := NewKafkaProducer([]string{"localhost:9092"}, configs.KafkaProducerConfig{Acks: "one", Compression: "zstd"})
+	require.NoError(t, err)
+	require.NoError(t, producer.Close())
+}
@@ -0,0 +1,134 @@
+// Package metrics holds the Prometheus collectors of the service. Business counters are package
+// variables incremented where the event happens; pool and producer state is read at scrape time.
+package metrics
+
+import (
+	"auth_service/internal/kafka"
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+)
+
+const namespace = "auth"
+
+// Registry keeps the service's own collectors apart from anything registered globally by libraries.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+	HTTPRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	Registrations = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Completed registrations.",
+	})
+	Logins = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Completed logins by method.",
+	}, []string{"method"})
+	LoginFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Failed logins by method and the error key of the service response.",
+	}, []string{"method", "reason"})
+	SessionRenewals = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "session_renewals_total",
+		Help:      "Sessions extended because they were used close to expiry.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func RegisterDB(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, "auth"))
+}
+
+type redisPool interface {
+	PoolStats() *redis.PoolStats
+}
+
+// RegisterRedis exports the connection pool statistics of the Redis client.
+func RegisterRedis(pool redisPool) error {
+	return Registry.Register(&redisCollector{pool: pool})
+}
+
+// RegisterKafkaProducer exports the publish results of the producer.
+func RegisterKafkaProducer(producer kafka.KafkaProducer) error {
+	return Registry.Register(&producerCollector{producer: producer})
+}
+
+var (
+	redisConnections = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis", "pool_connections"),
+		"Connections in the Redis pool by state.", []string{"state"}, nil)
+	redisPoolEvents = prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis", "pool_events_total"),
+		"Redis pool lookups by outcome: a free connection was found (hit), none was free (miss) or the wait timed out (timeout).", []string{"outcome"}, nil)
+)
+
+type redisCollector struct {
+	pool redisPool
+}
+
+func (c *redisCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redisConnections
+	ch <- redisPoolEvents
+}
+
+func (c *redisCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.pool.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisConnections, prometheus.GaugeValue, float64(stats.TotalConns), "total")
+	ch <- prometheus.MustNewConstMetric(redisConnections, prometheus.GaugeValue, float64(stats.IdleConns), "idle")
+	ch <- prometheus.MustNewConstMetric(redisConnections, prometheus.GaugeValue, float64(stats.StaleConns), "stale")
+	ch <- prometheus.MustNewConstMetric(redisPoolEvents, prometheus.CounterValue, float64(stats.Hits), "hit")
+	ch <- prometheus.MustNewConstMetric(redisPoolEvents, prometheus.CounterValue, float64(stats.Misses), "miss")
+	ch <- prometheus.MustNewConstMetric(redisPoolEvents, prometheus.CounterValue, float64(stats.Timeouts), "timeout")
+}
+
+var (
+	kafkaMessages = prometheus.NewDesc(prometheus.BuildFQName(namespace, "kafka", "messages_total"),
+		"Messages handed to the Kafka producer by result: delivered, failed or dropped because the queue was full.", []string{"result"}, nil)
+	kafkaQueued = prometheus.NewDesc(prometheus.BuildFQName(namespace, "kafka", "queued_messages"),
+		"Messages waiting in the producer queue.", nil, nil)
+)
+
+type producerCollector struct {
+	producer kafka.KafkaProducer
+}
+
+func (c *producerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- kafkaMessages
+	ch <- kafkaQueued
+}
+
+func (c *producerCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.producer.Stats()
+	ch <- prometheus.MustNewConstMetric(kafkaMessages, prometheus.CounterValue, float64(stats.Delivered), "delivered")
+	ch <- prometheus.MustNewConstMetric(kafkaMessages, prometheus.CounterValue, float64(stats.Failed), "failed")
+	ch <- prometheus.MustNewConstMetric(kafkaMessages, prometheus.CounterValue, float64(stats.Dropped), "dropped")
+	ch <- prometheus.MustNewConstMetric(kafkaQueued, prometheus.GaugeValue, float64(stats.Queued))
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"auth_service/internal/kafka"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePool struct{}
+
+func (fakePool) PoolStats() *redis.PoolStats {
+	return &redis.PoolStats{Hits: 10, Misses: 2, Timeouts: 1, TotalConns: 5, IdleConns: 3, StaleConns: 1}
+}
+
+type fakeProducer struct{}
+
+func (fakeProducer) SendMessage(ctx context.Context, topic string, key string, value []byte) error {
+	return nil
+}
+func (fakeProducer) SendMessageAsync(ctx context.Context, topic string, key string, value []byte, onDelivery kafka.DeliveryCallback) error {
+	return nil
+}
+func (fakeProducer) Stats() kafka.ProducerStats {
+	return kafka.ProducerStats{Queued: 4, Enqueued: 20, Delivered: 14, Failed: 1, Dropped: 1}
+}
+func (fakeProducer) Close() error { return nil }
+
+func TestRedisCollector(t *testing.T) {
+	expected := `
+# HELP auth_redis_pool_connections Connections in the Redis pool by state.
+# TYPE auth_redis_pool_connections gauge
+auth_redis_pool_connections{state="idle"} 3
+auth_redis_pool_connections{state="stale"} 1
+auth_redis_pool_connections{state="total"} 5
+`
+	require.NoError(t, testutil.CollectAndCompare(&redisCollector{pool: fakePool{}}, strings.NewReader(expected), "auth_redis_pool_connections"))
+	assert.Equal(t, 6, testutil.CollectAndCount(&redisCollector{pool: fakePool{}}), "Должны отдаваться соединения и события пула")
+}
+
+func TestProducerCollector(t *testing.T) {
+	expected := `
+# HELP auth_kafka_messages_total Messages handed to the Kafka producer by result: delivered, failed or dropped because the queue was full.
+# TYPE auth_kafka_messages_total counter
+auth_kafka_messages_total{result="delivered"} 14
+auth_kafka_messages_total{result="dropped"} 1
+auth_kafka_messages_total{result="failed"} 1
+# HELP auth_kafka_queued_messages Messages waiting in the producer queue.
+# TYPE auth_kafka_queued_messages gauge
+auth_kafka_queued_messages 4
+`
+	require.NoError(t, testutil.CollectAndCompare(&producerCollector{producer: fakeProducer{}}, strings.NewReader(expected)))
+}
+
+func TestRegistry(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, RegisterDB(db))
+	require.NoError(t, RegisterRedis(fakePool{}))
+	require.NoError(t, RegisterKafkaProducer(fakeProducer{}))
+	assert.Error(t, RegisterRedis(fakePool{}), "Повторная регистрация должна отклоняться")
+
+	SessionRenewals.Inc()
+	families, err := Registry.Gather()
+	require.NoError(t, err)
+	names := make(map[string]bool)
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	for _, name := range []string{"auth_session_renewals_total", "go_sql_open_connections", "auth_redis_pool_connections", "auth_kafka_messages_total", "go_goroutines"} {
+		assert.True(t, names[name], "Метрика %s должна отдаваться", name)
+	}
+}
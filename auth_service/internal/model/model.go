@@ -12,8 +12,123 @@ type Person struct {
 	Email    string    `json:"email" validate:"required,email"`
 	Password string    `json:"password" validate:"required,min=8"`
 }
+type Profile struct {
+	Id            uuid.UUID
+	Name          string
+	Email         string
+	EmailVerified bool
+}
 type Session struct {
 	SessionID      string
 	UserID         uuid.UUID
 	ExpirationTime time.Time
+	FamilyID       string
+	CreatedAt      time.Time
+}
+type RefreshToken struct {
+	TokenHash      string
+	UserID         uuid.UUID
+	FamilyID       string
+	ExpirationTime time.Time
+}
+type OutboxEvent struct {
+	ID        int64
+	Topic     string
+	Key       string
+	Payload   []byte
+	Attempts  int
+	CreatedAt time.Time
+	// Sensitive events have their payload cleared once they are sent.
+	Sensitive bool
+}
+type PasskeyCredential struct {
+	ID         []byte
+	UserID     uuid.UUID
+	PublicKey  []byte
+	SignCount  uint32
+	Transports []string
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// UserIdentity links the account a user holds at an external identity provider to the user.
+type UserIdentity struct {
+	Provider  string
+	Subject   string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+}
+
+// OAuthState is kept in Redis between the redirect to an identity provider and its callback.
+// LinkUserID is set when a signed in user links the provider to the account.
+type OAuthState struct {
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	LinkUserID   uuid.UUID `json:"link_user_id"`
+}
+
+// OAuthClient is an application that signs its users in through this service. Public clients,
+// such as single page and mobile apps, cannot keep a secret and rely on PKCE alone.
+type OAuthClient struct {
+	ClientID     string
+	SecretHash   string
+	Name         string
+	RedirectURIs []string
+	Public       bool
+	CreatedAt    time.Time
+}
+
+// AuthorizationRequest is the query a client sends the browser to /oauth2/authorize with.
+type AuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Prompt              string
+}
+
+// AuthorizationCode is kept in Redis between the authorization and its redemption at
+// /oauth2/token.
+type AuthorizationCode struct {
+	ClientID      string    `json:"client_id"`
+	RedirectURI   string    `json:"redirect_uri"`
+	UserID        uuid.UUID `json:"user_id"`
+	Scope         string    `json:"scope"`
+	Nonce         string    `json:"nonce"`
+	CodeChallenge string    `json:"code_challenge"`
+}
+
+// TokenRequest is the form a client posts to /oauth2/token.
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+}
+
+// OpenIDConfiguration is the discovery document at /.well-known/openid-configuration.
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
 }
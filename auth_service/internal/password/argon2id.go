@@ -0,0 +1,121 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Defaults follow the second recommended option of RFC 9106 with fewer lanes.
+const (
+	DefaultArgon2Memory      = 64 * 1024
+	DefaultArgon2Iterations  = 3
+	DefaultArgon2Parallelism = 2
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var phcEncoding = base64.RawStdEncoding
+
+// Argon2id hashes with argon2id. Memory is in KiB.
+type Argon2id struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (a Argon2id) IDs() []string {
+	return []string{AlgorithmArgon2id}
+}
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", AlgorithmArgon2id, argon2.Version,
+		a.Memory, a.Iterations, a.Parallelism, phcEncoding.EncodeToString(salt), phcEncoding.EncodeToString(key)), nil
+}
+
+func (a Argon2id) Verify(password, encoded string) (bool, error) {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+func (a Argon2id) NeedsRehash(encoded string) bool {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.memory != a.Memory || params.iterations != a.Iterations || params.parallelism != a.Parallelism ||
+		len(params.salt) != argon2SaltLength || len(params.key) != argon2KeyLength
+}
+
+func (a Argon2id) withDefaults() Argon2id {
+	if a.Memory == 0 {
+		a.Memory = DefaultArgon2Memory
+	}
+	if a.Iterations == 0 {
+		a.Iterations = DefaultArgon2Iterations
+	}
+	if a.Parallelism == 0 {
+		a.Parallelism = DefaultArgon2Parallelism
+	}
+	return a
+}
+
+func (a Argon2id) validate() error {
+	// argon2 needs at least 8 KiB of memory per lane.
+	if a.Memory < 8*uint32(a.Parallelism) {
+		return fmt.Errorf("password_hashing.argon2_memory must be at least 8 KiB per lane")
+	}
+	return nil
+}
+
+// decodeArgon2id parses $argon2id$v=19$m=<KiB>,t=<iterations>,p=<lanes>$<salt>$<hash>.
+func decodeArgon2id(encoded string) (argon2Params, error) {
+	var params argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != AlgorithmArgon2id {
+		return params, ErrMalformedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, ErrMalformedHash
+	}
+	if version != argon2.Version {
+		return params, fmt.Errorf("%w: unsupported argon2 version %d", ErrMalformedHash, version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, ErrMalformedHash
+	}
+	if params.iterations == 0 || params.parallelism == 0 {
+		return params, ErrMalformedHash
+	}
+	var err error
+	if params.salt, err = phcEncoding.DecodeString(parts[4]); err != nil {
+		return params, ErrMalformedHash
+	}
+	if params.key, err = phcEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return params, ErrMalformedHash
+	}
+	return params, nil
+}
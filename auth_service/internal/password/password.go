@@ -0,0 +1,129 @@
+// Package password hashes passwords into self-describing strings: bcrypt in its modular crypt
+// form ($2a$<cost>$...) and argon2id in the PHC string format
+// ($argon2id$v=19$m=<KiB>,t=<iterations>,p=<lanes>$<salt>$<hash>). The identifier between the
+// first two dollar signs picks the algorithm a stored hash is verified with.
+package password
+
+import (
+	"auth_service/configs"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("password: unknown hash algorithm")
+	ErrMalformedHash    = errors.New("password: malformed hash")
+)
+
+// Hasher is one hashing algorithm.
+type Hasher interface {
+	// IDs are the identifiers of the hashes the algorithm produces and verifies.
+	IDs() []string
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded, whatever parameters encoded was made with.
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether encoded was made with other parameters than the hasher uses.
+	NeedsRehash(encoded string) bool
+}
+
+// Policy hashes new passwords with the configured algorithm and verifies hashes of every
+// supported one, so switching the algorithm or raising its cost keeps existing passwords valid.
+type Policy struct {
+	current Hasher
+	byID    map[string]Hasher
+	dummy   func() string
+}
+
+// NewPolicy builds the policy described by cfg. The zero config hashes with argon2id.
+func NewPolicy(cfg configs.PasswordHashingConfig) (*Policy, error) {
+	bcryptHasher := Bcrypt{Cost: cfg.BcryptCost}
+	if bcryptHasher.Cost == 0 {
+		bcryptHasher.Cost = DefaultBcryptCost
+	}
+	argon2Hasher := Argon2id{Memory: cfg.Argon2Memory, Iterations: cfg.Argon2Iterations, Parallelism: cfg.Argon2Parallelism}.withDefaults()
+
+	var current Hasher
+	switch cfg.Algorithm {
+	case "", AlgorithmArgon2id:
+		current = argon2Hasher
+	case AlgorithmBcrypt:
+		current = bcryptHasher
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, cfg.Algorithm)
+	}
+	if err := bcryptHasher.validate(); err != nil {
+		return nil, err
+	}
+	if err := argon2Hasher.validate(); err != nil {
+		return nil, err
+	}
+	return New(current, bcryptHasher, argon2Hasher), nil
+}
+
+// New returns a policy that hashes with current and also verifies the hashes of others.
+func New(current Hasher, others ...Hasher) *Policy {
+	p := &Policy{current: current, byID: make(map[string]Hasher)}
+	for _, hasher := range others {
+		for _, id := range hasher.IDs() {
+			p.byID[id] = hasher
+		}
+	}
+	for _, id := range current.IDs() {
+		p.byID[id] = current
+	}
+	p.dummy = sync.OnceValue(func() string {
+		hash, err := current.Hash("dummy-password-for-timing")
+		if err != nil {
+			log.Printf("Error generating dummy password hash: %v", err)
+		}
+		return hash
+	})
+	return p
+}
+
+func (p *Policy) Hash(password string) (string, error) {
+	return p.current.Hash(password)
+}
+
+// Verify checks password against encoded. When it matches, rehash tells whether encoded was
+// made with another algorithm or other parameters than new hashes get and should be replaced.
+func (p *Policy) Verify(password, encoded string) (ok bool, rehash bool, err error) {
+	id := hashID(encoded)
+	hasher, found := p.byID[id]
+	if !found {
+		return false, false, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, id)
+	}
+	ok, err = hasher.Verify(password, encoded)
+	if err != nil || !ok {
+		return false, false, err
+	}
+	rehash = !slices.Contains(p.current.IDs(), id) || p.current.NeedsRehash(encoded)
+	return true, rehash, nil
+}
+
+// DummyVerify spends the time of a real verification, so a login with an unknown email cannot
+// be told apart from a wrong password by latency.
+func (p *Policy) DummyVerify(password string) {
+	if hash := p.dummy(); hash != "" {
+		p.current.Verify(password, hash)
+	}
+}
+
+// hashID returns the identifier between the first two dollar signs of encoded.
+func hashID(encoded string) string {
+	rest, ok := strings.CutPrefix(encoded, "$")
+	if !ok {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, "$")
+	return id
+}
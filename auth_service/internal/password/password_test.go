@@ -0,0 +1,106 @@
+package password
+
+import (
+	"auth_service/configs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2id keeps the parameters small so the tests stay fast.
+var testArgon2id = Argon2id{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func TestArgon2id_PHCFormat(t *testing.T) {
+	encoded, err := testArgon2id.Hash("password123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$"), "Хеш должен быть в формате PHC: %s", encoded)
+	assert.Len(t, strings.Split(encoded, "$"), 6, "Хеш должен содержать соль и ключ")
+
+	ok, err := testArgon2id.Verify("password123", encoded)
+	require.NoError(t, err)
+	assert.True(t, ok, "Верный пароль должен приниматься")
+	ok, err = testArgon2id.Verify("wrongpassword", encoded)
+	require.NoError(t, err)
+	assert.False(t, ok, "Неверный пароль не должен приниматься")
+
+	other, err := testArgon2id.Hash("password123")
+	require.NoError(t, err)
+	assert.NotEqual(t, encoded, other, "Соль должна быть случайной")
+
+	assert.False(t, testArgon2id.NeedsRehash(encoded), "Хеш с текущими параметрами не требует обновления")
+	assert.True(t, Argon2id{Memory: 128, Iterations: 1, Parallelism: 1}.NeedsRehash(encoded), "Хеш с другими параметрами требует обновления")
+}
+
+func TestArgon2id_Malformed(t *testing.T) {
+	for _, encoded := range []string{
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5",
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$a2V5a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5a2V5",
+	} {
+		_, err := testArgon2id.Verify("password123", encoded)
+		assert.ErrorIs(t, err, ErrMalformedHash, "Хеш %s должен отклоняться", encoded)
+	}
+}
+
+func TestPolicy_VerifyAndRehash(t *testing.T) {
+	legacy := Bcrypt{Cost: bcrypt.MinCost}
+	policy := New(testArgon2id, legacy)
+
+	bcryptHash, err := legacy.Hash("password123")
+	require.NoError(t, err)
+	ok, rehash, err := policy.Verify("password123", bcryptHash)
+	require.NoError(t, err)
+	assert.True(t, ok, "Хеш другого алгоритма должен проверяться")
+	assert.True(t, rehash, "Хеш другого алгоритма требует обновления")
+
+	ok, rehash, err = policy.Verify("wrongpassword", bcryptHash)
+	require.NoError(t, err)
+	assert.False(t, ok, "Неверный пароль не должен приниматься")
+	assert.False(t, rehash, "Без верного пароля обновлять нечего")
+
+	current, err := policy.Hash("password123")
+	require.NoError(t, err)
+	ok, rehash, err = policy.Verify("password123", current)
+	require.NoError(t, err)
+	assert.True(t, ok, "Текущий хеш должен проверяться")
+	assert.False(t, rehash, "Текущий хеш не требует обновления")
+
+	stronger := New(Argon2id{Memory: 128, Iterations: 2, Parallelism: 1})
+	ok, rehash, err = stronger.Verify("password123", current)
+	require.NoError(t, err)
+	assert.True(t, ok, "Хеш с прежними параметрами должен проверяться")
+	assert.True(t, rehash, "Хеш с прежними параметрами требует обновления")
+
+	_, _, err = New(testArgon2id).Verify("password123", bcryptHash)
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm, "Хеш неизвестного алгоритма должен отклоняться")
+	_, _, err = policy.Verify("password123", "plaintext")
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm, "Строка без идентификатора должна отклоняться")
+}
+
+func TestNewPolicy(t *testing.T) {
+	policy, err := NewPolicy(configs.PasswordHashingConfig{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 1})
+	require.NoError(t, err)
+	encoded, err := policy.Hash("password123")
+	require.NoError(t, err)
+	cost, err := bcrypt.Cost([]byte(encoded))
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost, cost, "Стоимость bcrypt должна браться из конфигурации")
+
+	argon2Hash, err := testArgon2id.Hash("password123")
+	require.NoError(t, err)
+	ok, rehash, err := policy.Verify("password123", argon2Hash)
+	require.NoError(t, err)
+	assert.True(t, ok, "Хеш argon2id должен проверяться и при bcrypt")
+	assert.True(t, rehash, "Хеш argon2id требует перехода на bcrypt")
+
+	_, err = NewPolicy(configs.PasswordHashingConfig{Algorithm: "md5"})
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm, "Неизвестный алгоритм должен отклоняться")
+	_, err = NewPolicy(configs.PasswordHashingConfig{BcryptCost: 40})
+	assert.Error(t, err, "Стоимость bcrypt вне допустимого диапазона должна отклоняться")
+	_, err = NewPolicy(configs.PasswordHashingConfig{Argon2Memory: 8, Argon2Parallelism: 2})
+	assert.Error(t, err, "Памяти argon2 должно хватать на все потоки")
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+)
+
+type IdentityPostgres struct {
+	Db *sql.DB
+}
+
+// AddIdentity links an external account to a user. An account that is already linked, to this
+// user or any other, is reported as ErrorIdentityLinked.
+func (repoip *IdentityPostgres) AddIdentity(ctx context.Context, tx *sql.Tx, identity model.UserIdentity) *RepositoryResponse {
+	result, err := executor(repoip.Db, tx).ExecContext(ctx,
+		"INSERT INTO UserIdentities (provider, subject, userid, email) VALUES ($1, $2, $3, $4) ON CONFLICT (provider, subject) DO NOTHING",
+		identity.Provider, identity.Subject, identity.UserID, identity.Email)
+	if err != nil {
+		log.Printf("AddIdentity Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorIdentityLinked}
+	}
+	return &RepositoryResponse{Success: true, Data: DBRepositoryResponseData{UserId: identity.UserID}}
+}
+
+func (repoip *IdentityPostgres) GetIdentity(ctx context.Context, provider, subject string) *RepositoryResponse {
+	identity := model.UserIdentity{Provider: provider, Subject: subject}
+	err := repoip.Db.QueryRowContext(ctx,
+		"SELECT userid, email, created_at FROM UserIdentities WHERE provider = $1 AND subject = $2",
+		provider, subject).Scan(&identity.UserID, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorIdentityNotFound}
+		}
+		log.Printf("GetIdentity Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	return &RepositoryResponse{Success: true, Data: DBIdentityResponseData{Identity: identity}}
+}
+
+func NewIdentityPostgres(db *sql.DB) *IdentityPostgres {
+	return &IdentityPostgres{Db: db}
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentityPostgres_AddIdentity(t *testing.T) {
+	type testCase struct {
+		name            string
+		rowsAffected    int64
+		expectedSuccess bool
+		expectedError   error
+	}
+
+	testCases := []testCase{
+		{name: "New Identity", rowsAffected: 1, expectedSuccess: true},
+		{name: "Already Linked", rowsAffected: 0, expectedSuccess: false, expectedError: erro.ErrorIdentityLinked},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			identity := model.UserIdentity{Provider: "google", Subject: "external-1", UserID: uuid.New(), Email: "tester@example.com"}
+			mock.ExpectExec("INSERT INTO UserIdentities").
+				WithArgs("google", "external-1", identity.UserID, "tester@example.com").
+				WillReturnResult(sqlmock.NewResult(0, tc.rowsAffected))
+
+			response := NewIdentityPostgres(db).AddIdentity(context.Background(), nil, identity)
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestIdentityPostgres_GetIdentity(t *testing.T) {
+	type testCase struct {
+		name             string
+		err              error
+		expectedSuccess  bool
+		expectedError    error
+		expectedIdentity model.UserIdentity
+	}
+
+	userID := uuid.New()
+	createdAt := time.Now()
+	testCases := []testCase{
+		{name: "Linked", expectedSuccess: true, expectedIdentity: model.UserIdentity{Provider: "google", Subject: "external-1", UserID: userID, Email: "tester@example.com", CreatedAt: createdAt}},
+		{name: "Not Linked", err: sql.ErrNoRows, expectedSuccess: false, expectedError: erro.ErrorIdentityNotFound},
+		{name: "Database Error", err: sql.ErrConnDone, expectedSuccess: false, expectedError: erro.ErrorInternalServer},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			query := mock.ExpectQuery("SELECT userid, email, created_at FROM UserIdentities").WithArgs("google", "external-1")
+			if tc.err != nil {
+				query.WillReturnError(tc.err)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"userid", "email", "created_at"}).AddRow(userID, "tester@example.com", createdAt))
+			}
+
+			response := NewIdentityPostgres(db).GetIdentity(context.Background(), "google", "external-1")
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+			if tc.expectedSuccess {
+				assert.Equal(t, DBIdentityResponseData{Identity: tc.expectedIdentity}, response.Data, "Data должен совпадать")
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
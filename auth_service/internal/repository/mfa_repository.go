@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+type MFAPostgres struct {
+	Db *sql.DB
+}
+
+func (repomp *MFAPostgres) GetTOTP(ctx context.Context, userId uuid.UUID) *RepositoryResponse {
+	var data DBMFAResponseData
+	err := repomp.Db.QueryRowContext(ctx, "SELECT totp_secret, enabled, last_used_step FROM UserMFA WHERE userid = $1", userId).
+		Scan(&data.Secret, &data.Enabled, &data.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorMFANotEnrolled}
+		}
+		log.Printf("GetTOTP Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	return &RepositoryResponse{Success: true, Data: data}
+}
+
+// SaveTOTPSecret starts an enrollment, replacing an earlier unconfirmed secret. The secret of an
+// enabled enrollment is never overwritten.
+func (repomp *MFAPostgres) SaveTOTPSecret(ctx context.Context, userId uuid.UUID, secret string) *RepositoryResponse {
+	result, err := repomp.Db.ExecContext(ctx,
+		`INSERT INTO UserMFA (userid, totp_secret) VALUES ($1, $2)
+		ON CONFLICT (userid) DO UPDATE SET totp_secret = EXCLUDED.totp_secret, last_used_step = 0, created_at = now()
+		WHERE UserMFA.enabled = FALSE`,
+		userId, secret)
+	if err != nil {
+		log.Printf("SaveTOTPSecret Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorMFAAlreadyEnabled}
+	}
+	return &RepositoryResponse{Success: true, Data: DBRepositoryResponseData{UserId: userId}}
+}
+
+// EnableTOTP confirms a pending enrollment with the step of the first accepted code.
+func (repomp *MFAPostgres) EnableTOTP(ctx context.Context, tx *sql.Tx, userId uuid.UUID, step int64) *RepositoryResponse {
+	result, err := executor(repomp.Db, tx).ExecContext(ctx,
+		"UPDATE UserMFA SET enabled = TRUE, last_used_step = $2, confirmed_at = now() WHERE userid = $1 AND enabled = FALSE",
+		userId, step)
+	if err != nil {
+		log.Printf("EnableTOTP Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorMFAAlreadyEnabled}
+	}
+	return &RepositoryResponse{Success: true, Data: DBRepositoryResponseData{UserId: userId}}
+}
+
+// UseTOTPStep records step as used. Steps only move forward, so a code that was already
+// accepted, or an older one, is refused even by concurrent requests.
+func (repomp *MFAPostgres) UseTOTPStep(ctx context.Context, userId uuid.UUID, step int64) *RepositoryResponse {
+	result, err := repomp.Db.ExecContext(ctx,
+		"UPDATE UserMFA SET last_used_step = $2 WHERE userid = $1 AND enabled = TRUE AND last_used_step < $2",
+		userId, step)
+	if err != nil {
+		log.Printf("UseTOTPStep Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidMFACode}
+	}
+	return &RepositoryResponse{Success: true, Data: DBRepositoryResponseData{UserId: userId}}
+}
+
+// DisableTOTP removes the enrollment together with the recovery codes.
+func (repomp *MFAPostgres) DisableTOTP(ctx context.Context, tx *sql.Tx, userId uuid.UUID) *RepositoryResponse {
+	db := executor(repomp.Db, tx)
+	if _, err := db.ExecContext(ctx, "DELETE FROM MFARecoveryCodes WHERE userid = $1", userId); err != nil {
+		log.Printf("DisableTOTP Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	result, err := db.ExecContext(ctx, "DELETE FROM UserMFA WHERE userid = $1", userId)
+	if err != nil {
+		log.Printf("DisableTOTP Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorMFANotEnrolled}
+	}
+	return &RepositoryResponse{Success: true, Data: DBRepositoryResponseData{UserId: userId}}
+}
+
+// ReplaceRecoveryCodes drops every recovery code of the user and stores the new hashes.
+func (repomp *MFAPostgres) ReplaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userId uuid.UUID, codeHashes []string) *RepositoryResponse {
+	db := executor(repomp.Db, tx)
+	if _, err := db.ExecContext(ctx, "DELETE FROM MFARecoveryCodes WHERE userid = $1", userId); err != nil {
+		log.Printf("ReplaceRecoveryCodes Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	for _, codeHash := range codeHashes {
+		if _, err := db.ExecContext(ctx, "INSERT INTO MFARecoveryCodes (userid, code_hash) VALUES ($1, $2)", userId, codeHash); err != nil {
+			log.Printf("ReplaceRecoveryCodes Error: %v", err)
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+		}
+	}
+	return &RepositoryResponse{Success: true, Data: DBRepositoryResponseData{UserId: userId}}
+}
+
+// UseRecoveryCode marks an unused recovery code as used; every code works once.
+func (repomp *MFAPostgres) UseRecoveryCode(ctx context.Context, userId uuid.UUID, codeHash string) *RepositoryResponse {
+	result, err := repomp.Db.ExecContext(ctx,
+		"UPDATE MFARecoveryCodes SET used_at = now() WHERE userid = $1 AND code_hash = $2 AND used_at IS NULL",
+		userId, codeHash)
+	if err != nil {
+		log.Printf("UseRecoveryCode Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidMFACode}
+	}
+	return &RepositoryResponse{Success: true, Data: DBRepositoryResponseData{UserId: userId}}
+}
+
+func NewMFAPostgres(db *sql.DB) *MFAPostgres {
+	return &MFAPostgres{Db: db}
+}
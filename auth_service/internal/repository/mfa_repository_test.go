@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMFAPostgres_GetTOTP(t *testing.T) {
+	type testCase struct {
+		name            string
+		mockSetup       func(mock sqlmock.Sqlmock, userID uuid.UUID)
+		expectedSuccess bool
+		expectedData    interface{}
+		expectedError   error
+	}
+
+	testCases := []testCase{
+		{
+			name: "Enabled Enrollment",
+			mockSetup: func(mock sqlmock.Sqlmock, userID uuid.UUID) {
+				mock.ExpectQuery("SELECT totp_secret, enabled, last_used_step FROM UserMFA").
+					WithArgs(userID).
+					WillReturnRows(sqlmock.NewRows([]string{"totp_secret", "enabled", "last_used_step"}).AddRow("JBSWY3DPEHPK3PXP", true, int64(42)))
+			},
+			expectedSuccess: true,
+			expectedData:    DBMFAResponseData{Secret: "JBSWY3DPEHPK3PXP", Enabled: true, LastUsedStep: 42},
+		},
+		{
+			name: "Not Enrolled",
+			mockSetup: func(mock sqlmock.Sqlmock, userID uuid.UUID) {
+				mock.ExpectQuery("SELECT totp_secret, enabled, last_used_step FROM UserMFA").
+					WithArgs(userID).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedSuccess: false,
+			expectedError:   erro.ErrorMFANotEnrolled,
+		},
+		{
+			name: "Database Error",
+			mockSetup: func(mock sqlmock.Sqlmock, userID uuid.UUID) {
+				mock.ExpectQuery("SELECT totp_secret, enabled, last_used_step FROM UserMFA").
+					WithArgs(userID).
+					WillReturnError(errors.New("general database error"))
+			},
+			expectedSuccess: false,
+			expectedError:   erro.ErrorInternalServer,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			userID := uuid.New()
+			tc.mockSetup(mock, userID)
+			repo := NewMFAPostgres(db)
+			response := repo.GetTOTP(context.Background(), userID)
+
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+			if tc.expectedSuccess {
+				assert.Equal(t, tc.expectedData, response.Data, "Data должен совпадать")
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestMFAPostgres_SaveTOTPSecret(t *testing.T) {
+	t.Run("Pending Enrollment", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		userID := uuid.New()
+		mock.ExpectExec("INSERT INTO UserMFA").
+			WithArgs(userID, "JBSWY3DPEHPK3PXP").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		response := NewMFAPostgres(db).SaveTOTPSecret(context.Background(), userID, "JBSWY3DPEHPK3PXP")
+		assert.True(t, response.Success, "Success должен быть true")
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("Already Enabled", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		userID := uuid.New()
+		mock.ExpectExec("INSERT INTO UserMFA").
+			WithArgs(userID, "JBSWY3DPEHPK3PXP").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		response := NewMFAPostgres(db).SaveTOTPSecret(context.Background(), userID, "JBSWY3DPEHPK3PXP")
+		assert.False(t, response.Success, "Success должен быть false")
+		assert.Equal(t, erro.ErrorMFAAlreadyEnabled, response.Errors, "Тип ошибки должен совпадать")
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestMFAPostgres_UseTOTPStep(t *testing.T) {
+	type testCase struct {
+		name            string
+		rowsAffected    int64
+		expectedSuccess bool
+		expectedError   error
+	}
+
+	testCases := []testCase{
+		{name: "New Step", rowsAffected: 1, expectedSuccess: true},
+		{name: "Replayed Step", rowsAffected: 0, expectedSuccess: false, expectedError: erro.ErrorInvalidMFACode},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			userID := uuid.New()
+			mock.ExpectExec("UPDATE UserMFA SET last_used_step").
+				WithArgs(userID, int64(100)).
+				WillReturnResult(sqlmock.NewResult(0, tc.rowsAffected))
+
+			response := NewMFAPostgres(db).UseTOTPStep(context.Background(), userID, 100)
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestMFAPostgres_ReplaceRecoveryCodes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	userID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM MFARecoveryCodes").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO MFARecoveryCodes").
+		WithArgs(userID, "hash-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO MFARecoveryCodes").
+		WithArgs(userID, "hash-2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	response := NewMFAPostgres(db).ReplaceRecoveryCodes(context.Background(), tx, userID, []string{"hash-1", "hash-2"})
+	assert.True(t, response.Success, "Success должен быть true")
+	assert.NoError(t, tx.Commit())
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMFAPostgres_UseRecoveryCode(t *testing.T) {
+	type testCase struct {
+		name            string
+		rowsAffected    int64
+		expectedSuccess bool
+		expectedError   error
+	}
+
+	testCases := []testCase{
+		{name: "Unused Code", rowsAffected: 1, expectedSuccess: true},
+		{name: "Used Or Unknown Code", rowsAffected: 0, expectedSuccess: false, expectedError: erro.ErrorInvalidMFACode},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			userID := uuid.New()
+			mock.ExpectExec("UPDATE MFARecoveryCodes SET used_at").
+				WithArgs(userID, "code-hash").
+				WillReturnResult(sqlmock.NewResult(0, tc.rowsAffected))
+
+			response := NewMFAPostgres(db).UseRecoveryCode(context.Background(), userID, "code-hash")
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
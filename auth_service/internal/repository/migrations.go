@@ -0,0 +1,8 @@
+package repository
+
+import "embed"
+
+const MigrationsDir = "migrations"
+
+//go:embed migrations/*.sql
+var Migrations embed.FS
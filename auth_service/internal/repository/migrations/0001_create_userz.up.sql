@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS UserZ (
+    userid       UUID PRIMARY KEY,
+    username     VARCHAR(255) NOT NULL,
+    useremail    VARCHAR(255) NOT NULL,
+    userpassword VARCHAR(255) NOT NULL,
+    CONSTRAINT userz_useremail_key UNIQUE (useremail)
+);
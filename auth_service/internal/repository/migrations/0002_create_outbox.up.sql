@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS Outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    topic           TEXT NOT NULL,
+    message_key     TEXT NOT NULL,
+    payload         BYTEA NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON Outbox (next_attempt_at, id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_pending_key_idx ON Outbox (message_key, id) WHERE sent_at IS NULL;
@@ -0,0 +1 @@
+ALTER TABLE UserZ DROP COLUMN IF EXISTS email_verified;
@@ -0,0 +1,3 @@
+-- Accounts created before verification existed are treated as verified, new ones start unverified.
+ALTER TABLE UserZ ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE UserZ ALTER COLUMN email_verified SET DEFAULT FALSE;
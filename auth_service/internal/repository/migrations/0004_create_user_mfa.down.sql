@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS MFARecoveryCodes;
+DROP TABLE IF EXISTS UserMFA;
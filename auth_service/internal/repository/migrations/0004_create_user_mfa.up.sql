@@ -0,0 +1,17 @@
+-- A TOTP secret is pending until the first code confirms it. last_used_step keeps a code from
+-- being accepted twice within its 30 second window.
+CREATE TABLE IF NOT EXISTS UserMFA (
+    userid         UUID PRIMARY KEY REFERENCES UserZ (userid) ON DELETE CASCADE,
+    totp_secret    TEXT NOT NULL,
+    enabled        BOOLEAN NOT NULL DEFAULT FALSE,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    confirmed_at   TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS MFARecoveryCodes (
+    userid    UUID NOT NULL REFERENCES UserZ (userid) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    used_at   TIMESTAMPTZ,
+    PRIMARY KEY (userid, code_hash)
+);
@@ -0,0 +1,14 @@
+-- Passkeys registered through WebAuthn. public_key is the COSE encoded key, sign_count the last
+-- signature counter the authenticator reported.
+CREATE TABLE IF NOT EXISTS WebAuthnCredentials (
+    credential_id BYTEA PRIMARY KEY,
+    userid        UUID NOT NULL REFERENCES UserZ (userid) ON DELETE CASCADE,
+    public_key    BYTEA NOT NULL,
+    sign_count    BIGINT NOT NULL DEFAULT 0,
+    transports    TEXT NOT NULL DEFAULT '',
+    name          TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_credentials_userid_idx ON WebAuthnCredentials (userid);
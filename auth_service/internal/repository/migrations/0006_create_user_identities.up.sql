@@ -0,0 +1,12 @@
+-- Accounts at external identity providers (user_identities). subject is the id the provider
+-- assigns, unique per provider; email is the address the provider reported when it was linked.
+CREATE TABLE IF NOT EXISTS UserIdentities (
+    provider   TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    userid     UUID NOT NULL REFERENCES UserZ (userid) ON DELETE CASCADE,
+    email      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_userid_idx ON UserIdentities (userid);
@@ -0,0 +1,11 @@
+-- Applications that sign their users in through the OpenID provider endpoints. secret_hash is
+-- the SHA-256 of the client secret and empty for public clients; redirect_uris is the
+-- comma-separated list of exact redirect URIs the client may use.
+CREATE TABLE IF NOT EXISTS OAuthClients (
+    client_id     TEXT PRIMARY KEY,
+    secret_hash   TEXT NOT NULL DEFAULT '',
+    name          TEXT NOT NULL,
+    redirect_uris TEXT NOT NULL,
+    public        BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
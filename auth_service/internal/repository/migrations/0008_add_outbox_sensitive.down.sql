@@ -0,0 +1 @@
+ALTER TABLE Outbox DROP COLUMN IF EXISTS sensitive;
@@ -0,0 +1,2 @@
+-- Events whose payload carries a secret, such as a one-time token, are cleared once published.
+ALTER TABLE Outbox ADD COLUMN IF NOT EXISTS sensitive BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+-- Lets the relay find sent events past their retention without scanning the pending ones.
+CREATE INDEX IF NOT EXISTS outbox_sent_idx ON Outbox (sent_at) WHERE sent_at IS NOT NULL;
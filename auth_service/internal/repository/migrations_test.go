@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"shared/migrate"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsAreEmbedded(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrator, err := migrate.New(db, Migrations, MigrationsDir)
+	require.NoError(t, err, "Файлы миграций должны быть парными и корректно названными")
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	statuses, err := migrator.Status(context.Background())
+	require.NoError(t, err)
+	for i, status := range statuses {
+		assert.Equal(t, i+1, status.Version, "Версии миграций должны идти без пропусков")
+		assert.False(t, status.Applied)
+	}
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+)
+
+type OAuthClientPostgres struct {
+	Db *sql.DB
+}
+
+func (repooc *OAuthClientPostgres) CreateClient(ctx context.Context, client model.OAuthClient) *RepositoryResponse {
+	_, err := repooc.Db.ExecContext(ctx,
+		"INSERT INTO OAuthClients (client_id, secret_hash, name, redirect_uris, public) VALUES ($1, $2, $3, $4, $5)",
+		client.ClientID, client.SecretHash, client.Name, strings.Join(client.RedirectURIs, ","), client.Public)
+	if err != nil {
+		log.Printf("CreateClient Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	return &RepositoryResponse{Success: true, Data: DBOAuthClientResponseData{Client: client}}
+}
+
+func (repooc *OAuthClientPostgres) GetClient(ctx context.Context, clientID string) *RepositoryResponse {
+	client := model.OAuthClient{ClientID: clientID}
+	var redirectURIs string
+	err := repooc.Db.QueryRowContext(ctx,
+		"SELECT secret_hash, name, redirect_uris, public, created_at FROM OAuthClients WHERE client_id = $1",
+		clientID).Scan(&client.SecretHash, &client.Name, &redirectURIs, &client.Public, &client.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorUnknownOAuthClient}
+		}
+		log.Printf("GetClient Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	if redirectURIs != "" {
+		client.RedirectURIs = strings.Split(redirectURIs, ",")
+	}
+	return &RepositoryResponse{Success: true, Data: DBOAuthClientResponseData{Client: client}}
+}
+
+func NewOAuthClientPostgres(db *sql.DB) *OAuthClientPostgres {
+	return &OAuthClientPostgres{Db: db}
+}
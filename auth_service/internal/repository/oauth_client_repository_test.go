@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuthClientPostgres_CreateClient(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	client := model.OAuthClient{ClientID: "client-1", SecretHash: "secret-hash", Name: "Billing", RedirectURIs: []string{"https://billing.example.com/callback", "http://localhost:3000/callback"}}
+	mock.ExpectExec("INSERT INTO OAuthClients").
+		WithArgs("client-1", "secret-hash", "Billing", "https://billing.example.com/callback,http://localhost:3000/callback", false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	response := NewOAuthClientPostgres(db).CreateClient(context.Background(), client)
+	assert.True(t, response.Success, "Success должен совпадать")
+	assert.Equal(t, DBOAuthClientResponseData{Client: client}, response.Data, "Data должен совпадать")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestOAuthClientPostgres_GetClient(t *testing.T) {
+	type testCase struct {
+		name            string
+		err             error
+		expectedSuccess bool
+		expectedError   error
+		expectedClient  model.OAuthClient
+	}
+
+	createdAt := time.Now()
+	testCases := []testCase{
+		{name: "Registered", expectedSuccess: true, expectedClient: model.OAuthClient{ClientID: "client-1", Name: "Billing", RedirectURIs: []string{"https://billing.example.com/callback", "http://localhost:3000/callback"}, Public: true, CreatedAt: createdAt}},
+		{name: "Unknown", err: sql.ErrNoRows, expectedSuccess: false, expectedError: erro.ErrorUnknownOAuthClient},
+		{name: "Database Error", err: sql.ErrConnDone, expectedSuccess: false, expectedError: erro.ErrorInternalServer},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			query := mock.ExpectQuery("SELECT secret_hash, name, redirect_uris, public, created_at FROM OAuthClients").WithArgs("client-1")
+			if tc.err != nil {
+				query.WillReturnError(tc.err)
+			} else {
+				query.WillReturnRows(sqlmock.NewRows([]string{"secret_hash", "name", "redirect_uris", "public", "created_at"}).
+					AddRow("", "Billing", "https://billing.example.com/callback,http://localhost:3000/callback", true, createdAt))
+			}
+
+			response := NewOAuthClientPostgres(db).GetClient(context.Background(), "client-1")
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+			if tc.expectedSuccess {
+				assert.Equal(t, DBOAuthClientResponseData{Client: tc.expectedClient}, response.Data, "Data должен совпадать")
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+type OutboxPostgres struct {
+	Db *sql.DB
+}
+
+func (repoop *OutboxPostgres) AddOutboxEvent(ctx context.Context, tx *sql.Tx, event model.OutboxEvent) *RepositoryResponse {
+	_, err := executor(repoop.Db, tx).ExecContext(ctx,
+		"INSERT INTO Outbox (topic, message_key, payload, sensitive) values ($1, $2, $3, $4)",
+		event.Topic, event.Key, event.Payload, event.Sensitive)
+	if err != nil {
+		log.Printf("AddOutboxEvent Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorAddOutboxEvent}
+	}
+	log.Printf("Event for topic %s was added to the outbox", event.Topic)
+	return &RepositoryResponse{Success: true}
+}
+
+// ClaimPendingEvents hands out at most limit unsent events and hides them from other relays for lease.
+// Only the oldest unsent event of every key is claimed, so events of one user are published in order.
+func (repoop *OutboxPostgres) ClaimPendingEvents(ctx context.Context, limit int, lease time.Duration) *RepositoryResponse {
+	rows, err := repoop.Db.QueryContext(ctx, `
+		UPDATE Outbox SET next_attempt_at = now() + $2 * interval '1 millisecond'
+		WHERE id IN (
+			SELECT o.id FROM Outbox o
+			WHERE o.sent_at IS NULL AND o.next_attempt_at <= now()
+			AND NOT EXISTS (SELECT 1 FROM Outbox p WHERE p.message_key = o.message_key AND p.sent_at IS NULL AND p.id < o.id)
+			ORDER BY o.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, message_key, payload, attempts, created_at`,
+		limit, lease.Milliseconds())
+	if err != nil {
+		log.Printf("ClaimPendingEvents Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetOutboxEvents}
+	}
+	defer rows.Close()
+
+	events := make([]model.OutboxEvent, 0, limit)
+	for rows.Next() {
+		var event model.OutboxEvent
+		err = rows.Scan(&event.ID, &event.Topic, &event.Key, &event.Payload, &event.Attempts, &event.CreatedAt)
+		if err != nil {
+			log.Printf("ClaimPendingEvents Scan Error: %v", err)
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorGetOutboxEvents}
+		}
+		events = append(events, event)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("ClaimPendingEvents Rows Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetOutboxEvents}
+	}
+	return &RepositoryResponse{Success: true, Data: DBOutboxResponseData{Events: events}}
+}
+
+// MarkEventSent records the delivery of an event and drops the payload of sensitive ones,
+// so secrets such as one-time tokens do not outlive their delivery.
+func (repoop *OutboxPostgres) MarkEventSent(ctx context.Context, eventID int64) *RepositoryResponse {
+	_, err := repoop.Db.ExecContext(ctx, `
+		UPDATE Outbox SET sent_at = now(), last_error = NULL,
+			payload = CASE WHEN sensitive THEN ''::bytea ELSE payload END
+		WHERE id = $1`, eventID)
+	if err != nil {
+		log.Printf("MarkEventSent Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorUpdateOutboxEvent}
+	}
+	return &RepositoryResponse{Success: true}
+}
+
+func (repoop *OutboxPostgres) MarkEventFailed(ctx context.Context, eventID int64, nextAttempt time.Time, lastError string) *RepositoryResponse {
+	_, err := repoop.Db.ExecContext(ctx,
+		"UPDATE Outbox SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3 WHERE id = $1",
+		eventID, nextAttempt, lastError)
+	if err != nil {
+		log.Printf("MarkEventFailed Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorUpdateOutboxEvent}
+	}
+	return &RepositoryResponse{Success: true}
+}
+
+// DeleteSentEvents removes at most limit events sent before sentBefore. Pending events are never
+// deleted, whatever their age.
+func (repoop *OutboxPostgres) DeleteSentEvents(ctx context.Context, sentBefore time.Time, limit int) *RepositoryResponse {
+	result, err := repoop.Db.ExecContext(ctx, `
+		DELETE FROM Outbox WHERE id IN (
+			SELECT id FROM Outbox WHERE sent_at IS NOT NULL AND sent_at < $1 ORDER BY sent_at LIMIT $2
+		)`, sentBefore, limit)
+	if err != nil {
+		log.Printf("DeleteSentEvents Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorDeleteOutboxEvents}
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("DeleteSentEvents RowsAffected Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorDeleteOutboxEvents}
+	}
+	return &RepositoryResponse{Success: true, Data: DBOutboxResponseData{Deleted: deleted}}
+}
+func NewOutboxPostgres(db *sql.DB) *OutboxPostgres {
+	return &OutboxPostgres{Db: db}
+}
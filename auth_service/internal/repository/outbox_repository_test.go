@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxPostgres_AddOutboxEvent(t *testing.T) {
+	event := model.OutboxEvent{Topic: "user-registered-topic", Key: "key", Payload: []byte(`{"user_id":"key"}`)}
+
+	t.Run("Inside Transaction", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO Outbox").
+			WithArgs(event.Topic, event.Key, event.Payload, event.Sensitive).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("Failed to begin transaction: %v", err)
+		}
+		repo := NewOutboxPostgres(db)
+		response := repo.AddOutboxEvent(context.Background(), tx, event)
+		assert.True(t, response.Success, "Success должен быть true")
+		assert.NoError(t, tx.Commit())
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("Insert Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("INSERT INTO Outbox").
+			WithArgs(event.Topic, event.Key, event.Payload, event.Sensitive).
+			WillReturnError(errors.New("general database error"))
+
+		repo := NewOutboxPostgres(db)
+		response := repo.AddOutboxEvent(context.Background(), nil, event)
+		assert.False(t, response.Success, "Success должен быть false")
+		assert.Equal(t, erro.ErrorAddOutboxEvent, response.Errors, "Тип ошибки должен совпадать")
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestOutboxPostgres_ClaimPendingEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	createdAt := time.Now()
+	mock.ExpectQuery("UPDATE Outbox SET next_attempt_at").
+		WithArgs(10, int64(30000)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "message_key", "payload", "attempts", "created_at"}).
+			AddRow(int64(1), "user-registered-topic", "a", []byte("{}"), 0, createdAt).
+			AddRow(int64(2), "user-delete-topic", "b", []byte("{}"), 3, createdAt))
+
+	repo := NewOutboxPostgres(db)
+	response := repo.ClaimPendingEvents(context.Background(), 10, 30*time.Second)
+
+	assert.True(t, response.Success, "Success должен быть true")
+	data, ok := response.Data.(DBOutboxResponseData)
+	assert.True(t, ok, "Data должен быть типа DBOutboxResponseData")
+	assert.Len(t, data.Events, 2)
+	assert.Equal(t, int64(2), data.Events[1].ID)
+	assert.Equal(t, 3, data.Events[1].Attempts)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestOutboxPostgres_MarkEventSent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec(`UPDATE Outbox SET sent_at = now\(\), last_error = NULL,\s+payload = CASE WHEN sensitive THEN ''::bytea ELSE payload END`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	repo := NewOutboxPostgres(db)
+	response := repo.MarkEventSent(context.Background(), 7)
+	assert.True(t, response.Success, "Success должен быть true")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestOutboxPostgres_DeleteSentEvents(t *testing.T) {
+	sentBefore := time.Now().Add(-7 * 24 * time.Hour)
+
+	t.Run("Deletes Sent Events", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec(`DELETE FROM Outbox WHERE id IN \(\s+SELECT id FROM Outbox WHERE sent_at IS NOT NULL AND sent_at < \$1`).
+			WithArgs(sentBefore, 100).
+			WillReturnResult(sqlmock.NewResult(0, 42))
+
+		repo := NewOutboxPostgres(db)
+		response := repo.DeleteSentEvents(context.Background(), sentBefore, 100)
+		assert.True(t, response.Success, "Success должен быть true")
+		data, ok := response.Data.(DBOutboxResponseData)
+		assert.True(t, ok, "Data должен быть типа DBOutboxResponseData")
+		assert.Equal(t, int64(42), data.Deleted)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("Delete Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("DELETE FROM Outbox").
+			WithArgs(sentBefore, 100).
+			WillReturnError(errors.New("general database error"))
+
+		repo := NewOutboxPostgres(db)
+		response := repo.DeleteSentEvents(context.Background(), sentBefore, 100)
+		assert.False(t, response.Success, "Success должен быть false")
+		assert.Equal(t, erro.ErrorDeleteOutboxEvents, response.Errors, "Тип ошибки должен совпадать")
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
@@ -9,17 +9,34 @@ import (
 	"log"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/lib/pq"
 )
 
+// uniqueViolation is the Postgres error code of a unique constraint violation.
+const uniqueViolation = "23505"
+
 type AuthPostgres struct {
 	Db *sql.DB
 }
 
-func (repoap *AuthPostgres) CreateUser(ctx context.Context, user *model.Person) *RepositoryResponse {
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// executor runs queries inside tx when the caller has one, otherwise directly on the pool.
+func executor(db *sql.DB, tx *sql.Tx) dbExecutor {
+	if tx != nil {
+		return tx
+	}
+	return db
+}
+
+func (repoap *AuthPostgres) CreateUser(ctx context.Context, tx *sql.Tx, user *model.Person) *RepositoryResponse {
 	var createdUserID uuid.UUID
 
-	err := repoap.Db.QueryRowContext(ctx,
+	err := executor(repoap.Db, tx).QueryRowContext(ctx,
 		"INSERT INTO UserZ (userid, username, useremail, userpassword) values ($1, $2, $3, $4) ON CONFLICT (useremail) DO NOTHING RETURNING userid;",
 		user.Id, user.Name, user.Email, user.Password).Scan(&createdUserID)
 
@@ -38,53 +55,119 @@ func (repoap *AuthPostgres) CreateUser(ctx context.Context, user *model.Person)
 	return &RepositoryResponse{Success: true, Data: responseData, Errors: nil}
 }
 
-func (repoap *AuthPostgres) GetUser(ctx context.Context, useremail, userpassword string) *RepositoryResponse {
-	var hashpass string
-	var userId uuid.UUID
-	err := repoap.Db.QueryRowContext(ctx, "SELECT userid, userpassword FROM userZ WHERE useremail = $1", useremail).Scan(&userId, &hashpass)
+// GetUser returns the id and the stored password hash of the user with the email,
+// ErrorFoundUser when there is none.
+func (repoap *AuthPostgres) GetUser(ctx context.Context, useremail string) *RepositoryResponse {
+	var data DBPasswordResponseData
+	err := repoap.Db.QueryRowContext(ctx, "SELECT userid, userpassword FROM userZ WHERE useremail = $1", useremail).Scan(&data.UserId, &data.PasswordHash)
 
 	if err != nil {
-		log.Printf("GetUser Error: %v", err)
 		if errors.Is(err, sql.ErrNoRows) {
-			return &RepositoryResponse{Success: false, Errors: erro.ErrorEmailNotRegister}
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorFoundUser}
 		}
+		log.Printf("GetUser Error: %v", err)
 		return &RepositoryResponse{Success: false, Errors: err}
 	}
+	log.Println("Successful get person!")
+	return &RepositoryResponse{Success: true, Data: data, Errors: nil}
+}
+func (repoap *AuthPostgres) DeleteUser(ctx context.Context, tx *sql.Tx, userId uuid.UUID) *RepositoryResponse {
+	result, err := executor(repoap.Db, tx).ExecContext(ctx, "DELETE FROM userZ where userId = $1", userId)
+	if err != nil {
+		log.Printf("Delete Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorFoundUser}
+	}
+	return &RepositoryResponse{Success: true}
+}
+func (repoap *AuthPostgres) GetUserByID(ctx context.Context, userId uuid.UUID) *RepositoryResponse {
+	var data DBUserResponseData
+	err := repoap.Db.QueryRowContext(ctx, "SELECT userid, username, useremail, email_verified FROM userZ WHERE userid = $1", userId).
+		Scan(&data.UserId, &data.Name, &data.Email, &data.EmailVerified)
+	if err != nil {
+		log.Printf("GetUserByID Error: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorFoundUser}
+		}
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	return &RepositoryResponse{Success: true, Data: data}
+}
 
-	err = bcrypt.CompareHashAndPassword([]byte(hashpass), []byte(userpassword))
+func (repoap *AuthPostgres) GetUserByEmail(ctx context.Context, useremail string) *RepositoryResponse {
+	var data DBUserResponseData
+	err := repoap.Db.QueryRowContext(ctx, "SELECT userid, username, useremail, email_verified FROM userZ WHERE useremail = $1", useremail).
+		Scan(&data.UserId, &data.Name, &data.Email, &data.EmailVerified)
 	if err != nil {
-		log.Printf("CompareHashAndPassword Error: %v", err)
-		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidPassword}
+		if errors.Is(err, sql.ErrNoRows) {
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorFoundUser}
+		}
+		log.Printf("GetUserByEmail Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
 	}
+	return &RepositoryResponse{Success: true, Data: data}
+}
 
-	responseData := DBRepositoryResponseData{
-		UserId: userId,
+// UpdatePassword stores an already hashed password.
+func (repoap *AuthPostgres) UpdatePassword(ctx context.Context, tx *sql.Tx, userId uuid.UUID, passwordHash string) *RepositoryResponse {
+	result, err := executor(repoap.Db, tx).ExecContext(ctx, "UPDATE userZ SET userpassword = $1 WHERE userid = $2", passwordHash, userId)
+	if err != nil {
+		log.Printf("UpdatePassword Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
 	}
-	log.Println("Successful get person!")
-	return &RepositoryResponse{Success: true, Data: responseData, Errors: nil}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorFoundUser}
+	}
+	return &RepositoryResponse{Success: true, Data: DBRepositoryResponseData{UserId: userId}}
 }
-func (repoap *AuthPostgres) DeleteUser(ctx context.Context, userId uuid.UUID, password string) *RepositoryResponse {
-	var hashpass string
-	err := repoap.Db.QueryRowContext(ctx, "SELECT userpassword FROM userZ WHERE userid = $1", userId).Scan(&hashpass)
 
+// GetPasswordHash returns the stored password hash of the user.
+func (repoap *AuthPostgres) GetPasswordHash(ctx context.Context, userId uuid.UUID) *RepositoryResponse {
+	data := DBPasswordResponseData{UserId: userId}
+	err := repoap.Db.QueryRowContext(ctx, "SELECT userpassword FROM userZ WHERE userid = $1", userId).Scan(&data.PasswordHash)
 	if err != nil {
-		log.Printf("DeleteUser Error: %v", err)
+		log.Printf("GetPasswordHash Error: %v", err)
 		if errors.Is(err, sql.ErrNoRows) {
 			return &RepositoryResponse{Success: false, Errors: erro.ErrorFoundUser}
 		}
-		return &RepositoryResponse{Success: false, Errors: err}
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
 	}
-	err = bcrypt.CompareHashAndPassword([]byte(hashpass), []byte(password))
+	return &RepositoryResponse{Success: true, Data: data}
+}
+
+// UpdateProfile stores name and email. A different email resets email_verified, the SET
+// expressions see the row as it was before the update.
+func (repoap *AuthPostgres) UpdateProfile(ctx context.Context, tx *sql.Tx, userId uuid.UUID, name, email string) *RepositoryResponse {
+	data := DBUserResponseData{UserId: userId, Name: name, Email: email}
+	err := executor(repoap.Db, tx).QueryRowContext(ctx,
+		"UPDATE userZ SET username = $1, useremail = $2, email_verified = (email_verified AND useremail = $2) WHERE userid = $3 RETURNING email_verified",
+		name, email, userId).Scan(&data.EmailVerified)
 	if err != nil {
-		log.Printf("CompareHashAndPassword Error: %v", err)
-		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidPassword}
+		log.Printf("UpdateProfile Error: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorFoundUser}
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorUniqueEmail}
+		}
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
 	}
-	_, err = repoap.Db.ExecContext(ctx, "DELETE FROM userZ where userId = $1", userId)
+	return &RepositoryResponse{Success: true, Data: data}
+}
+
+func (repoap *AuthPostgres) SetEmailVerified(ctx context.Context, tx *sql.Tx, userId uuid.UUID) *RepositoryResponse {
+	result, err := executor(repoap.Db, tx).ExecContext(ctx, "UPDATE userZ SET email_verified = TRUE WHERE userid = $1", userId)
 	if err != nil {
-		log.Printf("Delete Error: %v", err)
+		log.Printf("SetEmailVerified Error: %v", err)
 		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
 	}
-	return &RepositoryResponse{Success: true}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorFoundUser}
+	}
+	return &RepositoryResponse{Success: true, Data: DBRepositoryResponseData{UserId: userId}}
 }
 func (r *AuthPostgres) BeginTx(ctx context.Context) (*sql.Tx, error) {
 	return r.Db.BeginTx(ctx, nil)
@@ -10,8 +10,8 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/crypto/bcrypt"
 )
 
 func TestAuthPostgres_CreateUser(t *testing.T) {
@@ -87,7 +87,7 @@ func TestAuthPostgres_CreateUser(t *testing.T) {
 
 			tc.mockSetup(mock, tc.user)
 
-			response := repo.CreateUser(context.Background(), tc.user)
+			response := repo.CreateUser(context.Background(), nil, tc.user)
 
 			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
 			if tc.expectedError != nil {
@@ -117,64 +117,48 @@ func TestAuthPostgres_GetUser(t *testing.T) {
 	type testCase struct {
 		name            string
 		useremail       string
-		userpassword    string
 		mockSetup       func(mock sqlmock.Sqlmock, useremail string)
 		expectedSuccess bool
 		expectedError   error
 		checkData       func(t *testing.T, data interface{})
 	}
 
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
+	hashedPassword := "$argon2id$v=19$m=65536,t=3,p=2$c2FsdHNhbHRzYWx0c2FsdA$aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g"
 	userId := uuid.New()
 
 	testCases := []testCase{
 		{
-			name:         "Successful GetUser",
-			useremail:    "test@example.com",
-			userpassword: "password",
+			name:      "Successful GetUser",
+			useremail: "test@example.com",
 			mockSetup: func(mock sqlmock.Sqlmock, useremail string) {
 				mock.ExpectQuery("SELECT userid, userpassword FROM userZ WHERE useremail =").
 					WithArgs(useremail).
-					WillReturnRows(sqlmock.NewRows([]string{"userid", "userpassword"}).AddRow(userId, string(hashedPassword)))
+					WillReturnRows(sqlmock.NewRows([]string{"userid", "userpassword"}).AddRow(userId, hashedPassword))
 			},
 			expectedSuccess: true,
 			expectedError:   nil,
 			checkData: func(t *testing.T, data interface{}) {
-				dataCasted, ok := data.(DBRepositoryResponseData)
-				assert.True(t, ok, "Data должен быть типа DBRepositoryResponseData")
+				dataCasted, ok := data.(DBPasswordResponseData)
+				assert.True(t, ok, "Data должен быть типа DBPasswordResponseData")
 				assert.Equal(t, userId, dataCasted.UserId, "UserId должен совпадать")
+				assert.Equal(t, hashedPassword, dataCasted.PasswordHash, "Хеш пароля должен совпадать")
 			},
 		},
 		{
-			name:         "Email Not Register",
-			useremail:    "test@example.com",
-			userpassword: "password",
+			name:      "Email Not Register",
+			useremail: "test@example.com",
 			mockSetup: func(mock sqlmock.Sqlmock, useremail string) {
 				mock.ExpectQuery("SELECT userid, userpassword FROM userZ WHERE useremail =").
 					WithArgs(useremail).
 					WillReturnError(sql.ErrNoRows)
 			},
 			expectedSuccess: false,
-			expectedError:   erro.ErrorEmailNotRegister,
+			expectedError:   erro.ErrorFoundUser,
 			checkData:       nil,
 		},
 		{
-			name:         "Invalid Password",
-			useremail:    "test@example.com",
-			userpassword: "wrongpassword",
-			mockSetup: func(mock sqlmock.Sqlmock, useremail string) {
-				mock.ExpectQuery("SELECT userid, userpassword FROM userZ WHERE useremail =").
-					WithArgs(useremail).
-					WillReturnRows(sqlmock.NewRows([]string{"userid", "userpassword"}).AddRow(userId, string(hashedPassword)))
-			},
-			expectedSuccess: false,
-			expectedError:   erro.ErrorInvalidPassword,
-			checkData:       nil,
-		},
-		{
-			name:         "General DB Error",
-			useremail:    "test@example.com",
-			userpassword: "password",
+			name:      "General DB Error",
+			useremail: "test@example.com",
 			mockSetup: func(mock sqlmock.Sqlmock, useremail string) {
 				mock.ExpectQuery("SELECT userid, userpassword FROM userZ WHERE useremail =").
 					WithArgs(useremail).
@@ -198,7 +182,7 @@ func TestAuthPostgres_GetUser(t *testing.T) {
 
 			tc.mockSetup(mock, tc.useremail)
 
-			response := repo.GetUser(context.Background(), tc.useremail, tc.userpassword)
+			response := repo.GetUser(context.Background(), tc.useremail)
 
 			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
 
@@ -223,3 +207,142 @@ func TestAuthPostgres_GetUser(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthPostgres_GetUserByID(t *testing.T) {
+	userId := uuid.New()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	repo := NewAuthPostgres(db)
+
+	mock.ExpectQuery("SELECT userid, username, useremail, email_verified FROM userZ WHERE userid =").
+		WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"userid", "username", "useremail", "email_verified"}).AddRow(userId, "tester", "test@example.com", false))
+	mock.ExpectQuery("SELECT userid, username, useremail, email_verified FROM userZ WHERE userid =").
+		WithArgs(userId).
+		WillReturnError(sql.ErrNoRows)
+
+	response := repo.GetUserByID(context.Background(), userId)
+	assert.True(t, response.Success, "Success должен совпадать")
+	assert.Equal(t, DBUserResponseData{UserId: userId, Name: "tester", Email: "test@example.com"}, response.Data, "Data должен совпадать")
+
+	missing := repo.GetUserByID(context.Background(), userId)
+	assert.False(t, missing.Success, "Success должен совпадать")
+	assert.Equal(t, erro.ErrorFoundUser, missing.Errors, "Тип ошибки должен совпадать")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAuthPostgres_SetEmailVerified(t *testing.T) {
+	userId := uuid.New()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	repo := NewAuthPostgres(db)
+
+	mock.ExpectExec("UPDATE userZ SET email_verified = TRUE WHERE userid =").
+		WithArgs(userId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE userZ SET email_verified = TRUE WHERE userid =").
+		WithArgs(userId).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.True(t, repo.SetEmailVerified(context.Background(), nil, userId).Success, "Success должен совпадать")
+	deleted := repo.SetEmailVerified(context.Background(), nil, userId)
+	assert.False(t, deleted.Success, "Success должен совпадать")
+	assert.Equal(t, erro.ErrorFoundUser, deleted.Errors, "Тип ошибки должен совпадать")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAuthPostgres_UpdatePassword(t *testing.T) {
+	userId := uuid.New()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	repo := NewAuthPostgres(db)
+
+	mock.ExpectExec("UPDATE userZ SET userpassword = \\$1 WHERE userid = \\$2").
+		WithArgs("new-hash", userId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE userZ SET userpassword = \\$1 WHERE userid = \\$2").
+		WithArgs("new-hash", userId).
+		WillReturnError(errors.New("update error"))
+
+	assert.True(t, repo.UpdatePassword(context.Background(), nil, userId, "new-hash").Success, "Success должен совпадать")
+	failed := repo.UpdatePassword(context.Background(), nil, userId, "new-hash")
+	assert.False(t, failed.Success, "Success должен совпадать")
+	assert.Equal(t, erro.ErrorInternalServer, failed.Errors, "Тип ошибки должен совпадать")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAuthPostgres_UpdateProfile(t *testing.T) {
+	userId := uuid.New()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	repo := NewAuthPostgres(db)
+
+	query := "UPDATE userZ SET username = \\$1, useremail = \\$2, email_verified = \\(email_verified AND useremail = \\$2\\) WHERE userid = \\$3 RETURNING email_verified"
+	mock.ExpectQuery(query).
+		WithArgs("tester", "new@example.com", userId).
+		WillReturnRows(sqlmock.NewRows([]string{"email_verified"}).AddRow(false))
+	mock.ExpectQuery(query).
+		WithArgs("tester", "taken@example.com", userId).
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectQuery(query).
+		WithArgs("tester", "new@example.com", userId).
+		WillReturnError(sql.ErrNoRows)
+
+	updated := repo.UpdateProfile(context.Background(), nil, userId, "tester", "new@example.com")
+	assert.True(t, updated.Success, "Success должен совпадать")
+	assert.Equal(t, DBUserResponseData{UserId: userId, Name: "tester", Email: "new@example.com"}, updated.Data, "Data должен совпадать")
+	assert.Equal(t, erro.ErrorUniqueEmail, repo.UpdateProfile(context.Background(), nil, userId, "tester", "taken@example.com").Errors, "Тип ошибки должен совпадать")
+	assert.Equal(t, erro.ErrorFoundUser, repo.UpdateProfile(context.Background(), nil, userId, "tester", "new@example.com").Errors, "Тип ошибки должен совпадать")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAuthPostgres_GetPasswordHash(t *testing.T) {
+	userId := uuid.New()
+	hash := "$2a$04$abcdefghijklmnopqrstuuJ4ySDH1vXnQ3QXqSPfH4T4rzv2nYHNe"
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+	repo := NewAuthPostgres(db)
+
+	mock.ExpectQuery("SELECT userpassword FROM userZ WHERE userid = \\$1").
+		WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"userpassword"}).AddRow(hash))
+	mock.ExpectQuery("SELECT userpassword FROM userZ WHERE userid = \\$1").
+		WithArgs(userId).
+		WillReturnError(sql.ErrNoRows)
+
+	response := repo.GetPasswordHash(context.Background(), userId)
+	assert.True(t, response.Success, "Success должен совпадать")
+	assert.Equal(t, DBPasswordResponseData{UserId: userId, PasswordHash: hash}, response.Data, "Данные должны совпадать")
+	assert.Equal(t, erro.ErrorFoundUser, repo.GetPasswordHash(context.Background(), userId).Errors, "Тип ошибки должен совпадать")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Authorization codes of the OpenID provider are stored as oidc_code:<code hash> holding the
+// JSON encoded model.AuthorizationCode, and are deleted when the client redeems them.
+
+func (redisrepo *AuthRedis) SetAuthorizationCode(ctx context.Context, codeHash string, code model.AuthorizationCode, expiration time.Duration) *RepositoryResponse {
+	value, err := json.Marshal(code)
+	if err != nil {
+		log.Printf("Error marshalling authorization code: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorMarshal}
+	}
+	if err := redisrepo.Client.Set(ctx, "oidc_code:"+codeHash, string(value), expiration).Err(); err != nil {
+		log.Printf("Error setting authorization code: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetOneTimeToken}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisAuthCodeResponseData{Code: code}}
+}
+
+// ConsumeAuthorizationCode returns the code and deletes it atomically, so a code can be
+// redeemed only once.
+func (redisrepo *AuthRedis) ConsumeAuthorizationCode(ctx context.Context, codeHash string) *RepositoryResponse {
+	value, err := redisrepo.Client.GetDel(ctx, "oidc_code:"+codeHash).Result()
+	if err == redis.Nil {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidAuthCode}
+	}
+	if err != nil {
+		log.Printf("Error reading authorization code: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	var code model.AuthorizationCode
+	if err := json.Unmarshal([]byte(value), &code); err != nil {
+		log.Printf("Error parsing authorization code: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorUnexpectedData}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisAuthCodeResponseData{Code: code}}
+}
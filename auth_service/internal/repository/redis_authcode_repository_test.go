@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthRedis_AuthorizationCode(t *testing.T) {
+	code := model.AuthorizationCode{ClientID: "client-1", RedirectURI: "https://app.example.com/callback", UserID: uuid.New(), Scope: "openid email", Nonce: "nonce", CodeChallenge: "challenge"}
+	mockRedisClient := new(MockRedisClient)
+	repo := &AuthRedis{Client: mockRedisClient}
+	stored := `{"client_id":"client-1","redirect_uri":"https://app.example.com/callback","user_id":"` + code.UserID.String() + `","scope":"openid email","nonce":"nonce","code_challenge":"challenge"}`
+	setCmd := redis.NewStatusCmd(context.Background())
+	setCmd.SetVal("OK")
+	mockRedisClient.On("Set", context.Background(), "oidc_code:code-hash", stored, time.Minute).Return(setCmd)
+
+	response := repo.SetAuthorizationCode(context.Background(), "code-hash", code, time.Minute)
+	assert.True(t, response.Success, "Success должен совпадать")
+
+	getDelCmd := redis.NewStringCmd(context.Background())
+	getDelCmd.SetVal(stored)
+	mockRedisClient.On("GetDel", context.Background(), "oidc_code:code-hash").Return(getDelCmd).Once()
+	response = repo.ConsumeAuthorizationCode(context.Background(), "code-hash")
+	assert.True(t, response.Success, "Success должен совпадать")
+	assert.Equal(t, RedisAuthCodeResponseData{Code: code}, response.Data, "Data должен совпадать")
+
+	missingCmd := redis.NewStringCmd(context.Background())
+	missingCmd.SetErr(redis.Nil)
+	mockRedisClient.On("GetDel", context.Background(), "oidc_code:code-hash").Return(missingCmd).Once()
+	response = repo.ConsumeAuthorizationCode(context.Background(), "code-hash")
+	assert.False(t, response.Success, "Код используется один раз")
+	assert.Equal(t, erro.ErrorInvalidAuthCode, response.Errors, "Тип ошибки должен совпадать")
+	mockRedisClient.AssertExpectations(t)
+}
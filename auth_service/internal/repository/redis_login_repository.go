@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	loginFailuresPrefix = "login_failures:"
+	loginLockPrefix     = "login_lock:"
+)
+
+// countFailureScript increments the failure counter and restarts its window, so the counter
+// only resets after a full window without failures and lockouts keep escalating meanwhile.
+const countFailureScript = `
+local failures = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return failures
+`
+
+func (redisrepo *AuthRedis) RegisterLoginFailure(ctx context.Context, subject string, window time.Duration) *RepositoryResponse {
+	failures, err := redisrepo.Client.Eval(ctx, countFailureScript, []string{loginFailuresPrefix + subject}, window.Milliseconds()).Int64()
+	if err != nil {
+		log.Printf("Error counting login failure for %s: %v", subject, err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorLoginAttempts}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisLoginResponseData{Failures: int(failures)}}
+}
+
+func (redisrepo *AuthRedis) ResetLoginFailures(ctx context.Context, subject string) *RepositoryResponse {
+	err := redisrepo.Client.Del(ctx, loginFailuresPrefix+subject).Err()
+	if err != nil {
+		log.Printf("Error resetting login failures for %s: %v", subject, err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorLoginAttempts}
+	}
+	return &RepositoryResponse{Success: true}
+}
+
+func (redisrepo *AuthRedis) LockLogin(ctx context.Context, subject string, duration time.Duration) *RepositoryResponse {
+	err := redisrepo.Client.Set(ctx, loginLockPrefix+subject, 1, duration).Err()
+	if err != nil {
+		log.Printf("Error locking login for %s: %v", subject, err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorLoginAttempts}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisLoginResponseData{LockedFor: duration}}
+}
+
+// GetLoginLock reports how long the subject stays locked; LockedFor is zero when it is not locked.
+func (redisrepo *AuthRedis) GetLoginLock(ctx context.Context, subject string) *RepositoryResponse {
+	ttl, err := redisrepo.Client.PTTL(ctx, loginLockPrefix+subject).Result()
+	if err != nil && err != redis.Nil {
+		log.Printf("Error reading login lock for %s: %v", subject, err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorLoginAttempts}
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	return &RepositoryResponse{Success: true, Data: RedisLoginResponseData{LockedFor: ttl}}
+}
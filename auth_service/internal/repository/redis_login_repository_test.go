@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthRedis_RegisterLoginFailure(t *testing.T) {
+	type testCase struct {
+		name             string
+		mockSetup        func(mocks *MockRedisClient, key string)
+		expectedSuccess  bool
+		expectedError    error
+		expectedFailures int
+	}
+
+	window := 15 * time.Minute
+	testCases := []testCase{
+		{
+			name: "Failure Counted",
+			mockSetup: func(mocks *MockRedisClient, key string) {
+				evalCmd := redis.NewCmd(context.Background())
+				evalCmd.SetVal(int64(3))
+				mocks.On("Eval", context.Background(), countFailureScript, []string{key}, window.Milliseconds()).Return(evalCmd)
+			},
+			expectedSuccess:  true,
+			expectedFailures: 3,
+		},
+		{
+			name: "Eval Error",
+			mockSetup: func(mocks *MockRedisClient, key string) {
+				evalCmd := redis.NewCmd(context.Background())
+				evalCmd.SetErr(errors.New("eval error"))
+				mocks.On("Eval", context.Background(), countFailureScript, []string{key}, window.Milliseconds()).Return(evalCmd)
+			},
+			expectedSuccess: false,
+			expectedError:   erro.ErrorLoginAttempts,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRedisClient := new(MockRedisClient)
+			repo := &AuthRedis{Client: mockRedisClient}
+			tc.mockSetup(mockRedisClient, loginFailuresPrefix+"ip:192.0.2.1")
+
+			response := repo.RegisterLoginFailure(context.Background(), "ip:192.0.2.1", window)
+
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+			if tc.expectedSuccess {
+				data, ok := response.Data.(RedisLoginResponseData)
+				assert.True(t, ok, "Data должен быть типа RedisLoginResponseData")
+				assert.Equal(t, tc.expectedFailures, data.Failures, "Failures должен совпадать")
+			}
+			mockRedisClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthRedis_GetLoginLock(t *testing.T) {
+	type testCase struct {
+		name              string
+		ttl               time.Duration
+		err               error
+		expectedSuccess   bool
+		expectedError     error
+		expectedLockedFor time.Duration
+	}
+
+	testCases := []testCase{
+		{name: "Locked", ttl: 90 * time.Second, expectedSuccess: true, expectedLockedFor: 90 * time.Second},
+		{name: "Not Locked", ttl: -2 * time.Nanosecond, expectedSuccess: true},
+		{name: "PTTL Error", err: errors.New("pttl error"), expectedSuccess: false, expectedError: erro.ErrorLoginAttempts},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRedisClient := new(MockRedisClient)
+			repo := &AuthRedis{Client: mockRedisClient}
+			pttlCmd := redis.NewDurationCmd(context.Background(), time.Millisecond)
+			if tc.err != nil {
+				pttlCmd.SetErr(tc.err)
+			} else {
+				pttlCmd.SetVal(tc.ttl)
+			}
+			mockRedisClient.On("PTTL", context.Background(), loginLockPrefix+"account:hash").Return(pttlCmd)
+
+			response := repo.GetLoginLock(context.Background(), "account:hash")
+
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+			if tc.expectedSuccess {
+				data, ok := response.Data.(RedisLoginResponseData)
+				assert.True(t, ok, "Data должен быть типа RedisLoginResponseData")
+				assert.Equal(t, tc.expectedLockedFor, data.LockedFor, "LockedFor должен совпадать")
+			}
+			mockRedisClient.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The state of a sign-in with an identity provider is stored as oauth_state:<state hash> holding
+// the JSON encoded model.OAuthState, and is deleted when the callback arrives.
+
+func (redisrepo *AuthRedis) SetOAuthState(ctx context.Context, stateHash string, state model.OAuthState, expiration time.Duration) *RepositoryResponse {
+	value, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("Error marshalling oauth state: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorMarshal}
+	}
+	if err := redisrepo.Client.Set(ctx, "oauth_state:"+stateHash, string(value), expiration).Err(); err != nil {
+		log.Printf("Error setting oauth state: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetOneTimeToken}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisOAuthStateResponseData{State: state}}
+}
+
+// ConsumeOAuthState returns the state and deletes it atomically, so a callback can be
+// answered only once.
+func (redisrepo *AuthRedis) ConsumeOAuthState(ctx context.Context, stateHash string) *RepositoryResponse {
+	value, err := redisrepo.Client.GetDel(ctx, "oauth_state:"+stateHash).Result()
+	if err == redis.Nil {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidOAuthState}
+	}
+	if err != nil {
+		log.Printf("Error reading oauth state: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	var state model.OAuthState
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		log.Printf("Error parsing oauth state: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorUnexpectedData}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisOAuthStateResponseData{State: state}}
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthRedis_OAuthState(t *testing.T) {
+	state := model.OAuthState{Provider: "google", Nonce: "nonce", CodeVerifier: "verifier", LinkUserID: uuid.New()}
+	mockRedisClient := new(MockRedisClient)
+	repo := &AuthRedis{Client: mockRedisClient}
+	stored := `{"provider":"google","nonce":"nonce","code_verifier":"verifier","link_user_id":"` + state.LinkUserID.String() + `"}`
+	setCmd := redis.NewStatusCmd(context.Background())
+	setCmd.SetVal("OK")
+	mockRedisClient.On("Set", context.Background(), "oauth_state:state-hash", stored, 10*time.Minute).Return(setCmd)
+
+	response := repo.SetOAuthState(context.Background(), "state-hash", state, 10*time.Minute)
+	assert.True(t, response.Success, "Success должен совпадать")
+
+	getDelCmd := redis.NewStringCmd(context.Background())
+	getDelCmd.SetVal(stored)
+	mockRedisClient.On("GetDel", context.Background(), "oauth_state:state-hash").Return(getDelCmd).Once()
+	response = repo.ConsumeOAuthState(context.Background(), "state-hash")
+	assert.True(t, response.Success, "Success должен совпадать")
+	assert.Equal(t, RedisOAuthStateResponseData{State: state}, response.Data, "Data должен совпадать")
+
+	missingCmd := redis.NewStringCmd(context.Background())
+	missingCmd.SetErr(redis.Nil)
+	mockRedisClient.On("GetDel", context.Background(), "oauth_state:state-hash").Return(missingCmd).Once()
+	response = repo.ConsumeOAuthState(context.Background(), "state-hash")
+	assert.False(t, response.Success, "Повторный callback не должен приниматься")
+	assert.Equal(t, erro.ErrorInvalidOAuthState, response.Errors, "Тип ошибки должен совпадать")
+	mockRedisClient.AssertExpectations(t)
+}
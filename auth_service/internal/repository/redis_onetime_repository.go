@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// One-time tokens (email verification, password reset) are stored as <purpose>:<token hash>
+// holding the user id and an optional subject separated by a space, and are deleted on first use.
+// The subject lets the caller check that the token still applies, e.g. that the email it was
+// sent to is still the user's email.
+
+func (redisrepo *AuthRedis) SetOneTimeToken(ctx context.Context, purpose, tokenHash string, userID uuid.UUID, subject string, expiration time.Duration) *RepositoryResponse {
+	value := userID.String()
+	if subject != "" {
+		value += " " + subject
+	}
+	err := redisrepo.Client.Set(ctx, purpose+":"+tokenHash, value, expiration).Err()
+	if err != nil {
+		log.Printf("Error setting %s token: %v", purpose, err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetOneTimeToken}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisOneTimeTokenResponseData{UserID: userID, Subject: subject}}
+}
+
+// ConsumeOneTimeToken returns the user of the token and deletes it atomically, so a token
+// can never be used twice even by concurrent requests.
+func (redisrepo *AuthRedis) ConsumeOneTimeToken(ctx context.Context, purpose, tokenHash string) *RepositoryResponse {
+	return oneTimeTokenOwner(purpose, redisrepo.Client.GetDel(ctx, purpose+":"+tokenHash))
+}
+
+// PeekOneTimeToken returns the user of the token and leaves it in place, for tokens that allow
+// more than one attempt before they are consumed.
+func (redisrepo *AuthRedis) PeekOneTimeToken(ctx context.Context, purpose, tokenHash string) *RepositoryResponse {
+	return oneTimeTokenOwner(purpose, redisrepo.Client.Get(ctx, purpose+":"+tokenHash))
+}
+
+func oneTimeTokenOwner(purpose string, cmd *redis.StringCmd) *RepositoryResponse {
+	value, err := cmd.Result()
+	if err == redis.Nil {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidOneTimeToken}
+	}
+	if err != nil {
+		log.Printf("Error reading %s token: %v", purpose, err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	owner, subject, _ := strings.Cut(value, " ")
+	userID, err := uuid.Parse(owner)
+	if err != nil {
+		log.Printf("Error parsing %s token owner: %v", purpose, err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorUnexpectedData}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisOneTimeTokenResponseData{UserID: userID, Subject: subject}}
+}
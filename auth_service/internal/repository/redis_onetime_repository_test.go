@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthRedis_ConsumeOneTimeToken(t *testing.T) {
+	type testCase struct {
+		name            string
+		value           string
+		err             error
+		expectedSuccess bool
+		expectedError   error
+		expectedSubject string
+	}
+
+	userID := uuid.New()
+	testCases := []testCase{
+		{name: "Valid Token", value: userID.String(), expectedSuccess: true},
+		{name: "Token With Subject", value: userID.String() + " tester@example.com", expectedSuccess: true, expectedSubject: "tester@example.com"},
+		{name: "Unknown Token", err: redis.Nil, expectedSuccess: false, expectedError: erro.ErrorInvalidOneTimeToken},
+		{name: "Corrupted Value", value: "not-a-uuid", expectedSuccess: false, expectedError: erro.ErrorUnexpectedData},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRedisClient := new(MockRedisClient)
+			repo := &AuthRedis{Client: mockRedisClient}
+			getDelCmd := redis.NewStringCmd(context.Background())
+			if tc.err != nil {
+				getDelCmd.SetErr(tc.err)
+			} else {
+				getDelCmd.SetVal(tc.value)
+			}
+			mockRedisClient.On("GetDel", context.Background(), "verify_email:token-hash").Return(getDelCmd)
+
+			response := repo.ConsumeOneTimeToken(context.Background(), "verify_email", "token-hash")
+
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+			if tc.expectedSuccess {
+				assert.Equal(t, RedisOneTimeTokenResponseData{UserID: userID, Subject: tc.expectedSubject}, response.Data, "Data должен совпадать")
+			}
+			mockRedisClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthRedis_SetOneTimeToken(t *testing.T) {
+	userID := uuid.New()
+	mockRedisClient := new(MockRedisClient)
+	repo := &AuthRedis{Client: mockRedisClient}
+	setCmd := redis.NewStatusCmd(context.Background())
+	setCmd.SetVal("OK")
+	mockRedisClient.On("Set", context.Background(), "verify_email:token-hash", userID.String()+" tester@example.com", time.Hour).Return(setCmd)
+
+	response := repo.SetOneTimeToken(context.Background(), "verify_email", "token-hash", userID, "tester@example.com", time.Hour)
+
+	assert.True(t, response.Success, "Success должен быть true")
+	mockRedisClient.AssertExpectations(t)
+}
+
+func TestAuthRedis_PeekOneTimeToken(t *testing.T) {
+	userID := uuid.New()
+	mockRedisClient := new(MockRedisClient)
+	repo := &AuthRedis{Client: mockRedisClient}
+	getCmd := redis.NewStringCmd(context.Background())
+	getCmd.SetVal(userID.String())
+	mockRedisClient.On("Get", context.Background(), "mfa_session:token-hash").Return(getCmd)
+
+	response := repo.PeekOneTimeToken(context.Background(), "mfa_session", "token-hash")
+
+	assert.True(t, response.Success, "Success должен быть true")
+	assert.Equal(t, RedisOneTimeTokenResponseData{UserID: userID}, response.Data, "Data должен совпадать")
+	mockRedisClient.AssertNotCalled(t, "GetDel", context.Background(), "mfa_session:token-hash")
+	mockRedisClient.AssertExpectations(t)
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const rateLimitPrefix = "ratelimit:"
+
+// Both scripts take the time from Redis, so replicas with skewed clocks share one view of the limit.
+// They return {allowed, remaining, retry after ms, reset ms}.
+
+// tokenBucketScript refills ARGV[2] milliseconds per token up to ARGV[1] tokens and takes one.
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) / interval)
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) * interval)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity * interval))
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) * interval)}
+`
+
+// slidingWindowScript keeps the timestamps of the requests of the last ARGV[2] milliseconds
+// and admits a request while there are fewer than ARGV[1] of them.
+const slidingWindowScript = `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+  redis.call('ZADD', KEYS[1], now, ARGV[3])
+  redis.call('PEXPIRE', KEYS[1], window)
+  count = count + 1
+  allowed = 1
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local reset = tonumber(oldest[2]) + window - now
+local retry = 0
+if allowed == 0 then
+  retry = reset
+end
+return {allowed, limit - count, retry, reset}
+`
+
+func (redisrepo *AuthRedis) TakeToken(ctx context.Context, key string, capacity int, interval time.Duration) *RepositoryResponse {
+	intervalMs := float64(interval.Microseconds()) / 1000
+	result, err := redisrepo.Client.Eval(ctx, tokenBucketScript, []string{rateLimitPrefix + key}, capacity, intervalMs).Int64Slice()
+	return rateLimitResponse(key, result, err)
+}
+
+func (redisrepo *AuthRedis) CountInWindow(ctx context.Context, key string, limit int, window time.Duration) *RepositoryResponse {
+	result, err := redisrepo.Client.Eval(ctx, slidingWindowScript, []string{rateLimitPrefix + key}, limit, window.Milliseconds(), uuid.New().String()).Int64Slice()
+	return rateLimitResponse(key, result, err)
+}
+
+func rateLimitResponse(key string, result []int64, err error) *RepositoryResponse {
+	if err != nil || len(result) != 4 {
+		log.Printf("Error checking rate limit for %s: %v", key, err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorRateLimit}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisRateLimitResponseData{
+		Allowed:    result[0] == 1,
+		Remaining:  int(result[1]),
+		RetryAfter: time.Duration(result[2]) * time.Millisecond,
+		Reset:      time.Duration(result[3]) * time.Millisecond,
+	}}
+}
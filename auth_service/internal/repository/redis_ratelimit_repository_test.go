@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuthRedis_TakeToken(t *testing.T) {
+	type testCase struct {
+		name            string
+		result          interface{}
+		err             error
+		expectedSuccess bool
+		expectedError   error
+		expectedData    RedisRateLimitResponseData
+	}
+
+	testCases := []testCase{
+		{
+			name:            "Allowed",
+			result:          []interface{}{int64(1), int64(4), int64(0), int64(2000)},
+			expectedSuccess: true,
+			expectedData:    RedisRateLimitResponseData{Allowed: true, Remaining: 4, Reset: 2 * time.Second},
+		},
+		{
+			name:            "Rejected",
+			result:          []interface{}{int64(0), int64(0), int64(1500), int64(12000)},
+			expectedSuccess: true,
+			expectedData:    RedisRateLimitResponseData{Allowed: false, RetryAfter: 1500 * time.Millisecond, Reset: 12 * time.Second},
+		},
+		{
+			name:            "Eval Error",
+			err:             errors.New("eval error"),
+			expectedSuccess: false,
+			expectedError:   erro.ErrorRateLimit,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRedisClient := new(MockRedisClient)
+			repo := &AuthRedis{Client: mockRedisClient}
+			evalCmd := redis.NewCmd(context.Background())
+			if tc.err != nil {
+				evalCmd.SetErr(tc.err)
+			} else {
+				evalCmd.SetVal(tc.result)
+			}
+			mockRedisClient.On("Eval", context.Background(), tokenBucketScript, []string{rateLimitPrefix + "/auth:192.0.2.1"}, 6, float64(500)).Return(evalCmd)
+
+			response := repo.TakeToken(context.Background(), "/auth:192.0.2.1", 6, 500*time.Millisecond)
+
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+			if tc.expectedSuccess {
+				assert.Equal(t, tc.expectedData, response.Data, "Data должен совпадать")
+			}
+			mockRedisClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthRedis_CountInWindow(t *testing.T) {
+	mockRedisClient := new(MockRedisClient)
+	repo := &AuthRedis{Client: mockRedisClient}
+	evalCmd := redis.NewCmd(context.Background())
+	evalCmd.SetVal([]interface{}{int64(1), int64(2), int64(0), int64(60000)})
+	mockRedisClient.On("Eval", context.Background(), slidingWindowScript, []string{rateLimitPrefix + "/reg:192.0.2.1"}, 5, int64(60000), mock.AnythingOfType("string")).Return(evalCmd)
+
+	response := repo.CountInWindow(context.Background(), "/reg:192.0.2.1", 5, time.Minute)
+
+	assert.True(t, response.Success, "Success должен совпадать")
+	assert.Equal(t, RedisRateLimitResponseData{Allowed: true, Remaining: 2, Reset: time.Minute}, response.Data, "Data должен совпадать")
+	mockRedisClient.AssertExpectations(t)
+}
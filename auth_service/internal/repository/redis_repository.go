@@ -16,16 +16,31 @@ type RedisClientInterface interface {
 	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
 	HGetAll(ctx context.Context, key string) *redis.MapStringStringCmd
 	Del(ctx context.Context, key ...string) *redis.IntCmd
+	SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	SMembers(ctx context.Context, key string) *redis.StringSliceCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
+	SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	PTTL(ctx context.Context, key string) *redis.DurationCmd
+	GetDel(ctx context.Context, key string) *redis.StringCmd
+	Get(ctx context.Context, key string) *redis.StringCmd
 }
 type AuthRedis struct {
 	Client RedisClientInterface
 }
 
 func (redisrepo *AuthRedis) SetSession(ctx context.Context, session model.Session, expiration time.Duration) *RepositoryResponse {
-	err := redisrepo.Client.HSet(ctx, session.SessionID, map[string]interface{}{
+	fields := map[string]interface{}{
 		"UserID":         session.UserID.String(),
 		"ExpirationTime": session.ExpirationTime.Format(time.RFC3339),
-	}).Err()
+	}
+	if session.FamilyID != "" {
+		fields["FamilyID"] = session.FamilyID
+	}
+	if !session.CreatedAt.IsZero() {
+		fields["CreatedAt"] = session.CreatedAt.Format(time.RFC3339)
+	}
+	err := redisrepo.Client.HSet(ctx, session.SessionID, fields).Err()
 
 	if err != nil {
 		log.Printf("Hset error: %v", err)
@@ -38,10 +53,18 @@ func (redisrepo *AuthRedis) SetSession(ctx context.Context, session model.Sessio
 		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetSession}
 	}
 
-	responseData := &RedisRepositoryResponseData{
+	err = redisrepo.Client.Eval(ctx, indexSessionScript, []string{userSessionsPrefix + session.UserID.String()}, session.SessionID, expiration.Milliseconds()).Err()
+	if err != nil {
+		log.Printf("Error indexing session %s: %v", session.SessionID, err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetSession}
+	}
+
+	responseData := RedisRepositoryResponseData{
 		SessionId:      session.SessionID,
 		ExpirationTime: session.ExpirationTime,
 		UserID:         session.UserID,
+		FamilyID:       session.FamilyID,
+		CreatedAt:      session.CreatedAt,
 	}
 	log.Printf("Successful session id = %v installation!", session)
 	return &RepositoryResponse{Success: true, Data: responseData, Errors: nil}
@@ -50,8 +73,19 @@ func (redisrepo *AuthRedis) SetSession(ctx context.Context, session model.Sessio
 const (
 	sessionDuration         = 24 * time.Hour
 	sessionRenewalThreshold = 0.1
+	userSessionsPrefix      = "user_sessions:"
 )
 
+// indexSessionScript adds a session to the user's index and stretches the index TTL to the
+// longest-living session, so the index never expires before a session it points to.
+const indexSessionScript = `
+redis.call('SADD', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`
+
 func (redisrepo *AuthRedis) GetSession(ctx context.Context, sessionID string) *RepositoryResponse {
 	result, err := redisrepo.Client.HGetAll(ctx, sessionID).Result()
 	if err != nil {
@@ -63,66 +97,126 @@ func (redisrepo *AuthRedis) GetSession(ctx context.Context, sessionID string) *R
 		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidSessionID}
 	}
 
-	userIDString, ok := result["UserID"]
-	if !ok {
-		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetUserIdSession}
-	}
-
-	expirationTimeString, ok := result["ExpirationTime"]
-	if !ok {
-		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetExpirationTimeSession}
-	}
-
-	expirationTime, err := time.Parse(time.RFC3339, expirationTimeString)
-	if err != nil {
-		log.Printf("Time-parse error: %v", err)
-		return &RepositoryResponse{Success: false, Errors: erro.ErrorSessionParse}
-	}
-
-	userID, err := uuid.Parse(userIDString)
+	session, err := parseSession(sessionID, result)
 	if err != nil {
-		log.Printf("UUID-parse error: %v", err)
-		return &RepositoryResponse{Success: false, Errors: erro.ErrorSessionParse}
+		return &RepositoryResponse{Success: false, Errors: err}
 	}
+	userID := session.UserID
+	expirationTime := session.ExpirationTime
 
+	// Access-token sessions belong to a refresh token family and are renewed only by rotating the refresh token.
+	familyID := session.FamilyID
 	remainingTime := time.Until(expirationTime)
 	renewalThreshold := time.Duration(float64(sessionDuration.Seconds()) * sessionRenewalThreshold * float64(time.Second))
-	if remainingTime <= renewalThreshold {
+	if familyID == "" && remainingTime <= renewalThreshold {
 		log.Printf("Renewing session %s", sessionID)
 
 		newExpirationTime := time.Now().Add(sessionDuration)
 		renewalDuration := time.Until(newExpirationTime)
 
-		session := model.Session{
+		renewed := model.Session{
 			SessionID:      sessionID,
 			UserID:         userID,
 			ExpirationTime: newExpirationTime,
 		}
-		repoResponse := redisrepo.SetSession(ctx, session, renewalDuration)
+		repoResponse := redisrepo.SetSession(ctx, renewed, renewalDuration)
 		if !repoResponse.Success {
 			log.Printf("Error renewing session in Redis: %v", repoResponse.Errors)
 			return &RepositoryResponse{Success: false, Errors: repoResponse.Errors}
 		}
 		expirationTime = newExpirationTime
-
-	}
-	responseData := &RedisRepositoryResponseData{
-		SessionId:      sessionID,
-		ExpirationTime: expirationTime,
-		UserID:         userID,
+		session.Renewed = true
 	}
+	session.ExpirationTime = expirationTime
 	log.Printf("Successful session id = %v receiving!", sessionID)
-	return &RepositoryResponse{Success: true, Data: responseData, Errors: nil}
+	return &RepositoryResponse{Success: true, Data: session, Errors: nil}
 }
-func (redisrepo *AuthRedis) DeleteSession(ctx context.Context, sessionID string) *RepositoryResponse {
+func (redisrepo *AuthRedis) DeleteSession(ctx context.Context, userID uuid.UUID, sessionID string) *RepositoryResponse {
 	err := redisrepo.Client.Del(ctx, sessionID).Err()
 	if err != nil {
 		log.Printf("Error deleting session %s: %v", sessionID, err)
 		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
 	}
+	err = redisrepo.Client.SRem(ctx, userSessionsPrefix+userID.String(), sessionID).Err()
+	if err != nil {
+		// The session itself is gone; a stale index entry is pruned on the next listing.
+		log.Printf("Error removing session %s from the index: %v", sessionID, err)
+	}
 	log.Printf("Session %s deleted successfully", sessionID)
 	return &RepositoryResponse{Success: true}
 }
+
+// GetUserSessions lists the live sessions of a user. Index entries whose session has expired are pruned.
+func (redisrepo *AuthRedis) GetUserSessions(ctx context.Context, userID uuid.UUID) *RepositoryResponse {
+	indexKey := userSessionsPrefix + userID.String()
+	sessionIDs, err := redisrepo.Client.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		log.Printf("SMembers error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetUserSessions}
+	}
+	sessions := make([]RedisRepositoryResponseData, 0, len(sessionIDs))
+	var stale []interface{}
+	for _, sessionID := range sessionIDs {
+		result, err := redisrepo.Client.HGetAll(ctx, sessionID).Result()
+		if err != nil {
+			log.Printf("HGetAll error: %v", err)
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorGetUserSessions}
+		}
+		if len(result) == 0 {
+			stale = append(stale, sessionID)
+			continue
+		}
+		session, err := parseSession(sessionID, result)
+		if err != nil || session.UserID != userID {
+			log.Printf("Skipping broken session %s in the index of %s: %v", sessionID, userID, err)
+			stale = append(stale, sessionID)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if len(stale) > 0 {
+		if err := redisrepo.Client.SRem(ctx, indexKey, stale...).Err(); err != nil {
+			log.Printf("Error pruning the session index of %s: %v", userID, err)
+		}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisSessionListResponseData{Sessions: sessions}}
+}
+
+func parseSession(sessionID string, result map[string]string) (RedisRepositoryResponseData, error) {
+	userIDString, ok := result["UserID"]
+	if !ok {
+		return RedisRepositoryResponseData{}, erro.ErrorGetUserIdSession
+	}
+	expirationTimeString, ok := result["ExpirationTime"]
+	if !ok {
+		return RedisRepositoryResponseData{}, erro.ErrorGetExpirationTimeSession
+	}
+	expirationTime, err := time.Parse(time.RFC3339, expirationTimeString)
+	if err != nil {
+		log.Printf("Time-parse error: %v", err)
+		return RedisRepositoryResponseData{}, erro.ErrorSessionParse
+	}
+	userID, err := uuid.Parse(userIDString)
+	if err != nil {
+		log.Printf("UUID-parse error: %v", err)
+		return RedisRepositoryResponseData{}, erro.ErrorSessionParse
+	}
+	var createdAt time.Time
+	if createdAtString, ok := result["CreatedAt"]; ok {
+		createdAt, err = time.Parse(time.RFC3339, createdAtString)
+		if err != nil {
+			log.Printf("Time-parse error: %v", err)
+			return RedisRepositoryResponseData{}, erro.ErrorSessionParse
+		}
+	}
+	return RedisRepositoryResponseData{
+		SessionId:      sessionID,
+		ExpirationTime: expirationTime,
+		UserID:         userID,
+		FamilyID:       result["FamilyID"],
+		CreatedAt:      createdAt,
+	}, nil
+}
 func NewAuthRedis(client *redis.Client) *AuthRedis {
 	return &AuthRedis{Client: client}
 }
@@ -9,7 +9,6 @@ import (
 	"os"
 	"testing"
 	"time"
-	"unsafe"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
@@ -37,6 +36,50 @@ func (m *MockRedisClient) HGetAll(ctx context.Context, key string) *redis.MapStr
 	return args.Get(0).(*redis.MapStringStringCmd)
 }
 
+func (m *MockRedisClient) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	args := []interface{}{ctx}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	return m.Called(args...).Get(0).(*redis.IntCmd)
+}
+
+func (m *MockRedisClient) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	args := append([]interface{}{ctx, key}, members...)
+	return m.Called(args...).Get(0).(*redis.IntCmd)
+}
+
+func (m *MockRedisClient) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	args := m.Called(ctx, key)
+	return args.Get(0).(*redis.StringSliceCmd)
+}
+
+func (m *MockRedisClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	callArgs := append([]interface{}{ctx, script, keys}, args...)
+	return m.Called(callArgs...).Get(0).(*redis.Cmd)
+}
+
+func (m *MockRedisClient) SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	args := append([]interface{}{ctx, key}, members...)
+	return m.Called(args...).Get(0).(*redis.IntCmd)
+}
+
+func (m *MockRedisClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	return m.Called(ctx, key, value, expiration).Get(0).(*redis.StatusCmd)
+}
+
+func (m *MockRedisClient) PTTL(ctx context.Context, key string) *redis.DurationCmd {
+	return m.Called(ctx, key).Get(0).(*redis.DurationCmd)
+}
+
+func (m *MockRedisClient) GetDel(ctx context.Context, key string) *redis.StringCmd {
+	return m.Called(ctx, key).Get(0).(*redis.StringCmd)
+}
+
+func (m *MockRedisClient) Get(ctx context.Context, key string) *redis.StringCmd {
+	return m.Called(ctx, key).Get(0).(*redis.StringCmd)
+}
+
 func TestMain(m *testing.M) {
 	// setup
 	log.SetOutput(os.Stdout)
@@ -73,11 +116,15 @@ func TestAuthRedis_SetSession(t *testing.T) {
 				hSetCmd := redis.NewIntCmd(context.Background())
 				hSetCmd.SetVal(1)
 
-				mocks.On("HSet", context.Background(), session.SessionID, mock.AnythingOfType("map[string]interface{}")).Return(hSetCmd)
+				mocks.On("HSet", context.Background(), session.SessionID, mock.AnythingOfType("map[string]interface {}")).Return(hSetCmd)
 
 				expireCmd := redis.NewBoolCmd(context.Background())
 				expireCmd.SetVal(true)
 				mocks.On("Expire", context.Background(), session.SessionID, expiration).Return(expireCmd)
+
+				evalCmd := redis.NewCmd(context.Background())
+				evalCmd.SetVal(int64(1))
+				mocks.On("Eval", context.Background(), indexSessionScript, []string{userSessionsPrefix + session.UserID.String()}, session.SessionID, expiration.Milliseconds()).Return(evalCmd)
 			},
 			expectedSuccess: true,
 			expectedError:   nil,
@@ -113,7 +160,7 @@ func TestAuthRedis_SetSession(t *testing.T) {
 
 				expireCmd := redis.NewBoolCmd(context.Background())
 				expireCmd.SetErr(errors.New("expire error"))
-				mocks.On("Expire", context.Background(), session.SessionID, expiration, mock.Anything).Return(expireCmd)
+				mocks.On("Expire", context.Background(), session.SessionID, expiration).Return(expireCmd)
 			},
 			expectedSuccess: false,
 			expectedError:   erro.ErrorSetSession,
@@ -124,7 +171,7 @@ func TestAuthRedis_SetSession(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockRedisClient := new(MockRedisClient)
-			repo := &AuthRedis{Client: (*redis.Client)(unsafe.Pointer(mockRedisClient))}
+			repo := &AuthRedis{Client: mockRedisClient}
 
 			tc.mockSetup(mockRedisClient, tc.session, tc.expiration)
 
@@ -154,7 +201,7 @@ func TestAuthRedis_GetSession(t *testing.T) {
 
 	sessionID := "session-id"
 	userID := uuid.New()
-	expirationTime := time.Now().Add(time.Hour)
+	expirationTime := time.Now().Add(12 * time.Hour)
 
 	testCases := []testCase{
 		{
@@ -176,6 +223,7 @@ func TestAuthRedis_GetSession(t *testing.T) {
 				assert.Equal(t, sessionID, dataCasted.SessionId, "SessionId должен совпадать")
 				assert.Equal(t, expirationTime.Format(time.RFC3339), dataCasted.ExpirationTime.Format(time.RFC3339), "ExpirationTime должен совпадать")
 				assert.Equal(t, userID, dataCasted.UserID, "UserID должен совпадать")
+				assert.False(t, dataCasted.Renewed, "Сессия с большим запасом времени не должна продлеваться")
 			},
 		},
 		{
@@ -265,7 +313,7 @@ func TestAuthRedis_GetSession(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockRedisClient := new(MockRedisClient)
-			repo := &AuthRedis{Client: (*redis.Client)(unsafe.Pointer(mockRedisClient))} // <-  небезопасный способ
+			repo := &AuthRedis{Client: mockRedisClient}
 
 			tc.mockSetup(mockRedisClient, tc.sessionID)
 
@@ -282,3 +330,38 @@ func TestAuthRedis_GetSession(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthRedis_GetUserSessions(t *testing.T) {
+	mockRedisClient := new(MockRedisClient)
+	repo := &AuthRedis{Client: mockRedisClient}
+	userID := uuid.New()
+	indexKey := userSessionsPrefix + userID.String()
+	expirationTime := time.Now().Add(time.Hour)
+
+	membersCmd := redis.NewStringSliceCmd(context.Background())
+	membersCmd.SetVal([]string{"live", "expired"})
+	mockRedisClient.On("SMembers", context.Background(), indexKey).Return(membersCmd)
+	liveCmd := redis.NewMapStringStringCmd(context.Background())
+	liveCmd.SetVal(map[string]string{
+		"UserID":         userID.String(),
+		"ExpirationTime": expirationTime.Format(time.RFC3339),
+		"FamilyID":       "family",
+	})
+	mockRedisClient.On("HGetAll", context.Background(), "live").Return(liveCmd)
+	expiredCmd := redis.NewMapStringStringCmd(context.Background())
+	expiredCmd.SetVal(map[string]string{})
+	mockRedisClient.On("HGetAll", context.Background(), "expired").Return(expiredCmd)
+	sRemCmd := redis.NewIntCmd(context.Background())
+	sRemCmd.SetVal(1)
+	mockRedisClient.On("SRem", context.Background(), indexKey, "expired").Return(sRemCmd)
+
+	response := repo.GetUserSessions(context.Background(), userID)
+
+	assert.True(t, response.Success, "Success должен совпадать")
+	data, ok := response.Data.(RedisSessionListResponseData)
+	assert.True(t, ok, "Data должен быть типа RedisSessionListResponseData")
+	assert.Len(t, data.Sessions, 1, "Просроченные сессии не должны возвращаться")
+	assert.Equal(t, "live", data.Sessions[0].SessionId, "SessionId должен совпадать")
+	assert.Equal(t, "family", data.Sessions[0].FamilyID, "FamilyID должен совпадать")
+	mockRedisClient.AssertExpectations(t)
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	refreshTokenPrefix      = "refresh:"
+	tokenFamilyPrefix       = "refresh_family:"
+	userTokenFamiliesPrefix = "user_token_families:"
+)
+
+// useRefreshTokenScript marks a refresh token as used and returns how many times it has been presented,
+// or -1 when the token does not exist. Doing both in one script keeps rotation atomic across replicas.
+const useRefreshTokenScript = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+return redis.call('HINCRBY', KEYS[1], 'Used', 1)
+`
+
+func (redisrepo *AuthRedis) SetRefreshToken(ctx context.Context, token model.RefreshToken, expiration time.Duration) *RepositoryResponse {
+	key := refreshTokenPrefix + token.TokenHash
+	err := redisrepo.Client.HSet(ctx, key, map[string]interface{}{
+		"UserID":         token.UserID.String(),
+		"FamilyID":       token.FamilyID,
+		"ExpirationTime": token.ExpirationTime.Format(time.RFC3339),
+		"Used":           0,
+	}).Err()
+	if err != nil {
+		log.Printf("Hset error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetRefreshToken}
+	}
+	err = redisrepo.Client.Expire(ctx, key, expiration).Err()
+	if err != nil {
+		log.Printf("Expire error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetRefreshToken}
+	}
+	if response := redisrepo.AddToTokenFamily(ctx, token.FamilyID, key, expiration); !response.Success {
+		return response
+	}
+	// The user index outlives the access sessions, so revoking every login of the user
+	// also reaches families whose last access session has already expired.
+	userKey := userTokenFamiliesPrefix + token.UserID.String()
+	err = redisrepo.Client.SAdd(ctx, userKey, token.FamilyID).Err()
+	if err != nil {
+		log.Printf("SAdd error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetRefreshToken}
+	}
+	err = redisrepo.Client.Expire(ctx, userKey, expiration).Err()
+	if err != nil {
+		log.Printf("Expire error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetRefreshToken}
+	}
+	return &RepositoryResponse{Success: true}
+}
+
+// GetUserTokenFamilies returns the refresh token families issued to the user. Families that
+// were revoked since may still be listed, revoking them again is a no-op.
+func (redisrepo *AuthRedis) GetUserTokenFamilies(ctx context.Context, userID uuid.UUID) *RepositoryResponse {
+	families, err := redisrepo.Client.SMembers(ctx, userTokenFamiliesPrefix+userID.String()).Result()
+	if err != nil {
+		log.Printf("SMembers error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetRefreshToken}
+	}
+	return &RepositoryResponse{Success: true, Data: RedisTokenFamiliesResponseData{FamilyIDs: families}}
+}
+
+func (redisrepo *AuthRedis) UseRefreshToken(ctx context.Context, tokenHash string) *RepositoryResponse {
+	key := refreshTokenPrefix + tokenHash
+	result, err := redisrepo.Client.HGetAll(ctx, key).Result()
+	if err != nil {
+		log.Printf("HGetAll error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetRefreshToken}
+	}
+	if len(result) == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidRefreshToken}
+	}
+	userID, err := uuid.Parse(result["UserID"])
+	if err != nil {
+		log.Printf("UUID-parse error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSessionParse}
+	}
+	expirationTime, err := time.Parse(time.RFC3339, result["ExpirationTime"])
+	if err != nil {
+		log.Printf("Time-parse error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSessionParse}
+	}
+	responseData := RedisTokenResponseData{
+		UserID:         userID,
+		FamilyID:       result["FamilyID"],
+		ExpirationTime: expirationTime,
+	}
+
+	used, err := redisrepo.Client.Eval(ctx, useRefreshTokenScript, []string{key}).Int64()
+	if err != nil {
+		log.Printf("Eval error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetRefreshToken}
+	}
+	switch {
+	case used < 0:
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidRefreshToken}
+	case used > 1:
+		log.Printf("Refresh token of family %s was presented %d times", responseData.FamilyID, used)
+		return &RepositoryResponse{Success: false, Data: responseData, Errors: erro.ErrorRefreshTokenReused}
+	}
+	return &RepositoryResponse{Success: true, Data: responseData}
+}
+
+func (redisrepo *AuthRedis) AddToTokenFamily(ctx context.Context, familyID string, key string, expiration time.Duration) *RepositoryResponse {
+	familyKey := tokenFamilyPrefix + familyID
+	err := redisrepo.Client.SAdd(ctx, familyKey, key).Err()
+	if err != nil {
+		log.Printf("SAdd error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetRefreshToken}
+	}
+	err = redisrepo.Client.Expire(ctx, familyKey, expiration).Err()
+	if err != nil {
+		log.Printf("Expire error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetRefreshToken}
+	}
+	return &RepositoryResponse{Success: true}
+}
+
+// RevokeTokenFamily deletes every refresh token and access session ever issued for the family.
+func (redisrepo *AuthRedis) RevokeTokenFamily(ctx context.Context, familyID string) *RepositoryResponse {
+	familyKey := tokenFamilyPrefix + familyID
+	members, err := redisrepo.Client.SMembers(ctx, familyKey).Result()
+	if err != nil {
+		log.Printf("SMembers error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorRevokeTokenFamily}
+	}
+	err = redisrepo.Client.Del(ctx, append(members, familyKey)...).Err()
+	if err != nil {
+		log.Printf("Error revoking token family %s: %v", familyID, err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorRevokeTokenFamily}
+	}
+	log.Printf("Token family %s revoked (%d keys)", familyID, len(members))
+	return &RepositoryResponse{Success: true}
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuthRedis_UseRefreshToken(t *testing.T) {
+	type testCase struct {
+		name            string
+		mockSetup       func(mocks *MockRedisClient, key string)
+		expectedSuccess bool
+		expectedError   error
+		expectData      bool
+	}
+
+	tokenHash := "token-hash"
+	userID := uuid.New()
+	expirationTime := time.Now().Add(time.Hour)
+	stored := map[string]string{
+		"UserID":         userID.String(),
+		"FamilyID":       "family-id",
+		"ExpirationTime": expirationTime.Format(time.RFC3339),
+		"Used":           "0",
+	}
+	evalResult := func(val int64) *redis.Cmd {
+		cmd := redis.NewCmd(context.Background())
+		cmd.SetVal(val)
+		return cmd
+	}
+
+	testCases := []testCase{
+		{
+			name: "First Use",
+			mockSetup: func(mocks *MockRedisClient, key string) {
+				hGetAllCmd := redis.NewMapStringStringCmd(context.Background())
+				hGetAllCmd.SetVal(stored)
+				mocks.On("HGetAll", context.Background(), key).Return(hGetAllCmd)
+				mocks.On("Eval", context.Background(), useRefreshTokenScript, []string{key}).Return(evalResult(1))
+			},
+			expectedSuccess: true,
+			expectData:      true,
+		},
+		{
+			name: "Reused Token",
+			mockSetup: func(mocks *MockRedisClient, key string) {
+				hGetAllCmd := redis.NewMapStringStringCmd(context.Background())
+				hGetAllCmd.SetVal(stored)
+				mocks.On("HGetAll", context.Background(), key).Return(hGetAllCmd)
+				mocks.On("Eval", context.Background(), useRefreshTokenScript, []string{key}).Return(evalResult(2))
+			},
+			expectedSuccess: false,
+			expectedError:   erro.ErrorRefreshTokenReused,
+			expectData:      true,
+		},
+		{
+			name: "Unknown Token",
+			mockSetup: func(mocks *MockRedisClient, key string) {
+				hGetAllCmd := redis.NewMapStringStringCmd(context.Background())
+				hGetAllCmd.SetVal(map[string]string{})
+				mocks.On("HGetAll", context.Background(), key).Return(hGetAllCmd)
+			},
+			expectedSuccess: false,
+			expectedError:   erro.ErrorInvalidRefreshToken,
+		},
+		{
+			name: "HGetAll Error",
+			mockSetup: func(mocks *MockRedisClient, key string) {
+				hGetAllCmd := redis.NewMapStringStringCmd(context.Background())
+				hGetAllCmd.SetErr(errors.New("hgetall error"))
+				mocks.On("HGetAll", context.Background(), key).Return(hGetAllCmd)
+			},
+			expectedSuccess: false,
+			expectedError:   erro.ErrorGetRefreshToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRedisClient := new(MockRedisClient)
+			repo := &AuthRedis{Client: mockRedisClient}
+			tc.mockSetup(mockRedisClient, refreshTokenPrefix+tokenHash)
+
+			response := repo.UseRefreshToken(context.Background(), tokenHash)
+
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+			if tc.expectData {
+				data, ok := response.Data.(RedisTokenResponseData)
+				assert.True(t, ok, "Data должен быть типа RedisTokenResponseData")
+				assert.Equal(t, userID, data.UserID, "UserID должен совпадать")
+				assert.Equal(t, "family-id", data.FamilyID, "FamilyID должен совпадать")
+			}
+			mockRedisClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthRedis_RevokeTokenFamily(t *testing.T) {
+	mockRedisClient := new(MockRedisClient)
+	repo := &AuthRedis{Client: mockRedisClient}
+	familyKey := tokenFamilyPrefix + "family-id"
+
+	membersCmd := redis.NewStringSliceCmd(context.Background())
+	membersCmd.SetVal([]string{"refresh:a", "session-b"})
+	mockRedisClient.On("SMembers", context.Background(), familyKey).Return(membersCmd)
+	delCmd := redis.NewIntCmd(context.Background())
+	delCmd.SetVal(3)
+	mockRedisClient.On("Del", context.Background(), "refresh:a", "session-b", familyKey).Return(delCmd)
+
+	response := repo.RevokeTokenFamily(context.Background(), "family-id")
+
+	assert.True(t, response.Success, "Success должен совпадать")
+	mockRedisClient.AssertExpectations(t)
+}
+
+func TestAuthRedis_SetRefreshTokenIndexesFamily(t *testing.T) {
+	mockRedisClient := new(MockRedisClient)
+	repo := &AuthRedis{Client: mockRedisClient}
+	userID := uuid.New()
+	token := model.RefreshToken{TokenHash: "token-hash", UserID: userID, FamilyID: "family-id", ExpirationTime: time.Now().Add(time.Hour)}
+	key := refreshTokenPrefix + token.TokenHash
+	familyKey := tokenFamilyPrefix + token.FamilyID
+	userKey := userTokenFamiliesPrefix + userID.String()
+	intCmd := redis.NewIntCmd(context.Background())
+	boolCmd := redis.NewBoolCmd(context.Background())
+	boolCmd.SetVal(true)
+
+	mockRedisClient.On("HSet", context.Background(), key, mock.Anything).Return(intCmd)
+	mockRedisClient.On("Expire", context.Background(), key, time.Hour).Return(boolCmd)
+	mockRedisClient.On("SAdd", context.Background(), familyKey, key).Return(intCmd)
+	mockRedisClient.On("Expire", context.Background(), familyKey, time.Hour).Return(boolCmd)
+	mockRedisClient.On("SAdd", context.Background(), userKey, token.FamilyID).Return(intCmd)
+	mockRedisClient.On("Expire", context.Background(), userKey, time.Hour).Return(boolCmd)
+
+	response := repo.SetRefreshToken(context.Background(), token, time.Hour)
+	assert.True(t, response.Success, "Success должен совпадать")
+
+	membersCmd := redis.NewStringSliceCmd(context.Background())
+	membersCmd.SetVal([]string{token.FamilyID})
+	mockRedisClient.On("SMembers", context.Background(), userKey).Return(membersCmd)
+	families := repo.GetUserTokenFamilies(context.Background(), userID)
+	assert.True(t, families.Success, "Success должен совпадать")
+	assert.Equal(t, RedisTokenFamiliesResponseData{FamilyIDs: []string{token.FamilyID}}, families.Data, "Семейство должно находиться по пользователю")
+	mockRedisClient.AssertExpectations(t)
+}
@@ -12,9 +12,15 @@ import (
 
 //go:generate mockgen -source=repository.go -destination=mocks/mock.go
 type DBAuthenticateRepos interface {
-	CreateUser(ctx context.Context, user *model.Person) *RepositoryResponse
-	GetUser(ctx context.Context, useremail, password string) *RepositoryResponse
-	DeleteUser(ctx context.Context, userId uuid.UUID, password string) *RepositoryResponse
+	CreateUser(ctx context.Context, tx *sql.Tx, user *model.Person) *RepositoryResponse
+	GetUser(ctx context.Context, useremail string) *RepositoryResponse
+	DeleteUser(ctx context.Context, tx *sql.Tx, userId uuid.UUID) *RepositoryResponse
+	GetUserByID(ctx context.Context, userId uuid.UUID) *RepositoryResponse
+	SetEmailVerified(ctx context.Context, tx *sql.Tx, userId uuid.UUID) *RepositoryResponse
+	GetUserByEmail(ctx context.Context, useremail string) *RepositoryResponse
+	UpdatePassword(ctx context.Context, tx *sql.Tx, userId uuid.UUID, passwordHash string) *RepositoryResponse
+	GetPasswordHash(ctx context.Context, userId uuid.UUID) *RepositoryResponse
+	UpdateProfile(ctx context.Context, tx *sql.Tx, userId uuid.UUID, name, email string) *RepositoryResponse
 	BeginTx(ctx context.Context) (*sql.Tx, error)
 	RollbackTx(ctx context.Context, tx *sql.Tx) error
 	CommitTx(ctx context.Context, tx *sql.Tx) error
@@ -22,11 +28,83 @@ type DBAuthenticateRepos interface {
 type RedisSessionRepos interface {
 	SetSession(ctx context.Context, session model.Session, expiration time.Duration) *RepositoryResponse
 	GetSession(ctx context.Context, sessionID string) *RepositoryResponse
-	DeleteSession(ctx context.Context, sessionID string) *RepositoryResponse
+	DeleteSession(ctx context.Context, userID uuid.UUID, sessionID string) *RepositoryResponse
+	GetUserSessions(ctx context.Context, userID uuid.UUID) *RepositoryResponse
+}
+type RedisTokenRepos interface {
+	SetRefreshToken(ctx context.Context, token model.RefreshToken, expiration time.Duration) *RepositoryResponse
+	UseRefreshToken(ctx context.Context, tokenHash string) *RepositoryResponse
+	AddToTokenFamily(ctx context.Context, familyID string, sessionID string, expiration time.Duration) *RepositoryResponse
+	RevokeTokenFamily(ctx context.Context, familyID string) *RepositoryResponse
+	GetUserTokenFamilies(ctx context.Context, userID uuid.UUID) *RepositoryResponse
+}
+type RedisLoginAttemptRepos interface {
+	RegisterLoginFailure(ctx context.Context, subject string, window time.Duration) *RepositoryResponse
+	ResetLoginFailures(ctx context.Context, subject string) *RepositoryResponse
+	LockLogin(ctx context.Context, subject string, duration time.Duration) *RepositoryResponse
+	GetLoginLock(ctx context.Context, subject string) *RepositoryResponse
+}
+type RedisOneTimeTokenRepos interface {
+	SetOneTimeToken(ctx context.Context, purpose, tokenHash string, userID uuid.UUID, subject string, expiration time.Duration) *RepositoryResponse
+	ConsumeOneTimeToken(ctx context.Context, purpose, tokenHash string) *RepositoryResponse
+	PeekOneTimeToken(ctx context.Context, purpose, tokenHash string) *RepositoryResponse
+}
+type RedisRateLimitRepos interface {
+	TakeToken(ctx context.Context, key string, capacity int, interval time.Duration) *RepositoryResponse
+	CountInWindow(ctx context.Context, key string, limit int, window time.Duration) *RepositoryResponse
+}
+type DBMFARepos interface {
+	GetTOTP(ctx context.Context, userId uuid.UUID) *RepositoryResponse
+	SaveTOTPSecret(ctx context.Context, userId uuid.UUID, secret string) *RepositoryResponse
+	EnableTOTP(ctx context.Context, tx *sql.Tx, userId uuid.UUID, step int64) *RepositoryResponse
+	UseTOTPStep(ctx context.Context, userId uuid.UUID, step int64) *RepositoryResponse
+	DisableTOTP(ctx context.Context, tx *sql.Tx, userId uuid.UUID) *RepositoryResponse
+	ReplaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userId uuid.UUID, codeHashes []string) *RepositoryResponse
+	UseRecoveryCode(ctx context.Context, userId uuid.UUID, codeHash string) *RepositoryResponse
+}
+type DBPasskeyRepos interface {
+	AddPasskey(ctx context.Context, credential model.PasskeyCredential) *RepositoryResponse
+	GetPasskey(ctx context.Context, credentialID []byte) *RepositoryResponse
+	GetUserPasskeys(ctx context.Context, userId uuid.UUID) *RepositoryResponse
+	UsePasskey(ctx context.Context, credentialID []byte, signCount uint32) *RepositoryResponse
+}
+type DBIdentityRepos interface {
+	AddIdentity(ctx context.Context, tx *sql.Tx, identity model.UserIdentity) *RepositoryResponse
+	GetIdentity(ctx context.Context, provider, subject string) *RepositoryResponse
+}
+type RedisOAuthStateRepos interface {
+	SetOAuthState(ctx context.Context, stateHash string, state model.OAuthState, expiration time.Duration) *RepositoryResponse
+	ConsumeOAuthState(ctx context.Context, stateHash string) *RepositoryResponse
+}
+type DBOAuthClientRepos interface {
+	CreateClient(ctx context.Context, client model.OAuthClient) *RepositoryResponse
+	GetClient(ctx context.Context, clientID string) *RepositoryResponse
+}
+type RedisAuthCodeRepos interface {
+	SetAuthorizationCode(ctx context.Context, codeHash string, code model.AuthorizationCode, expiration time.Duration) *RepositoryResponse
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) *RepositoryResponse
+}
+type DBOutboxRepos interface {
+	AddOutboxEvent(ctx context.Context, tx *sql.Tx, event model.OutboxEvent) *RepositoryResponse
+	ClaimPendingEvents(ctx context.Context, limit int, lease time.Duration) *RepositoryResponse
+	MarkEventSent(ctx context.Context, eventID int64) *RepositoryResponse
+	MarkEventFailed(ctx context.Context, eventID int64, nextAttempt time.Time, lastError string) *RepositoryResponse
+	DeleteSentEvents(ctx context.Context, sentBefore time.Time, limit int) *RepositoryResponse
 }
 type Repository struct {
 	DBAuthenticateRepos
 	RedisSessionRepos
+	RedisTokenRepos
+	RedisLoginAttemptRepos
+	RedisRateLimitRepos
+	RedisOneTimeTokenRepos
+	DBMFARepos
+	DBPasskeyRepos
+	DBIdentityRepos
+	RedisOAuthStateRepos
+	DBOAuthClientRepos
+	RedisAuthCodeRepos
+	DBOutboxRepos
 }
 type RepositoryResponse struct {
 	Success bool
@@ -38,15 +116,110 @@ type DBRepositoryResponseData struct {
 	UserId uuid.UUID
 }
 
+type DBPasswordResponseData struct {
+	UserId       uuid.UUID
+	PasswordHash string
+}
+
+type DBUserResponseData struct {
+	UserId        uuid.UUID
+	Name          string
+	Email         string
+	EmailVerified bool
+}
+
+type DBMFAResponseData struct {
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64
+}
+
+type DBPasskeyResponseData struct {
+	Passkey model.PasskeyCredential
+}
+
+type DBPasskeyListResponseData struct {
+	Passkeys []model.PasskeyCredential
+}
+
+type DBIdentityResponseData struct {
+	Identity model.UserIdentity
+}
+
+type DBOAuthClientResponseData struct {
+	Client model.OAuthClient
+}
+
+type DBOutboxResponseData struct {
+	Events []model.OutboxEvent
+	// Deleted is the number of rows removed by DeleteSentEvents.
+	Deleted int64
+}
+
 type RedisRepositoryResponseData struct {
 	SessionId      string
 	ExpirationTime time.Time
 	UserID         uuid.UUID
+	FamilyID       string
+	CreatedAt      time.Time
+	// Renewed is set by GetSession when it extended the session.
+	Renewed bool
+}
+
+type RedisSessionListResponseData struct {
+	Sessions []RedisRepositoryResponseData
+}
+
+type RedisTokenFamiliesResponseData struct {
+	FamilyIDs []string
+}
+
+type RedisLoginResponseData struct {
+	Failures  int
+	LockedFor time.Duration
+}
+
+type RedisRateLimitResponseData struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+type RedisOneTimeTokenResponseData struct {
+	UserID uuid.UUID
+	// Subject is what the token was issued for, e.g. the email a verification link was sent to.
+	Subject string
+}
+
+type RedisOAuthStateResponseData struct {
+	State model.OAuthState
+}
+
+type RedisAuthCodeResponseData struct {
+	Code model.AuthorizationCode
+}
+
+type RedisTokenResponseData struct {
+	UserID         uuid.UUID
+	FamilyID       string
+	ExpirationTime time.Time
 }
 
 func NewRepository(db *sql.DB, client *redis.Client) *Repository {
 	return &Repository{
-		DBAuthenticateRepos: NewAuthPostgres(db),
-		RedisSessionRepos:   NewAuthRedis(client),
+		DBAuthenticateRepos:    NewAuthPostgres(db),
+		RedisSessionRepos:      NewAuthRedis(client),
+		RedisTokenRepos:        NewAuthRedis(client),
+		RedisLoginAttemptRepos: NewAuthRedis(client),
+		RedisRateLimitRepos:    NewAuthRedis(client),
+		RedisOneTimeTokenRepos: NewAuthRedis(client),
+		DBMFARepos:             NewMFAPostgres(db),
+		DBPasskeyRepos:         NewPasskeyPostgres(db),
+		DBIdentityRepos:        NewIdentityPostgres(db),
+		RedisOAuthStateRepos:   NewAuthRedis(client),
+		DBOAuthClientRepos:     NewOAuthClientPostgres(db),
+		RedisAuthCodeRepos:     NewAuthRedis(client),
+		DBOutboxRepos:          NewOutboxPostgres(db),
 	}
 }
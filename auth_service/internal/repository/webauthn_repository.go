@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type PasskeyPostgres struct {
+	Db *sql.DB
+}
+
+func (repopp *PasskeyPostgres) AddPasskey(ctx context.Context, credential model.PasskeyCredential) *RepositoryResponse {
+	result, err := repopp.Db.ExecContext(ctx,
+		`INSERT INTO WebAuthnCredentials (credential_id, userid, public_key, sign_count, transports, name)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (credential_id) DO NOTHING`,
+		credential.ID, credential.UserID, credential.PublicKey, int64(credential.SignCount), strings.Join(credential.Transports, ","), credential.Name)
+	if err != nil {
+		log.Printf("AddPasskey Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorPasskeyExists}
+	}
+	return &RepositoryResponse{Success: true, Data: DBRepositoryResponseData{UserId: credential.UserID}}
+}
+
+func (repopp *PasskeyPostgres) GetPasskey(ctx context.Context, credentialID []byte) *RepositoryResponse {
+	row := repopp.Db.QueryRowContext(ctx,
+		"SELECT credential_id, userid, public_key, sign_count, transports, name, created_at, last_used_at FROM WebAuthnCredentials WHERE credential_id = $1",
+		credentialID)
+	credential, err := scanPasskey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorPasskeyNotFound}
+		}
+		log.Printf("GetPasskey Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	return &RepositoryResponse{Success: true, Data: DBPasskeyResponseData{Passkey: credential}}
+}
+
+func (repopp *PasskeyPostgres) GetUserPasskeys(ctx context.Context, userId uuid.UUID) *RepositoryResponse {
+	rows, err := repopp.Db.QueryContext(ctx,
+		"SELECT credential_id, userid, public_key, sign_count, transports, name, created_at, last_used_at FROM WebAuthnCredentials WHERE userid = $1 ORDER BY created_at",
+		userId)
+	if err != nil {
+		log.Printf("GetUserPasskeys Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	defer rows.Close()
+
+	var passkeys []model.PasskeyCredential
+	for rows.Next() {
+		credential, err := scanPasskey(rows)
+		if err != nil {
+			log.Printf("GetUserPasskeys Scan Error: %v", err)
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+		}
+		passkeys = append(passkeys, credential)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("GetUserPasskeys Rows Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	return &RepositoryResponse{Success: true, Data: DBPasskeyListResponseData{Passkeys: passkeys}}
+}
+
+// UsePasskey stores the signature counter of a successful login. The counter may only grow
+// (authenticators without one always send zero), so of two logins replaying the same
+// assertion only one gets through.
+func (repopp *PasskeyPostgres) UsePasskey(ctx context.Context, credentialID []byte, signCount uint32) *RepositoryResponse {
+	result, err := repopp.Db.ExecContext(ctx,
+		"UPDATE WebAuthnCredentials SET sign_count = $2, last_used_at = now() WHERE credential_id = $1 AND (sign_count < $2 OR $2 = 0)",
+		credentialID, int64(signCount))
+	if err != nil {
+		log.Printf("UsePasskey Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInternalServer}
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorInvalidPasskey}
+	}
+	return &RepositoryResponse{Success: true}
+}
+
+type passkeyScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPasskey(row passkeyScanner) (model.PasskeyCredential, error) {
+	var credential model.PasskeyCredential
+	var signCount int64
+	var transports string
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&credential.ID, &credential.UserID, &credential.PublicKey, &signCount, &transports, &credential.Name, &credential.CreatedAt, &lastUsedAt)
+	if err != nil {
+		return model.PasskeyCredential{}, err
+	}
+	credential.SignCount = uint32(signCount)
+	if transports != "" {
+		credential.Transports = strings.Split(transports, ",")
+	}
+	if lastUsedAt.Valid {
+		credential.LastUsedAt = &lastUsedAt.Time
+	}
+	return credential, nil
+}
+
+func NewPasskeyPostgres(db *sql.DB) *PasskeyPostgres {
+	return &PasskeyPostgres{Db: db}
+}
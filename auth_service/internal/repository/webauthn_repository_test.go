@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var passkeyColumns = []string{"credential_id", "userid", "public_key", "sign_count", "transports", "name", "created_at", "last_used_at"}
+
+func TestPasskeyPostgres_AddPasskey(t *testing.T) {
+	type testCase struct {
+		name            string
+		rowsAffected    int64
+		expectedSuccess bool
+		expectedError   error
+	}
+
+	testCases := []testCase{
+		{name: "New Passkey", rowsAffected: 1, expectedSuccess: true},
+		{name: "Already Registered", rowsAffected: 0, expectedSuccess: false, expectedError: erro.ErrorPasskeyExists},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			credential := model.PasskeyCredential{ID: []byte("credential"), UserID: uuid.New(), PublicKey: []byte("key"), SignCount: 3, Transports: []string{"internal", "hybrid"}, Name: "laptop"}
+			mock.ExpectExec("INSERT INTO WebAuthnCredentials").
+				WithArgs(credential.ID, credential.UserID, credential.PublicKey, int64(3), "internal,hybrid", "laptop").
+				WillReturnResult(sqlmock.NewResult(0, tc.rowsAffected))
+
+			response := NewPasskeyPostgres(db).AddPasskey(context.Background(), credential)
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPasskeyPostgres_GetPasskey(t *testing.T) {
+	t.Run("Found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		userID := uuid.New()
+		createdAt := time.Now()
+		mock.ExpectQuery("SELECT credential_id, userid, public_key, sign_count, transports, name, created_at, last_used_at FROM WebAuthnCredentials").
+			WithArgs([]byte("credential")).
+			WillReturnRows(sqlmock.NewRows(passkeyColumns).AddRow([]byte("credential"), userID, []byte("key"), int64(7), "internal", "laptop", createdAt, nil))
+
+		response := NewPasskeyPostgres(db).GetPasskey(context.Background(), []byte("credential"))
+		assert.True(t, response.Success, "Success должен быть true")
+		expected := model.PasskeyCredential{ID: []byte("credential"), UserID: userID, PublicKey: []byte("key"), SignCount: 7, Transports: []string{"internal"}, Name: "laptop", CreatedAt: createdAt}
+		assert.Equal(t, DBPasskeyResponseData{Passkey: expected}, response.Data, "Data должен совпадать")
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("Not Found", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT credential_id, userid, public_key, sign_count, transports, name, created_at, last_used_at FROM WebAuthnCredentials").
+			WithArgs([]byte("credential")).
+			WillReturnError(sql.ErrNoRows)
+
+		response := NewPasskeyPostgres(db).GetPasskey(context.Background(), []byte("credential"))
+		assert.False(t, response.Success, "Success должен быть false")
+		assert.Equal(t, erro.ErrorPasskeyNotFound, response.Errors, "Тип ошибки должен совпадать")
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
+
+func TestPasskeyPostgres_UsePasskey(t *testing.T) {
+	type testCase struct {
+		name            string
+		rowsAffected    int64
+		expectedSuccess bool
+		expectedError   error
+	}
+
+	testCases := []testCase{
+		{name: "Counter Grows", rowsAffected: 1, expectedSuccess: true},
+		{name: "Counter Replayed", rowsAffected: 0, expectedSuccess: false, expectedError: erro.ErrorInvalidPasskey},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectExec("UPDATE WebAuthnCredentials SET sign_count").
+				WithArgs([]byte("credential"), int64(8)).
+				WillReturnResult(sqlmock.NewResult(0, tc.rowsAffected))
+
+			response := NewPasskeyPostgres(db).UsePasskey(context.Background(), []byte("credential"), 8)
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
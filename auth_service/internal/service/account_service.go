@@ -0,0 +1,146 @@
+package service
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"database/sql"
+	"log"
+	"shared/events"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ChangePassword replaces the password after checking the current one. Every other session of
+// the user is revoked, the session the change was made from stays logged in.
+func (as *AuthService) ChangePassword(ctx context.Context, sessionID string, userID uuid.UUID, currentPassword, newPassword string) *ServiceResponse {
+	changeMap := personFieldErrors(as.validator.StructPartial(&model.Person{Password: newPassword}, "Password"))
+	if changeMap != nil {
+		return &ServiceResponse{Success: false, Errors: changeMap}
+	}
+	changeMap = make(map[string]error)
+	if currentPassword == newPassword {
+		changeMap["Password"] = erro.ErrorSamePassword
+		return &ServiceResponse{Success: false, Errors: changeMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("ChangePassword: Context cancelled before checkPassword: %v", ctx.Err())
+		changeMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: changeMap}
+	}
+	if err := as.checkPassword(ctx, userID, currentPassword); err != nil {
+		log.Printf("Error when checking the password of %s: %v", userID, err)
+		changeMap["CurrentPassword"] = err
+		return &ServiceResponse{Success: false, Errors: changeMap}
+	}
+	if err := as.changePassword(ctx, userID, newPassword, sessionID); err != nil {
+		changeMap["ChangePasswordError"] = err
+		return &ServiceResponse{Success: false, Errors: changeMap}
+	}
+	log.Printf("Password of %s was changed", userID)
+	return &ServiceResponse{Success: true, UserId: userID}
+}
+
+// UpdateProfile changes the name and/or the email, nil leaves a field as it is. A new email is
+// unverified until the link sent to it is opened.
+func (as *AuthService) UpdateProfile(ctx context.Context, userID uuid.UUID, name, email *string) (response *ServiceResponse) {
+	updateMap := make(map[string]error)
+	if name == nil && email == nil {
+		updateMap["Profile"] = erro.ErrorNothingToUpdate
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	current, err := as.userByID(ctx, userID)
+	if err != nil {
+		log.Printf("Error when getting the user %s: %v", userID, err)
+		updateMap["GetUserError"] = err
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	person := model.Person{Name: current.Name, Email: current.Email}
+	var fields []string
+	emailChanged := false
+	if name != nil && strings.TrimSpace(*name) != current.Name {
+		person.Name = strings.TrimSpace(*name)
+		fields = append(fields, events.UserFieldName)
+	}
+	if email != nil && strings.TrimSpace(*email) != current.Email {
+		person.Email = strings.TrimSpace(*email)
+		fields = append(fields, events.UserFieldEmail)
+		emailChanged = true
+	}
+	if validateMap := personFieldErrors(as.validator.StructPartial(&person, "Name", "Email")); validateMap != nil {
+		return &ServiceResponse{Success: false, Errors: validateMap}
+	}
+	if len(fields) == 0 {
+		return &ServiceResponse{Success: true, UserId: userID, Profile: profileOf(current)}
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("UpdateProfile: Context cancelled before BeginTx: %v", ctx.Err())
+		updateMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	var tx *sql.Tx
+	tx, err = as.dbrepo.BeginTx(ctx)
+	if err != nil {
+		log.Printf("TransactionError %v", err)
+		updateMap["TransactionError"] = erro.ErrorStartTransaction
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	defer func() {
+		if !response.Success && tx != nil {
+			if rErr := as.dbrepo.RollbackTx(ctx, tx); rErr != nil {
+				log.Printf("Error rolling back transaction: %v", rErr)
+			}
+		}
+	}()
+	updateResponse := as.dbrepo.UpdateProfile(ctx, tx, userID, person.Name, person.Email)
+	if !updateResponse.Success {
+		log.Printf("Error when updating the profile of %s: %v", userID, updateResponse.Errors)
+		updateMap["UpdateError"] = updateResponse.Errors
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	updated, ok := updateResponse.Data.(repository.DBUserResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", updateResponse.Data)
+		updateMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	payload := &events.UserUpdated{
+		UserID:        userID,
+		Name:          updated.Name,
+		Email:         updated.Email,
+		EmailVerified: updated.EmailVerified,
+		Fields:        fields,
+	}
+	outboxEvent, err := newOutboxEvent(as.topics.UserUpdated, userID, payload)
+	if err != nil {
+		log.Printf("Error building %s event: %v", events.TypeUserUpdated, err)
+		updateMap["EventError"] = erro.ErrorMarshal
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, tx, outboxEvent)
+	if !outboxResponse.Success {
+		log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+		updateMap["OutboxError"] = outboxResponse.Errors
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	if emailChanged {
+		if err := as.requestVerification(ctx, tx, userID, updated.Email); err != nil {
+			updateMap["VerificationError"] = err
+			return &ServiceResponse{Success: false, Errors: updateMap}
+		}
+	}
+	if err := as.dbrepo.CommitTx(ctx, tx); err != nil {
+		log.Printf("Transaction commit error: %v", err)
+		updateMap["CommitError"] = erro.ErrorCommitTransaction
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	log.Printf("Profile of %s was updated: %s", userID, strings.Join(fields, ", "))
+	return &ServiceResponse{Success: true, UserId: userID, Profile: profileOf(updated)}
+}
+
+func profileOf(user repository.DBUserResponseData) *model.Profile {
+	return &model.Profile{Id: user.UserId, Name: user.Name, Email: user.Email, EmailVerified: user.EmailVerified}
+}
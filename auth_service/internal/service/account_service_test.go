@@ -0,0 +1,106 @@
+package service
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"shared/events"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	userID := uuid.New()
+	db := &fakeDBRepo{userID: userID, password: "password123"}
+	redisRepo := &fakeRedisRepo{}
+	tokens := newFakeTokenRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: db, RedisSessionRepos: redisRepo, RedisTokenRepos: tokens, DBOutboxRepos: outbox}})
+	ctx := context.Background()
+	person := &model.Person{Email: "tester@example.com", Password: "password123"}
+
+	current := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, current.Success)
+	other := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, other.Success)
+	mobile := as.IssueTokens(ctx, person, "192.0.2.1")
+	require.True(t, mobile.Success)
+	outbox.events = nil
+	stored := db.passwordHash
+
+	short := as.ChangePassword(ctx, current.SessionId, userID, "password123", "short")
+	assert.False(t, short.Success, "Короткий пароль должен отклоняться")
+	assert.NotNil(t, short.Errors["Password"], "Ошибка должна относиться к паролю")
+
+	same := as.ChangePassword(ctx, current.SessionId, userID, "password123", "password123")
+	assert.Equal(t, erro.ErrorSamePassword, same.Errors["Password"], "Тип ошибки должен совпадать")
+
+	wrong := as.ChangePassword(ctx, current.SessionId, userID, "wrongpassword", "newpassword123")
+	assert.False(t, wrong.Success, "Без верного текущего пароля смена невозможна")
+	assert.Equal(t, erro.ErrorInvalidPassword, wrong.Errors["CurrentPassword"], "Тип ошибки должен совпадать")
+	assert.Equal(t, stored, db.passwordHash, "Пароль не должен меняться")
+
+	changed := as.ChangePassword(ctx, current.SessionId, userID, "password123", "newpassword123")
+	require.True(t, changed.Success, "Смена пароля должна пройти успешно: %v", changed.Errors)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(db.passwordHash), []byte("newpassword123")), "Новый пароль должен сохраняться в виде bcrypt-хеша")
+	assert.Contains(t, redisRepo.sessions, current.SessionId, "Текущая сессия должна сохраниться")
+	assert.NotContains(t, redisRepo.sessions, other.SessionId, "Остальные сессии должны быть отозваны")
+	assert.False(t, as.RefreshTokens(ctx, mobile.RefreshToken).Success, "Refresh token должен быть отозван")
+	require.Len(t, outbox.events, 1, "Должно быть опубликовано событие смены пароля")
+	assert.Equal(t, testTopics.UserPasswordChanged, outbox.events[0].Topic)
+}
+
+func TestAuthService_UpdateProfile(t *testing.T) {
+	userID := uuid.New()
+	db := &fakeDBRepo{userID: userID, verified: map[uuid.UUID]bool{userID: true}}
+	onetime := newFakeOneTimeRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: db, RedisOneTimeTokenRepos: onetime, DBOutboxRepos: outbox}})
+	ctx := context.Background()
+	ptr := func(s string) *string { return &s }
+
+	nothing := as.UpdateProfile(ctx, userID, nil, nil)
+	assert.Equal(t, erro.ErrorNothingToUpdate, nothing.Errors["Profile"], "Тип ошибки должен совпадать")
+
+	invalid := as.UpdateProfile(ctx, userID, ptr("ab"), ptr("not-an-email"))
+	assert.False(t, invalid.Success, "Success должен совпадать")
+	assert.NotNil(t, invalid.Errors["Name"], "Имя должно проверяться правилами model.Person")
+	assert.Equal(t, erro.ErrorNotEmail, invalid.Errors["Email"], "Тип ошибки должен совпадать")
+
+	unchanged := as.UpdateProfile(ctx, userID, ptr("tester"), nil)
+	require.True(t, unchanged.Success)
+	assert.Empty(t, outbox.events, "Без изменений событие не публикуется")
+
+	renamed := as.UpdateProfile(ctx, userID, ptr(" new name "), nil)
+	require.True(t, renamed.Success, "Смена имени должна пройти успешно: %v", renamed.Errors)
+	assert.Equal(t, "new name", renamed.Profile.Name, "Имя должно совпадать")
+	assert.True(t, renamed.Profile.EmailVerified, "Смена имени не сбрасывает подтверждение email")
+	require.Len(t, outbox.events, 1, "Должно быть опубликовано событие изменения профиля")
+	assert.Equal(t, testTopics.UserUpdated, outbox.events[0].Topic)
+	_, payload, err := events.Unmarshal(outbox.events[0].Payload)
+	require.NoError(t, err)
+	assert.Equal(t, []string{events.UserFieldName}, payload.(*events.UserUpdated).Fields)
+	assert.Empty(t, onetime.tokens, "Смена имени не требует подтверждения")
+
+	taken := as.UpdateProfile(ctx, userID, nil, ptr("taken@example.com"))
+	assert.Equal(t, erro.ErrorUniqueEmail, taken.Errors["UpdateError"], "Тип ошибки должен совпадать")
+
+	outbox.events = nil
+	moved := as.UpdateProfile(ctx, userID, nil, ptr("new@example.com"))
+	require.True(t, moved.Success, "Смена email должна пройти успешно: %v", moved.Errors)
+	assert.Equal(t, "new@example.com", moved.Profile.Email, "Email должен совпадать")
+	assert.False(t, moved.Profile.EmailVerified, "Новый email должен требовать подтверждения")
+	require.Len(t, outbox.events, 2, "Должны быть опубликованы события изменения профиля и подтверждения email")
+	assert.Equal(t, testTopics.UserUpdated, outbox.events[0].Topic)
+	assert.Equal(t, testTopics.UserVerificationRequested, outbox.events[1].Topic)
+	_, payload, err = events.Unmarshal(outbox.events[1].Payload)
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", payload.(*events.UserVerificationRequested).Email, "Ссылка должна уходить на новый email")
+	assert.Len(t, onetime.tokens, 1, "Должен быть выпущен токен подтверждения")
+}
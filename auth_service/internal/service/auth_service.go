@@ -1,37 +1,111 @@
 package service
 
 import (
+	"auth_service/configs"
 	"auth_service/internal/erro"
-	"auth_service/internal/kafka"
+	"auth_service/internal/identity"
 	"auth_service/internal/model"
 	"auth_service/internal/repository"
+	"auth_service/internal/webauthn"
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
+	"shared/events"
 	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
+const producerName = "auth_service"
+
 type AuthService struct {
-	dbrepo        repository.DBAuthenticateRepos
-	redisrepo     repository.RedisSessionRepos
-	kafkaProducer kafka.KafkaProducer
-	validator     *validator.Validate
+	dbrepo         repository.DBAuthenticateRepos
+	redisrepo      repository.RedisSessionRepos
+	tokenrepo      repository.RedisTokenRepos
+	loginrepo      repository.RedisLoginAttemptRepos
+	onetimerepo    repository.RedisOneTimeTokenRepos
+	mfarepo        repository.DBMFARepos
+	passkeyrepo    repository.DBPasskeyRepos
+	identityrepo   repository.DBIdentityRepos
+	oauthrepo      repository.RedisOAuthStateRepos
+	clientrepo     repository.DBOAuthClientRepos
+	authcoderepo   repository.RedisAuthCodeRepos
+	outboxrepo     repository.DBOutboxRepos
+	topics         events.Topics
+	tokens         configs.TokenConfig
+	login          configs.LoginConfig
+	verification   configs.VerificationConfig
+	passwordReset  configs.PasswordResetConfig
+	mfa            configs.MFAConfig
+	passkeys       configs.WebAuthnConfig
+	relyingParty   webauthn.RelyingParty
+	socialLogin    configs.SocialLoginConfig
+	providers      map[string]identity.Provider
+	oidcProvider   configs.OIDCProviderConfig
+	verifiedRoutes map[string]bool
+	passwords      PasswordHasher
+	keys           AccessTokenKeys
+	validator      *validator.Validate
 }
 
-func NewAuthService(repo repository.DBAuthenticateRepos, redis repository.RedisSessionRepos, kafkaProd kafka.KafkaProducer) *AuthService {
-	validator := validator.New()
-	return &AuthService{dbrepo: repo, validator: validator, redisrepo: redis, kafkaProducer: kafkaProd}
+// AuthServiceConfig holds the settings of AuthService; zero values fall back to defaults.
+type AuthServiceConfig struct {
+	Topics        events.Topics
+	Tokens        configs.TokenConfig
+	Login         configs.LoginConfig
+	Verification  configs.VerificationConfig
+	PasswordReset configs.PasswordResetConfig
+	MFA           configs.MFAConfig
+	WebAuthn      configs.WebAuthnConfig
+	SocialLogin   configs.SocialLoginConfig
+	OIDCProvider  configs.OIDCProviderConfig
 }
 
-type UserRegistrateEvent struct {
-	UserID     uuid.UUID `json:"user_id"`
-	LastUpdate time.Time `json:"last_update"`
+func NewAuthService(repos *repository.Repository, config AuthServiceConfig, passwords PasswordHasher, keys AccessTokenKeys) *AuthService {
+	tokens := config.Tokens
+	if tokens.AccessTokenTTL <= 0 {
+		tokens.AccessTokenTTL = 15 * time.Minute
+	}
+	if tokens.RefreshTokenTTL <= 0 {
+		tokens.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+	verifiedRoutes := make(map[string]bool, len(config.Verification.Routes))
+	for _, route := range config.Verification.Routes {
+		verifiedRoutes[route] = true
+	}
+	webauthnConfig := withWebAuthnDefaults(config.WebAuthn)
+	socialLogin := withSocialLoginDefaults(config.SocialLogin)
+	return &AuthService{
+		dbrepo:         repos.DBAuthenticateRepos,
+		redisrepo:      repos.RedisSessionRepos,
+		tokenrepo:      repos.RedisTokenRepos,
+		loginrepo:      repos.RedisLoginAttemptRepos,
+		onetimerepo:    repos.RedisOneTimeTokenRepos,
+		mfarepo:        repos.DBMFARepos,
+		passkeyrepo:    repos.DBPasskeyRepos,
+		identityrepo:   repos.DBIdentityRepos,
+		oauthrepo:      repos.RedisOAuthStateRepos,
+		clientrepo:     repos.DBOAuthClientRepos,
+		authcoderepo:   repos.RedisAuthCodeRepos,
+		outboxrepo:     repos.DBOutboxRepos,
+		topics:         config.Topics,
+		tokens:         tokens,
+		login:          withLoginDefaults(config.Login),
+		verification:   withVerificationDefaults(config.Verification),
+		passwordReset:  withPasswordResetDefaults(config.PasswordReset),
+		mfa:            withMFADefaults(config.MFA),
+		passkeys:       webauthnConfig,
+		relyingParty:   newRelyingParty(webauthnConfig),
+		socialLogin:    socialLogin,
+		providers:      newIdentityProviders(socialLogin),
+		oidcProvider:   withOIDCProviderDefaults(config.OIDCProvider),
+		verifiedRoutes: verifiedRoutes,
+		passwords:      passwords,
+		keys:           keys,
+		validator:      validator.New(),
+	}
 }
 
 func (as *AuthService) RegistrateAndLogin(ctx context.Context, user *model.Person) *ServiceResponse {
@@ -64,13 +138,13 @@ func (as *AuthService) RegistrateAndLogin(ctx context.Context, user *model.Perso
 		return &ServiceResponse{Success: false, Errors: registrateMap}
 	}
 
-	hashpass, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashpass, err := as.passwords.Hash(user.Password)
 	if err != nil {
 		log.Printf("HashPassError %v", err)
 		registrateMap["HashPassError"] = erro.ErrorHashPass
 		return &ServiceResponse{Success: false, Errors: registrateMap}
 	}
-	user.Password = string(hashpass)
+	user.Password = hashpass
 
 	if ctx.Err() != nil {
 		log.Printf("Context cancelled before CreateUser: %v", ctx.Err())
@@ -81,7 +155,7 @@ func (as *AuthService) RegistrateAndLogin(ctx context.Context, user *model.Perso
 
 	userID := uuid.New()
 	user.Id = userID
-	response := as.dbrepo.CreateUser(ctx, user)
+	response := as.dbrepo.CreateUser(ctx, tx, user)
 
 	if !response.Success {
 		err = response.Errors
@@ -114,6 +188,7 @@ func (as *AuthService) RegistrateAndLogin(ctx context.Context, user *model.Perso
 		SessionID:      sessionID,
 		UserID:         createdUserID,
 		ExpirationTime: expirationTime,
+		CreatedAt:      time.Now(),
 	}
 
 	redisResponse := as.redisrepo.SetSession(ctx, session, duration)
@@ -132,6 +207,26 @@ func (as *AuthService) RegistrateAndLogin(ctx context.Context, user *model.Perso
 		return &ServiceResponse{Success: false, Errors: registrateMap}
 	}
 
+	outboxEvent, err := newOutboxEvent(as.topics.UserRegistered, dbData.UserId, &events.UserRegistered{UserID: dbData.UserId})
+	if err != nil {
+		registrateMap["EventError"] = erro.ErrorMarshal
+		log.Printf("Error building %s event: %v", events.TypeUserRegistered, err)
+		return &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, tx, outboxEvent)
+	if !outboxResponse.Success {
+		err = outboxResponse.Errors
+		log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+		registrateMap["OutboxError"] = outboxResponse.Errors
+		return &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+
+	err = as.requestVerification(ctx, tx, dbData.UserId, user.Email)
+	if err != nil {
+		registrateMap["VerificationError"] = err
+		return &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+
 	if ctx.Err() != nil {
 		log.Printf("Context cancelled before CommitTx: %v", ctx.Err())
 		err = ctx.Err()
@@ -147,23 +242,6 @@ func (as *AuthService) RegistrateAndLogin(ctx context.Context, user *model.Perso
 	}
 
 	log.Println("The session was created successfully and the user is registered!")
-	event := UserRegistrateEvent{
-		UserID:     dbData.UserId,
-		LastUpdate: time.Now(),
-	}
-	eventBytes, errv := json.Marshal(event)
-	if errv != nil {
-		registrateMap["MarshalError"] = erro.ErrorMarshal
-		log.Printf("Error marshaling event to JSON: %v", errv)
-		return &ServiceResponse{Success: false, Errors: registrateMap}
-	}
-	errv = as.kafkaProducer.SendMessage("user-registered-topic", dbData.UserId.String(), eventBytes)
-	if errv != nil {
-		registrateMap["SendMessage"] = erro.ErrorSendKafkaMessage
-		log.Printf("Error marshaling event to JSON: %v", errv)
-		return &ServiceResponse{Success: false, Errors: registrateMap}
-	}
-	log.Println("Messages have been successfully delivered to the broker")
 	return &ServiceResponse{
 		Success:        true,
 		UserId:         dbData.UserId,
@@ -172,12 +250,7 @@ func (as *AuthService) RegistrateAndLogin(ctx context.Context, user *model.Perso
 	}
 }
 
-type UserAuthenticateEvent struct {
-	UserID     uuid.UUID `json:"user_id"`
-	LastUpdate time.Time `json:"last_update"`
-}
-
-func (as *AuthService) AuthenticateAndLogin(ctx context.Context, user *model.Person) *ServiceResponse {
+func (as *AuthService) AuthenticateAndLogin(ctx context.Context, user *model.Person, clientIP string) *ServiceResponse {
 	authenticateMap := make(map[string]error)
 
 	errorvalidate := validatePerson(as, user, false)
@@ -185,40 +258,32 @@ func (as *AuthService) AuthenticateAndLogin(ctx context.Context, user *model.Per
 		log.Printf("Validate error %v", errorvalidate)
 		return &ServiceResponse{Success: false, Errors: errorvalidate}
 	}
-	if ctx.Err() != nil {
-		log.Printf("AuthenticateAndLogin: Context cancelled before GetUser: %v", ctx.Err())
-		authenticateMap["ContextError"] = erro.ErrorContextTimeout
-		return &ServiceResponse{Success: false, Errors: authenticateMap}
-	}
 
-	response := as.dbrepo.GetUser(ctx, user.Email, user.Password)
-	if !response.Success {
-		log.Printf("Failed to authenticate user: %v", response.Errors)
-		authenticateMap["AuthenticateError"] = response.Errors
-		return &ServiceResponse{Success: false, Errors: authenticateMap}
+	userID, failure := as.authenticate(ctx, user.Email, user.Password, clientIP, authenticateMap)
+	if failure != nil {
+		return failure
 	}
-
-	dbData, ok := response.Data.(repository.DBRepositoryResponseData)
-	if !ok {
-		log.Printf("Unexpected data type from repository: %T", response.Data)
-		authenticateMap["UnexpectedData"] = erro.ErrorUnexpectedData
-		return &ServiceResponse{Success: false, Errors: authenticateMap}
+	if challenge := as.requireMFA(ctx, userID, mfaSessionPurpose, authenticateMap); challenge != nil {
+		return challenge
 	}
+	return as.startSession(ctx, userID, authenticateMap)
+}
 
-	userID := dbData.UserId
-
+// startSession creates the cookie session of an authenticated user.
+func (as *AuthService) startSession(ctx context.Context, userID uuid.UUID, authenticateMap map[string]error) *ServiceResponse {
 	sessionID := uuid.New().String()
 	expirationTime := time.Now().Add(24 * time.Hour)
 	session := model.Session{
 		SessionID:      sessionID,
 		UserID:         userID,
 		ExpirationTime: expirationTime,
+		CreatedAt:      time.Now(),
 	}
 
 	duration := time.Until(expirationTime)
 
 	if ctx.Err() != nil {
-		log.Printf("AuthenticateAndLogin: Context cancelled before SetSession: %v", ctx.Err())
+		log.Printf("startSession: Context cancelled before SetSession: %v", ctx.Err())
 		authenticateMap["ContextError"] = erro.ErrorContextTimeout
 		return &ServiceResponse{Success: false, Errors: authenticateMap}
 	}
@@ -238,26 +303,21 @@ func (as *AuthService) AuthenticateAndLogin(ctx context.Context, user *model.Per
 	}
 
 	log.Println("The session was created successfully and the user is authenticated!")
-	event := UserAuthenticateEvent{
-		UserID:     dbData.UserId,
-		LastUpdate: time.Now(),
-	}
-	eventBytes, errv := json.Marshal(event)
+	outboxEvent, errv := newOutboxEvent(as.topics.UserAuthenticate, userID, &events.UserAuthenticated{UserID: userID})
 	if errv != nil {
-		authenticateMap["MarshalError"] = erro.ErrorMarshal
-		log.Printf("Error marshaling event to JSON: %v", errv)
+		authenticateMap["EventError"] = erro.ErrorMarshal
+		log.Printf("Error building %s event: %v", events.TypeUserAuthenticated, errv)
 		return &ServiceResponse{Success: false, Errors: authenticateMap}
 	}
-	errv = as.kafkaProducer.SendMessage("user-authenticate-topic", dbData.UserId.String(), eventBytes)
-	if errv != nil {
-		authenticateMap["SendMessage"] = erro.ErrorSendKafkaMessage
-		log.Printf("Error marshaling event to JSON: %v", errv)
+	outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, nil, outboxEvent)
+	if !outboxResponse.Success {
+		log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+		authenticateMap["OutboxError"] = outboxResponse.Errors
 		return &ServiceResponse{Success: false, Errors: authenticateMap}
 	}
-	log.Println("Messages have been successfully delivered to the broker")
 	return &ServiceResponse{
 		Success:        true,
-		UserId:         dbData.UserId,
+		UserId:         userID,
 		SessionId:      redisData.SessionId,
 		ExpirationTime: redisData.ExpirationTime,
 	}
@@ -292,15 +352,11 @@ func (as *AuthService) Authorization(ctx context.Context, sessionID string) *Ser
 			UserId:         redisData.UserID,
 			SessionId:      redisData.SessionId,
 			ExpirationTime: redisData.ExpirationTime,
+			SessionRenewed: redisData.Renewed,
 		}
 	}
 }
 
-type UserLogoutEvent struct {
-	UserID     uuid.UUID `json:"user_id"`
-	LastUpdate time.Time `json:"last_update"`
-}
-
 func (as *AuthService) Logout(ctx context.Context, sessionID string, userId uuid.UUID) *ServiceResponse {
 	logoutMap := make(map[string]error)
 	select {
@@ -309,41 +365,44 @@ func (as *AuthService) Logout(ctx context.Context, sessionID string, userId uuid
 		logoutMap["ContextError"] = erro.ErrorContextTimeout
 		return &ServiceResponse{Success: false, Errors: logoutMap}
 	default:
-		repoResponse := as.redisrepo.DeleteSession(ctx, sessionID)
-		if !repoResponse.Success {
-			log.Printf("Error during session deletion from Redis: %v", repoResponse.Errors)
-			logoutMap["DelSessionError"] = repoResponse.Errors
+		// The session is looked up through the user's index: it is read without being renewed
+		// and only a session of this user is deleted. Logging out of a token session must also
+		// kill its refresh token, or the client could mint a new one.
+		sessions, err := as.userSessions(ctx, userId)
+		if err != nil {
+			log.Printf("Error when getting the sessions of %s: %v", userId, err)
+			logoutMap["DelSessionError"] = err
 			return &ServiceResponse{Success: false, Errors: logoutMap}
 		}
-		log.Println("The session was successfully accepted and deleted")
-		event := UserAuthenticateEvent{
-			UserID:     userId,
-			LastUpdate: time.Now(),
+		for _, session := range sessions {
+			if session.SessionId != sessionID {
+				continue
+			}
+			if err := as.revokeSessions(ctx, userId, []repository.RedisRepositoryResponseData{session}); err != nil {
+				log.Printf("Error during session deletion from Redis: %v", err)
+				logoutMap["DelSessionError"] = err
+				return &ServiceResponse{Success: false, Errors: logoutMap}
+			}
 		}
-		eventBytes, errv := json.Marshal(event)
+		log.Println("The session was successfully accepted and deleted")
+		outboxEvent, errv := newOutboxEvent(as.topics.UserLoggedOut, userId, &events.UserLoggedOut{UserID: userId})
 		if errv != nil {
-			logoutMap["MarshalError"] = erro.ErrorMarshal
-			log.Printf("Error marshaling event to JSON: %v", errv)
+			logoutMap["EventError"] = erro.ErrorMarshal
+			log.Printf("Error building %s event: %v", events.TypeUserLoggedOut, errv)
 			return &ServiceResponse{Success: false, Errors: logoutMap}
 		}
-		errv = as.kafkaProducer.SendMessage("user-logged-out-topic", userId.String(), eventBytes)
-		if errv != nil {
-			logoutMap["SendMessage"] = erro.ErrorSendKafkaMessage
-			log.Printf("Error marshaling event to JSON: %v", errv)
+		outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, nil, outboxEvent)
+		if !outboxResponse.Success {
+			log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+			logoutMap["OutboxError"] = outboxResponse.Errors
 			return &ServiceResponse{Success: false, Errors: logoutMap}
 		}
-		log.Println("Messages have been successfully delivered to the broker")
 		return &ServiceResponse{
 			Success: true,
 		}
 	}
 }
 
-type UserDeleteEvent struct {
-	UserID     uuid.UUID `json:"user_id"`
-	LastUpdate time.Time `json:"last_update"`
-}
-
 func (as *AuthService) DeleteAccount(ctx context.Context, sessionID string, userid uuid.UUID, password string) *ServiceResponse {
 	deletemap := make(map[string]error)
 	var err error
@@ -361,6 +420,16 @@ func (as *AuthService) DeleteAccount(ctx context.Context, sessionID string, user
 			}
 		}
 	}()
+	if ctx.Err() != nil {
+		log.Printf("DeleteAccount: Context cancelled before checkPassword: %v", ctx.Err())
+		deletemap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: deletemap}
+	}
+	if checkErr := as.checkPassword(ctx, userid, password); checkErr != nil {
+		log.Printf("Error when checking the password of %s: %v", userid, checkErr)
+		deletemap["DeleteError"] = checkErr
+		return &ServiceResponse{Success: false, Errors: deletemap}
+	}
 	tx, err = as.dbrepo.BeginTx(ctx)
 	if err != nil {
 		log.Printf("TransactionError %v", err)
@@ -373,24 +442,24 @@ func (as *AuthService) DeleteAccount(ctx context.Context, sessionID string, user
 		deletemap["ContextError"] = erro.ErrorContextTimeout
 		return &ServiceResponse{Success: false, Errors: deletemap}
 	}
-	response := as.dbrepo.DeleteUser(ctx, userid, password)
+	response := as.dbrepo.DeleteUser(ctx, tx, userid)
 	if !response.Success {
 		err = response.Errors
 		log.Printf("Failed to delete user: %v", response.Errors)
 		deletemap["DeleteError"] = response.Errors
 		return &ServiceResponse{Success: false, Errors: deletemap}
 	}
-	if ctx.Err() != nil {
-		err = ctx.Err()
-		log.Printf("DeleteAccount: Context cancelled before DeleteUser: %v", ctx.Err())
-		deletemap["ContextError"] = erro.ErrorContextTimeout
+	outboxEvent, err := newOutboxEvent(as.topics.UserDelete, userid, &events.UserDeleted{UserID: userid})
+	if err != nil {
+		deletemap["EventError"] = erro.ErrorMarshal
+		log.Printf("Error building %s event: %v", events.TypeUserDeleted, err)
 		return &ServiceResponse{Success: false, Errors: deletemap}
 	}
-	repoResponse := as.redisrepo.DeleteSession(ctx, sessionID)
-	if !repoResponse.Success {
-		err = repoResponse.Errors
-		log.Printf("Error during session deletion from Redis: %v", repoResponse.Errors)
-		deletemap["DelSessionError"] = repoResponse.Errors
+	outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, tx, outboxEvent)
+	if !outboxResponse.Success {
+		err = outboxResponse.Errors
+		log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+		deletemap["OutboxError"] = outboxResponse.Errors
 		return &ServiceResponse{Success: false, Errors: deletemap}
 	}
 	if ctx.Err() != nil {
@@ -405,35 +474,42 @@ func (as *AuthService) DeleteAccount(ctx context.Context, sessionID string, user
 		deletemap["CommitError"] = erro.ErrorCommitTransaction
 		return &ServiceResponse{Success: false, Errors: deletemap}
 	}
-	log.Println("The account was successfully deleted with all data")
-	event := UserAuthenticateEvent{
-		UserID:     userid,
-		LastUpdate: time.Now(),
-	}
-	eventBytes, errv := json.Marshal(event)
-	if errv != nil {
-		deletemap["MarshalError"] = erro.ErrorMarshal
-		log.Printf("Error marshaling event to JSON: %v", errv)
-		return &ServiceResponse{Success: false, Errors: deletemap}
-	}
-	errv = as.kafkaProducer.SendMessage("user-delete-topic", userid.String(), eventBytes)
-	if errv != nil {
-		deletemap["SendMessage"] = erro.ErrorSendKafkaMessage
-		log.Printf("Error marshaling event to JSON: %v", errv)
+	tx = nil
+	// Sessions are revoked only once the account is gone, so a failed delete leaves the user signed in.
+	if revokeErr := as.revokeUserSessions(ctx, userid, ""); revokeErr != nil {
+		log.Printf("Error during session deletion from Redis: %v", revokeErr)
+		deletemap["DelSessionError"] = revokeErr
 		return &ServiceResponse{Success: false, Errors: deletemap}
 	}
-	log.Println("Messages have been successfully delivered to the broker")
+	log.Println("The account was successfully deleted with all data")
 	return &ServiceResponse{
 		Success: true,
 	}
 }
 
+// newOutboxEvent wraps payload into a validated envelope keyed by the user, so all events of one user share a partition.
+func newOutboxEvent(topic string, userID uuid.UUID, payload events.Payload) (model.OutboxEvent, error) {
+	return newKeyedOutboxEvent(topic, userID.String(), payload)
+}
+
+func newKeyedOutboxEvent(topic, key string, payload events.Payload) (model.OutboxEvent, error) {
+	data, _, err := events.Marshal(payload, producerName, time.Now())
+	if err != nil {
+		return model.OutboxEvent{}, err
+	}
+	return model.OutboxEvent{Topic: topic, Key: key, Payload: data}, nil
+}
+
 func validatePerson(as *AuthService, user *model.Person, flag bool) map[string]error {
 	personToValidate := *user
 	if !flag {
 		personToValidate.Name = "qwertyuiopasdfghjklzxcvbn"
 	}
-	err := as.validator.Struct(&personToValidate)
+	return personFieldErrors(as.validator.Struct(&personToValidate))
+}
+
+// personFieldErrors turns a failed validation of model.Person into errors keyed by field.
+func personFieldErrors(err error) map[string]error {
 	if err != nil {
 		validationErrors, ok := err.(validator.ValidationErrors)
 		if ok {
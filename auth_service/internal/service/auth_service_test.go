@@ -0,0 +1,272 @@
+package service
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/password"
+	"auth_service/internal/repository"
+	"context"
+	"database/sql"
+	"shared/events"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeDBRepo struct {
+	userID   uuid.UUID
+	password string
+	verified map[uuid.UUID]bool
+	// email is the current email of the user after UpdateProfile, tester@example.com until then.
+	email string
+	// commitErr is returned by CommitTx.
+	commitErr error
+	// deleted makes GetUserByID report the user as gone.
+	deleted bool
+	// verifyErr fails SetEmailVerified.
+	verifyErr error
+
+	passwordHash string
+}
+
+func (f *fakeDBRepo) currentEmail() string {
+	if f.email == "" {
+		return "tester@example.com"
+	}
+	return f.email
+}
+
+func (f *fakeDBRepo) CreateUser(ctx context.Context, tx *sql.Tx, user *model.Person) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: user.Id}}
+}
+func (f *fakeDBRepo) GetUser(ctx context.Context, useremail string) *repository.RepositoryResponse {
+	return f.GetPasswordHash(ctx, f.userID)
+}
+func (f *fakeDBRepo) DeleteUser(ctx context.Context, tx *sql.Tx, userId uuid.UUID) *repository.RepositoryResponse {
+	f.deleted = f.commitErr == nil
+	return &repository.RepositoryResponse{Success: true}
+}
+func (f *fakeDBRepo) GetUserByID(ctx context.Context, userId uuid.UUID) *repository.RepositoryResponse {
+	if f.deleted {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorFoundUser}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBUserResponseData{UserId: userId, Name: "tester", Email: f.currentEmail(), EmailVerified: f.verified[userId]}}
+}
+func (f *fakeDBRepo) SetEmailVerified(ctx context.Context, tx *sql.Tx, userId uuid.UUID) *repository.RepositoryResponse {
+	if f.verifyErr != nil {
+		return &repository.RepositoryResponse{Success: false, Errors: f.verifyErr}
+	}
+	if f.verified == nil {
+		f.verified = make(map[uuid.UUID]bool)
+	}
+	f.verified[userId] = true
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: userId}}
+}
+func (f *fakeDBRepo) GetUserByEmail(ctx context.Context, useremail string) *repository.RepositoryResponse {
+	if useremail != "tester@example.com" {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorFoundUser}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBUserResponseData{UserId: f.userID, Name: "tester", Email: useremail}}
+}
+func (f *fakeDBRepo) UpdatePassword(ctx context.Context, tx *sql.Tx, userId uuid.UUID, passwordHash string) *repository.RepositoryResponse {
+	f.passwordHash = passwordHash
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: userId}}
+}
+
+// GetPasswordHash returns the last stored hash, or a hash of password, "password123" when unset.
+func (f *fakeDBRepo) GetPasswordHash(ctx context.Context, userId uuid.UUID) *repository.RepositoryResponse {
+	if f.passwordHash == "" {
+		password := f.password
+		if password == "" {
+			password = "password123"
+		}
+		hash, err := testPasswords.Hash(password)
+		if err != nil {
+			return &repository.RepositoryResponse{Success: false, Errors: err}
+		}
+		f.passwordHash = hash
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBPasswordResponseData{UserId: userId, PasswordHash: f.passwordHash}}
+}
+func (f *fakeDBRepo) UpdateProfile(ctx context.Context, tx *sql.Tx, userId uuid.UUID, name, email string) *repository.RepositoryResponse {
+	if email == "taken@example.com" {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorUniqueEmail}
+	}
+	verified := f.verified[userId] && email == f.currentEmail()
+	f.email = email
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBUserResponseData{UserId: userId, Name: name, Email: email, EmailVerified: verified}}
+}
+func (f *fakeDBRepo) BeginTx(ctx context.Context) (*sql.Tx, error)     { return nil, nil }
+func (f *fakeDBRepo) RollbackTx(ctx context.Context, tx *sql.Tx) error { return nil }
+func (f *fakeDBRepo) CommitTx(ctx context.Context, tx *sql.Tx) error   { return f.commitErr }
+
+type fakeRedisRepo struct {
+	sessions map[string]repository.RedisRepositoryResponseData
+	// reads counts GetSession calls, each of which may renew the session.
+	reads int
+}
+
+func (f *fakeRedisRepo) SetSession(ctx context.Context, session model.Session, expiration time.Duration) *repository.RepositoryResponse {
+	if f.sessions == nil {
+		f.sessions = make(map[string]repository.RedisRepositoryResponseData)
+	}
+	data := repository.RedisRepositoryResponseData{
+		SessionId: session.SessionID, UserID: session.UserID, ExpirationTime: session.ExpirationTime,
+		FamilyID: session.FamilyID, CreatedAt: session.CreatedAt,
+	}
+	f.sessions[session.SessionID] = data
+	return &repository.RepositoryResponse{Success: true, Data: data}
+}
+func (f *fakeRedisRepo) GetSession(ctx context.Context, sessionID string) *repository.RepositoryResponse {
+	f.reads++
+	data, ok := f.sessions[sessionID]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: data}
+}
+func (f *fakeRedisRepo) DeleteSession(ctx context.Context, userID uuid.UUID, sessionID string) *repository.RepositoryResponse {
+	delete(f.sessions, sessionID)
+	return &repository.RepositoryResponse{Success: true}
+}
+func (f *fakeRedisRepo) GetUserSessions(ctx context.Context, userID uuid.UUID) *repository.RepositoryResponse {
+	var sessions []repository.RedisRepositoryResponseData
+	for _, session := range f.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisSessionListResponseData{Sessions: sessions}}
+}
+
+var testPasswords = password.New(password.Bcrypt{Cost: bcrypt.MinCost}, password.Argon2id{Memory: 64, Iterations: 1, Parallelism: 1})
+
+var testTopics = events.Topics{
+	UserAuthenticate:           "test-user-authenticate-topic",
+	UserRegistered:             "test-user-registered-topic",
+	UserLoggedOut:              "test-user-logged-out-topic",
+	UserDelete:                 "test-user-delete-topic",
+	UserLockedOut:              "test-user-locked-out-topic",
+	UserVerificationRequested:  "test-user-verification-requested-topic",
+	UserPasswordResetRequested: "test-user-password-reset-requested-topic",
+	UserPasswordChanged:        "test-user-password-changed-topic",
+	UserUpdated:                "test-user-updated-topic",
+}
+
+// testDeps are the parts of a test AuthService a test controls. Unset repositories get fresh
+// fakes, unset topics testTopics and unset keys a new key set.
+type testDeps struct {
+	repos  repository.Repository
+	config AuthServiceConfig
+	keys   AccessTokenKeys
+}
+
+func newTestAuthService(t *testing.T, deps testDeps) *AuthService {
+	t.Helper()
+	repos := deps.repos
+	if repos.DBAuthenticateRepos == nil {
+		repos.DBAuthenticateRepos = &fakeDBRepo{}
+	}
+	if repos.RedisSessionRepos == nil {
+		repos.RedisSessionRepos = &fakeRedisRepo{}
+	}
+	if repos.RedisTokenRepos == nil {
+		repos.RedisTokenRepos = newFakeTokenRepo()
+	}
+	if repos.RedisLoginAttemptRepos == nil {
+		repos.RedisLoginAttemptRepos = newFakeLoginRepo()
+	}
+	if repos.RedisOneTimeTokenRepos == nil {
+		repos.RedisOneTimeTokenRepos = newFakeOneTimeRepo()
+	}
+	if repos.DBMFARepos == nil {
+		repos.DBMFARepos = newFakeMFARepo()
+	}
+	if repos.DBPasskeyRepos == nil {
+		repos.DBPasskeyRepos = newFakePasskeyRepo()
+	}
+	if repos.DBIdentityRepos == nil {
+		repos.DBIdentityRepos = newFakeIdentityRepo()
+	}
+	if repos.RedisOAuthStateRepos == nil {
+		repos.RedisOAuthStateRepos = newFakeOAuthStateRepo()
+	}
+	if repos.DBOAuthClientRepos == nil {
+		repos.DBOAuthClientRepos = newFakeOAuthClientRepo()
+	}
+	if repos.RedisAuthCodeRepos == nil {
+		repos.RedisAuthCodeRepos = newFakeAuthCodeRepo()
+	}
+	if repos.DBOutboxRepos == nil {
+		repos.DBOutboxRepos = &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	}
+	config := deps.config
+	if config.Topics == (events.Topics{}) {
+		config.Topics = testTopics
+	}
+	keys := deps.keys
+	if keys == nil {
+		keys = newTestKeys(t)
+	}
+	return NewAuthService(&repos, config, testPasswords, keys)
+}
+
+// The statustracking consumer decodes these payloads with the same registry, so every
+// event written to the outbox has to pass validation.
+func TestAuthService_OutboxEventsMatchSchemas(t *testing.T) {
+	userID := uuid.New()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID}, DBOutboxRepos: outbox}})
+	ctx := context.Background()
+
+	registered := as.RegistrateAndLogin(ctx, &model.Person{Name: "tester", Email: "tester@example.com", Password: "password123"})
+	require.True(t, registered.Success, "Регистрация должна пройти успешно: %v", registered.Errors)
+	require.True(t, as.AuthenticateAndLogin(ctx, &model.Person{Email: "tester@example.com", Password: "password123"}, "192.0.2.1").Success)
+	require.True(t, as.Logout(ctx, "session", userID).Success)
+	require.True(t, as.DeleteAccount(ctx, "session", userID, "password123").Success)
+
+	expected := []struct {
+		topic     string
+		eventType string
+		userID    uuid.UUID
+	}{
+		{testTopics.UserRegistered, events.TypeUserRegistered, registered.UserId},
+		{testTopics.UserVerificationRequested, events.TypeUserVerificationRequested, registered.UserId},
+		{testTopics.UserAuthenticate, events.TypeUserAuthenticated, userID},
+		{testTopics.UserLoggedOut, events.TypeUserLoggedOut, userID},
+		{testTopics.UserDelete, events.TypeUserDeleted, userID},
+	}
+	require.Len(t, outbox.events, len(expected))
+	for i, exp := range expected {
+		event := outbox.events[i]
+		assert.Equal(t, exp.topic, event.Topic)
+		assert.Equal(t, exp.userID.String(), event.Key)
+
+		envelope, payload, err := events.Unmarshal(event.Payload)
+		require.NoError(t, err)
+		assert.Equal(t, exp.eventType, envelope.EventType)
+		assert.Equal(t, producerName, envelope.Producer)
+		payloadUserID, ok := events.UserIDOf(payload)
+		assert.True(t, ok)
+		assert.Equal(t, exp.userID, payloadUserID)
+	}
+}
+
+func TestAuthService_AuthorizationReportsRenewal(t *testing.T) {
+	userID := uuid.New()
+	redisRepo := &fakeRedisRepo{sessions: map[string]repository.RedisRepositoryResponseData{
+		"fresh":   {SessionId: "fresh", UserID: userID},
+		"renewed": {SessionId: "renewed", UserID: userID, Renewed: true},
+	}}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{RedisSessionRepos: redisRepo}})
+	ctx := context.Background()
+
+	assert.False(t, as.Authorization(ctx, "fresh").SessionRenewed)
+	renewed := as.Authorization(ctx, "renewed")
+	assert.True(t, renewed.Success)
+	assert.True(t, renewed.SessionRenewed, "Продление сессии должно передаваться из репозитория")
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/identity"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"shared/events"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultOAuthStateTTL = 10 * time.Minute
+
+func withSocialLoginDefaults(cfg configs.SocialLoginConfig) configs.SocialLoginConfig {
+	if cfg.StateTTL <= 0 {
+		cfg.StateTTL = defaultOAuthStateTTL
+	}
+	cfg.CallbackURL = strings.TrimSuffix(cfg.CallbackURL, "/")
+	return cfg
+}
+
+// newIdentityProviders builds the providers that have a client id. The configuration is
+// validated at startup, so a provider that still fails to build is only logged.
+func newIdentityProviders(cfg configs.SocialLoginConfig) map[string]identity.Provider {
+	providers := make(map[string]identity.Provider, len(cfg.Providers))
+	for _, providerConfig := range cfg.Providers {
+		if providerConfig.ClientID == "" {
+			continue
+		}
+		provider, err := identity.New(identity.Config{
+			Name:         providerConfig.Name,
+			Type:         providerConfig.Type,
+			Issuer:       providerConfig.Issuer,
+			ClientID:     providerConfig.ClientID,
+			ClientSecret: providerConfig.ClientSecret,
+			RedirectURL:  cfg.CallbackURL + "/" + providerConfig.Name + "/callback",
+			Scopes:       providerConfig.Scopes,
+		}, nil)
+		if err != nil {
+			log.Printf("Identity provider %s is disabled: %v", providerConfig.Name, err)
+			continue
+		}
+		providers[providerConfig.Name] = provider
+	}
+	return providers
+}
+
+// BeginExternalLogin starts a sign-in with an identity provider and returns the URL to send
+// the browser to, along with the state the callback has to present. With linkUserID set, the
+// callback links the external account to that user instead of signing in.
+func (as *AuthService) BeginExternalLogin(ctx context.Context, providerName string, linkUserID uuid.UUID) *ServiceResponse {
+	beginMap := make(map[string]error)
+	provider, ok := as.providers[providerName]
+	if !ok {
+		beginMap["IdentityError"] = erro.ErrorUnknownIdentityProvider
+		return &ServiceResponse{Success: false, Errors: beginMap}
+	}
+	var secrets [3]string
+	for i := range secrets {
+		secret, err := identity.NewRandom()
+		if err != nil {
+			log.Printf("Error generating oauth state: %v", err)
+			beginMap["IdentityError"] = erro.ErrorGenerateToken
+			return &ServiceResponse{Success: false, Errors: beginMap}
+		}
+		secrets[i] = secret
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, identity.CodeChallenge(verifier))
+	if err != nil {
+		log.Printf("Error building the %s sign-in URL: %v", providerName, err)
+		beginMap["IdentityError"] = erro.ErrorIdentityProvider
+		return &ServiceResponse{Success: false, Errors: beginMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("BeginExternalLogin: Context cancelled before SetOAuthState: %v", ctx.Err())
+		beginMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: beginMap}
+	}
+	stateResponse := as.oauthrepo.SetOAuthState(ctx, hashToken(state), model.OAuthState{Provider: providerName, Nonce: nonce, CodeVerifier: verifier, LinkUserID: linkUserID}, as.socialLogin.StateTTL)
+	if !stateResponse.Success {
+		log.Printf("Error when storing the oauth state: %v", stateResponse.Errors)
+		beginMap["IdentityError"] = stateResponse.Errors
+		return &ServiceResponse{Success: false, Errors: beginMap}
+	}
+	return &ServiceResponse{Success: true, UserId: linkUserID, RedirectURL: authURL, OAuthState: state, ExpirationTime: time.Now().Add(as.socialLogin.StateTTL)}
+}
+
+// CompleteExternalLogin answers the callback of a provider. A linked account signs its user in;
+// a new one is linked to the user who started the flow from /oauth/{provider}/link, or else
+// registers a new user. An unknown account whose email already belongs to a user is refused:
+// taking over that user needs the user's own consent through linking. Enrolled users still
+// have to pass TOTP.
+func (as *AuthService) CompleteExternalLogin(ctx context.Context, providerName, code, state string) *ServiceResponse {
+	loginMap := make(map[string]error)
+	provider, ok := as.providers[providerName]
+	if !ok {
+		loginMap["IdentityError"] = erro.ErrorUnknownIdentityProvider
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("CompleteExternalLogin: Context cancelled before ConsumeOAuthState: %v", ctx.Err())
+		loginMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	stateResponse := as.oauthrepo.ConsumeOAuthState(ctx, hashToken(state))
+	if !stateResponse.Success {
+		log.Printf("Error when consuming the oauth state: %v", stateResponse.Errors)
+		loginMap["IdentityError"] = stateResponse.Errors
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	stateData, ok := stateResponse.Data.(repository.RedisOAuthStateResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", stateResponse.Data)
+		loginMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	if stateData.State.Provider != providerName {
+		log.Printf("OAuth state of %s presented to the %s callback", stateData.State.Provider, providerName)
+		loginMap["IdentityError"] = erro.ErrorInvalidOAuthState
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	external, err := provider.Exchange(ctx, code, stateData.State.CodeVerifier, stateData.State.Nonce)
+	if err != nil {
+		log.Printf("Sign-in with %s failed: %v", providerName, err)
+		loginMap["IdentityError"] = erro.ErrorIdentityProvider
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+
+	linkUserID := stateData.State.LinkUserID
+	identityResponse := as.identityrepo.GetIdentity(ctx, providerName, external.Subject)
+	switch {
+	case identityResponse.Success:
+		identityData, ok := identityResponse.Data.(repository.DBIdentityResponseData)
+		if !ok {
+			log.Printf("Unexpected data type from repository: %T", identityResponse.Data)
+			loginMap["UnexpectedData"] = erro.ErrorUnexpectedData
+			return &ServiceResponse{Success: false, Errors: loginMap}
+		}
+		userID := identityData.Identity.UserID
+		if linkUserID != uuid.Nil {
+			if userID != linkUserID {
+				log.Printf("%s account %s is already linked to another user", providerName, external.Subject)
+				loginMap["IdentityError"] = erro.ErrorIdentityLinked
+				return &ServiceResponse{Success: false, Errors: loginMap}
+			}
+			return &ServiceResponse{Success: true, UserId: userID}
+		}
+		log.Printf("Person with id: %v has signed in with %s", userID, providerName)
+		if challenge := as.requireMFA(ctx, userID, mfaSessionPurpose, loginMap); challenge != nil {
+			return challenge
+		}
+		return as.startSession(ctx, userID, loginMap)
+	case !errors.Is(identityResponse.Errors, erro.ErrorIdentityNotFound):
+		log.Printf("Error when getting the %s identity: %v", providerName, identityResponse.Errors)
+		loginMap["IdentityError"] = identityResponse.Errors
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+
+	if linkUserID != uuid.Nil {
+		linkResponse := as.identityrepo.AddIdentity(ctx, nil, model.UserIdentity{Provider: providerName, Subject: external.Subject, UserID: linkUserID, Email: external.Email})
+		if !linkResponse.Success {
+			log.Printf("Error when linking %s to %s: %v", providerName, linkUserID, linkResponse.Errors)
+			loginMap["IdentityError"] = linkResponse.Errors
+			return &ServiceResponse{Success: false, Errors: loginMap}
+		}
+		log.Printf("Person with id: %v has linked %s", linkUserID, providerName)
+		return &ServiceResponse{Success: true, UserId: linkUserID}
+	}
+	userID, failure := as.registerExternalUser(ctx, external, loginMap)
+	if failure != nil {
+		return failure
+	}
+	return as.startSession(ctx, userID, loginMap)
+}
+
+// registerExternalUser creates the user of an external account seen for the first time, with
+// the same user-registered event as a registration through /reg. The password is random, so
+// the account can only be used through the provider until the user resets it. An email the
+// provider vouches for counts as verified; any other gets the usual verification link.
+func (as *AuthService) registerExternalUser(ctx context.Context, external identity.Identity, registrateMap map[string]error) (uuid.UUID, *ServiceResponse) {
+	if external.Email == "" {
+		log.Printf("%s account %s has no email", external.Provider, external.Subject)
+		registrateMap["IdentityError"] = erro.ErrorIdentityEmailMissing
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("registerExternalUser: Context cancelled before GetUserByEmail: %v", ctx.Err())
+		registrateMap["ContextError"] = erro.ErrorContextTimeout
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	existing := as.dbrepo.GetUserByEmail(ctx, external.Email)
+	if existing.Success {
+		log.Printf("%s account %s uses the email of an existing user", external.Provider, external.Subject)
+		registrateMap["IdentityError"] = erro.ErrorIdentityEmailTaken
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	if !errors.Is(existing.Errors, erro.ErrorFoundUser) {
+		log.Printf("Error when getting the user by email: %v", existing.Errors)
+		registrateMap["GetUserError"] = existing.Errors
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+
+	password, err := generateToken()
+	if err != nil {
+		log.Printf("Error generating password: %v", err)
+		registrateMap["HashPassError"] = erro.ErrorHashPass
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	hashpass, err := as.passwords.Hash(password)
+	if err != nil {
+		log.Printf("HashPassError %v", err)
+		registrateMap["HashPassError"] = erro.ErrorHashPass
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	name := external.Name
+	if name == "" {
+		name, _, _ = strings.Cut(external.Email, "@")
+	}
+	user := &model.Person{Id: uuid.New(), Name: name, Email: external.Email, Password: hashpass}
+
+	var tx *sql.Tx
+	defer func() {
+		if tx != nil && err != nil {
+			if rErr := as.dbrepo.RollbackTx(ctx, tx); rErr != nil {
+				log.Printf("Error rolling back transaction: %v", rErr)
+			}
+		}
+	}()
+	tx, err = as.dbrepo.BeginTx(ctx)
+	if err != nil {
+		log.Printf("TransactionError %v", err)
+		registrateMap["TransactionError"] = erro.ErrorStartTransaction
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	response := as.dbrepo.CreateUser(ctx, tx, user)
+	if !response.Success {
+		err = response.Errors
+		log.Printf("Error when creating person in the database %v", response.Errors)
+		if errors.Is(response.Errors, erro.ErrorUniqueEmail) {
+			registrateMap["IdentityError"] = erro.ErrorIdentityEmailTaken
+		} else {
+			registrateMap["RegistrateError"] = response.Errors
+		}
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	dbData, ok := response.Data.(repository.DBRepositoryResponseData)
+	if !ok {
+		err = erro.ErrorUnexpectedData
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		registrateMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	linkResponse := as.identityrepo.AddIdentity(ctx, tx, model.UserIdentity{Provider: external.Provider, Subject: external.Subject, UserID: dbData.UserId, Email: external.Email})
+	if !linkResponse.Success {
+		err = linkResponse.Errors
+		log.Printf("Error when linking %s to the new user: %v", external.Provider, linkResponse.Errors)
+		registrateMap["IdentityError"] = linkResponse.Errors
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	outboxEvent, err := newOutboxEvent(as.topics.UserRegistered, dbData.UserId, &events.UserRegistered{UserID: dbData.UserId})
+	if err != nil {
+		registrateMap["EventError"] = erro.ErrorMarshal
+		log.Printf("Error building %s event: %v", events.TypeUserRegistered, err)
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, tx, outboxEvent)
+	if !outboxResponse.Success {
+		err = outboxResponse.Errors
+		log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+		registrateMap["OutboxError"] = outboxResponse.Errors
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	// The provider's verification is stored with the account, otherwise a failed update would
+	// leave the user unverified and without a link to verify.
+	if external.EmailVerified {
+		verified := as.dbrepo.SetEmailVerified(ctx, tx, dbData.UserId)
+		if !verified.Success {
+			err = verified.Errors
+			log.Printf("Error when marking the email of %s verified: %v", dbData.UserId, verified.Errors)
+			registrateMap["VerificationError"] = verified.Errors
+			return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+		}
+	} else {
+		err = as.requestVerification(ctx, tx, dbData.UserId, external.Email)
+		if err != nil {
+			registrateMap["VerificationError"] = err
+			return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+		}
+	}
+	if ctx.Err() != nil {
+		err = ctx.Err()
+		log.Printf("Context cancelled before CommitTx: %v", ctx.Err())
+		registrateMap["ContextError"] = erro.ErrorContextTimeout
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	err = as.dbrepo.CommitTx(ctx, tx)
+	if err != nil {
+		log.Printf("Transaction commit error: %v", err)
+		registrateMap["CommitError"] = erro.ErrorCommitTransaction
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: registrateMap}
+	}
+	log.Printf("Person with id: %v has registered with %s", dbData.UserId, external.Provider)
+	return dbData.UserId, nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/identity/identitytest"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"database/sql"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeIdentityRepo struct {
+	identities map[string]model.UserIdentity
+}
+
+func newFakeIdentityRepo() *fakeIdentityRepo {
+	return &fakeIdentityRepo{identities: make(map[string]model.UserIdentity)}
+}
+
+func (f *fakeIdentityRepo) AddIdentity(ctx context.Context, tx *sql.Tx, identity model.UserIdentity) *repository.RepositoryResponse {
+	key := identity.Provider + ":" + identity.Subject
+	if _, ok := f.identities[key]; ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorIdentityLinked}
+	}
+	f.identities[key] = identity
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: identity.UserID}}
+}
+func (f *fakeIdentityRepo) GetIdentity(ctx context.Context, provider, subject string) *repository.RepositoryResponse {
+	identity, ok := f.identities[provider+":"+subject]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorIdentityNotFound}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBIdentityResponseData{Identity: identity}}
+}
+
+type fakeOAuthStateRepo struct {
+	states map[string]model.OAuthState
+}
+
+func newFakeOAuthStateRepo() *fakeOAuthStateRepo {
+	return &fakeOAuthStateRepo{states: make(map[string]model.OAuthState)}
+}
+
+func (f *fakeOAuthStateRepo) SetOAuthState(ctx context.Context, stateHash string, state model.OAuthState, expiration time.Duration) *repository.RepositoryResponse {
+	f.states[stateHash] = state
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisOAuthStateResponseData{State: state}}
+}
+func (f *fakeOAuthStateRepo) ConsumeOAuthState(ctx context.Context, stateHash string) *repository.RepositoryResponse {
+	state, ok := f.states[stateHash]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorInvalidOAuthState}
+	}
+	delete(f.states, stateHash)
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisOAuthStateResponseData{State: state}}
+}
+
+// signInWith runs the browser side of the flow against the stub provider and returns the query
+// of the callback.
+func signInWith(t *testing.T, as *AuthService, stub *identitytest.Provider, user identitytest.User, linkUserID uuid.UUID) url.Values {
+	stub.SignIn(user)
+	begun := as.BeginExternalLogin(context.Background(), "stub", linkUserID)
+	require.True(t, begun.Success, "Начало входа должно пройти: %v", begun.Errors)
+	callback, err := stub.Authorize(begun.RedirectURL)
+	require.NoError(t, err)
+	return callback
+}
+
+func TestAuthService_ExternalLogin(t *testing.T) {
+	stub := identitytest.NewProvider("client-1", "secret-1")
+	defer stub.Close()
+	userID := uuid.New()
+	dbRepo := &fakeDBRepo{userID: userID}
+	redisRepo := &fakeRedisRepo{}
+	identities := newFakeIdentityRepo()
+	oneTime := newFakeOneTimeRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	socialLogin := configs.SocialLoginConfig{
+		CallbackURL: "http://localhost:8081/oauth/",
+		Providers: []configs.IdentityProviderConfig{
+			{Name: "stub", Type: configs.IdentityProviderOIDC, Issuer: stub.URL, ClientID: stub.ClientID, ClientSecret: stub.ClientSecret},
+			{Name: "disabled", Type: configs.IdentityProviderGitHub},
+		},
+	}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: dbRepo, RedisSessionRepos: redisRepo, RedisOneTimeTokenRepos: oneTime, DBIdentityRepos: identities, DBOutboxRepos: outbox}, config: AuthServiceConfig{SocialLogin: socialLogin}})
+	ctx := context.Background()
+
+	newcomer := identitytest.User{Subject: "external-1", Email: "newcomer@example.com", EmailVerified: true, Name: "Newcomer"}
+	callback := signInWith(t, as, stub, newcomer, uuid.Nil)
+	registered := as.CompleteExternalLogin(ctx, "stub", callback.Get("code"), callback.Get("state"))
+	require.True(t, registered.Success, "Первый вход должен регистрировать пользователя: %v", registered.Errors)
+	require.Contains(t, redisRepo.sessions, registered.SessionId, "Сессия должна быть создана")
+	assert.Equal(t, registered.UserId, identities.identities["stub:external-1"].UserID, "Аккаунт провайдера должен быть привязан")
+	assert.True(t, dbRepo.verified[registered.UserId], "Email, подтвержденный провайдером, считается подтвержденным")
+	assert.Empty(t, oneTime.tokens, "Ссылка подтверждения не нужна")
+	require.NotEmpty(t, outbox.events)
+	assert.Equal(t, testTopics.UserRegistered, outbox.events[0].Topic, "Должно публиковаться событие регистрации")
+	assert.Equal(t, registered.UserId.String(), outbox.events[0].Key)
+
+	replayed := as.CompleteExternalLogin(ctx, "stub", callback.Get("code"), callback.Get("state"))
+	assert.Equal(t, erro.ErrorInvalidOAuthState, replayed.Errors["IdentityError"], "State используется один раз")
+
+	callback = signInWith(t, as, stub, newcomer, uuid.Nil)
+	again := as.CompleteExternalLogin(ctx, "stub", callback.Get("code"), callback.Get("state"))
+	require.True(t, again.Success, "Повторный вход должен пройти: %v", again.Errors)
+	assert.Equal(t, registered.UserId, again.UserId, "Повторный вход не должен создавать нового пользователя")
+
+	unverified := identitytest.User{Subject: "external-2", Email: "unverified@example.com", Name: "Unverified"}
+	callback = signInWith(t, as, stub, unverified, uuid.Nil)
+	pending := as.CompleteExternalLogin(ctx, "stub", callback.Get("code"), callback.Get("state"))
+	require.True(t, pending.Success, "Вход с неподтвержденным email должен пройти: %v", pending.Errors)
+	assert.False(t, dbRepo.verified[pending.UserId], "Неподтвержденный провайдером email не считается подтвержденным")
+	assert.Len(t, oneTime.tokens, 1, "Должна быть отправлена ссылка подтверждения")
+
+	owner := identitytest.User{Subject: "external-3", Email: "tester@example.com", EmailVerified: true, Name: "Tester"}
+	callback = signInWith(t, as, stub, owner, uuid.Nil)
+	taken := as.CompleteExternalLogin(ctx, "stub", callback.Get("code"), callback.Get("state"))
+	assert.Equal(t, erro.ErrorIdentityEmailTaken, taken.Errors["IdentityError"], "Чужой аккаунт не должен захватываться по email")
+
+	callback = signInWith(t, as, stub, owner, userID)
+	linked := as.CompleteExternalLogin(ctx, "stub", callback.Get("code"), callback.Get("state"))
+	require.True(t, linked.Success, "Привязка должна пройти: %v", linked.Errors)
+	assert.Empty(t, linked.SessionId, "Привязка не создает новую сессию")
+	assert.Equal(t, userID, identities.identities["stub:external-3"].UserID, "Аккаунт должен быть привязан к пользователю")
+
+	callback = signInWith(t, as, stub, owner, uuid.Nil)
+	signedIn := as.CompleteExternalLogin(ctx, "stub", callback.Get("code"), callback.Get("state"))
+	require.True(t, signedIn.Success, "Вход через привязанный аккаунт должен пройти: %v", signedIn.Errors)
+	assert.Equal(t, userID, signedIn.UserId, "UserId должен совпадать")
+
+	callback = signInWith(t, as, stub, newcomer, userID)
+	foreign := as.CompleteExternalLogin(ctx, "stub", callback.Get("code"), callback.Get("state"))
+	assert.Equal(t, erro.ErrorIdentityLinked, foreign.Errors["IdentityError"], "Аккаунт другого пользователя не должен перепривязываться")
+
+	assert.Equal(t, erro.ErrorUnknownIdentityProvider, as.BeginExternalLogin(ctx, "disabled", uuid.Nil).Errors["IdentityError"], "Провайдер без client_id отключен")
+	callback = signInWith(t, as, stub, newcomer, uuid.Nil)
+	assert.Equal(t, erro.ErrorUnknownIdentityProvider, as.CompleteExternalLogin(ctx, "other", callback.Get("code"), callback.Get("state")).Errors["IdentityError"])
+}
+
+func TestAuthService_ExternalRegistrationFailsWithoutVerifiedFlag(t *testing.T) {
+	stub := identitytest.NewProvider("client-1", "secret-1")
+	defer stub.Close()
+	dbRepo := &fakeDBRepo{userID: uuid.New(), verifyErr: erro.ErrorInternalServer}
+	redisRepo := &fakeRedisRepo{}
+	socialLogin := configs.SocialLoginConfig{
+		CallbackURL: "http://localhost:8081/oauth/",
+		Providers:   []configs.IdentityProviderConfig{{Name: "stub", Type: configs.IdentityProviderOIDC, Issuer: stub.URL, ClientID: stub.ClientID, ClientSecret: stub.ClientSecret}},
+	}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: dbRepo, RedisSessionRepos: redisRepo}, config: AuthServiceConfig{SocialLogin: socialLogin}})
+
+	callback := signInWith(t, as, stub, identitytest.User{Subject: "external-1", Email: "newcomer@example.com", EmailVerified: true}, uuid.Nil)
+	failed := as.CompleteExternalLogin(context.Background(), "stub", callback.Get("code"), callback.Get("state"))
+	assert.False(t, failed.Success, "Регистрация не должна завершаться без отметки о подтверждении email")
+	assert.Equal(t, erro.ErrorInternalServer, failed.Errors["VerificationError"], "Тип ошибки должен совпадать")
+	assert.Empty(t, redisRepo.sessions, "Сессия не должна создаваться")
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/repository"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"shared/events"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultMaxAccountFailures = 5
+	defaultMaxIPFailures      = 50
+	defaultFailureWindow      = 15 * time.Minute
+	defaultBaseLockout        = time.Minute
+	defaultMaxLockout         = time.Hour
+)
+
+type loginSubject struct {
+	key         string
+	scope       string
+	maxFailures int
+}
+
+func withLoginDefaults(cfg configs.LoginConfig) configs.LoginConfig {
+	if cfg.MaxAccountFailures <= 0 {
+		cfg.MaxAccountFailures = defaultMaxAccountFailures
+	}
+	if cfg.MaxIPFailures <= 0 {
+		cfg.MaxIPFailures = defaultMaxIPFailures
+	}
+	if cfg.FailureWindow <= 0 {
+		cfg.FailureWindow = defaultFailureWindow
+	}
+	if cfg.BaseLockout <= 0 {
+		cfg.BaseLockout = defaultBaseLockout
+	}
+	if cfg.MaxLockout < cfg.BaseLockout {
+		cfg.MaxLockout = defaultMaxLockout
+	}
+	return cfg
+}
+
+// authenticate checks the credentials behind the per-account and per-IP lockouts. Every credential
+// failure is reported as ErrorInvalidCredentials, whether the email exists or not.
+func (as *AuthService) authenticate(ctx context.Context, email, password, clientIP string, errMap map[string]error) (uuid.UUID, *ServiceResponse) {
+	subjects := as.loginSubjects(email, clientIP)
+
+	retryAfter, err := as.loginLock(ctx, subjects)
+	if err != nil {
+		log.Printf("Error checking login lock: %v", err)
+		errMap["AuthenticateError"] = err
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	if retryAfter > 0 {
+		log.Printf("Login attempt rejected, subject is locked for %v", retryAfter)
+		errMap["AuthenticateError"] = erro.ErrorTooManyAttempts
+		return uuid.Nil, &ServiceResponse{Success: false, RetryAfter: retryAfter, Errors: errMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("authenticate: Context cancelled before GetUser: %v", ctx.Err())
+		errMap["ContextError"] = erro.ErrorContextTimeout
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+
+	response := as.dbrepo.GetUser(ctx, email)
+	if !response.Success {
+		if !errors.Is(response.Errors, erro.ErrorFoundUser) {
+			log.Printf("Failed to authenticate user: %v", response.Errors)
+			errMap["AuthenticateError"] = response.Errors
+			return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+		}
+		// An unknown email costs as much as a wrong password, so it cannot be told apart by latency.
+		as.passwords.DummyVerify(password)
+		as.registerLoginFailure(ctx, subjects, nil, clientIP)
+		errMap["AuthenticateError"] = erro.ErrorInvalidCredentials
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	dbData, ok := response.Data.(repository.DBPasswordResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		errMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	match, rehash, err := as.passwords.Verify(password, dbData.PasswordHash)
+	if err != nil {
+		log.Printf("Error verifying the password hash of %s: %v", dbData.UserId, err)
+		errMap["AuthenticateError"] = erro.ErrorInternalServer
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	if !match {
+		as.registerLoginFailure(ctx, subjects, &dbData.UserId, clientIP)
+		errMap["AuthenticateError"] = erro.ErrorInvalidCredentials
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	if rehash {
+		as.rehashPassword(ctx, dbData.UserId, password)
+	}
+	// Only the account counter is reset; an IP spraying many accounts keeps its failures.
+	if resetResponse := as.loginrepo.ResetLoginFailures(ctx, subjects[0].key); !resetResponse.Success {
+		log.Printf("Error resetting login failures: %v", resetResponse.Errors)
+	}
+	return dbData.UserId, nil
+}
+
+func (as *AuthService) loginSubjects(email, clientIP string) []loginSubject {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	subjects := []loginSubject{{
+		key:         events.LockoutScopeAccount + ":" + hex.EncodeToString(sum[:]),
+		scope:       events.LockoutScopeAccount,
+		maxFailures: as.login.MaxAccountFailures,
+	}}
+	if clientIP != "" {
+		subjects = append(subjects, loginSubject{
+			key:         events.LockoutScopeIP + ":" + clientIP,
+			scope:       events.LockoutScopeIP,
+			maxFailures: as.login.MaxIPFailures,
+		})
+	}
+	return subjects
+}
+
+func (as *AuthService) loginLock(ctx context.Context, subjects []loginSubject) (time.Duration, error) {
+	var retryAfter time.Duration
+	for _, subject := range subjects {
+		response := as.loginrepo.GetLoginLock(ctx, subject.key)
+		if !response.Success {
+			return 0, response.Errors
+		}
+		data, ok := response.Data.(repository.RedisLoginResponseData)
+		if !ok {
+			return 0, erro.ErrorUnexpectedData
+		}
+		if data.LockedFor > retryAfter {
+			retryAfter = data.LockedFor
+		}
+	}
+	return retryAfter, nil
+}
+
+// registerLoginFailure counts the failure for every subject and locks the ones over their limit.
+// Counting is best effort: a Redis outage must not turn into a login outage.
+func (as *AuthService) registerLoginFailure(ctx context.Context, subjects []loginSubject, userID *uuid.UUID, clientIP string) {
+	for _, subject := range subjects {
+		response := as.loginrepo.RegisterLoginFailure(ctx, subject.key, as.login.FailureWindow)
+		if !response.Success {
+			log.Printf("Error counting login failure: %v", response.Errors)
+			continue
+		}
+		data, ok := response.Data.(repository.RedisLoginResponseData)
+		if !ok || data.Failures < subject.maxFailures {
+			continue
+		}
+		lockout := lockoutDuration(as.login, data.Failures-subject.maxFailures)
+		if lockResponse := as.loginrepo.LockLogin(ctx, subject.key, lockout); !lockResponse.Success {
+			log.Printf("Error locking login: %v", lockResponse.Errors)
+			continue
+		}
+		log.Printf("Login locked for %v after %d failures (%s)", lockout, data.Failures, subject.scope)
+
+		payload := &events.UserLockedOut{
+			Scope:          subject.scope,
+			FailedAttempts: data.Failures,
+			LockedUntil:    time.Now().Add(lockout).UTC().Truncate(time.Second),
+		}
+		key := subject.key
+		if subject.scope == events.LockoutScopeAccount {
+			if userID == nil {
+				continue
+			}
+			payload.UserID = userID
+			key = userID.String()
+		} else {
+			payload.IP = clientIP
+		}
+		outboxEvent, err := newKeyedOutboxEvent(as.topics.UserLockedOut, key, payload)
+		if err != nil {
+			log.Printf("Error building %s event: %v", events.TypeUserLockedOut, err)
+			continue
+		}
+		outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, nil, outboxEvent)
+		if !outboxResponse.Success {
+			log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+		}
+	}
+}
+
+// lockoutDuration doubles BaseLockout for every failure beyond the limit, capped at MaxLockout.
+func lockoutDuration(cfg configs.LoginConfig, excess int) time.Duration {
+	lockout := cfg.BaseLockout
+	for i := 0; i < excess && lockout < cfg.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > cfg.MaxLockout {
+		lockout = cfg.MaxLockout
+	}
+	return lockout
+}
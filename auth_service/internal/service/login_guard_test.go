@@ -0,0 +1,155 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/password"
+	"auth_service/internal/repository"
+	"context"
+	"shared/events"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLoginRepo struct {
+	failures map[string]int
+	locks    map[string]time.Duration
+}
+
+func newFakeLoginRepo() *fakeLoginRepo {
+	return &fakeLoginRepo{failures: make(map[string]int), locks: make(map[string]time.Duration)}
+}
+
+func (f *fakeLoginRepo) RegisterLoginFailure(ctx context.Context, subject string, window time.Duration) *repository.RepositoryResponse {
+	f.failures[subject]++
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisLoginResponseData{Failures: f.failures[subject]}}
+}
+func (f *fakeLoginRepo) ResetLoginFailures(ctx context.Context, subject string) *repository.RepositoryResponse {
+	delete(f.failures, subject)
+	return &repository.RepositoryResponse{Success: true}
+}
+func (f *fakeLoginRepo) LockLogin(ctx context.Context, subject string, duration time.Duration) *repository.RepositoryResponse {
+	f.locks[subject] = duration
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisLoginResponseData{LockedFor: duration}}
+}
+func (f *fakeLoginRepo) GetLoginLock(ctx context.Context, subject string) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisLoginResponseData{LockedFor: f.locks[subject]}}
+}
+
+// expire simulates the lock running out while the failure counter is still inside its window.
+func (f *fakeLoginRepo) expire() {
+	f.locks = make(map[string]time.Duration)
+}
+
+func TestAuthService_LoginLockout(t *testing.T) {
+	userID := uuid.New()
+	login := newFakeLoginRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	loginConfig := configs.LoginConfig{MaxAccountFailures: 3, MaxIPFailures: 100, FailureWindow: time.Minute, BaseLockout: time.Minute, MaxLockout: 4 * time.Minute}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID, password: "password123"}, RedisLoginAttemptRepos: login, DBOutboxRepos: outbox}, config: AuthServiceConfig{Login: loginConfig}})
+	ctx := context.Background()
+	wrong := &model.Person{Email: "tester@example.com", Password: "wrongpassword"}
+
+	for i := 0; i < 2; i++ {
+		response := as.AuthenticateAndLogin(ctx, wrong, "192.0.2.1")
+		assert.False(t, response.Success, "Success должен совпадать")
+		assert.Equal(t, erro.ErrorInvalidCredentials, response.Errors["AuthenticateError"], "Тип ошибки должен совпадать")
+	}
+	assert.Empty(t, outbox.events, "До достижения лимита событий быть не должно")
+
+	locking := as.AuthenticateAndLogin(ctx, wrong, "192.0.2.1")
+	assert.Equal(t, erro.ErrorInvalidCredentials, locking.Errors["AuthenticateError"], "Тип ошибки должен совпадать")
+	require.Len(t, outbox.events, 1, "Блокировка должна публиковать событие")
+	assert.Equal(t, testTopics.UserLockedOut, outbox.events[0].Topic)
+	assert.Equal(t, userID.String(), outbox.events[0].Key)
+	envelope, payload, err := events.Unmarshal(outbox.events[0].Payload)
+	require.NoError(t, err)
+	assert.Equal(t, events.TypeUserLockedOut, envelope.EventType)
+	lockedOut := payload.(*events.UserLockedOut)
+	assert.Equal(t, events.LockoutScopeAccount, lockedOut.Scope)
+	assert.Equal(t, 3, lockedOut.FailedAttempts)
+
+	locked := as.AuthenticateAndLogin(ctx, &model.Person{Email: "tester@example.com", Password: "password123"}, "192.0.2.1")
+	assert.False(t, locked.Success, "Во время блокировки вход невозможен даже с верным паролем")
+	assert.Equal(t, erro.ErrorTooManyAttempts, locked.Errors["AuthenticateError"], "Тип ошибки должен совпадать")
+	assert.Equal(t, time.Minute, locked.RetryAfter, "RetryAfter должен совпадать")
+
+	login.expire()
+	as.AuthenticateAndLogin(ctx, wrong, "192.0.2.1")
+	escalated := as.AuthenticateAndLogin(ctx, wrong, "192.0.2.1")
+	assert.Equal(t, erro.ErrorTooManyAttempts, escalated.Errors["AuthenticateError"], "Тип ошибки должен совпадать")
+	assert.Equal(t, 2*time.Minute, escalated.RetryAfter, "Повторная блокировка должна быть длиннее")
+
+	login.expire()
+	succeeded := as.AuthenticateAndLogin(ctx, &model.Person{Email: "tester@example.com", Password: "password123"}, "192.0.2.1")
+	require.True(t, succeeded.Success, "После блокировки вход должен пройти: %v", succeeded.Errors)
+	assert.Len(t, login.failures, 1, "Успешный вход сбрасывает только счетчик аккаунта")
+}
+
+func TestAuthService_LoginLockoutByIP(t *testing.T) {
+	login := newFakeLoginRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	loginConfig := configs.LoginConfig{MaxAccountFailures: 100, MaxIPFailures: 2}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: uuid.New(), password: "password123"}, RedisLoginAttemptRepos: login, DBOutboxRepos: outbox}, config: AuthServiceConfig{Login: loginConfig}})
+	ctx := context.Background()
+
+	as.AuthenticateAndLogin(ctx, &model.Person{Email: "first@example.com", Password: "wrongpassword"}, "192.0.2.7")
+	as.AuthenticateAndLogin(ctx, &model.Person{Email: "second@example.com", Password: "wrongpassword"}, "192.0.2.7")
+	require.Len(t, outbox.events, 1, "Блокировка IP должна публиковать событие")
+	assert.Equal(t, "ip:192.0.2.7", outbox.events[0].Key)
+	_, payload, err := events.Unmarshal(outbox.events[0].Payload)
+	require.NoError(t, err)
+	assert.Equal(t, events.LockoutScopeIP, payload.(*events.UserLockedOut).Scope)
+	assert.Equal(t, "192.0.2.7", payload.(*events.UserLockedOut).IP)
+
+	blocked := as.AuthenticateAndLogin(ctx, &model.Person{Email: "third@example.com", Password: "password123"}, "192.0.2.7")
+	assert.Equal(t, erro.ErrorTooManyAttempts, blocked.Errors["AuthenticateError"], "Тип ошибки должен совпадать")
+	other := as.AuthenticateAndLogin(ctx, &model.Person{Email: "third@example.com", Password: "password123"}, "192.0.2.8")
+	assert.True(t, other.Success, "Другой IP не должен блокироваться: %v", other.Errors)
+}
+
+func TestAuthService_LoginUpgradesPasswordHash(t *testing.T) {
+	legacy, err := password.Argon2id{Memory: 64, Iterations: 1, Parallelism: 1}.Hash("password123")
+	require.NoError(t, err)
+	db := &fakeDBRepo{userID: uuid.New(), passwordHash: legacy}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: db}})
+	ctx := context.Background()
+
+	failed := as.AuthenticateAndLogin(ctx, &model.Person{Email: "tester@example.com", Password: "wrongpassword"}, "192.0.2.1")
+	assert.False(t, failed.Success, "Success должен совпадать")
+	assert.Equal(t, legacy, db.passwordHash, "Без верного пароля хеш не должен меняться")
+
+	response := as.AuthenticateAndLogin(ctx, &model.Person{Email: "tester@example.com", Password: "password123"}, "192.0.2.1")
+	require.True(t, response.Success, "Вход со старым хешем должен проходить: %v", response.Errors)
+	assert.NotEqual(t, legacy, db.passwordHash, "Устаревший хеш должен заменяться")
+	ok, rehash, err := testPasswords.Verify("password123", db.passwordHash)
+	require.NoError(t, err)
+	assert.True(t, ok, "Новый хеш должен подходить к паролю")
+	assert.False(t, rehash, "Новый хеш должен быть сделан текущим алгоритмом")
+
+	upgraded := db.passwordHash
+	require.True(t, as.AuthenticateAndLogin(ctx, &model.Person{Email: "tester@example.com", Password: "password123"}, "192.0.2.1").Success)
+	assert.Equal(t, upgraded, db.passwordHash, "Актуальный хеш не должен пересчитываться")
+}
+
+func TestLockoutDuration(t *testing.T) {
+	cfg := configs.LoginConfig{BaseLockout: time.Minute, MaxLockout: 5 * time.Minute}
+	cases := []struct {
+		excess   int
+		expected time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{2, 4 * time.Minute},
+		{3, 5 * time.Minute},
+		{40, 5 * time.Minute},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, lockoutDuration(cfg, tc.excess), "Длительность блокировки для %d должна совпадать", tc.excess)
+	}
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/repository"
+	"auth_service/internal/totp"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	mfaSessionPurpose = "mfa_session"
+	mfaTokenPurpose   = "mfa_token"
+
+	defaultMFAIssuer        = "auth_service"
+	defaultMFAChallengeTTL  = 5 * time.Minute
+	defaultMFARecoveryCodes = 10
+
+	recoveryCodeBytes = 5
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func withMFADefaults(cfg configs.MFAConfig) configs.MFAConfig {
+	if cfg.Issuer == "" {
+		cfg.Issuer = defaultMFAIssuer
+	}
+	if cfg.ChallengeTTL <= 0 {
+		cfg.ChallengeTTL = defaultMFAChallengeTTL
+	}
+	if cfg.RecoveryCodes <= 0 {
+		cfg.RecoveryCodes = defaultMFARecoveryCodes
+	}
+	if cfg.Skew < 0 {
+		cfg.Skew = 0
+	}
+	return cfg
+}
+
+// EnrollTOTP generates a new TOTP secret for the user. It stays inactive until ConfirmTOTP sees
+// a code made with it, so an abandoned enrollment never locks the user out.
+func (as *AuthService) EnrollTOTP(ctx context.Context, userID uuid.UUID) *ServiceResponse {
+	enrollMap := make(map[string]error)
+	user, err := as.userByID(ctx, userID)
+	if err != nil {
+		log.Printf("Error when getting the user %s: %v", userID, err)
+		enrollMap["GetUserError"] = err
+		return &ServiceResponse{Success: false, Errors: enrollMap}
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Printf("Error generating TOTP secret: %v", err)
+		enrollMap["MFAError"] = erro.ErrorGenerateToken
+		return &ServiceResponse{Success: false, Errors: enrollMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("EnrollTOTP: Context cancelled before SaveTOTPSecret: %v", ctx.Err())
+		enrollMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: enrollMap}
+	}
+	saveResponse := as.mfarepo.SaveTOTPSecret(ctx, userID, secret)
+	if !saveResponse.Success {
+		log.Printf("Error when saving the TOTP secret of %s: %v", userID, saveResponse.Errors)
+		enrollMap["MFAError"] = saveResponse.Errors
+		return &ServiceResponse{Success: false, Errors: enrollMap}
+	}
+	log.Printf("TOTP enrollment started for %s", userID)
+	return &ServiceResponse{
+		Success:    true,
+		UserId:     userID,
+		TOTPSecret: secret,
+		TOTPURI:    totp.URI(as.mfa.Issuer, user.Email, secret),
+	}
+}
+
+// ConfirmTOTP enables a pending enrollment and returns the recovery codes. They are shown this
+// once; only their hashes are kept.
+func (as *AuthService) ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) (response *ServiceResponse) {
+	confirmMap := make(map[string]error)
+	enrollment, err := as.totpEnrollment(ctx, userID)
+	if err != nil {
+		confirmMap["MFAError"] = err
+		return &ServiceResponse{Success: false, Errors: confirmMap}
+	}
+	if enrollment.Enabled {
+		confirmMap["MFAError"] = erro.ErrorMFAAlreadyEnabled
+		return &ServiceResponse{Success: false, Errors: confirmMap}
+	}
+	step, ok := totp.Validate(enrollment.Secret, strings.TrimSpace(code), time.Now(), as.mfa.Skew)
+	if !ok {
+		confirmMap["MFAError"] = erro.ErrorInvalidMFACode
+		return &ServiceResponse{Success: false, Errors: confirmMap}
+	}
+	codes, hashes, err := generateRecoveryCodes(as.mfa.RecoveryCodes)
+	if err != nil {
+		log.Printf("Error generating recovery codes: %v", err)
+		confirmMap["MFAError"] = erro.ErrorGenerateToken
+		return &ServiceResponse{Success: false, Errors: confirmMap}
+	}
+
+	var tx *sql.Tx
+	tx, err = as.dbrepo.BeginTx(ctx)
+	if err != nil {
+		log.Printf("TransactionError %v", err)
+		confirmMap["TransactionError"] = erro.ErrorStartTransaction
+		return &ServiceResponse{Success: false, Errors: confirmMap}
+	}
+	defer func() {
+		if !response.Success && tx != nil {
+			if rErr := as.dbrepo.RollbackTx(ctx, tx); rErr != nil {
+				log.Printf("Error rolling back transaction: %v", rErr)
+			}
+		}
+	}()
+	if enableResponse := as.mfarepo.EnableTOTP(ctx, tx, userID, step); !enableResponse.Success {
+		log.Printf("Error when enabling TOTP for %s: %v", userID, enableResponse.Errors)
+		confirmMap["MFAError"] = enableResponse.Errors
+		return &ServiceResponse{Success: false, Errors: confirmMap}
+	}
+	if codesResponse := as.mfarepo.ReplaceRecoveryCodes(ctx, tx, userID, hashes); !codesResponse.Success {
+		log.Printf("Error when storing the recovery codes of %s: %v", userID, codesResponse.Errors)
+		confirmMap["MFAError"] = codesResponse.Errors
+		return &ServiceResponse{Success: false, Errors: confirmMap}
+	}
+	if err := as.dbrepo.CommitTx(ctx, tx); err != nil {
+		log.Printf("Transaction commit error: %v", err)
+		confirmMap["CommitError"] = erro.ErrorCommitTransaction
+		return &ServiceResponse{Success: false, Errors: confirmMap}
+	}
+	log.Printf("TOTP enabled for %s", userID)
+	return &ServiceResponse{Success: true, UserId: userID, RecoveryCodes: codes}
+}
+
+// DisableTOTP turns two-factor authentication off after checking the password.
+func (as *AuthService) DisableTOTP(ctx context.Context, userID uuid.UUID, password string) *ServiceResponse {
+	disableMap := make(map[string]error)
+	if ctx.Err() != nil {
+		log.Printf("DisableTOTP: Context cancelled before checkPassword: %v", ctx.Err())
+		disableMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: disableMap}
+	}
+	if err := as.checkPassword(ctx, userID, password); err != nil {
+		log.Printf("Error when checking the password of %s: %v", userID, err)
+		disableMap["CurrentPassword"] = err
+		return &ServiceResponse{Success: false, Errors: disableMap}
+	}
+	disableResponse := as.mfarepo.DisableTOTP(ctx, nil, userID)
+	if !disableResponse.Success {
+		log.Printf("Error when disabling TOTP for %s: %v", userID, disableResponse.Errors)
+		disableMap["MFAError"] = disableResponse.Errors
+		return &ServiceResponse{Success: false, Errors: disableMap}
+	}
+	log.Printf("TOTP disabled for %s", userID)
+	return &ServiceResponse{Success: true, UserId: userID}
+}
+
+// CompleteMFALogin finishes a password login that was answered with a challenge and creates
+// the cookie session.
+func (as *AuthService) CompleteMFALogin(ctx context.Context, challenge, code, clientIP string) *ServiceResponse {
+	mfaMap := make(map[string]error)
+	userID, failure := as.verifyMFAChallenge(ctx, mfaSessionPurpose, challenge, code, clientIP, mfaMap)
+	if failure != nil {
+		return failure
+	}
+	return as.startSession(ctx, userID, mfaMap)
+}
+
+// CompleteMFATokens is CompleteMFALogin for the token endpoint.
+func (as *AuthService) CompleteMFATokens(ctx context.Context, challenge, code, clientIP string) *ServiceResponse {
+	mfaMap := make(map[string]error)
+	userID, failure := as.verifyMFAChallenge(ctx, mfaTokenPurpose, challenge, code, clientIP, mfaMap)
+	if failure != nil {
+		return failure
+	}
+	return as.startTokenFamily(ctx, userID, mfaMap)
+}
+
+// requireMFA answers a correct password with a challenge when the user has TOTP enabled. It
+// returns nil when the login can go on without a second factor.
+func (as *AuthService) requireMFA(ctx context.Context, userID uuid.UUID, purpose string, errMap map[string]error) *ServiceResponse {
+	enrollment, err := as.totpEnrollment(ctx, userID)
+	if errors.Is(err, erro.ErrorMFANotEnrolled) {
+		return nil
+	}
+	if err != nil {
+		errMap["MFAError"] = err
+		return &ServiceResponse{Success: false, Errors: errMap}
+	}
+	if !enrollment.Enabled {
+		return nil
+	}
+	challenge, err := generateToken()
+	if err != nil {
+		log.Printf("Error generating MFA challenge: %v", err)
+		errMap["MFAError"] = erro.ErrorGenerateToken
+		return &ServiceResponse{Success: false, Errors: errMap}
+	}
+	tokenResponse := as.onetimerepo.SetOneTimeToken(ctx, purpose, hashToken(challenge), userID, "", as.mfa.ChallengeTTL)
+	if !tokenResponse.Success {
+		log.Printf("Error when storing the MFA challenge: %v", tokenResponse.Errors)
+		errMap["MFAError"] = tokenResponse.Errors
+		return &ServiceResponse{Success: false, Errors: errMap}
+	}
+	log.Printf("Password of %s accepted, waiting for the second factor", userID)
+	return &ServiceResponse{Success: true, MFAChallenge: challenge, ExpirationTime: time.Now().Add(as.mfa.ChallengeTTL)}
+}
+
+// verifyMFAChallenge checks a code against the challenge. The challenge survives a wrong code,
+// but every wrong code counts as a failed login, so guessing runs into the login lockout.
+func (as *AuthService) verifyMFAChallenge(ctx context.Context, purpose, challenge, code, clientIP string, errMap map[string]error) (uuid.UUID, *ServiceResponse) {
+	if challenge == "" {
+		errMap["MFAError"] = erro.ErrorInvalidMFAChallenge
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("verifyMFAChallenge: Context cancelled before PeekOneTimeToken: %v", ctx.Err())
+		errMap["ContextError"] = erro.ErrorContextTimeout
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	challengeHash := hashToken(challenge)
+	tokenResponse := as.onetimerepo.PeekOneTimeToken(ctx, purpose, challengeHash)
+	if !tokenResponse.Success {
+		log.Printf("Error when reading an MFA challenge: %v", tokenResponse.Errors)
+		errMap["MFAError"] = mfaChallengeError(tokenResponse.Errors)
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	tokenData, ok := tokenResponse.Data.(repository.RedisOneTimeTokenResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", tokenResponse.Data)
+		errMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	userID := tokenData.UserID
+	user, err := as.userByID(ctx, userID)
+	if err != nil {
+		log.Printf("Error when getting the user %s: %v", userID, err)
+		errMap["GetUserError"] = err
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+
+	subjects := as.loginSubjects(user.Email, clientIP)
+	retryAfter, err := as.loginLock(ctx, subjects)
+	if err != nil {
+		log.Printf("Error checking login lock: %v", err)
+		errMap["AuthenticateError"] = err
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	if retryAfter > 0 {
+		log.Printf("MFA attempt rejected, subject is locked for %v", retryAfter)
+		errMap["AuthenticateError"] = erro.ErrorTooManyAttempts
+		return uuid.Nil, &ServiceResponse{Success: false, RetryAfter: retryAfter, Errors: errMap}
+	}
+	if err := as.checkMFACode(ctx, userID, code); err != nil {
+		if errors.Is(err, erro.ErrorInvalidMFACode) {
+			as.registerLoginFailure(ctx, subjects, &userID, clientIP)
+		}
+		errMap["MFAError"] = err
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+
+	// The challenge is consumed only now; losing the race to a concurrent request means the
+	// other request got the session.
+	consumeResponse := as.onetimerepo.ConsumeOneTimeToken(ctx, purpose, challengeHash)
+	if !consumeResponse.Success {
+		log.Printf("Error when consuming an MFA challenge: %v", consumeResponse.Errors)
+		errMap["MFAError"] = mfaChallengeError(consumeResponse.Errors)
+		return uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	if resetResponse := as.loginrepo.ResetLoginFailures(ctx, subjects[0].key); !resetResponse.Success {
+		log.Printf("Error resetting login failures: %v", resetResponse.Errors)
+	}
+	return userID, nil
+}
+
+// checkMFACode accepts a current TOTP code or an unused recovery code.
+func (as *AuthService) checkMFACode(ctx context.Context, userID uuid.UUID, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		enrollment, err := as.totpEnrollment(ctx, userID)
+		if err != nil {
+			return err
+		}
+		step, ok := totp.Validate(enrollment.Secret, code, time.Now(), as.mfa.Skew)
+		if !enrollment.Enabled || !ok {
+			return erro.ErrorInvalidMFACode
+		}
+		if stepResponse := as.mfarepo.UseTOTPStep(ctx, userID, step); !stepResponse.Success {
+			log.Printf("TOTP code of %s refused: %v", userID, stepResponse.Errors)
+			return stepResponse.Errors
+		}
+		return nil
+	}
+	if ctx.Err() != nil {
+		return erro.ErrorContextTimeout
+	}
+	recoveryResponse := as.mfarepo.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+	if !recoveryResponse.Success {
+		log.Printf("Recovery code of %s refused: %v", userID, recoveryResponse.Errors)
+		return recoveryResponse.Errors
+	}
+	log.Printf("Recovery code used by %s", userID)
+	return nil
+}
+
+func (as *AuthService) totpEnrollment(ctx context.Context, userID uuid.UUID) (repository.DBMFAResponseData, error) {
+	if ctx.Err() != nil {
+		return repository.DBMFAResponseData{}, erro.ErrorContextTimeout
+	}
+	response := as.mfarepo.GetTOTP(ctx, userID)
+	if !response.Success {
+		if !errors.Is(response.Errors, erro.ErrorMFANotEnrolled) {
+			log.Printf("Error when getting the TOTP enrollment of %s: %v", userID, response.Errors)
+		}
+		return repository.DBMFAResponseData{}, response.Errors
+	}
+	enrollment, ok := response.Data.(repository.DBMFAResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		return repository.DBMFAResponseData{}, erro.ErrorUnexpectedData
+	}
+	return enrollment, nil
+}
+
+func mfaChallengeError(err error) error {
+	if errors.Is(err, erro.ErrorInvalidOneTimeToken) {
+		return erro.ErrorInvalidMFAChallenge
+	}
+	return err
+}
+
+// generateRecoveryCodes returns n codes formatted as xxxx-xxxx together with their hashes.
+func generateRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+	buf := make([]byte, recoveryCodeBytes)
+	for i := 0; i < n; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))
+		codes = append(codes, raw[:4]+"-"+raw[4:])
+		hashes = append(hashes, hashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"auth_service/internal/totp"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMFARepo struct {
+	enrollments map[uuid.UUID]repository.DBMFAResponseData
+	// recovery maps a code hash to whether it is still unused.
+	recovery map[uuid.UUID]map[string]bool
+}
+
+func newFakeMFARepo() *fakeMFARepo {
+	return &fakeMFARepo{enrollments: make(map[uuid.UUID]repository.DBMFAResponseData), recovery: make(map[uuid.UUID]map[string]bool)}
+}
+
+func (f *fakeMFARepo) GetTOTP(ctx context.Context, userId uuid.UUID) *repository.RepositoryResponse {
+	data, ok := f.enrollments[userId]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorMFANotEnrolled}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: data}
+}
+func (f *fakeMFARepo) SaveTOTPSecret(ctx context.Context, userId uuid.UUID, secret string) *repository.RepositoryResponse {
+	if f.enrollments[userId].Enabled {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorMFAAlreadyEnabled}
+	}
+	f.enrollments[userId] = repository.DBMFAResponseData{Secret: secret}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: userId}}
+}
+func (f *fakeMFARepo) EnableTOTP(ctx context.Context, tx *sql.Tx, userId uuid.UUID, step int64) *repository.RepositoryResponse {
+	data, ok := f.enrollments[userId]
+	if !ok || data.Enabled {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorMFAAlreadyEnabled}
+	}
+	data.Enabled, data.LastUsedStep = true, step
+	f.enrollments[userId] = data
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: userId}}
+}
+func (f *fakeMFARepo) UseTOTPStep(ctx context.Context, userId uuid.UUID, step int64) *repository.RepositoryResponse {
+	data, ok := f.enrollments[userId]
+	if !ok || !data.Enabled || data.LastUsedStep >= step {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorInvalidMFACode}
+	}
+	data.LastUsedStep = step
+	f.enrollments[userId] = data
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: userId}}
+}
+func (f *fakeMFARepo) DisableTOTP(ctx context.Context, tx *sql.Tx, userId uuid.UUID) *repository.RepositoryResponse {
+	if _, ok := f.enrollments[userId]; !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorMFANotEnrolled}
+	}
+	delete(f.enrollments, userId)
+	delete(f.recovery, userId)
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: userId}}
+}
+func (f *fakeMFARepo) ReplaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userId uuid.UUID, codeHashes []string) *repository.RepositoryResponse {
+	f.recovery[userId] = make(map[string]bool)
+	for _, codeHash := range codeHashes {
+		f.recovery[userId][codeHash] = true
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: userId}}
+}
+func (f *fakeMFARepo) UseRecoveryCode(ctx context.Context, userId uuid.UUID, codeHash string) *repository.RepositoryResponse {
+	if !f.recovery[userId][codeHash] {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorInvalidMFACode}
+	}
+	f.recovery[userId][codeHash] = false
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: userId}}
+}
+
+func TestAuthService_TOTPLogin(t *testing.T) {
+	userID := uuid.New()
+	redisRepo := &fakeRedisRepo{}
+	mfa := newFakeMFARepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	mfaConfig := configs.MFAConfig{Issuer: "Auth Service", ChallengeTTL: time.Minute, RecoveryCodes: 3, Skew: 1}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID, password: "password123"}, RedisSessionRepos: redisRepo, DBMFARepos: mfa, DBOutboxRepos: outbox}, config: AuthServiceConfig{MFA: mfaConfig}})
+	ctx := context.Background()
+	person := &model.Person{Email: "tester@example.com", Password: "password123"}
+
+	enrolled := as.EnrollTOTP(ctx, userID)
+	require.True(t, enrolled.Success, "Подключение должно пройти: %v", enrolled.Errors)
+	assert.Contains(t, enrolled.TOTPURI, "otpauth://totp/", "URI должен быть в формате otpauth")
+
+	pending := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, pending.Success, "Вход должен пройти: %v", pending.Errors)
+	assert.Empty(t, pending.MFAChallenge, "Неподтвержденный TOTP не должен требовать второй фактор")
+
+	wrong := as.ConfirmTOTP(ctx, userID, "000000")
+	assert.False(t, wrong.Success, "Неверный код не должен подтверждать подключение")
+	code, err := totp.Code(enrolled.TOTPSecret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	confirmed := as.ConfirmTOTP(ctx, userID, code)
+	require.True(t, confirmed.Success, "Подтверждение должно пройти: %v", confirmed.Errors)
+	require.Len(t, confirmed.RecoveryCodes, 3, "Количество кодов восстановления должно совпадать")
+	for _, stored := range mfa.recovery[userID] {
+		assert.True(t, stored, "Коды восстановления должны быть неиспользованными")
+	}
+	assert.NotContains(t, mfa.recovery[userID], confirmed.RecoveryCodes[0], "Коды восстановления хранятся только в виде хэшей")
+
+	challenged := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, challenged.Success, "Пароль должен приниматься: %v", challenged.Errors)
+	require.NotEmpty(t, challenged.MFAChallenge, "Должен вернуться challenge второго фактора")
+	assert.Empty(t, challenged.SessionId, "Сессия не должна создаваться до проверки кода")
+
+	replayed := as.CompleteMFALogin(ctx, challenged.MFAChallenge, code, "192.0.2.1")
+	assert.False(t, replayed.Success, "Уже использованный код не должен приниматься")
+	assert.Equal(t, erro.ErrorInvalidMFACode, replayed.Errors["MFAError"], "Тип ошибки должен совпадать")
+
+	next, err := totp.Code(enrolled.TOTPSecret, totp.Step(time.Now())+1)
+	require.NoError(t, err)
+	completed := as.CompleteMFALogin(ctx, challenged.MFAChallenge, next, "192.0.2.1")
+	require.True(t, completed.Success, "Вход со вторым фактором должен пройти: %v", completed.Errors)
+	assert.Equal(t, userID, completed.UserId, "UserId должен совпадать")
+	assert.Contains(t, redisRepo.sessions, completed.SessionId, "Сессия должна быть создана")
+
+	again := as.CompleteMFALogin(ctx, challenged.MFAChallenge, confirmed.RecoveryCodes[0], "192.0.2.1")
+	assert.Equal(t, erro.ErrorInvalidMFAChallenge, again.Errors["MFAError"], "Challenge используется один раз")
+
+	tokenChallenge := as.IssueTokens(ctx, person, "192.0.2.1")
+	require.NotEmpty(t, tokenChallenge.MFAChallenge, "Выдача токенов тоже требует второй фактор")
+	recovered := as.CompleteMFATokens(ctx, tokenChallenge.MFAChallenge, confirmed.RecoveryCodes[0], "192.0.2.1")
+	require.True(t, recovered.Success, "Код восстановления должен приниматься: %v", recovered.Errors)
+	assert.NotEmpty(t, recovered.AccessToken, "AccessToken должен быть выдан")
+
+	tokenChallenge = as.IssueTokens(ctx, person, "192.0.2.1")
+	reused := as.CompleteMFATokens(ctx, tokenChallenge.MFAChallenge, confirmed.RecoveryCodes[0], "192.0.2.1")
+	assert.Equal(t, erro.ErrorInvalidMFACode, reused.Errors["MFAError"], "Код восстановления используется один раз")
+
+	assert.False(t, as.DisableTOTP(ctx, userID, "wrongpassword").Success, "Отключение требует верного пароля")
+	require.True(t, as.DisableTOTP(ctx, userID, "password123").Success, "Отключение должно пройти")
+	plain := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	assert.NotEmpty(t, plain.SessionId, "После отключения вход снова выдает сессию")
+}
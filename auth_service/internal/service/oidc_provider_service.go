@@ -0,0 +1,393 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/identity"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultAuthCodeTTL = time.Minute
+
+// Scopes of the OpenID provider. openid is required; email and profile add the matching claims
+// to the ID token.
+const (
+	scopeOpenID  = "openid"
+	scopeEmail   = "email"
+	scopeProfile = "profile"
+)
+
+const (
+	grantAuthorizationCode = "authorization_code"
+	grantRefreshToken      = "refresh_token"
+)
+
+func withOIDCProviderDefaults(cfg configs.OIDCProviderConfig) configs.OIDCProviderConfig {
+	if cfg.CodeTTL <= 0 {
+		cfg.CodeTTL = defaultAuthCodeTTL
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return cfg
+}
+
+// NewOAuthClient prepares the registration of a client and returns it together with its
+// secret. Only the hash of the secret is stored, so it can be shown once. Public clients get
+// no secret. Redirect URIs have to be absolute and are later compared exactly.
+func NewOAuthClient(name string, redirectURIs []string, public bool) (model.OAuthClient, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return model.OAuthClient{}, "", fmt.Errorf("client name is required")
+	}
+	if len(redirectURIs) == 0 {
+		return model.OAuthClient{}, "", fmt.Errorf("at least one redirect URI is required")
+	}
+	for _, redirectURI := range redirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" || parsed.Fragment != "" || strings.Contains(redirectURI, ",") {
+			return model.OAuthClient{}, "", fmt.Errorf("invalid redirect URI %q", redirectURI)
+		}
+	}
+	clientID, err := generateToken()
+	if err != nil {
+		return model.OAuthClient{}, "", err
+	}
+	client := model.OAuthClient{ClientID: clientID, Name: name, RedirectURIs: redirectURIs, Public: public}
+	if public {
+		return client, "", nil
+	}
+	secret, err := generateToken()
+	if err != nil {
+		return model.OAuthClient{}, "", err
+	}
+	client.SecretHash = hashToken(secret)
+	return client, secret, nil
+}
+
+// OpenIDConfiguration describes the provider for discovery. ID tokens are signed with the
+// access token keys, so jwks_uri is the JWKS other services already use.
+func (as *AuthService) OpenIDConfiguration() *ServiceResponse {
+	configMap := make(map[string]error)
+	if as.oidcProvider.Issuer == "" {
+		configMap["OIDCError"] = erro.ErrorOIDCProviderDisabled
+		return &ServiceResponse{Success: false, Errors: configMap}
+	}
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, key := range as.keys.JWKS().Keys {
+		if !seen[key.Alg] {
+			seen[key.Alg] = true
+			algorithms = append(algorithms, key.Alg)
+		}
+	}
+	issuer := as.oidcProvider.Issuer
+	return &ServiceResponse{Success: true, OpenIDConfiguration: &model.OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth2/authorize",
+		TokenEndpoint:                     issuer + "/oauth2/token",
+		UserinfoEndpoint:                  issuer + "/oauth2/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{grantAuthorizationCode, grantRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		ScopesSupported:                   []string{scopeOpenID, scopeEmail, scopeProfile},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "iat", "exp", "nonce", "email", "email_verified", "name"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+	}}
+}
+
+// CheckAuthorizationRequest validates an authorization request before the user is asked to sign
+// in. ErrorUnknownOAuthClient and ErrorInvalidRedirectURI mean the browser must not be sent
+// back to the redirect URI; ErrorInvalidAuthRequest can be reported to it. PKCE with S256 is
+// required from every client. On success RedirectURL is the login page to open when the
+// browser has no session, carrying the request as its query, or empty when none is configured.
+func (as *AuthService) CheckAuthorizationRequest(ctx context.Context, request model.AuthorizationRequest) *ServiceResponse {
+	checkMap := make(map[string]error)
+	if as.oidcProvider.Issuer == "" {
+		checkMap["OIDCError"] = erro.ErrorOIDCProviderDisabled
+		return &ServiceResponse{Success: false, Errors: checkMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("CheckAuthorizationRequest: Context cancelled before GetClient: %v", ctx.Err())
+		checkMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: checkMap}
+	}
+	client, failure := as.getOAuthClient(ctx, request.ClientID, checkMap)
+	if failure != nil {
+		return failure
+	}
+	if !slices.Contains(client.RedirectURIs, request.RedirectURI) {
+		log.Printf("Client %s asked for the unregistered redirect URI %q", client.ClientID, request.RedirectURI)
+		checkMap["OIDCError"] = erro.ErrorInvalidRedirectURI
+		return &ServiceResponse{Success: false, Errors: checkMap}
+	}
+	if request.ResponseType != "code" || !hasScope(request.Scope, scopeOpenID) || request.CodeChallenge == "" || request.CodeChallengeMethod != "S256" {
+		log.Printf("Client %s sent an unsupported authorization request", client.ClientID)
+		checkMap["OIDCError"] = erro.ErrorInvalidAuthRequest
+		return &ServiceResponse{Success: false, Errors: checkMap}
+	}
+	var loginURL string
+	if as.oidcProvider.LoginURL != "" {
+		login, err := url.Parse(as.oidcProvider.LoginURL)
+		if err != nil {
+			log.Printf("Invalid login URL %q: %v", as.oidcProvider.LoginURL, err)
+			checkMap["OIDCError"] = erro.ErrorInternalServer
+			return &ServiceResponse{Success: false, Errors: checkMap}
+		}
+		query := login.Query()
+		for key, values := range authorizationQuery(request) {
+			query[key] = values
+		}
+		login.RawQuery = query.Encode()
+		loginURL = login.String()
+	}
+	return &ServiceResponse{Success: true, RedirectURL: loginURL}
+}
+
+// Authorize issues an authorization code to the signed in user and returns the redirect URI of
+// the client carrying it.
+func (as *AuthService) Authorize(ctx context.Context, request model.AuthorizationRequest, userID uuid.UUID) *ServiceResponse {
+	check := as.CheckAuthorizationRequest(ctx, request)
+	if !check.Success {
+		return check
+	}
+	authorizeMap := make(map[string]error)
+	code, err := generateToken()
+	if err != nil {
+		log.Printf("Error generating authorization code: %v", err)
+		authorizeMap["OIDCError"] = erro.ErrorGenerateToken
+		return &ServiceResponse{Success: false, Errors: authorizeMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("Authorize: Context cancelled before SetAuthorizationCode: %v", ctx.Err())
+		authorizeMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: authorizeMap}
+	}
+	codeResponse := as.authcoderepo.SetAuthorizationCode(ctx, hashToken(code), model.AuthorizationCode{
+		ClientID:      request.ClientID,
+		RedirectURI:   request.RedirectURI,
+		UserID:        userID,
+		Scope:         request.Scope,
+		Nonce:         request.Nonce,
+		CodeChallenge: request.CodeChallenge,
+	}, as.oidcProvider.CodeTTL)
+	if !codeResponse.Success {
+		log.Printf("Error when storing the authorization code: %v", codeResponse.Errors)
+		authorizeMap["OIDCError"] = codeResponse.Errors
+		return &ServiceResponse{Success: false, Errors: authorizeMap}
+	}
+	redirect, err := url.Parse(request.RedirectURI)
+	if err != nil {
+		log.Printf("Invalid redirect URI %q: %v", request.RedirectURI, err)
+		authorizeMap["OIDCError"] = erro.ErrorInvalidRedirectURI
+		return &ServiceResponse{Success: false, Errors: authorizeMap}
+	}
+	query := redirect.Query()
+	query.Set("code", code)
+	query.Set("iss", as.oidcProvider.Issuer)
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	redirect.RawQuery = query.Encode()
+	log.Printf("Person with id: %v has authorized client %s", userID, request.ClientID)
+	return &ServiceResponse{Success: true, UserId: userID, RedirectURL: redirect.String()}
+}
+
+// ExchangeClientToken answers the token endpoint. An authorization code is redeemed once, by
+// the client it was issued to, with the redirect URI and the PKCE verifier of its request, for
+// a token family like the one of /token plus an ID token. A refresh token is rotated the same
+// way as through /token/refresh.
+func (as *AuthService) ExchangeClientToken(ctx context.Context, request model.TokenRequest) *ServiceResponse {
+	exchangeMap := make(map[string]error)
+	if as.oidcProvider.Issuer == "" {
+		exchangeMap["OIDCError"] = erro.ErrorOIDCProviderDisabled
+		return &ServiceResponse{Success: false, Errors: exchangeMap}
+	}
+	if request.GrantType != grantAuthorizationCode && request.GrantType != grantRefreshToken {
+		exchangeMap["OIDCError"] = erro.ErrorUnsupportedGrantType
+		return &ServiceResponse{Success: false, Errors: exchangeMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("ExchangeClientToken: Context cancelled before GetClient: %v", ctx.Err())
+		exchangeMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: exchangeMap}
+	}
+	client, failure := as.getOAuthClient(ctx, request.ClientID, exchangeMap)
+	if failure != nil {
+		if exchangeMap["OIDCError"] == erro.ErrorUnknownOAuthClient {
+			exchangeMap["OIDCError"] = erro.ErrorInvalidClientCredentials
+		}
+		return failure
+	}
+	if !clientSecretMatches(client, request.ClientSecret) {
+		log.Printf("Client %s failed to authenticate", client.ClientID)
+		exchangeMap["OIDCError"] = erro.ErrorInvalidClientCredentials
+		return &ServiceResponse{Success: false, Errors: exchangeMap}
+	}
+	if request.GrantType == grantRefreshToken {
+		return as.RefreshTokens(ctx, request.RefreshToken)
+	}
+
+	codeResponse := as.authcoderepo.ConsumeAuthorizationCode(ctx, hashToken(request.Code))
+	if !codeResponse.Success {
+		log.Printf("Error when consuming the authorization code: %v", codeResponse.Errors)
+		exchangeMap["OIDCError"] = codeResponse.Errors
+		return &ServiceResponse{Success: false, Errors: exchangeMap}
+	}
+	codeData, ok := codeResponse.Data.(repository.RedisAuthCodeResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", codeResponse.Data)
+		exchangeMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: exchangeMap}
+	}
+	issued := codeData.Code
+	if issued.ClientID != client.ClientID || issued.RedirectURI != request.RedirectURI ||
+		subtle.ConstantTimeCompare([]byte(identity.CodeChallenge(request.CodeVerifier)), []byte(issued.CodeChallenge)) != 1 {
+		log.Printf("Authorization code presented by client %s does not match its request", client.ClientID)
+		exchangeMap["OIDCError"] = erro.ErrorInvalidAuthCode
+		return &ServiceResponse{Success: false, Errors: exchangeMap}
+	}
+
+	tokens := as.startTokenFamily(ctx, issued.UserID, exchangeMap)
+	if !tokens.Success {
+		return tokens
+	}
+	idToken, err := as.signIDToken(ctx, issued, tokens.ExpirationTime)
+	if err != nil {
+		log.Printf("Error signing ID token: %v", err)
+		exchangeMap["TokenError"] = erro.ErrorGenerateToken
+		return &ServiceResponse{Success: false, Errors: exchangeMap}
+	}
+	tokens.IDToken = idToken
+	log.Printf("Client %s has redeemed an authorization code of %v", client.ClientID, issued.UserID)
+	return tokens
+}
+
+// UserInfo returns the claims of the userinfo endpoint.
+func (as *AuthService) UserInfo(ctx context.Context, userID uuid.UUID) *ServiceResponse {
+	userInfoMap := make(map[string]error)
+	if as.oidcProvider.Issuer == "" {
+		userInfoMap["OIDCError"] = erro.ErrorOIDCProviderDisabled
+		return &ServiceResponse{Success: false, Errors: userInfoMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("UserInfo: Context cancelled before GetUserByID: %v", ctx.Err())
+		userInfoMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: userInfoMap}
+	}
+	response := as.dbrepo.GetUserByID(ctx, userID)
+	if !response.Success {
+		log.Printf("Error when getting the user: %v", response.Errors)
+		userInfoMap["GetUserError"] = response.Errors
+		return &ServiceResponse{Success: false, Errors: userInfoMap}
+	}
+	userData, ok := response.Data.(repository.DBUserResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		userInfoMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: userInfoMap}
+	}
+	return &ServiceResponse{Success: true, UserId: userID, Profile: &model.Profile{Id: userData.UserId, Name: userData.Name, Email: userData.Email, EmailVerified: userData.EmailVerified}}
+}
+
+type idTokenClaims struct {
+	Issuer        string `json:"iss"`
+	Subject       string `json:"sub"`
+	Audience      string `json:"aud"`
+	IssuedAt      int64  `json:"iat"`
+	ExpiresAt     int64  `json:"exp"`
+	Nonce         string `json:"nonce,omitempty"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+}
+
+// signIDToken signs the ID token for a redeemed code. It expires with the access token issued
+// alongside it and carries the email and profile claims the client asked for.
+func (as *AuthService) signIDToken(ctx context.Context, issued model.AuthorizationCode, expiresAt time.Time) (string, error) {
+	claims := idTokenClaims{
+		Issuer:    as.oidcProvider.Issuer,
+		Subject:   issued.UserID.String(),
+		Audience:  issued.ClientID,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: expiresAt.Unix(),
+		Nonce:     issued.Nonce,
+	}
+	if hasScope(issued.Scope, scopeEmail) || hasScope(issued.Scope, scopeProfile) {
+		userInfo := as.UserInfo(ctx, issued.UserID)
+		if !userInfo.Success {
+			return "", fmt.Errorf("get user: %v", userInfo.Errors)
+		}
+		if hasScope(issued.Scope, scopeEmail) {
+			claims.Email = userInfo.Profile.Email
+			claims.EmailVerified = &userInfo.Profile.EmailVerified
+		}
+		if hasScope(issued.Scope, scopeProfile) {
+			claims.Name = userInfo.Profile.Name
+		}
+	}
+	return as.keys.SignPayload(claims)
+}
+
+func (as *AuthService) getOAuthClient(ctx context.Context, clientID string, errMap map[string]error) (model.OAuthClient, *ServiceResponse) {
+	if clientID == "" {
+		errMap["OIDCError"] = erro.ErrorUnknownOAuthClient
+		return model.OAuthClient{}, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	response := as.clientrepo.GetClient(ctx, clientID)
+	if !response.Success {
+		log.Printf("Error when getting client %s: %v", clientID, response.Errors)
+		errMap["OIDCError"] = response.Errors
+		return model.OAuthClient{}, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	clientData, ok := response.Data.(repository.DBOAuthClientResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		errMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return model.OAuthClient{}, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	return clientData.Client, nil
+}
+
+// clientSecretMatches authenticates a client at the token endpoint. Public clients have no
+// secret and must not send one.
+func clientSecretMatches(client model.OAuthClient, secret string) bool {
+	if client.Public {
+		return secret == ""
+	}
+	return secret != "" && subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(client.SecretHash)) == 1
+}
+
+func authorizationQuery(request model.AuthorizationRequest) url.Values {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"client_id":             request.ClientID,
+		"redirect_uri":          request.RedirectURI,
+		"response_type":         request.ResponseType,
+		"scope":                 request.Scope,
+		"state":                 request.State,
+		"nonce":                 request.Nonce,
+		"code_challenge":        request.CodeChallenge,
+		"code_challenge_method": request.CodeChallengeMethod,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	return query
+}
+
+func hasScope(scope, want string) bool {
+	return slices.Contains(strings.Fields(scope), want)
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/identity"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"encoding/json"
+	"net/url"
+	"shared/jwtverify"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOAuthClientRepo struct {
+	clients map[string]model.OAuthClient
+}
+
+func newFakeOAuthClientRepo() *fakeOAuthClientRepo {
+	return &fakeOAuthClientRepo{clients: make(map[string]model.OAuthClient)}
+}
+
+func (f *fakeOAuthClientRepo) CreateClient(ctx context.Context, client model.OAuthClient) *repository.RepositoryResponse {
+	f.clients[client.ClientID] = client
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBOAuthClientResponseData{Client: client}}
+}
+func (f *fakeOAuthClientRepo) GetClient(ctx context.Context, clientID string) *repository.RepositoryResponse {
+	client, ok := f.clients[clientID]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorUnknownOAuthClient}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBOAuthClientResponseData{Client: client}}
+}
+
+type fakeAuthCodeRepo struct {
+	codes map[string]model.AuthorizationCode
+}
+
+func newFakeAuthCodeRepo() *fakeAuthCodeRepo {
+	return &fakeAuthCodeRepo{codes: make(map[string]model.AuthorizationCode)}
+}
+
+func (f *fakeAuthCodeRepo) SetAuthorizationCode(ctx context.Context, codeHash string, code model.AuthorizationCode, expiration time.Duration) *repository.RepositoryResponse {
+	f.codes[codeHash] = code
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisAuthCodeResponseData{Code: code}}
+}
+func (f *fakeAuthCodeRepo) ConsumeAuthorizationCode(ctx context.Context, codeHash string) *repository.RepositoryResponse {
+	code, ok := f.codes[codeHash]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorInvalidAuthCode}
+	}
+	delete(f.codes, codeHash)
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisAuthCodeResponseData{Code: code}}
+}
+
+const testRedirectURI = "https://billing.example.com/callback"
+
+func newTestProvider(t *testing.T, userID uuid.UUID) (*AuthService, *fakeOAuthClientRepo, *jwtverify.Verifier) {
+	clients := newFakeOAuthClientRepo()
+	keys := newTestKeys(t)
+	oidcProvider := configs.OIDCProviderConfig{Issuer: "https://auth.example.com/", LoginURL: "https://app.example.com/login?theme=dark"}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID, verified: map[uuid.UUID]bool{userID: true}}, DBOAuthClientRepos: clients}, config: AuthServiceConfig{OIDCProvider: oidcProvider}, keys: keys})
+	return as, clients, jwtverify.NewVerifier(keys, "https://auth.example.com")
+}
+
+func newTestAuthorizationRequest(clientID, verifier string) model.AuthorizationRequest {
+	return model.AuthorizationRequest{
+		ClientID:            clientID,
+		RedirectURI:         testRedirectURI,
+		ResponseType:        "code",
+		Scope:               "openid email profile",
+		State:               "client-state",
+		Nonce:               "client-nonce",
+		CodeChallenge:       identity.CodeChallenge(verifier),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+func TestNewOAuthClient(t *testing.T) {
+	client, secret, err := NewOAuthClient("Billing", []string{testRedirectURI}, false)
+	require.NoError(t, err)
+	assert.NotEmpty(t, client.ClientID)
+	assert.NotEmpty(t, secret, "Конфиденциальный клиент получает секрет")
+	assert.Equal(t, hashToken(secret), client.SecretHash, "Секрет не должен храниться в открытом виде")
+
+	public, secret, err := NewOAuthClient("Mobile", []string{"http://localhost:3000/callback"}, true)
+	require.NoError(t, err)
+	assert.Empty(t, secret, "Публичный клиент не получает секрет")
+	assert.Empty(t, public.SecretHash)
+
+	_, _, err = NewOAuthClient("Billing", []string{"/callback"}, false)
+	assert.Error(t, err, "Redirect URI должен быть абсолютным")
+	_, _, err = NewOAuthClient("Billing", nil, false)
+	assert.Error(t, err, "Нужен хотя бы один redirect URI")
+}
+
+func TestAuthService_AuthorizationCodeFlow(t *testing.T) {
+	userID := uuid.New()
+	as, clients, verifier := newTestProvider(t, userID)
+	client, secret, err := NewOAuthClient("Billing", []string{testRedirectURI}, false)
+	require.NoError(t, err)
+	clients.CreateClient(context.Background(), client)
+	ctx := context.Background()
+
+	discovery := as.OpenIDConfiguration()
+	require.True(t, discovery.Success)
+	assert.Equal(t, "https://auth.example.com", discovery.OpenIDConfiguration.Issuer, "Issuer не должен заканчиваться слешем")
+	assert.Equal(t, "https://auth.example.com/oauth2/token", discovery.OpenIDConfiguration.TokenEndpoint)
+	assert.Equal(t, []string{jwtverify.AlgEdDSA}, discovery.OpenIDConfiguration.IDTokenSigningAlgValuesSupported)
+
+	request := newTestAuthorizationRequest(client.ClientID, "verifier-1")
+	check := as.CheckAuthorizationRequest(ctx, request)
+	require.True(t, check.Success, "Запрос должен быть принят: %v", check.Errors)
+	login, err := url.Parse(check.RedirectURL)
+	require.NoError(t, err)
+	assert.Equal(t, "app.example.com", login.Host)
+	assert.Equal(t, "dark", login.Query().Get("theme"), "Параметры страницы входа должны сохраняться")
+	assert.Equal(t, client.ClientID, login.Query().Get("client_id"), "Страница входа получает запрос авторизации")
+
+	authorized := as.Authorize(ctx, request, userID)
+	require.True(t, authorized.Success, "Авторизация должна пройти: %v", authorized.Errors)
+	callback, err := url.Parse(authorized.RedirectURL)
+	require.NoError(t, err)
+	assert.Equal(t, testRedirectURI, callback.Scheme+"://"+callback.Host+callback.Path)
+	assert.Equal(t, "client-state", callback.Query().Get("state"), "State должен вернуться клиенту")
+	code := callback.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	exchange := model.TokenRequest{GrantType: "authorization_code", ClientID: client.ClientID, ClientSecret: secret, Code: code, RedirectURI: testRedirectURI, CodeVerifier: "verifier-1"}
+	tokens := as.ExchangeClientToken(ctx, exchange)
+	require.True(t, tokens.Success, "Обмен кода должен пройти: %v", tokens.Errors)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	payload, err := verifier.VerifySignature(ctx, tokens.IDToken)
+	require.NoError(t, err, "ID token должен быть подписан ключом сервиса")
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	assert.Equal(t, "https://auth.example.com", claims["iss"])
+	assert.Equal(t, userID.String(), claims["sub"])
+	assert.Equal(t, client.ClientID, claims["aud"])
+	assert.Equal(t, "client-nonce", claims["nonce"])
+	assert.Equal(t, "tester@example.com", claims["email"])
+	assert.Equal(t, true, claims["email_verified"])
+	assert.Equal(t, "tester", claims["name"])
+
+	replayed := as.ExchangeClientToken(ctx, exchange)
+	assert.Equal(t, erro.ErrorInvalidAuthCode, replayed.Errors["OIDCError"], "Код используется один раз")
+
+	refreshed := as.ExchangeClientToken(ctx, model.TokenRequest{GrantType: "refresh_token", ClientID: client.ClientID, ClientSecret: secret, RefreshToken: tokens.RefreshToken})
+	require.True(t, refreshed.Success, "Refresh token должен обновляться через token endpoint: %v", refreshed.Errors)
+
+	userInfo := as.UserInfo(ctx, userID)
+	require.True(t, userInfo.Success)
+	assert.Equal(t, "tester@example.com", userInfo.Profile.Email)
+}
+
+func TestAuthService_AuthorizationCodeFlowRejections(t *testing.T) {
+	userID := uuid.New()
+	as, clients, _ := newTestProvider(t, userID)
+	client, secret, err := NewOAuthClient("Billing", []string{testRedirectURI}, false)
+	require.NoError(t, err)
+	clients.CreateClient(context.Background(), client)
+	public, _, err := NewOAuthClient("Mobile", []string{testRedirectURI}, true)
+	require.NoError(t, err)
+	clients.CreateClient(context.Background(), public)
+	ctx := context.Background()
+
+	request := newTestAuthorizationRequest(client.ClientID, "verifier-1")
+	unknown := request
+	unknown.ClientID = "unknown"
+	assert.Equal(t, erro.ErrorUnknownOAuthClient, as.CheckAuthorizationRequest(ctx, unknown).Errors["OIDCError"])
+	foreignRedirect := request
+	foreignRedirect.RedirectURI = "https://evil.example.com/callback"
+	assert.Equal(t, erro.ErrorInvalidRedirectURI, as.CheckAuthorizationRequest(ctx, foreignRedirect).Errors["OIDCError"], "Redirect URI сравнивается точно")
+	withoutPKCE := request
+	withoutPKCE.CodeChallenge = ""
+	assert.Equal(t, erro.ErrorInvalidAuthRequest, as.CheckAuthorizationRequest(ctx, withoutPKCE).Errors["OIDCError"], "PKCE обязателен")
+	withoutOpenID := request
+	withoutOpenID.Scope = "email"
+	assert.Equal(t, erro.ErrorInvalidAuthRequest, as.CheckAuthorizationRequest(ctx, withoutOpenID).Errors["OIDCError"], "Нужен scope openid")
+
+	issueCode := func(request model.AuthorizationRequest) string {
+		authorized := as.Authorize(ctx, request, userID)
+		require.True(t, authorized.Success, "Авторизация должна пройти: %v", authorized.Errors)
+		callback, err := url.Parse(authorized.RedirectURL)
+		require.NoError(t, err)
+		return callback.Query().Get("code")
+	}
+	exchange := func(request model.TokenRequest) error {
+		return as.ExchangeClientToken(ctx, request).Errors["OIDCError"]
+	}
+
+	code := issueCode(request)
+	assert.Equal(t, erro.ErrorInvalidClientCredentials, exchange(model.TokenRequest{GrantType: "authorization_code", ClientID: client.ClientID, ClientSecret: "wrong", Code: code, RedirectURI: testRedirectURI, CodeVerifier: "verifier-1"}), "Неверный секрет")
+	assert.Equal(t, erro.ErrorInvalidAuthCode, exchange(model.TokenRequest{GrantType: "authorization_code", ClientID: client.ClientID, ClientSecret: secret, Code: code, RedirectURI: testRedirectURI, CodeVerifier: "wrong-verifier"}), "Неверный PKCE verifier")
+	assert.Equal(t, erro.ErrorInvalidAuthCode, exchange(model.TokenRequest{GrantType: "authorization_code", ClientID: client.ClientID, ClientSecret: secret, Code: code, RedirectURI: testRedirectURI, CodeVerifier: "verifier-1"}), "Код сгорает после неудачного обмена")
+
+	code = issueCode(request)
+	assert.Equal(t, erro.ErrorInvalidAuthCode, exchange(model.TokenRequest{GrantType: "authorization_code", ClientID: public.ClientID, Code: code, RedirectURI: testRedirectURI, CodeVerifier: "verifier-1"}), "Код другого клиента не принимается")
+
+	publicRequest := newTestAuthorizationRequest(public.ClientID, "verifier-2")
+	code = issueCode(publicRequest)
+	assert.Equal(t, erro.ErrorInvalidClientCredentials, exchange(model.TokenRequest{GrantType: "authorization_code", ClientID: public.ClientID, ClientSecret: "secret", Code: code, RedirectURI: testRedirectURI, CodeVerifier: "verifier-2"}), "Публичный клиент не присылает секрет")
+	tokens := as.ExchangeClientToken(ctx, model.TokenRequest{GrantType: "authorization_code", ClientID: public.ClientID, Code: issueCode(publicRequest), RedirectURI: testRedirectURI, CodeVerifier: "verifier-2"})
+	assert.True(t, tokens.Success, "Публичный клиент обменивает код только с PKCE: %v", tokens.Errors)
+
+	assert.Equal(t, erro.ErrorUnsupportedGrantType, exchange(model.TokenRequest{GrantType: "password", ClientID: client.ClientID, ClientSecret: secret}))
+	assert.Equal(t, erro.ErrorInvalidClientCredentials, exchange(model.TokenRequest{GrantType: "authorization_code", ClientID: "unknown"}))
+
+	disabled := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID}, DBOAuthClientRepos: clients}})
+	assert.Equal(t, erro.ErrorOIDCProviderDisabled, disabled.CheckAuthorizationRequest(ctx, request).Errors["OIDCError"], "Без issuer провайдер выключен")
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"auth_service/internal/kafka"
+	"auth_service/internal/repository"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type OutboxRelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Lease        time.Duration
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	// Retention is how long sent events are kept before the relay deletes them.
+	Retention       time.Duration
+	CleanupInterval time.Duration
+}
+
+// OutboxRelay publishes events committed to the outbox table. Delivery is at-least-once:
+// an event that reached Kafka but could not be marked as sent is published again.
+type OutboxRelay struct {
+	outboxrepo    repository.DBOutboxRepos
+	kafkaProducer kafka.KafkaProducer
+	config        OutboxRelayConfig
+}
+
+func NewOutboxRelay(outbox repository.DBOutboxRepos, kafkaProd kafka.KafkaProducer, config OutboxRelayConfig) *OutboxRelay {
+	if config.PollInterval <= 0 {
+		config.PollInterval = 500 * time.Millisecond
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.Lease <= 0 {
+		config.Lease = 30 * time.Second
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = time.Second
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+	if config.Retention <= 0 {
+		config.Retention = 7 * 24 * time.Hour
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = time.Hour
+	}
+	return &OutboxRelay{outboxrepo: outbox, kafkaProducer: kafkaProd, config: config}
+}
+
+func (or *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(or.config.PollInterval)
+	defer ticker.Stop()
+	cleanupTicker := time.NewTicker(or.config.CleanupInterval)
+	defer cleanupTicker.Stop()
+	or.deleteSentEvents(ctx)
+	for {
+		for or.relayBatch(ctx) == or.config.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			log.Println("Outbox relay stopped")
+			return
+		case <-ticker.C:
+		case <-cleanupTicker.C:
+			or.deleteSentEvents(ctx)
+		}
+	}
+}
+
+// deleteSentEvents removes the events sent longer than Retention ago, in batches so that a large
+// backlog does not hold one long transaction.
+func (or *OutboxRelay) deleteSentEvents(ctx context.Context) int64 {
+	sentBefore := time.Now().Add(-or.config.Retention)
+	var total int64
+	for ctx.Err() == nil {
+		response := or.outboxrepo.DeleteSentEvents(ctx, sentBefore, or.config.BatchSize)
+		if !response.Success {
+			log.Printf("Error when deleting sent events from the outbox: %v", response.Errors)
+			break
+		}
+		outboxData, ok := response.Data.(repository.DBOutboxResponseData)
+		if !ok {
+			log.Printf("Unexpected data type from repository: %T", response.Data)
+			break
+		}
+		total += outboxData.Deleted
+		if outboxData.Deleted < int64(or.config.BatchSize) {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("%d sent events older than %v have been deleted from the outbox", total, or.config.Retention)
+	}
+	return total
+}
+
+// relayBatch returns the number of claimed events so Run can drain a backlog without waiting for the ticker.
+func (or *OutboxRelay) relayBatch(ctx context.Context) int {
+	response := or.outboxrepo.ClaimPendingEvents(ctx, or.config.BatchSize, or.config.Lease)
+	if !response.Success {
+		log.Printf("Error when claiming events from the outbox: %v", response.Errors)
+		return 0
+	}
+	outboxData, ok := response.Data.(repository.DBOutboxResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		return 0
+	}
+	// The whole batch is handed to the producer before waiting, so it can be written in one go.
+	results := make([]error, len(outboxData.Events))
+	var wg sync.WaitGroup
+	for i, event := range outboxData.Events {
+		wg.Add(1)
+		err := or.kafkaProducer.SendMessageAsync(ctx, event.Topic, event.Key, event.Payload, func(delivery kafka.Delivery) {
+			results[i] = delivery.Err
+			wg.Done()
+		})
+		if err != nil {
+			results[i] = err
+			wg.Done()
+		}
+	}
+	delivered := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+	case <-ctx.Done():
+		// Unconfirmed events stay claimed until their lease ends and are published again then.
+		log.Printf("Outbox relay stopped before %d events were confirmed by the broker", len(outboxData.Events))
+		return len(outboxData.Events)
+	}
+	for i, event := range outboxData.Events {
+		if err := results[i]; err != nil {
+			nextAttempt := time.Now().Add(or.backoff(event.Attempts))
+			log.Printf("Error publishing outbox event %d to %s (attempt %d), next attempt at %v: %v", event.ID, event.Topic, event.Attempts+1, nextAttempt, err)
+			if failResponse := or.outboxrepo.MarkEventFailed(ctx, event.ID, nextAttempt, err.Error()); !failResponse.Success {
+				log.Printf("Error when marking outbox event %d as failed: %v", event.ID, failResponse.Errors)
+			}
+			continue
+		}
+		if sentResponse := or.outboxrepo.MarkEventSent(ctx, event.ID); !sentResponse.Success {
+			log.Printf("Error when marking outbox event %d as sent: %v", event.ID, sentResponse.Errors)
+			continue
+		}
+		log.Printf("Outbox event %d has been successfully delivered to the broker", event.ID)
+	}
+	return len(outboxData.Events)
+}
+
+func (or *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := or.config.MinBackoff
+	for i := 0; i < attempts && delay < or.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > or.config.MaxBackoff {
+		delay = or.config.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/kafka"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"shared/events"
+	"shared/memkafka"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOutboxRepo struct {
+	events []model.OutboxEvent
+	sent   []int64
+	failed map[int64]time.Time
+	// sentAt holds delivered events for DeleteSentEvents.
+	sentAt map[int64]time.Time
+}
+
+func (f *fakeOutboxRepo) AddOutboxEvent(ctx context.Context, tx *sql.Tx, event model.OutboxEvent) *repository.RepositoryResponse {
+	f.events = append(f.events, event)
+	return &repository.RepositoryResponse{Success: true}
+}
+func (f *fakeOutboxRepo) ClaimPendingEvents(ctx context.Context, limit int, lease time.Duration) *repository.RepositoryResponse {
+	events := f.events
+	f.events = nil
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBOutboxResponseData{Events: events}}
+}
+func (f *fakeOutboxRepo) MarkEventSent(ctx context.Context, eventID int64) *repository.RepositoryResponse {
+	f.sent = append(f.sent, eventID)
+	return &repository.RepositoryResponse{Success: true}
+}
+func (f *fakeOutboxRepo) MarkEventFailed(ctx context.Context, eventID int64, nextAttempt time.Time, lastError string) *repository.RepositoryResponse {
+	f.failed[eventID] = nextAttempt
+	return &repository.RepositoryResponse{Success: true}
+}
+func (f *fakeOutboxRepo) DeleteSentEvents(ctx context.Context, sentBefore time.Time, limit int) *repository.RepositoryResponse {
+	var deleted int64
+	for id, sentAt := range f.sentAt {
+		if deleted == int64(limit) {
+			break
+		}
+		if sentAt.Before(sentBefore) {
+			delete(f.sentAt, id)
+			deleted++
+		}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBOutboxResponseData{Deleted: deleted}}
+}
+
+type fakeProducer struct {
+	failTopic      string
+	queueFullTopic string
+	// holdTopic messages are accepted but never confirmed.
+	holdTopic string
+	sent      []string
+}
+
+func (f *fakeProducer) SendMessage(ctx context.Context, topic string, key string, value []byte) error {
+	if topic == f.failTopic {
+		return errors.New("broker is not available")
+	}
+	f.sent = append(f.sent, topic)
+	return nil
+}
+func (f *fakeProducer) SendMessageAsync(ctx context.Context, topic string, key string, value []byte, onDelivery kafka.DeliveryCallback) error {
+	if topic == f.queueFullTopic {
+		return kafka.ErrQueueFull
+	}
+	if topic == f.holdTopic {
+		return nil
+	}
+	onDelivery(kafka.Delivery{Topic: topic, Key: key, Err: f.SendMessage(ctx, topic, key, value)})
+	return nil
+}
+func (f *fakeProducer) Stats() kafka.ProducerStats { return kafka.ProducerStats{} }
+func (f *fakeProducer) Close() error               { return nil }
+
+func TestOutboxRelay_RelayBatch(t *testing.T) {
+	repo := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	repo.events = []model.OutboxEvent{
+		{ID: 1, Topic: "user-registered-topic", Key: "a"},
+		{ID: 2, Topic: "user-delete-topic", Key: "b", Attempts: 3},
+		{ID: 3, Topic: "user-logged-out-topic", Key: "c"},
+	}
+	producer := &fakeProducer{failTopic: "user-delete-topic", queueFullTopic: "user-logged-out-topic"}
+	relay := NewOutboxRelay(repo, producer, OutboxRelayConfig{BatchSize: 10, MinBackoff: time.Second, MaxBackoff: time.Minute})
+
+	start := time.Now()
+	claimed := relay.relayBatch(context.Background())
+
+	assert.Equal(t, 3, claimed)
+	assert.Equal(t, []string{"user-registered-topic"}, producer.sent)
+	assert.Equal(t, []int64{1}, repo.sent)
+	nextAttempt, ok := repo.failed[2]
+	assert.True(t, ok, "Событие с ошибкой должно быть помечено как failed")
+	assert.WithinDuration(t, start.Add(8*time.Second), nextAttempt, time.Second, "Backoff должен расти экспоненциально")
+	assert.Contains(t, repo.failed, int64(3), "Событие, не принятое в очередь, должно быть помечено как failed")
+}
+
+func TestOutboxRelay_RelayBatchStopsWithContext(t *testing.T) {
+	repo := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	repo.events = []model.OutboxEvent{
+		{ID: 1, Topic: "user-registered-topic", Key: "a"},
+		{ID: 2, Topic: "user-delete-topic", Key: "b"},
+	}
+	producer := &fakeProducer{holdTopic: "user-delete-topic"}
+	relay := NewOutboxRelay(repo, producer, OutboxRelayConfig{BatchSize: 10})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	assert.Equal(t, 2, relay.relayBatch(ctx))
+	assert.Less(t, time.Since(start), time.Second, "Ожидание доставки должно прерываться вместе с контекстом")
+	assert.Empty(t, repo.sent, "Без подтверждения всей пачки события остаются за relay до конца аренды")
+	assert.Empty(t, repo.failed)
+}
+
+func TestOutboxRelay_DeleteSentEvents(t *testing.T) {
+	now := time.Now()
+	repo := &fakeOutboxRepo{sentAt: map[int64]time.Time{
+		1: now.Add(-30 * 24 * time.Hour),
+		2: now.Add(-8 * 24 * time.Hour),
+		3: now.Add(-8 * 24 * time.Hour),
+		4: now.Add(-time.Hour),
+	}}
+	relay := NewOutboxRelay(repo, nil, OutboxRelayConfig{BatchSize: 2, Retention: 7 * 24 * time.Hour})
+
+	deleted := relay.deleteSentEvents(context.Background())
+
+	assert.Equal(t, int64(3), deleted, "Должны удаляться все события старше срока хранения, пачками")
+	assert.Equal(t, map[int64]time.Time{4: now.Add(-time.Hour)}, repo.sentAt, "Недавние события должны сохраняться")
+}
+
+func TestOutboxRelay_Backoff(t *testing.T) {
+	relay := NewOutboxRelay(nil, nil, OutboxRelayConfig{MinBackoff: time.Second, MaxBackoff: 10 * time.Second})
+	assert.Equal(t, time.Second, relay.backoff(0))
+	assert.Equal(t, 4*time.Second, relay.backoff(2))
+	assert.Equal(t, 10*time.Second, relay.backoff(20))
+}
+
+// The events of a user reach the broker on one partition in the order they happened, which is
+// what the statustracking consumer relies on.
+func TestOutboxRelay_DeliversToBroker(t *testing.T) {
+	userID := uuid.New()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID}, DBOutboxRepos: outbox}})
+	ctx := context.Background()
+	person := &model.Person{Email: "tester@example.com", Password: "password123"}
+	require.True(t, as.AuthenticateAndLogin(ctx, person, "192.0.2.1").Success)
+	require.True(t, as.Logout(ctx, "session", userID).Success)
+	require.True(t, as.AuthenticateAndLogin(ctx, person, "192.0.2.1").Success)
+	require.True(t, as.Logout(ctx, "session", userID).Success)
+
+	broker := memkafka.NewBroker(4)
+	producer := kafka.NewMemoryProducer(broker, configs.KafkaProducerConfig{Async: true, BatchSize: 3})
+	defer producer.Close()
+	relay := NewOutboxRelay(outbox, producer, OutboxRelayConfig{BatchSize: 10})
+	assert.Equal(t, 4, relay.relayBatch(ctx))
+	assert.Len(t, outbox.sent, 4, "Все события должны быть отмечены как отправленные")
+
+	reader := broker.Reader("statustracking", testTopics.UserAuthenticate, testTopics.UserLoggedOut)
+	defer reader.Close()
+	var received []events.Envelope
+	partitions := make(map[int]bool)
+	for range 4 {
+		fetchCtx, cancel := context.WithTimeout(ctx, time.Second)
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		require.NoError(t, err)
+		assert.Equal(t, userID.String(), string(msg.Key))
+		partitions[msg.Partition] = true
+		envelope, _, err := events.Unmarshal(msg.Value)
+		require.NoError(t, err, "Событие в брокере должно проходить валидацию")
+		received = append(received, envelope)
+		require.NoError(t, reader.CommitMessages(ctx, msg))
+	}
+	assert.Zero(t, broker.Lag("statustracking", testTopics.UserAuthenticate))
+	assert.Zero(t, broker.Lag("statustracking", testTopics.UserLoggedOut))
+	assert.Empty(t, broker.Messages(testTopics.UserAuthenticate), "Прочитанные события не должны храниться в брокере")
+
+	assert.Len(t, partitions, 1, "События одного пользователя должны попадать в одну партицию")
+	types := make(map[string]int)
+	for _, envelope := range received {
+		types[envelope.EventType]++
+	}
+	assert.Equal(t, map[string]int{events.TypeUserAuthenticated: 2, events.TypeUserLoggedOut: 2}, types)
+}
+
+// streamMessage is an entry of shared/events/testdata/auth_service.stream.json, the messages
+// auth_service publishes for a login, logout, login and account deletion. The statustracking
+// listener test replays the same file, so the two services are tested against one stream.
+type streamMessage struct {
+	Topic string          `json:"topic"`
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// streamEvent is the part of a stream message that does not change between runs.
+type streamEvent struct {
+	Key           string
+	EventType     string
+	SchemaVersion int
+	Producer      string
+	Payload       string
+}
+
+func newStreamEvent(t *testing.T, key string, value []byte) streamEvent {
+	t.Helper()
+	envelope, _, err := events.Unmarshal(value)
+	require.NoError(t, err, "Событие должно проходить валидацию")
+	var payload bytes.Buffer
+	require.NoError(t, json.Compact(&payload, envelope.Payload))
+	return streamEvent{Key: key, EventType: envelope.EventType, SchemaVersion: envelope.SchemaVersion, Producer: envelope.Producer, Payload: payload.String()}
+}
+
+func TestOutboxRelay_MatchesConsumerStream(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "shared", "events", "testdata", "auth_service.stream.json"))
+	require.NoError(t, err)
+	var stream []streamMessage
+	require.NoError(t, json.Unmarshal(data, &stream))
+	expected := make(map[string][]streamEvent)
+	for _, msg := range stream {
+		expected[msg.Topic] = append(expected[msg.Topic], newStreamEvent(t, msg.Key, msg.Value))
+	}
+
+	userID := uuid.MustParse(stream[0].Key)
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	redisRepo := &fakeRedisRepo{}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID}, RedisSessionRepos: redisRepo, DBOutboxRepos: outbox}})
+	ctx := context.Background()
+	person := &model.Person{Email: "tester@example.com", Password: "password123"}
+	first := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, first.Success)
+	require.True(t, as.Logout(ctx, first.SessionId, userID).Success)
+	second := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, second.Success)
+	require.True(t, as.DeleteAccount(ctx, second.SessionId, userID, "password123").Success)
+
+	broker := memkafka.NewBroker(4)
+	producer := kafka.NewMemoryProducer(broker, configs.KafkaProducerConfig{})
+	defer producer.Close()
+	relay := NewOutboxRelay(outbox, producer, OutboxRelayConfig{BatchSize: 10})
+	assert.Equal(t, len(stream), relay.relayBatch(ctx))
+
+	published := make(map[string][]streamEvent)
+	for key, topic := range testTopics.Names() {
+		for _, msg := range broker.Messages(topic) {
+			published[key] = append(published[key], newStreamEvent(t, string(msg.Key), msg.Value))
+		}
+	}
+	assert.Equal(t, expected, published, "Опубликованные события должны совпадать с потоком, который читает statustracking")
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"auth_service/internal/webauthn"
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	passkeyRegisterPurpose = "webauthn_register"
+	passkeyLoginPurpose    = "webauthn_login"
+
+	defaultPasskeyRPID         = "localhost"
+	defaultPasskeyRPName       = "auth_service"
+	defaultPasskeyChallengeTTL = 5 * time.Minute
+)
+
+func withWebAuthnDefaults(cfg configs.WebAuthnConfig) configs.WebAuthnConfig {
+	if cfg.RPID == "" {
+		cfg.RPID = defaultPasskeyRPID
+	}
+	if cfg.RPName == "" {
+		cfg.RPName = defaultPasskeyRPName
+	}
+	if cfg.ChallengeTTL <= 0 {
+		cfg.ChallengeTTL = defaultPasskeyChallengeTTL
+	}
+	return cfg
+}
+
+func newRelyingParty(cfg configs.WebAuthnConfig) webauthn.RelyingParty {
+	return webauthn.RelyingParty{ID: cfg.RPID, Name: cfg.RPName, Origins: cfg.Origins, Timeout: cfg.ChallengeTTL}
+}
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create(). Passkeys the
+// user already has are excluded, so the same authenticator is not registered twice.
+func (as *AuthService) BeginPasskeyRegistration(ctx context.Context, userID uuid.UUID) *ServiceResponse {
+	registerMap := make(map[string]error)
+	user, err := as.userByID(ctx, userID)
+	if err != nil {
+		log.Printf("Error when getting the user %s: %v", userID, err)
+		registerMap["GetUserError"] = err
+		return &ServiceResponse{Success: false, Errors: registerMap}
+	}
+	passkeys, err := as.userPasskeys(ctx, userID)
+	if err != nil {
+		registerMap["PasskeyError"] = err
+		return &ServiceResponse{Success: false, Errors: registerMap}
+	}
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		exclude = append(exclude, webauthn.CredentialDescriptor{Type: "public-key", ID: passkey.ID, Transports: passkey.Transports})
+	}
+	challenge, failure := as.startPasskeyCeremony(ctx, passkeyRegisterPurpose, userID, registerMap)
+	if failure != nil {
+		return failure
+	}
+	options := as.relyingParty.CreationOptions(challenge, webauthn.UserEntity{ID: userID[:], Name: user.Email, DisplayName: user.Name}, exclude)
+	return &ServiceResponse{Success: true, UserId: userID, PasskeyCreation: &options}
+}
+
+// FinishPasskeyRegistration verifies the new credential and stores it for the user who started
+// the ceremony.
+func (as *AuthService) FinishPasskeyRegistration(ctx context.Context, userID uuid.UUID, name string, response webauthn.RegistrationResponse) *ServiceResponse {
+	registerMap := make(map[string]error)
+	challenge, owner, failure := as.finishPasskeyCeremony(ctx, passkeyRegisterPurpose, response.Response.ClientDataJSON, registerMap)
+	if failure != nil {
+		return failure
+	}
+	if owner != userID {
+		log.Printf("Passkey challenge of %s presented by %s", owner, userID)
+		registerMap["PasskeyError"] = erro.ErrorInvalidPasskeyChallenge
+		return &ServiceResponse{Success: false, Errors: registerMap}
+	}
+	credential, err := as.relyingParty.FinishRegistration(challenge, response)
+	if err != nil {
+		log.Printf("Passkey registration of %s rejected: %v", userID, err)
+		registerMap["PasskeyError"] = erro.ErrorInvalidPasskey
+		return &ServiceResponse{Success: false, Errors: registerMap}
+	}
+	addResponse := as.passkeyrepo.AddPasskey(ctx, model.PasskeyCredential{
+		ID:         credential.ID,
+		UserID:     userID,
+		PublicKey:  credential.PublicKey,
+		SignCount:  credential.SignCount,
+		Transports: credential.Transports,
+		Name:       name,
+	})
+	if !addResponse.Success {
+		log.Printf("Error when storing the passkey of %s: %v", userID, addResponse.Errors)
+		registerMap["PasskeyError"] = addResponse.Errors
+		return &ServiceResponse{Success: false, Errors: registerMap}
+	}
+	log.Printf("Passkey registered for %s", userID)
+	return &ServiceResponse{Success: true, UserId: userID}
+}
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get(). The user is not known
+// yet; the authenticator picks the passkey and the response names its owner.
+func (as *AuthService) BeginPasskeyLogin(ctx context.Context) *ServiceResponse {
+	loginMap := make(map[string]error)
+	challenge, failure := as.startPasskeyCeremony(ctx, passkeyLoginPurpose, uuid.Nil, loginMap)
+	if failure != nil {
+		return failure
+	}
+	options := as.relyingParty.RequestOptions(challenge)
+	return &ServiceResponse{Success: true, PasskeyRequest: &options}
+}
+
+// FinishPasskeyLogin verifies the assertion and creates the same cookie session as a password
+// login. The passkey already proves possession and user verification, so TOTP is not asked for.
+func (as *AuthService) FinishPasskeyLogin(ctx context.Context, response webauthn.AuthenticationResponse) *ServiceResponse {
+	loginMap := make(map[string]error)
+	challenge, _, failure := as.finishPasskeyCeremony(ctx, passkeyLoginPurpose, response.Response.ClientDataJSON, loginMap)
+	if failure != nil {
+		return failure
+	}
+	if ctx.Err() != nil {
+		log.Printf("FinishPasskeyLogin: Context cancelled before GetPasskey: %v", ctx.Err())
+		loginMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	passkeyResponse := as.passkeyrepo.GetPasskey(ctx, response.RawID)
+	if !passkeyResponse.Success {
+		log.Printf("Error when getting a passkey: %v", passkeyResponse.Errors)
+		loginMap["PasskeyError"] = passkeyError(passkeyResponse.Errors)
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	passkeyData, ok := passkeyResponse.Data.(repository.DBPasskeyResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", passkeyResponse.Data)
+		loginMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	passkey := passkeyData.Passkey
+	if len(response.Response.UserHandle) > 0 && !bytes.Equal(response.Response.UserHandle, passkey.UserID[:]) {
+		log.Printf("Passkey of %s presented with a foreign user handle", passkey.UserID)
+		loginMap["PasskeyError"] = erro.ErrorInvalidPasskey
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	credential := webauthn.Credential{ID: passkey.ID, PublicKey: passkey.PublicKey, SignCount: passkey.SignCount}
+	signCount, err := as.relyingParty.FinishLogin(challenge, credential, response)
+	if err != nil {
+		log.Printf("Passkey login of %s rejected: %v", passkey.UserID, err)
+		loginMap["PasskeyError"] = erro.ErrorInvalidPasskey
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	useResponse := as.passkeyrepo.UsePasskey(ctx, passkey.ID, signCount)
+	if !useResponse.Success {
+		log.Printf("Error when updating the passkey of %s: %v", passkey.UserID, useResponse.Errors)
+		loginMap["PasskeyError"] = useResponse.Errors
+		return &ServiceResponse{Success: false, Errors: loginMap}
+	}
+	log.Printf("Person with id: %v has used a passkey", passkey.UserID)
+	return as.startSession(ctx, passkey.UserID, loginMap)
+}
+
+// startPasskeyCeremony stores a new challenge in Redis for ChallengeTTL. The challenge comes
+// back inside the signed client data, so it doubles as the key of the ceremony.
+func (as *AuthService) startPasskeyCeremony(ctx context.Context, purpose string, userID uuid.UUID, errMap map[string]error) (webauthn.Base64URL, *ServiceResponse) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		log.Printf("Error generating passkey challenge: %v", err)
+		errMap["PasskeyError"] = erro.ErrorGenerateToken
+		return nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("startPasskeyCeremony: Context cancelled before SetOneTimeToken: %v", ctx.Err())
+		errMap["ContextError"] = erro.ErrorContextTimeout
+		return nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	tokenResponse := as.onetimerepo.SetOneTimeToken(ctx, purpose, hashToken(string(challenge)), userID, "", as.passkeys.ChallengeTTL)
+	if !tokenResponse.Success {
+		log.Printf("Error when storing the passkey challenge: %v", tokenResponse.Errors)
+		errMap["PasskeyError"] = tokenResponse.Errors
+		return nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	return challenge, nil
+}
+
+// finishPasskeyCeremony consumes the challenge the client signed and returns it with the user
+// the ceremony was started for. Every challenge answers one response only.
+func (as *AuthService) finishPasskeyCeremony(ctx context.Context, purpose string, clientDataJSON []byte, errMap map[string]error) (webauthn.Base64URL, uuid.UUID, *ServiceResponse) {
+	challenge, err := webauthn.Challenge(clientDataJSON)
+	if err != nil {
+		errMap["PasskeyError"] = erro.ErrorInvalidPasskey
+		return nil, uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("finishPasskeyCeremony: Context cancelled before ConsumeOneTimeToken: %v", ctx.Err())
+		errMap["ContextError"] = erro.ErrorContextTimeout
+		return nil, uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	tokenResponse := as.onetimerepo.ConsumeOneTimeToken(ctx, purpose, hashToken(string(challenge)))
+	if !tokenResponse.Success {
+		log.Printf("Error when consuming a passkey challenge: %v", tokenResponse.Errors)
+		errMap["PasskeyError"] = passkeyError(tokenResponse.Errors)
+		return nil, uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	tokenData, ok := tokenResponse.Data.(repository.RedisOneTimeTokenResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", tokenResponse.Data)
+		errMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return nil, uuid.Nil, &ServiceResponse{Success: false, Errors: errMap}
+	}
+	return challenge, tokenData.UserID, nil
+}
+
+func (as *AuthService) userPasskeys(ctx context.Context, userID uuid.UUID) ([]model.PasskeyCredential, error) {
+	if ctx.Err() != nil {
+		return nil, erro.ErrorContextTimeout
+	}
+	response := as.passkeyrepo.GetUserPasskeys(ctx, userID)
+	if !response.Success {
+		log.Printf("Error when getting the passkeys of %s: %v", userID, response.Errors)
+		return nil, response.Errors
+	}
+	data, ok := response.Data.(repository.DBPasskeyListResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		return nil, erro.ErrorUnexpectedData
+	}
+	return data.Passkeys, nil
+}
+
+// passkeyError translates repository errors for the client; an unknown passkey is reported as a
+// failed verification.
+func passkeyError(err error) error {
+	if errors.Is(err, erro.ErrorInvalidOneTimeToken) {
+		return erro.ErrorInvalidPasskeyChallenge
+	}
+	if errors.Is(err, erro.ErrorPasskeyNotFound) {
+		return erro.ErrorInvalidPasskey
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"auth_service/internal/webauthn/webauthntest"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePasskeyRepo struct {
+	passkeys map[string]model.PasskeyCredential
+}
+
+func newFakePasskeyRepo() *fakePasskeyRepo {
+	return &fakePasskeyRepo{passkeys: make(map[string]model.PasskeyCredential)}
+}
+
+func (f *fakePasskeyRepo) AddPasskey(ctx context.Context, credential model.PasskeyCredential) *repository.RepositoryResponse {
+	if _, ok := f.passkeys[string(credential.ID)]; ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorPasskeyExists}
+	}
+	f.passkeys[string(credential.ID)] = credential
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{UserId: credential.UserID}}
+}
+func (f *fakePasskeyRepo) GetPasskey(ctx context.Context, credentialID []byte) *repository.RepositoryResponse {
+	credential, ok := f.passkeys[string(credentialID)]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorPasskeyNotFound}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBPasskeyResponseData{Passkey: credential}}
+}
+func (f *fakePasskeyRepo) GetUserPasskeys(ctx context.Context, userId uuid.UUID) *repository.RepositoryResponse {
+	var passkeys []model.PasskeyCredential
+	for _, credential := range f.passkeys {
+		if credential.UserID == userId {
+			passkeys = append(passkeys, credential)
+		}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBPasskeyListResponseData{Passkeys: passkeys}}
+}
+func (f *fakePasskeyRepo) UsePasskey(ctx context.Context, credentialID []byte, signCount uint32) *repository.RepositoryResponse {
+	credential, ok := f.passkeys[string(credentialID)]
+	if !ok || (signCount != 0 && signCount <= credential.SignCount) {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorInvalidPasskey}
+	}
+	credential.SignCount = signCount
+	f.passkeys[string(credentialID)] = credential
+	return &repository.RepositoryResponse{Success: true}
+}
+
+func TestAuthService_PasskeyLogin(t *testing.T) {
+	userID := uuid.New()
+	redisRepo := &fakeRedisRepo{}
+	passkeys := newFakePasskeyRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	webauthnConfig := configs.WebAuthnConfig{RPID: "example.com", RPName: "Example", Origins: []string{"https://example.com"}, ChallengeTTL: time.Minute}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID}, RedisSessionRepos: redisRepo, DBPasskeyRepos: passkeys, DBOutboxRepos: outbox}, config: AuthServiceConfig{WebAuthn: webauthnConfig}})
+	ctx := context.Background()
+	authenticator := webauthntest.New("example.com", "https://example.com")
+
+	begun := as.BeginPasskeyRegistration(ctx, userID)
+	require.True(t, begun.Success, "Начало регистрации должно пройти: %v", begun.Errors)
+	require.NotNil(t, begun.PasskeyCreation)
+	assert.Equal(t, "example.com", begun.PasskeyCreation.RP.ID, "RP ID должен совпадать")
+	assert.Equal(t, "tester@example.com", begun.PasskeyCreation.User.Name, "Имя пользователя должно совпадать")
+
+	stranger := as.FinishPasskeyRegistration(ctx, uuid.New(), "laptop", authenticator.Register(*begun.PasskeyCreation))
+	assert.Equal(t, erro.ErrorInvalidPasskeyChallenge, stranger.Errors["PasskeyError"], "Чужой challenge не должен приниматься")
+
+	begun = as.BeginPasskeyRegistration(ctx, userID)
+	registration := authenticator.Register(*begun.PasskeyCreation)
+	registered := as.FinishPasskeyRegistration(ctx, userID, "laptop", registration)
+	require.True(t, registered.Success, "Регистрация должна пройти: %v", registered.Errors)
+	require.Contains(t, passkeys.passkeys, string(authenticator.CredentialID), "Passkey должен быть сохранен")
+	assert.Equal(t, "laptop", passkeys.passkeys[string(authenticator.CredentialID)].Name)
+
+	replayed := as.FinishPasskeyRegistration(ctx, userID, "laptop", registration)
+	assert.Equal(t, erro.ErrorInvalidPasskeyChallenge, replayed.Errors["PasskeyError"], "Challenge используется один раз")
+
+	again := as.BeginPasskeyRegistration(ctx, userID)
+	require.Len(t, again.PasskeyCreation.ExcludeCredentials, 1, "Существующий passkey должен исключаться")
+
+	login := as.BeginPasskeyLogin(ctx)
+	require.True(t, login.Success, "Начало входа должно пройти: %v", login.Errors)
+	assertion := authenticator.Login(*login.PasskeyRequest)
+	loggedIn := as.FinishPasskeyLogin(ctx, assertion)
+	require.True(t, loggedIn.Success, "Вход по passkey должен пройти: %v", loggedIn.Errors)
+	assert.Equal(t, userID, loggedIn.UserId, "UserId должен совпадать")
+	require.Contains(t, redisRepo.sessions, loggedIn.SessionId, "Сессия должна быть создана через SetSession")
+	authorized := as.Authorization(ctx, loggedIn.SessionId)
+	assert.True(t, authorized.Success, "Сессия passkey должна проходить авторизацию")
+	assert.Equal(t, uint32(1), passkeys.passkeys[string(authenticator.CredentialID)].SignCount, "Счетчик подписей должен обновиться")
+
+	reused := as.FinishPasskeyLogin(ctx, assertion)
+	assert.Equal(t, erro.ErrorInvalidPasskeyChallenge, reused.Errors["PasskeyError"], "Повтор ответа не должен приниматься")
+
+	unknown := webauthntest.New("example.com", "https://example.com")
+	login = as.BeginPasskeyLogin(ctx)
+	rejected := as.FinishPasskeyLogin(ctx, unknown.Login(*login.PasskeyRequest))
+	assert.Equal(t, erro.ErrorInvalidPasskey, rejected.Errors["PasskeyError"], "Неизвестный passkey не должен приниматься")
+}
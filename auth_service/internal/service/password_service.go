@@ -0,0 +1,317 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"shared/events"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	resetPasswordPurpose     = "reset_password"
+	defaultPasswordResetTTL  = time.Hour
+	defaultPasswordResetLink = "http://localhost:3000/reset-password"
+	defaultMinResponseTime   = 300 * time.Millisecond
+)
+
+func withPasswordResetDefaults(cfg configs.PasswordResetConfig) configs.PasswordResetConfig {
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = defaultPasswordResetTTL
+	}
+	if cfg.LinkURL == "" {
+		cfg.LinkURL = defaultPasswordResetLink
+	}
+	if cfg.MinResponseTime <= 0 {
+		cfg.MinResponseTime = defaultMinResponseTime
+	}
+	return cfg
+}
+
+// ForgotPassword queues a reset link for the account of email. The response is the same whether
+// the account exists or not, and it takes at least MinResponseTime either way, so the endpoint
+// cannot be used to probe for registered emails.
+func (as *AuthService) ForgotPassword(ctx context.Context, email string) *ServiceResponse {
+	email = strings.TrimSpace(email)
+	if err := as.validator.Var(email, "required,email"); err != nil {
+		return &ServiceResponse{Success: false, Errors: map[string]error{"Email": erro.ErrorNotEmail}}
+	}
+	start := time.Now()
+	response := as.requestPasswordReset(ctx, email)
+	as.waitMinResponseTime(ctx, start)
+	return response
+}
+
+// waitMinResponseTime blocks until MinResponseTime has passed since start or ctx is done.
+func (as *AuthService) waitMinResponseTime(ctx context.Context, start time.Time) {
+	timer := time.NewTimer(time.Until(start.Add(as.passwordReset.MinResponseTime)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+func (as *AuthService) requestPasswordReset(ctx context.Context, email string) *ServiceResponse {
+	forgotMap := make(map[string]error)
+	if ctx.Err() != nil {
+		log.Printf("ForgotPassword: Context cancelled before GetUserByEmail: %v", ctx.Err())
+		forgotMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: forgotMap}
+	}
+	response := as.dbrepo.GetUserByEmail(ctx, email)
+	if !response.Success {
+		if errors.Is(response.Errors, erro.ErrorFoundUser) {
+			// A token is generated and hashed anyway so this branch costs what the other one does
+			// before it reaches the store.
+			if token, err := generateToken(); err == nil {
+				hashToken(token)
+			}
+			log.Println("Password reset requested for an unknown email")
+			return &ServiceResponse{Success: true}
+		}
+		log.Printf("Error when getting the user by email: %v", response.Errors)
+		forgotMap["GetUserError"] = response.Errors
+		return &ServiceResponse{Success: false, Errors: forgotMap}
+	}
+	user, ok := response.Data.(repository.DBUserResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		forgotMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: forgotMap}
+	}
+
+	fingerprint, err := as.passwordFingerprint(ctx, user.UserId)
+	if err != nil {
+		log.Printf("Error when getting the password of %s: %v", user.UserId, err)
+		forgotMap["GetUserError"] = err
+		return &ServiceResponse{Success: false, Errors: forgotMap}
+	}
+	token, err := generateToken()
+	if err != nil {
+		log.Printf("Error generating password reset token: %v", err)
+		forgotMap["TokenError"] = erro.ErrorGenerateToken
+		return &ServiceResponse{Success: false, Errors: forgotMap}
+	}
+	tokenResponse := as.onetimerepo.SetOneTimeToken(ctx, resetPasswordPurpose, hashToken(token), user.UserId, fingerprint, as.passwordReset.TokenTTL)
+	if !tokenResponse.Success {
+		log.Printf("Error when storing the password reset token: %v", tokenResponse.Errors)
+		forgotMap["TokenError"] = tokenResponse.Errors
+		return &ServiceResponse{Success: false, Errors: forgotMap}
+	}
+	link, err := resetLink(as.passwordReset.LinkURL, token)
+	if err != nil {
+		log.Printf("Error building password reset link: %v", err)
+		forgotMap["TokenError"] = erro.ErrorGenerateToken
+		return &ServiceResponse{Success: false, Errors: forgotMap}
+	}
+	payload := &events.UserPasswordResetRequested{
+		UserID:    user.UserId,
+		Email:     user.Email,
+		Token:     token,
+		Link:      link,
+		ExpiresAt: time.Now().Add(as.passwordReset.TokenTTL).UTC().Truncate(time.Second),
+	}
+	outboxEvent, err := newOutboxEvent(as.topics.UserPasswordResetRequested, user.UserId, payload)
+	if err != nil {
+		forgotMap["EventError"] = erro.ErrorMarshal
+		log.Printf("Error building %s event: %v", events.TypeUserPasswordResetRequested, err)
+		return &ServiceResponse{Success: false, Errors: forgotMap}
+	}
+	// The payload carries the token in plain text; the relay clears it once it is published.
+	outboxEvent.Sensitive = true
+	outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, nil, outboxEvent)
+	if !outboxResponse.Success {
+		log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+		forgotMap["OutboxError"] = outboxResponse.Errors
+		return &ServiceResponse{Success: false, Errors: forgotMap}
+	}
+	log.Printf("Password reset requested for %s", user.UserId)
+	return &ServiceResponse{Success: true}
+}
+
+// ResetPassword sets a new password with a reset token and logs the user out everywhere.
+func (as *AuthService) ResetPassword(ctx context.Context, token, password string) *ServiceResponse {
+	resetMap := make(map[string]error)
+	if token == "" {
+		resetMap["ResetError"] = erro.ErrorInvalidResetToken
+		return &ServiceResponse{Success: false, Errors: resetMap}
+	}
+	if err := as.validator.Var(password, "required,min=8"); err != nil {
+		resetMap["Password"] = fmt.Errorf("Password is too short")
+		return &ServiceResponse{Success: false, Errors: resetMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("ResetPassword: Context cancelled before ConsumeOneTimeToken: %v", ctx.Err())
+		resetMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: resetMap}
+	}
+	tokenResponse := as.onetimerepo.ConsumeOneTimeToken(ctx, resetPasswordPurpose, hashToken(token))
+	if !tokenResponse.Success {
+		log.Printf("Error when using a password reset token: %v", tokenResponse.Errors)
+		if errors.Is(tokenResponse.Errors, erro.ErrorInvalidOneTimeToken) {
+			resetMap["ResetError"] = erro.ErrorInvalidResetToken
+		} else {
+			resetMap["ResetError"] = tokenResponse.Errors
+		}
+		return &ServiceResponse{Success: false, Errors: resetMap}
+	}
+	tokenData, ok := tokenResponse.Data.(repository.RedisOneTimeTokenResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", tokenResponse.Data)
+		resetMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: resetMap}
+	}
+	// Links issued before the password last changed, by an earlier reset or otherwise, are void.
+	fingerprint, err := as.passwordFingerprint(ctx, tokenData.UserID)
+	if err != nil {
+		log.Printf("Error when getting the password of %s: %v", tokenData.UserID, err)
+		resetMap["ResetError"] = err
+		return &ServiceResponse{Success: false, Errors: resetMap}
+	}
+	if tokenData.Subject != fingerprint {
+		log.Printf("Password reset token of %s was issued for a previous password", tokenData.UserID)
+		resetMap["ResetError"] = erro.ErrorInvalidResetToken
+		return &ServiceResponse{Success: false, Errors: resetMap}
+	}
+
+	if err := as.changePassword(ctx, tokenData.UserID, password, ""); err != nil {
+		resetMap["ResetError"] = err
+		return &ServiceResponse{Success: false, Errors: resetMap}
+	}
+	log.Printf("Password of %s was reset", tokenData.UserID)
+	return &ServiceResponse{Success: true, UserId: tokenData.UserID}
+}
+
+// checkPassword compares password with the stored hash of the user, ErrorInvalidPassword when
+// they differ.
+func (as *AuthService) checkPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	passwordHash, err := as.passwordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+	match, _, err := as.passwords.Verify(password, passwordHash)
+	if err != nil {
+		log.Printf("Error verifying the password hash of %s: %v", userID, err)
+		return erro.ErrorInternalServer
+	}
+	if !match {
+		return erro.ErrorInvalidPassword
+	}
+	return nil
+}
+
+func (as *AuthService) passwordHash(ctx context.Context, userID uuid.UUID) (string, error) {
+	if ctx.Err() != nil {
+		return "", erro.ErrorContextTimeout
+	}
+	response := as.dbrepo.GetPasswordHash(ctx, userID)
+	if !response.Success {
+		return "", response.Errors
+	}
+	data, ok := response.Data.(repository.DBPasswordResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		return "", erro.ErrorUnexpectedData
+	}
+	return data.PasswordHash, nil
+}
+
+// passwordFingerprint identifies the stored password hash without revealing it. Reset tokens
+// carry it as their subject, so every change of the hash voids the reset links issued before.
+func (as *AuthService) passwordFingerprint(ctx context.Context, userID uuid.UUID) (string, error) {
+	passwordHash, err := as.passwordHash(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return hashToken(passwordHash), nil
+}
+
+// rehashPassword replaces a stored hash made with an outdated algorithm or outdated parameters.
+// It runs after a successful login, so a failure is only logged and retried on the next one.
+func (as *AuthService) rehashPassword(ctx context.Context, userID uuid.UUID, password string) {
+	hashpass, err := as.passwords.Hash(password)
+	if err != nil {
+		log.Printf("HashPassError %v", err)
+		return
+	}
+	if response := as.dbrepo.UpdatePassword(ctx, nil, userID, hashpass); !response.Success {
+		log.Printf("Error upgrading the password hash of %s: %v", userID, response.Errors)
+		return
+	}
+	log.Printf("Password hash of %s was upgraded", userID)
+}
+
+// changePassword stores the new password together with the password-changed event and then
+// revokes every session of the user except keepSessionID.
+func (as *AuthService) changePassword(ctx context.Context, userID uuid.UUID, password, keepSessionID string) (err error) {
+	hashpass, err := as.passwords.Hash(password)
+	if err != nil {
+		log.Printf("HashPassError %v", err)
+		return erro.ErrorHashPass
+	}
+	if ctx.Err() != nil {
+		log.Printf("changePassword: Context cancelled before BeginTx: %v", ctx.Err())
+		return erro.ErrorContextTimeout
+	}
+	var tx *sql.Tx
+	tx, err = as.dbrepo.BeginTx(ctx)
+	if err != nil {
+		log.Printf("TransactionError %v", err)
+		return erro.ErrorStartTransaction
+	}
+	defer func() {
+		if err != nil && tx != nil {
+			if rErr := as.dbrepo.RollbackTx(ctx, tx); rErr != nil {
+				log.Printf("Error rolling back transaction: %v", rErr)
+			}
+		}
+	}()
+	updateResponse := as.dbrepo.UpdatePassword(ctx, tx, userID, hashpass)
+	if !updateResponse.Success {
+		log.Printf("Error when updating the password of %s: %v", userID, updateResponse.Errors)
+		return updateResponse.Errors
+	}
+	outboxEvent, err := newOutboxEvent(as.topics.UserPasswordChanged, userID, &events.UserPasswordChanged{UserID: userID})
+	if err != nil {
+		log.Printf("Error building %s event: %v", events.TypeUserPasswordChanged, err)
+		return erro.ErrorMarshal
+	}
+	outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, tx, outboxEvent)
+	if !outboxResponse.Success {
+		log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+		return outboxResponse.Errors
+	}
+	if err = as.dbrepo.CommitTx(ctx, tx); err != nil {
+		log.Printf("Transaction commit error: %v", err)
+		return erro.ErrorCommitTransaction
+	}
+	tx = nil
+	// The password is changed at this point. A failed revocation is still reported, since the
+	// old sessions may be exactly what the user is trying to get rid of.
+	if revokeErr := as.revokeUserSessions(ctx, userID, keepSessionID); revokeErr != nil {
+		log.Printf("Error revoking the sessions of %s after a password change: %v", userID, revokeErr)
+		return erro.ErrorRevokeSessions
+	}
+	return nil
+}
+
+func resetLink(base, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"shared/events"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthService_PasswordReset(t *testing.T) {
+	userID := uuid.New()
+	db := &fakeDBRepo{userID: userID}
+	redisRepo := &fakeRedisRepo{}
+	tokens := newFakeTokenRepo()
+	onetime := newFakeOneTimeRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	reset := configs.PasswordResetConfig{TokenTTL: time.Hour, LinkURL: "https://example.com/reset?lang=ru", MinResponseTime: time.Millisecond}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: db, RedisSessionRepos: redisRepo, RedisTokenRepos: tokens, RedisOneTimeTokenRepos: onetime, DBOutboxRepos: outbox}, config: AuthServiceConfig{PasswordReset: reset}})
+	ctx := context.Background()
+	person := &model.Person{Email: "tester@example.com", Password: "password123"}
+
+	browser := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, browser.Success)
+	mobile := as.IssueTokens(ctx, person, "192.0.2.1")
+	require.True(t, mobile.Success)
+	outbox.events = nil
+
+	unknown := as.ForgotPassword(ctx, "nobody@example.com")
+	assert.True(t, unknown.Success, "Ответ для незарегистрированного email не должен отличаться")
+	assert.Empty(t, outbox.events, "Для незарегистрированного email событие не публикуется")
+	assert.Equal(t, erro.ErrorNotEmail, as.ForgotPassword(ctx, "not-an-email").Errors["Email"], "Тип ошибки должен совпадать")
+
+	forgot := as.ForgotPassword(ctx, "tester@example.com")
+	require.True(t, forgot.Success, "Запрос сброса должен пройти успешно: %v", forgot.Errors)
+	assert.Equal(t, unknown, forgot, "Ответ для зарегистрированного email не должен отличаться")
+	require.Len(t, outbox.events, 1, "Должно быть опубликовано событие со ссылкой")
+	assert.Equal(t, testTopics.UserPasswordResetRequested, outbox.events[0].Topic)
+	assert.True(t, outbox.events[0].Sensitive, "Событие с токеном должно очищаться после отправки")
+	_, payload, err := events.Unmarshal(outbox.events[0].Payload)
+	require.NoError(t, err)
+	requested := payload.(*events.UserPasswordResetRequested)
+	assert.True(t, strings.HasPrefix(requested.Link, "https://example.com/reset?"), "Ссылка должна строиться из link_url")
+	assert.Contains(t, requested.Link, "lang=ru", "Параметры link_url должны сохраняться")
+	assert.Contains(t, requested.Link, "token="+requested.Token, "Ссылка должна содержать токен")
+	assert.NotContains(t, onetime.tokens, resetPasswordPurpose+":"+requested.Token, "Токен не должен храниться в открытом виде")
+
+	require.True(t, as.ForgotPassword(ctx, "tester@example.com").Success)
+	require.Len(t, outbox.events, 2)
+	_, payload, err = events.Unmarshal(outbox.events[1].Payload)
+	require.NoError(t, err)
+	older := payload.(*events.UserPasswordResetRequested)
+	outbox.events = outbox.events[:1]
+
+	short := as.ResetPassword(ctx, requested.Token, "short")
+	assert.False(t, short.Success, "Короткий пароль должен отклоняться")
+	assert.NotNil(t, short.Errors["Password"], "Ошибка должна относиться к паролю")
+
+	resetResponse := as.ResetPassword(ctx, requested.Token, "newpassword123")
+	require.True(t, resetResponse.Success, "Сброс пароля должен пройти успешно: %v", resetResponse.Errors)
+	assert.Equal(t, userID, resetResponse.UserId, "UserId должен совпадать")
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(db.passwordHash), []byte("newpassword123")), "Новый пароль должен сохраняться в виде bcrypt-хеша")
+	assert.Empty(t, redisRepo.sessions, "Все сессии пользователя должны быть отозваны")
+	assert.False(t, as.RefreshTokens(ctx, mobile.RefreshToken).Success, "Refresh token должен быть отозван")
+	require.Len(t, outbox.events, 2, "Должно быть опубликовано событие смены пароля")
+	assert.Equal(t, testTopics.UserPasswordChanged, outbox.events[1].Topic)
+	assert.Equal(t, userID.String(), outbox.events[1].Key)
+
+	reused := as.ResetPassword(ctx, requested.Token, "anotherpassword123")
+	assert.False(t, reused.Success, "Токен должен работать один раз")
+	assert.Equal(t, erro.ErrorInvalidResetToken, reused.Errors["ResetError"], "Тип ошибки должен совпадать")
+
+	stale := as.ResetPassword(ctx, older.Token, "anotherpassword123")
+	assert.False(t, stale.Success, "Другие ссылки сброса должны отзываться при смене пароля")
+	assert.Equal(t, erro.ErrorInvalidResetToken, stale.Errors["ResetError"], "Тип ошибки должен совпадать")
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(db.passwordHash), []byte("newpassword123")), "Пароль не должен меняться")
+}
+
+func TestAuthService_ForgotPasswordTakesMinResponseTime(t *testing.T) {
+	reset := configs.PasswordResetConfig{MinResponseTime: 50 * time.Millisecond}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: uuid.New()}}, config: AuthServiceConfig{PasswordReset: reset}})
+	ctx := context.Background()
+
+	for _, email := range []string{"nobody@example.com", "tester@example.com"} {
+		start := time.Now()
+		require.True(t, as.ForgotPassword(ctx, email).Success)
+		assert.GreaterOrEqual(t, time.Since(start), reset.MinResponseTime, "Время ответа не должно зависеть от существования аккаунта: %s", email)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	start := time.Now()
+	as.ForgotPassword(cancelled, "tester@example.com")
+	assert.Less(t, time.Since(start), reset.MinResponseTime, "Отменённый запрос не должен ждать")
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// RateLimitDecision describes the limit that applied to a request. Limit is zero when the
+// route is not limited.
+type RateLimitDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+	Policy     configs.RouteLimit
+}
+
+type RateLimitService struct {
+	repo   repository.RedisRateLimitRepos
+	def    configs.RouteLimit
+	routes map[string]configs.RouteLimit
+}
+
+func NewRateLimitService(repo repository.RedisRateLimitRepos, cfg configs.RateLimitConfig) *RateLimitService {
+	routes := make(map[string]configs.RouteLimit, len(cfg.Routes))
+	for _, route := range cfg.Routes {
+		routes[route.Path] = route
+	}
+	return &RateLimitService{repo: repo, def: cfg.Default, routes: routes}
+}
+
+// AllowRequest takes one request of subject from the limit of route.
+func (rs *RateLimitService) AllowRequest(ctx context.Context, route, subject string) (RateLimitDecision, error) {
+	limit, ok := rs.routes[route]
+	if !ok {
+		limit = rs.def
+	}
+	if limit.Limit <= 0 || limit.Period <= 0 {
+		return RateLimitDecision{Allowed: true}, nil
+	}
+	if ctx.Err() != nil {
+		log.Printf("AllowRequest: Context cancelled before rate limit check: %v", ctx.Err())
+		return RateLimitDecision{}, erro.ErrorContextTimeout
+	}
+
+	key := route + ":" + subject
+	capacity := limit.Limit
+	var response *repository.RepositoryResponse
+	if limit.Algorithm == configs.RateLimitSlidingWindow {
+		response = rs.repo.CountInWindow(ctx, key, limit.Limit, limit.Period)
+	} else {
+		if limit.Burst > 0 {
+			capacity = limit.Burst
+		}
+		response = rs.repo.TakeToken(ctx, key, capacity, limit.Period/time.Duration(limit.Limit))
+	}
+	if !response.Success {
+		return RateLimitDecision{}, response.Errors
+	}
+	data, ok := response.Data.(repository.RedisRateLimitResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		return RateLimitDecision{}, erro.ErrorUnexpectedData
+	}
+	return RateLimitDecision{
+		Allowed:    data.Allowed,
+		Limit:      capacity,
+		Remaining:  data.Remaining,
+		RetryAfter: data.RetryAfter,
+		Reset:      data.Reset,
+		Policy:     limit,
+	}, nil
+}
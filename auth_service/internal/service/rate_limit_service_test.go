@@ -0,0 +1,73 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rateLimitCall struct {
+	algorithm string
+	key       string
+	limit     int
+	period    time.Duration
+}
+
+type fakeRateLimitRepo struct {
+	calls []rateLimitCall
+}
+
+func (f *fakeRateLimitRepo) TakeToken(ctx context.Context, key string, capacity int, interval time.Duration) *repository.RepositoryResponse {
+	f.calls = append(f.calls, rateLimitCall{configs.RateLimitTokenBucket, key, capacity, interval})
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisRateLimitResponseData{Allowed: true, Remaining: capacity - 1}}
+}
+func (f *fakeRateLimitRepo) CountInWindow(ctx context.Context, key string, limit int, window time.Duration) *repository.RepositoryResponse {
+	f.calls = append(f.calls, rateLimitCall{configs.RateLimitSlidingWindow, key, limit, window})
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisRateLimitResponseData{Allowed: true, Remaining: limit - 1}}
+}
+
+func TestRateLimitService_AllowRequest(t *testing.T) {
+	repo := &fakeRateLimitRepo{}
+	rs := NewRateLimitService(repo, configs.RateLimitConfig{
+		Enabled: true,
+		Default: configs.RouteLimit{Algorithm: configs.RateLimitTokenBucket, Limit: 120, Period: time.Minute},
+		Routes: []configs.RouteLimit{
+			{Path: "/reg", Algorithm: configs.RateLimitSlidingWindow, Limit: 5, Period: time.Minute},
+			{Path: "/check-session", Algorithm: configs.RateLimitTokenBucket, Limit: 60, Period: time.Minute, Burst: 20},
+		},
+	})
+	ctx := context.Background()
+
+	decision, err := rs.AllowRequest(ctx, "/check-session", "192.0.2.1")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed, "Запрос должен быть разрешен")
+	assert.Equal(t, 20, decision.Limit, "Лимит token bucket равен burst")
+	assert.Equal(t, 19, decision.Remaining, "Remaining должен совпадать")
+
+	_, err = rs.AllowRequest(ctx, "/sessions/{id}", "192.0.2.1")
+	require.NoError(t, err)
+	rs.AllowRequest(ctx, "/reg", "192.0.2.1")
+
+	assert.Equal(t, []rateLimitCall{
+		{configs.RateLimitTokenBucket, "/check-session:192.0.2.1", 20, time.Second},
+		{configs.RateLimitTokenBucket, "/sessions/{id}:192.0.2.1", 120, 500 * time.Millisecond},
+		{configs.RateLimitSlidingWindow, "/reg:192.0.2.1", 5, time.Minute},
+	}, repo.calls, "Алгоритм и параметры должны браться из конфигурации маршрута")
+}
+
+func TestRateLimitService_Unlimited(t *testing.T) {
+	repo := &fakeRateLimitRepo{}
+	rs := NewRateLimitService(repo, configs.RateLimitConfig{Enabled: true})
+
+	decision, err := rs.AllowRequest(context.Background(), "/auth", "192.0.2.1")
+
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed, "Маршрут без лимита должен быть разрешен")
+	assert.Zero(t, decision.Limit, "Limit должен быть нулевым")
+	assert.Empty(t, repo.calls, "Redis не должен вызываться")
+}
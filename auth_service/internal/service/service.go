@@ -1,10 +1,12 @@
 package service
 
 import (
-	"auth_service/internal/kafka"
+	"auth_service/configs"
 	"auth_service/internal/model"
 	"auth_service/internal/repository"
+	"auth_service/internal/webauthn"
 	"context"
+	"shared/jwtverify"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,26 +15,101 @@ import (
 //go:generate mockgen -source=service.go -destination=mocks/mock.go
 type UserAuthentication interface {
 	RegistrateAndLogin(ctx context.Context, user *model.Person) *ServiceResponse
-	AuthenticateAndLogin(ctx context.Context, user *model.Person) *ServiceResponse
+	AuthenticateAndLogin(ctx context.Context, user *model.Person, clientIP string) *ServiceResponse
 	Authorization(ctx context.Context, sessionID string) *ServiceResponse
 	Logout(ctx context.Context, sessionID string, userId uuid.UUID) *ServiceResponse
 	DeleteAccount(ctx context.Context, sessionID string, userid uuid.UUID, password string) *ServiceResponse
+	IssueTokens(ctx context.Context, user *model.Person, clientIP string) *ServiceResponse
+	RefreshTokens(ctx context.Context, refreshToken string) *ServiceResponse
+	ListSessions(ctx context.Context, userID uuid.UUID) *ServiceResponse
+	RevokeSession(ctx context.Context, userID uuid.UUID, sessionID string) *ServiceResponse
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID, keepSessionID string) *ServiceResponse
+	VerifyEmail(ctx context.Context, token string) *ServiceResponse
+	ResendVerification(ctx context.Context, userID uuid.UUID) *ServiceResponse
+	RequireVerifiedEmail(ctx context.Context, route string, userID uuid.UUID) *ServiceResponse
+	ForgotPassword(ctx context.Context, email string) *ServiceResponse
+	ResetPassword(ctx context.Context, token, password string) *ServiceResponse
+	CompleteMFALogin(ctx context.Context, challenge, code, clientIP string) *ServiceResponse
+	CompleteMFATokens(ctx context.Context, challenge, code, clientIP string) *ServiceResponse
+	EnrollTOTP(ctx context.Context, userID uuid.UUID) *ServiceResponse
+	ConfirmTOTP(ctx context.Context, userID uuid.UUID, code string) *ServiceResponse
+	DisableTOTP(ctx context.Context, userID uuid.UUID, password string) *ServiceResponse
+	BeginPasskeyRegistration(ctx context.Context, userID uuid.UUID) *ServiceResponse
+	FinishPasskeyRegistration(ctx context.Context, userID uuid.UUID, name string, response webauthn.RegistrationResponse) *ServiceResponse
+	BeginPasskeyLogin(ctx context.Context) *ServiceResponse
+	FinishPasskeyLogin(ctx context.Context, response webauthn.AuthenticationResponse) *ServiceResponse
+	BeginExternalLogin(ctx context.Context, provider string, linkUserID uuid.UUID) *ServiceResponse
+	CompleteExternalLogin(ctx context.Context, provider, code, state string) *ServiceResponse
+	OpenIDConfiguration() *ServiceResponse
+	CheckAuthorizationRequest(ctx context.Context, request model.AuthorizationRequest) *ServiceResponse
+	Authorize(ctx context.Context, request model.AuthorizationRequest, userID uuid.UUID) *ServiceResponse
+	ExchangeClientToken(ctx context.Context, request model.TokenRequest) *ServiceResponse
+	UserInfo(ctx context.Context, userID uuid.UUID) *ServiceResponse
+	ChangePassword(ctx context.Context, sessionID string, userID uuid.UUID, currentPassword, newPassword string) *ServiceResponse
+	UpdateProfile(ctx context.Context, userID uuid.UUID, name, email *string) *ServiceResponse
+}
+type AccessTokens interface {
+	VerifyAccessToken(ctx context.Context, token string) (jwtverify.Claims, error)
+	JWKS() jwtverify.JWKS
+}
+type RateLimiting interface {
+	AllowRequest(ctx context.Context, route, subject string) (RateLimitDecision, error)
+}
+
+// AccessTokenKeys is the signing side of access and ID tokens, implemented by jwtkeys.Manager.
+type AccessTokenKeys interface {
+	jwtverify.KeySource
+	Sign(claims jwtverify.Claims) (string, error)
+	SignPayload(payload interface{}) (string, error)
+	JWKS() jwtverify.JWKS
+}
+
+// PasswordHasher hashes new passwords and verifies stored hashes of every supported algorithm,
+// implemented by password.Policy.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (ok bool, rehash bool, err error)
+	DummyVerify(password string)
 }
 type Service struct {
 	UserAuthentication
+	AccessTokens
+	RateLimiting
 }
 type ServiceResponse struct {
-	Success        bool
-	UserId         uuid.UUID
-	SessionId      string
-	ExpirationTime time.Time
-	Errors         map[string]error
+	Success               bool
+	UserId                uuid.UUID
+	SessionId             string
+	ExpirationTime        time.Time
+	SessionRenewed        bool
+	AccessToken           string
+	RefreshToken          string
+	RefreshExpirationTime time.Time
+	Sessions              []model.Session
+	Profile               *model.Profile
+	RetryAfter            time.Duration
+	MFAChallenge          string
+	TOTPSecret            string
+	TOTPURI               string
+	RecoveryCodes         []string
+	PasskeyCreation       *webauthn.CreationOptions
+	PasskeyRequest        *webauthn.RequestOptions
+	RedirectURL           string
+	OAuthState            string
+	IDToken               string
+	OpenIDConfiguration   *model.OpenIDConfiguration
+	Errors                map[string]error
 }
 
-func NewService(repos *repository.Repository, kafkaProd kafka.KafkaProducer) *Service {
+func NewService(repos *repository.Repository, config AuthServiceConfig, rateLimit configs.RateLimitConfig, passwords PasswordHasher, keys AccessTokenKeys) *Service {
 
-	return &Service{
+	services := &Service{
 
-		UserAuthentication: NewAuthService(repos.DBAuthenticateRepos, repos.RedisSessionRepos, kafkaProd),
+		UserAuthentication: NewAuthService(repos, config, passwords, keys),
+		AccessTokens:       NewAccessTokenService(keys, config.Tokens.Issuer),
+	}
+	if rateLimit.Enabled {
+		services.RateLimiting = NewRateLimitService(repos.RedisRateLimitRepos, rateLimit)
 	}
+	return services
 }
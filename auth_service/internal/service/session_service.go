@@ -0,0 +1,185 @@
+package service
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"log"
+	"shared/events"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+func (as *AuthService) ListSessions(ctx context.Context, userID uuid.UUID) *ServiceResponse {
+	listMap := make(map[string]error)
+	if ctx.Err() != nil {
+		log.Printf("ListSessions: Context cancelled before GetUserSessions: %v", ctx.Err())
+		listMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: listMap}
+	}
+	sessions, err := as.userSessions(ctx, userID)
+	if err != nil {
+		log.Printf("Error when getting the sessions of %s: %v", userID, err)
+		listMap["GetSessionsError"] = err
+		return &ServiceResponse{Success: false, Errors: listMap}
+	}
+	result := make([]model.Session, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, model.Session{
+			SessionID:      session.SessionId,
+			UserID:         session.UserID,
+			ExpirationTime: session.ExpirationTime,
+			FamilyID:       session.FamilyID,
+			CreatedAt:      session.CreatedAt,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	return &ServiceResponse{Success: true, UserId: userID, Sessions: result}
+}
+
+// RevokeSession ends one session of the user. Sessions of other users are reported as not found.
+func (as *AuthService) RevokeSession(ctx context.Context, userID uuid.UUID, sessionID string) *ServiceResponse {
+	revokeMap := make(map[string]error)
+	if ctx.Err() != nil {
+		log.Printf("RevokeSession: Context cancelled before GetUserSessions: %v", ctx.Err())
+		revokeMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: revokeMap}
+	}
+	sessions, err := as.userSessions(ctx, userID)
+	if err != nil {
+		log.Printf("Error when getting the sessions of %s: %v", userID, err)
+		revokeMap["GetSessionsError"] = err
+		return &ServiceResponse{Success: false, Errors: revokeMap}
+	}
+	for _, session := range sessions {
+		if session.SessionId != sessionID {
+			continue
+		}
+		if err := as.revokeSessions(ctx, userID, []repository.RedisRepositoryResponseData{session}); err != nil {
+			log.Printf("Error revoking session %s: %v", sessionID, err)
+			revokeMap["DelSessionError"] = err
+			return &ServiceResponse{Success: false, Errors: revokeMap}
+		}
+		log.Printf("Session %s of %s was revoked", sessionID, userID)
+		return &ServiceResponse{Success: true, UserId: userID}
+	}
+	revokeMap["SessionId"] = erro.ErrorInvalidSessionID
+	return &ServiceResponse{Success: false, Errors: revokeMap}
+}
+
+// RevokeAllSessions logs the user out everywhere, optionally keeping the session the request came from.
+func (as *AuthService) RevokeAllSessions(ctx context.Context, userID uuid.UUID, keepSessionID string) *ServiceResponse {
+	revokeMap := make(map[string]error)
+	if ctx.Err() != nil {
+		log.Printf("RevokeAllSessions: Context cancelled before GetUserSessions: %v", ctx.Err())
+		revokeMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: revokeMap}
+	}
+	if err := as.revokeUserSessions(ctx, userID, keepSessionID); err != nil {
+		log.Printf("Error revoking the sessions of %s: %v", userID, err)
+		revokeMap["DelSessionError"] = err
+		return &ServiceResponse{Success: false, Errors: revokeMap}
+	}
+	if keepSessionID == "" {
+		outboxEvent, errv := newOutboxEvent(as.topics.UserLoggedOut, userID, &events.UserLoggedOut{UserID: userID})
+		if errv != nil {
+			revokeMap["EventError"] = erro.ErrorMarshal
+			log.Printf("Error building %s event: %v", events.TypeUserLoggedOut, errv)
+			return &ServiceResponse{Success: false, Errors: revokeMap}
+		}
+		outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, nil, outboxEvent)
+		if !outboxResponse.Success {
+			log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+			revokeMap["OutboxError"] = outboxResponse.Errors
+			return &ServiceResponse{Success: false, Errors: revokeMap}
+		}
+	}
+	log.Printf("All sessions of %s were revoked", userID)
+	return &ServiceResponse{Success: true, UserId: userID}
+}
+
+func (as *AuthService) userSessions(ctx context.Context, userID uuid.UUID) ([]repository.RedisRepositoryResponseData, error) {
+	repoResponse := as.redisrepo.GetUserSessions(ctx, userID)
+	if !repoResponse.Success {
+		return nil, repoResponse.Errors
+	}
+	data, ok := repoResponse.Data.(repository.RedisSessionListResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", repoResponse.Data)
+		return nil, erro.ErrorUnexpectedData
+	}
+	return data.Sessions, nil
+}
+
+func (as *AuthService) revokeUserSessions(ctx context.Context, userID uuid.UUID, keepSessionID string) error {
+	sessions, err := as.userSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	keepFamilyID := ""
+	revoke := make([]repository.RedisRepositoryResponseData, 0, len(sessions))
+	for _, session := range sessions {
+		if session.SessionId == keepSessionID {
+			keepFamilyID = session.FamilyID
+			continue
+		}
+		revoke = append(revoke, session)
+	}
+	if err := as.revokeSessions(ctx, userID, revoke); err != nil {
+		return err
+	}
+	// Refresh tokens outlive their access sessions, so families are revoked through the
+	// user index rather than through the sessions that are still alive.
+	families, err := as.userTokenFamilies(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, familyID := range families {
+		if familyID == keepFamilyID {
+			continue
+		}
+		if ctx.Err() != nil {
+			return erro.ErrorContextTimeout
+		}
+		if response := as.tokenrepo.RevokeTokenFamily(ctx, familyID); !response.Success {
+			return response.Errors
+		}
+	}
+	return nil
+}
+
+func (as *AuthService) userTokenFamilies(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	repoResponse := as.tokenrepo.GetUserTokenFamilies(ctx, userID)
+	if !repoResponse.Success {
+		return nil, repoResponse.Errors
+	}
+	data, ok := repoResponse.Data.(repository.RedisTokenFamiliesResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", repoResponse.Data)
+		return nil, erro.ErrorUnexpectedData
+	}
+	return data.FamilyIDs, nil
+}
+
+// revokeSessions deletes sessions and the refresh token families behind token sessions,
+// so a revoked mobile login cannot come back through /token/refresh.
+func (as *AuthService) revokeSessions(ctx context.Context, userID uuid.UUID, sessions []repository.RedisRepositoryResponseData) error {
+	revokedFamilies := make(map[string]bool)
+	for _, session := range sessions {
+		if ctx.Err() != nil {
+			return erro.ErrorContextTimeout
+		}
+		if session.FamilyID != "" && !revokedFamilies[session.FamilyID] {
+			if response := as.tokenrepo.RevokeTokenFamily(ctx, session.FamilyID); !response.Success {
+				return response.Errors
+			}
+			revokedFamilies[session.FamilyID] = true
+		}
+		if response := as.redisrepo.DeleteSession(ctx, userID, session.SessionId); !response.Success {
+			return erro.ErrorRevokeSessions
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthService_RevokeSessions(t *testing.T) {
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	redisRepo := &fakeRedisRepo{}
+	tokens := newFakeTokenRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID}, RedisSessionRepos: redisRepo, RedisTokenRepos: tokens, DBOutboxRepos: outbox}})
+	ctx := context.Background()
+	person := &model.Person{Email: "tester@example.com", Password: "password123"}
+
+	current := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, current.Success)
+	other := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, other.Success)
+	mobile := as.IssueTokens(ctx, person, "192.0.2.1")
+	require.True(t, mobile.Success)
+	redisRepo.SetSession(ctx, model.Session{SessionID: "foreign", UserID: otherUserID, ExpirationTime: time.Now().Add(time.Hour)}, time.Hour)
+
+	listed := as.ListSessions(ctx, userID)
+	require.True(t, listed.Success)
+	assert.Len(t, listed.Sessions, 3, "Должны возвращаться только сессии пользователя")
+
+	notOwned := as.RevokeSession(ctx, userID, "foreign")
+	assert.False(t, notOwned.Success, "Чужую сессию нельзя отозвать")
+	assert.Equal(t, erro.ErrorInvalidSessionID, notOwned.Errors["SessionId"], "Тип ошибки должен совпадать")
+
+	require.True(t, as.RevokeSession(ctx, userID, other.SessionId).Success)
+	assert.NotContains(t, redisRepo.sessions, other.SessionId)
+
+	require.True(t, as.RevokeAllSessions(ctx, userID, current.SessionId).Success)
+	assert.Contains(t, redisRepo.sessions, current.SessionId, "Текущая сессия должна сохраниться")
+	assert.NotContains(t, redisRepo.sessions, mobile.SessionId)
+	assert.False(t, as.RefreshTokens(ctx, mobile.RefreshToken).Success, "Refresh token отозванной сессии не должен работать")
+
+	require.True(t, as.DeleteAccount(ctx, current.SessionId, userID, "password123").Success)
+	assert.Len(t, redisRepo.sessions, 1, "После удаления аккаунта должны остаться только чужие сессии")
+	assert.Contains(t, redisRepo.sessions, "foreign")
+}
+
+func TestAuthService_DeleteAccountFailedCommitKeepsSessions(t *testing.T) {
+	userID := uuid.New()
+	db := &fakeDBRepo{userID: userID}
+	redisRepo := &fakeRedisRepo{}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: db, RedisSessionRepos: redisRepo}})
+	ctx := context.Background()
+	person := &model.Person{Email: "tester@example.com", Password: "password123"}
+	session := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, session.Success)
+
+	db.commitErr = errors.New("connection reset")
+	deleted := as.DeleteAccount(ctx, session.SessionId, userID, "password123")
+	assert.False(t, deleted.Success)
+	assert.Equal(t, erro.ErrorCommitTransaction, deleted.Errors["CommitError"], "Тип ошибки должен совпадать")
+	assert.Contains(t, redisRepo.sessions, session.SessionId, "Сессии не должны отзываться, если аккаунт не удалён")
+}
+
+// Refresh tokens live for weeks while their access sessions expire within minutes, so
+// revocation must not depend on the access session still being there.
+func TestAuthService_RevokeSessionsAfterAccessSessionExpired(t *testing.T) {
+	userID := uuid.New()
+	db := &fakeDBRepo{userID: userID}
+	redisRepo := &fakeRedisRepo{}
+	tokens := newFakeTokenRepo()
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: db, RedisSessionRepos: redisRepo, RedisTokenRepos: tokens}})
+	ctx := context.Background()
+	person := &model.Person{Email: "tester@example.com", Password: "password123"}
+
+	current := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, current.Success)
+	mobile := as.IssueTokens(ctx, person, "192.0.2.1")
+	require.True(t, mobile.Success)
+	delete(redisRepo.sessions, mobile.SessionId)
+
+	require.True(t, as.RevokeAllSessions(ctx, userID, current.SessionId).Success)
+	revoked := as.RefreshTokens(ctx, mobile.RefreshToken)
+	assert.False(t, revoked.Success, "Refresh token должен отзываться и после истечения access-сессии")
+	assert.Equal(t, erro.ErrorInvalidRefreshToken, revoked.Errors["RefreshError"], "Тип ошибки должен совпадать")
+
+	tablet := as.IssueTokens(ctx, person, "192.0.2.1")
+	require.True(t, tablet.Success)
+	delete(redisRepo.sessions, tablet.SessionId)
+	require.True(t, as.DeleteAccount(ctx, current.SessionId, userID, "password123").Success)
+	assert.False(t, as.RefreshTokens(ctx, tablet.RefreshToken).Success, "После удаления аккаунта refresh token не должен работать")
+}
+
+func TestAuthService_RefreshTokensOfDeletedUser(t *testing.T) {
+	userID := uuid.New()
+	db := &fakeDBRepo{userID: userID}
+	tokens := newFakeTokenRepo()
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: db, RedisTokenRepos: tokens}})
+	ctx := context.Background()
+
+	issued := as.IssueTokens(ctx, &model.Person{Email: "tester@example.com", Password: "password123"}, "192.0.2.1")
+	require.True(t, issued.Success)
+	db.deleted = true
+
+	refreshed := as.RefreshTokens(ctx, issued.RefreshToken)
+	assert.False(t, refreshed.Success, "Удалённый пользователь не должен получать новые токены")
+	assert.Equal(t, erro.ErrorInvalidRefreshToken, refreshed.Errors["RefreshError"], "Тип ошибки должен совпадать")
+	assert.Len(t, tokens.revoked, 1, "Семейство токенов удалённого пользователя должно быть отозвано")
+}
+
+func TestAuthService_LogoutDoesNotRenewSession(t *testing.T) {
+	userID := uuid.New()
+	redisRepo := &fakeRedisRepo{}
+	tokens := newFakeTokenRepo()
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID}, RedisSessionRepos: redisRepo, RedisTokenRepos: tokens}})
+	ctx := context.Background()
+	person := &model.Person{Email: "tester@example.com", Password: "password123"}
+
+	browser := as.AuthenticateAndLogin(ctx, person, "192.0.2.1")
+	require.True(t, browser.Success)
+	mobile := as.IssueTokens(ctx, person, "192.0.2.1")
+	require.True(t, mobile.Success)
+	redisRepo.SetSession(ctx, model.Session{SessionID: "foreign", UserID: uuid.New(), ExpirationTime: time.Now().Add(time.Hour)}, time.Hour)
+
+	require.True(t, as.Logout(ctx, browser.SessionId, userID).Success)
+	require.True(t, as.Logout(ctx, mobile.SessionId, userID).Success)
+	require.True(t, as.Logout(ctx, "foreign", userID).Success)
+	assert.Zero(t, redisRepo.reads, "Выход не должен продлевать сессию перед удалением")
+	assert.NotContains(t, redisRepo.sessions, browser.SessionId)
+	assert.NotContains(t, redisRepo.sessions, mobile.SessionId)
+	assert.Contains(t, redisRepo.sessions, "foreign", "Чужая сессия не должна удаляться")
+	assert.False(t, as.RefreshTokens(ctx, mobile.RefreshToken).Success, "Refresh token сессии должен отзываться при выходе")
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"shared/events"
+	"shared/jwtverify"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const refreshTokenBytes = 32
+
+// IssueTokens authenticates the user and starts a new refresh token family with a short-lived access token.
+func (as *AuthService) IssueTokens(ctx context.Context, user *model.Person, clientIP string) *ServiceResponse {
+	issueMap := make(map[string]error)
+
+	errorvalidate := validatePerson(as, user, false)
+	if errorvalidate != nil {
+		log.Printf("Validate error %v", errorvalidate)
+		return &ServiceResponse{Success: false, Errors: errorvalidate}
+	}
+
+	userID, failure := as.authenticate(ctx, user.Email, user.Password, clientIP, issueMap)
+	if failure != nil {
+		return failure
+	}
+	if challenge := as.requireMFA(ctx, userID, mfaTokenPurpose, issueMap); challenge != nil {
+		return challenge
+	}
+	return as.startTokenFamily(ctx, userID, issueMap)
+}
+
+// startTokenFamily issues the first token pair of a new family to an authenticated user.
+func (as *AuthService) startTokenFamily(ctx context.Context, userID uuid.UUID, issueMap map[string]error) *ServiceResponse {
+	tokens := as.issueTokenPair(ctx, userID, uuid.New().String(), issueMap)
+	if !tokens.Success {
+		return tokens
+	}
+
+	outboxEvent, errv := newOutboxEvent(as.topics.UserAuthenticate, userID, &events.UserAuthenticated{UserID: userID})
+	if errv != nil {
+		issueMap["EventError"] = erro.ErrorMarshal
+		log.Printf("Error building %s event: %v", events.TypeUserAuthenticated, errv)
+		return &ServiceResponse{Success: false, Errors: issueMap}
+	}
+	outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, nil, outboxEvent)
+	if !outboxResponse.Success {
+		log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+		issueMap["OutboxError"] = outboxResponse.Errors
+		return &ServiceResponse{Success: false, Errors: issueMap}
+	}
+	log.Println("Tokens were issued and the user is authenticated!")
+	return tokens
+}
+
+// RefreshTokens rotates a refresh token. Presenting an already used token revokes its whole family,
+// because it means either the client or an attacker holds a stolen copy.
+func (as *AuthService) RefreshTokens(ctx context.Context, refreshToken string) *ServiceResponse {
+	refreshMap := make(map[string]error)
+
+	if refreshToken == "" {
+		refreshMap["RefreshError"] = erro.ErrorInvalidRefreshToken
+		return &ServiceResponse{Success: false, Errors: refreshMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("RefreshTokens: Context cancelled before UseRefreshToken: %v", ctx.Err())
+		refreshMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: refreshMap}
+	}
+
+	repoResponse := as.tokenrepo.UseRefreshToken(ctx, hashToken(refreshToken))
+	if !repoResponse.Success {
+		if errors.Is(repoResponse.Errors, erro.ErrorRefreshTokenReused) {
+			if tokenData, ok := repoResponse.Data.(repository.RedisTokenResponseData); ok {
+				revokeResponse := as.tokenrepo.RevokeTokenFamily(ctx, tokenData.FamilyID)
+				if !revokeResponse.Success {
+					log.Printf("Error revoking token family %s: %v", tokenData.FamilyID, revokeResponse.Errors)
+				}
+			}
+		}
+		log.Printf("Error when using a refresh token: %v", repoResponse.Errors)
+		refreshMap["RefreshError"] = repoResponse.Errors
+		return &ServiceResponse{Success: false, Errors: refreshMap}
+	}
+	tokenData, ok := repoResponse.Data.(repository.RedisTokenResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", repoResponse.Data)
+		refreshMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: refreshMap}
+	}
+
+	// A deleted account must not keep minting access tokens from a refresh token that
+	// outlived its revocation.
+	if _, err := as.userByID(ctx, tokenData.UserID); err != nil {
+		log.Printf("Error when getting the owner of token family %s: %v", tokenData.FamilyID, err)
+		if errors.Is(err, erro.ErrorFoundUser) {
+			if revokeResponse := as.tokenrepo.RevokeTokenFamily(ctx, tokenData.FamilyID); !revokeResponse.Success {
+				log.Printf("Error revoking token family %s: %v", tokenData.FamilyID, revokeResponse.Errors)
+			}
+			refreshMap["RefreshError"] = erro.ErrorInvalidRefreshToken
+			return &ServiceResponse{Success: false, Errors: refreshMap}
+		}
+		refreshMap["GetUserError"] = err
+		return &ServiceResponse{Success: false, Errors: refreshMap}
+	}
+
+	log.Printf("Refresh token of family %s rotated", tokenData.FamilyID)
+	return as.issueTokenPair(ctx, tokenData.UserID, tokenData.FamilyID, refreshMap)
+}
+
+func (as *AuthService) issueTokenPair(ctx context.Context, userID uuid.UUID, familyID string, errMap map[string]error) *ServiceResponse {
+	refreshToken, err := generateToken()
+	if err != nil {
+		log.Printf("Error generating refresh token: %v", err)
+		errMap["TokenError"] = erro.ErrorGenerateToken
+		return &ServiceResponse{Success: false, Errors: errMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("issueTokenPair: Context cancelled before SetSession: %v", ctx.Err())
+		errMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: errMap}
+	}
+
+	session := model.Session{
+		SessionID:      uuid.New().String(),
+		UserID:         userID,
+		ExpirationTime: time.Now().Add(as.tokens.AccessTokenTTL),
+		FamilyID:       familyID,
+		CreatedAt:      time.Now(),
+	}
+	sessionResponse := as.redisrepo.SetSession(ctx, session, as.tokens.AccessTokenTTL)
+	if !sessionResponse.Success {
+		log.Printf("Error when creating a session in Redis: %v", sessionResponse.Errors)
+		errMap["SetSessionError"] = sessionResponse.Errors
+		return &ServiceResponse{Success: false, Errors: errMap}
+	}
+	accessToken, err := as.keys.Sign(jwtverify.Claims{
+		Issuer:    as.tokens.Issuer,
+		Subject:   userID.String(),
+		SessionID: session.SessionID,
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: session.ExpirationTime.Unix(),
+	})
+	if err != nil {
+		log.Printf("Error signing access token: %v", err)
+		errMap["TokenError"] = erro.ErrorGenerateToken
+		return &ServiceResponse{Success: false, Errors: errMap}
+	}
+	familyResponse := as.tokenrepo.AddToTokenFamily(ctx, familyID, session.SessionID, as.tokens.RefreshTokenTTL)
+	if !familyResponse.Success {
+		log.Printf("Error when adding a session to the token family: %v", familyResponse.Errors)
+		errMap["SetRefreshTokenError"] = familyResponse.Errors
+		return &ServiceResponse{Success: false, Errors: errMap}
+	}
+
+	token := model.RefreshToken{
+		TokenHash:      hashToken(refreshToken),
+		UserID:         userID,
+		FamilyID:       familyID,
+		ExpirationTime: time.Now().Add(as.tokens.RefreshTokenTTL),
+	}
+	tokenResponse := as.tokenrepo.SetRefreshToken(ctx, token, as.tokens.RefreshTokenTTL)
+	if !tokenResponse.Success {
+		log.Printf("Error when creating a refresh token in Redis: %v", tokenResponse.Errors)
+		errMap["SetRefreshTokenError"] = tokenResponse.Errors
+		return &ServiceResponse{Success: false, Errors: errMap}
+	}
+
+	return &ServiceResponse{
+		Success:               true,
+		UserId:                userID,
+		SessionId:             session.SessionID,
+		ExpirationTime:        session.ExpirationTime,
+		AccessToken:           accessToken,
+		RefreshToken:          refreshToken,
+		RefreshExpirationTime: token.ExpirationTime,
+	}
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken is what gets stored in Redis, so a dump of the store does not hand out usable refresh tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type AccessTokenService struct {
+	keys     AccessTokenKeys
+	verifier *jwtverify.Verifier
+}
+
+func NewAccessTokenService(keys AccessTokenKeys, issuer string) *AccessTokenService {
+	return &AccessTokenService{keys: keys, verifier: jwtverify.NewVerifier(keys, issuer)}
+}
+
+func (ats *AccessTokenService) VerifyAccessToken(ctx context.Context, token string) (jwtverify.Claims, error) {
+	return ats.verifier.Verify(ctx, token)
+}
+
+func (ats *AccessTokenService) JWKS() jwtverify.JWKS {
+	return ats.keys.JWKS()
+}
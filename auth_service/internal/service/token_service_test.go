@@ -0,0 +1,106 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/jwtkeys"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTokenRepo struct {
+	tokens   map[string]model.RefreshToken
+	used     map[string]int
+	families map[string][]string
+	revoked  []string
+	// userFamilies is the user index, it keeps families after their sessions expire.
+	userFamilies map[uuid.UUID][]string
+}
+
+func newFakeTokenRepo() *fakeTokenRepo {
+	return &fakeTokenRepo{tokens: make(map[string]model.RefreshToken), used: make(map[string]int), families: make(map[string][]string), userFamilies: make(map[uuid.UUID][]string)}
+}
+
+func (f *fakeTokenRepo) SetRefreshToken(ctx context.Context, token model.RefreshToken, expiration time.Duration) *repository.RepositoryResponse {
+	f.tokens[token.TokenHash] = token
+	f.userFamilies[token.UserID] = append(f.userFamilies[token.UserID], token.FamilyID)
+	return f.AddToTokenFamily(ctx, token.FamilyID, token.TokenHash, expiration)
+}
+func (f *fakeTokenRepo) UseRefreshToken(ctx context.Context, tokenHash string) *repository.RepositoryResponse {
+	token, ok := f.tokens[tokenHash]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorInvalidRefreshToken}
+	}
+	f.used[tokenHash]++
+	data := repository.RedisTokenResponseData{UserID: token.UserID, FamilyID: token.FamilyID, ExpirationTime: token.ExpirationTime}
+	if f.used[tokenHash] > 1 {
+		return &repository.RepositoryResponse{Success: false, Data: data, Errors: erro.ErrorRefreshTokenReused}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: data}
+}
+func (f *fakeTokenRepo) AddToTokenFamily(ctx context.Context, familyID string, key string, expiration time.Duration) *repository.RepositoryResponse {
+	f.families[familyID] = append(f.families[familyID], key)
+	return &repository.RepositoryResponse{Success: true}
+}
+func (f *fakeTokenRepo) RevokeTokenFamily(ctx context.Context, familyID string) *repository.RepositoryResponse {
+	for _, key := range f.families[familyID] {
+		delete(f.tokens, key)
+	}
+	delete(f.families, familyID)
+	f.revoked = append(f.revoked, familyID)
+	return &repository.RepositoryResponse{Success: true}
+}
+func (f *fakeTokenRepo) GetUserTokenFamilies(ctx context.Context, userID uuid.UUID) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: true, Data: repository.RedisTokenFamiliesResponseData{FamilyIDs: f.userFamilies[userID]}}
+}
+
+func newTestKeys(t *testing.T) *jwtkeys.Manager {
+	keys, err := jwtkeys.NewManager(configs.TokenConfig{AccessTokenTTL: time.Minute})
+	require.NoError(t, err)
+	return keys
+}
+
+func TestAuthService_RefreshTokens(t *testing.T) {
+	userID := uuid.New()
+	tokens := newFakeTokenRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	keys := newTestKeys(t)
+	tokenConfig := configs.TokenConfig{AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour, Issuer: "auth_service"}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: &fakeDBRepo{userID: userID}, RedisTokenRepos: tokens, DBOutboxRepos: outbox}, config: AuthServiceConfig{Tokens: tokenConfig}, keys: keys})
+	ctx := context.Background()
+
+	issued := as.IssueTokens(ctx, &model.Person{Email: "tester@example.com", Password: "password123"}, "192.0.2.1")
+	require.True(t, issued.Success, "Выдача токенов должна пройти успешно: %v", issued.Errors)
+	assert.Equal(t, userID, issued.UserId, "UserId должен совпадать")
+	claims, err := NewAccessTokenService(keys, "auth_service").VerifyAccessToken(ctx, issued.AccessToken)
+	require.NoError(t, err, "Access token должен быть подписан действующим ключом")
+	assert.Equal(t, userID.String(), claims.Subject, "Subject должен совпадать")
+	assert.Equal(t, issued.SessionId, claims.SessionID, "SessionID должен совпадать")
+	assert.Equal(t, issued.ExpirationTime.Unix(), claims.ExpiresAt, "ExpiresAt должен совпадать")
+	assert.NotEmpty(t, issued.RefreshToken, "Refresh token должен быть выдан")
+	assert.NotContains(t, tokens.tokens, issued.RefreshToken, "Refresh token не должен храниться в открытом виде")
+
+	rotated := as.RefreshTokens(ctx, issued.RefreshToken)
+	require.True(t, rotated.Success, "Ротация должна пройти успешно: %v", rotated.Errors)
+	assert.NotEqual(t, issued.RefreshToken, rotated.RefreshToken, "Refresh token должен меняться при ротации")
+	assert.NotEqual(t, issued.SessionId, rotated.SessionId, "Access token должен меняться при ротации")
+
+	reused := as.RefreshTokens(ctx, issued.RefreshToken)
+	assert.False(t, reused.Success, "Success должен совпадать")
+	assert.Equal(t, erro.ErrorRefreshTokenReused, reused.Errors["RefreshError"], "Тип ошибки должен совпадать")
+	assert.Len(t, tokens.revoked, 1, "Семейство токенов должно быть отозвано")
+
+	afterRevoke := as.RefreshTokens(ctx, rotated.RefreshToken)
+	assert.False(t, afterRevoke.Success, "Токены отозванного семейства не должны работать")
+	assert.Equal(t, erro.ErrorInvalidRefreshToken, afterRevoke.Errors["RefreshError"], "Тип ошибки должен совпадать")
+
+	unknown := as.RefreshTokens(ctx, "unknown")
+	assert.False(t, unknown.Success, "Success должен совпадать")
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"shared/events"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	verifyEmailPurpose     = "verify_email"
+	defaultVerificationTTL = 24 * time.Hour
+)
+
+func withVerificationDefaults(cfg configs.VerificationConfig) configs.VerificationConfig {
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = defaultVerificationTTL
+	}
+	return cfg
+}
+
+// VerifyEmail marks the email of the token owner as verified. The token works once.
+func (as *AuthService) VerifyEmail(ctx context.Context, token string) *ServiceResponse {
+	verifyMap := make(map[string]error)
+	if token == "" {
+		verifyMap["VerificationError"] = erro.ErrorInvalidVerificationToken
+		return &ServiceResponse{Success: false, Errors: verifyMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("VerifyEmail: Context cancelled before ConsumeOneTimeToken: %v", ctx.Err())
+		verifyMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: verifyMap}
+	}
+	tokenResponse := as.onetimerepo.ConsumeOneTimeToken(ctx, verifyEmailPurpose, hashToken(token))
+	if !tokenResponse.Success {
+		log.Printf("Error when using a verification token: %v", tokenResponse.Errors)
+		if errors.Is(tokenResponse.Errors, erro.ErrorInvalidOneTimeToken) {
+			verifyMap["VerificationError"] = erro.ErrorInvalidVerificationToken
+		} else {
+			verifyMap["VerificationError"] = tokenResponse.Errors
+		}
+		return &ServiceResponse{Success: false, Errors: verifyMap}
+	}
+	tokenData, ok := tokenResponse.Data.(repository.RedisOneTimeTokenResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", tokenResponse.Data)
+		verifyMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: verifyMap}
+	}
+	// A link sent to an address the user has since changed must not verify the new one.
+	user, err := as.userByID(ctx, tokenData.UserID)
+	if err != nil {
+		log.Printf("Error when getting the user %s: %v", tokenData.UserID, err)
+		verifyMap["GetUserError"] = err
+		return &ServiceResponse{Success: false, Errors: verifyMap}
+	}
+	if !strings.EqualFold(tokenData.Subject, user.Email) {
+		log.Printf("Verification token of %s was issued for a previous email", tokenData.UserID)
+		verifyMap["VerificationError"] = erro.ErrorInvalidVerificationToken
+		return &ServiceResponse{Success: false, Errors: verifyMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("VerifyEmail: Context cancelled before SetEmailVerified: %v", ctx.Err())
+		verifyMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: verifyMap}
+	}
+	dbResponse := as.dbrepo.SetEmailVerified(ctx, nil, tokenData.UserID)
+	if !dbResponse.Success {
+		log.Printf("Error when marking the email of %s verified: %v", tokenData.UserID, dbResponse.Errors)
+		verifyMap["VerificationError"] = dbResponse.Errors
+		return &ServiceResponse{Success: false, Errors: verifyMap}
+	}
+	log.Printf("Email of %s was verified", tokenData.UserID)
+	return &ServiceResponse{Success: true, UserId: tokenData.UserID}
+}
+
+// ResendVerification issues a new verification link; links sent earlier stay valid until they expire.
+func (as *AuthService) ResendVerification(ctx context.Context, userID uuid.UUID) *ServiceResponse {
+	resendMap := make(map[string]error)
+	user, err := as.userByID(ctx, userID)
+	if err != nil {
+		log.Printf("Error when getting the user %s: %v", userID, err)
+		resendMap["GetUserError"] = err
+		return &ServiceResponse{Success: false, Errors: resendMap}
+	}
+	if user.EmailVerified {
+		resendMap["VerificationError"] = erro.ErrorEmailAlreadyVerified
+		return &ServiceResponse{Success: false, Errors: resendMap}
+	}
+	if err := as.requestVerification(ctx, nil, userID, user.Email); err != nil {
+		resendMap["VerificationError"] = err
+		return &ServiceResponse{Success: false, Errors: resendMap}
+	}
+	return &ServiceResponse{Success: true, UserId: userID}
+}
+
+// RequireVerifiedEmail fails with ErrorEmailNotVerified when the route is limited to verified
+// users by the configuration and the user has not verified the email yet.
+func (as *AuthService) RequireVerifiedEmail(ctx context.Context, route string, userID uuid.UUID) *ServiceResponse {
+	verifiedMap := make(map[string]error)
+	if !as.verification.RequireVerified || !as.verifiedRoutes[route] {
+		return &ServiceResponse{Success: true, UserId: userID}
+	}
+	user, err := as.userByID(ctx, userID)
+	if err != nil {
+		log.Printf("Error when getting the user %s: %v", userID, err)
+		verifiedMap["GetUserError"] = err
+		return &ServiceResponse{Success: false, Errors: verifiedMap}
+	}
+	if !user.EmailVerified {
+		verifiedMap["VerificationError"] = erro.ErrorEmailNotVerified
+		return &ServiceResponse{Success: false, Errors: verifiedMap}
+	}
+	return &ServiceResponse{Success: true, UserId: userID}
+}
+
+func (as *AuthService) userByID(ctx context.Context, userID uuid.UUID) (repository.DBUserResponseData, error) {
+	if ctx.Err() != nil {
+		return repository.DBUserResponseData{}, erro.ErrorContextTimeout
+	}
+	response := as.dbrepo.GetUserByID(ctx, userID)
+	if !response.Success {
+		return repository.DBUserResponseData{}, response.Errors
+	}
+	user, ok := response.Data.(repository.DBUserResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		return repository.DBUserResponseData{}, erro.ErrorUnexpectedData
+	}
+	return user, nil
+}
+
+// requestVerification stores a new verification token and queues the event the mail sender
+// delivers the link from. With tx the event commits together with the caller's changes.
+func (as *AuthService) requestVerification(ctx context.Context, tx *sql.Tx, userID uuid.UUID, email string) error {
+	token, err := generateToken()
+	if err != nil {
+		log.Printf("Error generating verification token: %v", err)
+		return erro.ErrorGenerateToken
+	}
+	if ctx.Err() != nil {
+		log.Printf("requestVerification: Context cancelled before SetOneTimeToken: %v", ctx.Err())
+		return erro.ErrorContextTimeout
+	}
+	tokenResponse := as.onetimerepo.SetOneTimeToken(ctx, verifyEmailPurpose, hashToken(token), userID, email, as.verification.TokenTTL)
+	if !tokenResponse.Success {
+		log.Printf("Error when storing the verification token: %v", tokenResponse.Errors)
+		return tokenResponse.Errors
+	}
+	payload := &events.UserVerificationRequested{
+		UserID:    userID,
+		Email:     email,
+		Token:     token,
+		ExpiresAt: time.Now().Add(as.verification.TokenTTL).UTC().Truncate(time.Second),
+	}
+	outboxEvent, err := newOutboxEvent(as.topics.UserVerificationRequested, userID, payload)
+	if err != nil {
+		log.Printf("Error building %s event: %v", events.TypeUserVerificationRequested, err)
+		return erro.ErrorMarshal
+	}
+	// The payload carries the token in plain text; the relay clears it once it is published.
+	outboxEvent.Sensitive = true
+	outboxResponse := as.outboxrepo.AddOutboxEvent(ctx, tx, outboxEvent)
+	if !outboxResponse.Success {
+		log.Printf("Error when adding the event to the outbox: %v", outboxResponse.Errors)
+		return outboxResponse.Errors
+	}
+	log.Printf("Email verification requested for %s", userID)
+	return nil
+}
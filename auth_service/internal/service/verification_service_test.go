@@ -0,0 +1,126 @@
+package service
+
+import (
+	"auth_service/configs"
+	"auth_service/internal/erro"
+	"auth_service/internal/model"
+	"auth_service/internal/repository"
+	"context"
+	"shared/events"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOneTimeRepo struct {
+	tokens map[string]repository.RedisOneTimeTokenResponseData
+}
+
+func newFakeOneTimeRepo() *fakeOneTimeRepo {
+	return &fakeOneTimeRepo{tokens: make(map[string]repository.RedisOneTimeTokenResponseData)}
+}
+
+func (f *fakeOneTimeRepo) SetOneTimeToken(ctx context.Context, purpose, tokenHash string, userID uuid.UUID, subject string, expiration time.Duration) *repository.RepositoryResponse {
+	f.tokens[purpose+":"+tokenHash] = repository.RedisOneTimeTokenResponseData{UserID: userID, Subject: subject}
+	return &repository.RepositoryResponse{Success: true, Data: f.tokens[purpose+":"+tokenHash]}
+}
+func (f *fakeOneTimeRepo) ConsumeOneTimeToken(ctx context.Context, purpose, tokenHash string) *repository.RepositoryResponse {
+	data, ok := f.tokens[purpose+":"+tokenHash]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorInvalidOneTimeToken}
+	}
+	delete(f.tokens, purpose+":"+tokenHash)
+	return &repository.RepositoryResponse{Success: true, Data: data}
+}
+func (f *fakeOneTimeRepo) PeekOneTimeToken(ctx context.Context, purpose, tokenHash string) *repository.RepositoryResponse {
+	data, ok := f.tokens[purpose+":"+tokenHash]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorInvalidOneTimeToken}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: data}
+}
+
+func TestAuthService_VerifyEmail(t *testing.T) {
+	db := &fakeDBRepo{}
+	onetime := newFakeOneTimeRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	verification := configs.VerificationConfig{TokenTTL: time.Hour, RequireVerified: true, Routes: []string{"/sessions"}}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: db, RedisOneTimeTokenRepos: onetime, DBOutboxRepos: outbox}, config: AuthServiceConfig{Verification: verification}})
+	ctx := context.Background()
+
+	registered := as.RegistrateAndLogin(ctx, &model.Person{Name: "tester", Email: "tester@example.com", Password: "password123"})
+	require.True(t, registered.Success, "Регистрация должна пройти успешно: %v", registered.Errors)
+	require.Len(t, outbox.events, 2, "Регистрация должна запросить подтверждение email")
+	assert.Equal(t, testTopics.UserVerificationRequested, outbox.events[1].Topic)
+	assert.True(t, outbox.events[1].Sensitive, "Событие с токеном должно очищаться после отправки")
+	_, payload, err := events.Unmarshal(outbox.events[1].Payload)
+	require.NoError(t, err)
+	requested := payload.(*events.UserVerificationRequested)
+	assert.Equal(t, "tester@example.com", requested.Email, "Email должен совпадать")
+	assert.NotContains(t, onetime.tokens, verifyEmailPurpose+":"+requested.Token, "Токен не должен храниться в открытом виде")
+
+	blocked := as.RequireVerifiedEmail(ctx, "/sessions", registered.UserId)
+	assert.False(t, blocked.Success, "До подтверждения маршрут должен быть закрыт")
+	assert.Equal(t, erro.ErrorEmailNotVerified, blocked.Errors["VerificationError"], "Тип ошибки должен совпадать")
+	assert.True(t, as.RequireVerifiedEmail(ctx, "/logout", registered.UserId).Success, "Маршруты вне списка не ограничиваются")
+
+	verified := as.VerifyEmail(ctx, requested.Token)
+	require.True(t, verified.Success, "Подтверждение должно пройти успешно: %v", verified.Errors)
+	assert.Equal(t, registered.UserId, verified.UserId, "UserId должен совпадать")
+	assert.True(t, as.RequireVerifiedEmail(ctx, "/sessions", registered.UserId).Success, "После подтверждения маршрут должен открыться")
+
+	reused := as.VerifyEmail(ctx, requested.Token)
+	assert.False(t, reused.Success, "Токен должен работать один раз")
+	assert.Equal(t, erro.ErrorInvalidVerificationToken, reused.Errors["VerificationError"], "Тип ошибки должен совпадать")
+
+	resent := as.ResendVerification(ctx, registered.UserId)
+	assert.Equal(t, erro.ErrorEmailAlreadyVerified, resent.Errors["VerificationError"], "Тип ошибки должен совпадать")
+}
+
+func TestAuthService_VerifyEmailAfterEmailChange(t *testing.T) {
+	userID := uuid.New()
+	db := &fakeDBRepo{userID: userID}
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{DBAuthenticateRepos: db, DBOutboxRepos: outbox}})
+	ctx := context.Background()
+	verificationToken := func(i int) string {
+		_, payload, err := events.Unmarshal(outbox.events[i].Payload)
+		require.NoError(t, err)
+		return payload.(*events.UserVerificationRequested).Token
+	}
+
+	require.True(t, as.ResendVerification(ctx, userID).Success)
+	oldLink := verificationToken(0)
+	newEmail := "new@example.com"
+	updated := as.UpdateProfile(ctx, userID, nil, &newEmail)
+	require.True(t, updated.Success, "Смена email должна пройти успешно: %v", updated.Errors)
+	require.Len(t, outbox.events, 3, "Смена email должна запросить подтверждение нового адреса")
+
+	stale := as.VerifyEmail(ctx, oldLink)
+	assert.False(t, stale.Success, "Ссылка на прежний адрес не должна подтверждать новый")
+	assert.Equal(t, erro.ErrorInvalidVerificationToken, stale.Errors["VerificationError"], "Тип ошибки должен совпадать")
+	assert.False(t, db.verified[userID], "Email не должен стать подтвержденным")
+
+	verified := as.VerifyEmail(ctx, verificationToken(2))
+	require.True(t, verified.Success, "Ссылка на новый адрес должна работать: %v", verified.Errors)
+	assert.True(t, db.verified[userID])
+}
+
+func TestAuthService_ResendVerification(t *testing.T) {
+	userID := uuid.New()
+	onetime := newFakeOneTimeRepo()
+	outbox := &fakeOutboxRepo{failed: make(map[int64]time.Time)}
+	as := newTestAuthService(t, testDeps{repos: repository.Repository{RedisOneTimeTokenRepos: onetime, DBOutboxRepos: outbox}})
+	ctx := context.Background()
+
+	response := as.ResendVerification(ctx, userID)
+
+	require.True(t, response.Success, "Повторная отправка должна пройти успешно: %v", response.Errors)
+	require.Len(t, outbox.events, 1, "Должно быть опубликовано событие")
+	assert.Equal(t, userID.String(), outbox.events[0].Key)
+	assert.Len(t, onetime.tokens, 1, "Токен должен быть сохранен")
+	assert.True(t, as.RequireVerifiedEmail(ctx, "/sessions", userID).Success, "Без require_verified маршруты не ограничиваются")
+}
@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238(t *testing.T) {
+	cases := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tc := range cases {
+		code, err := Code(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, code, "Код для %d должен совпадать", tc.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	previous, err := Code(secret, Step(now)-1)
+	require.NoError(t, err)
+	old, err := Code(secret, Step(now)-2)
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, previous, now, 1)
+	assert.True(t, ok, "Код предыдущего шага должен приниматься")
+	assert.Equal(t, Step(now)-1, step, "Шаг должен совпадать")
+	if old != previous {
+		_, ok = Validate(secret, old, now, 1)
+		assert.False(t, ok, "Код за пределами допуска не должен приниматься")
+	}
+	_, ok = Validate(secret, "12345", now, 1)
+	assert.False(t, ok, "Код неверной длины не должен приниматься")
+	_, ok = Validate("not base32!", "123456", now, 1)
+	assert.False(t, ok, "Некорректный секрет не должен приниматься")
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("Auth Service", "tester@example.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Auth Service:tester@example.com", uri.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	assert.Equal(t, "Auth Service", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+}
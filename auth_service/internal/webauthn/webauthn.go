@@ -0,0 +1,379 @@
+// Package webauthn implements the relying party side of the WebAuthn registration and
+// authentication ceremonies used for passkeys. Attestation is not requested, so every
+// registration is treated as "none" attestation and only the credential key is kept.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+
+	flagUserPresent   = 0x01
+	flagUserVerified  = 0x04
+	flagAttestedData  = 0x40
+	flagExtensionData = 0x80
+
+	challengeBytes     = 32
+	maxCredentialIDLen = 1023
+)
+
+var (
+	ErrInvalidResponse = errors.New("webauthn: malformed response")
+	ErrChallenge       = errors.New("webauthn: challenge does not match")
+	ErrOrigin          = errors.New("webauthn: origin is not allowed")
+	ErrRelyingParty    = errors.New("webauthn: credential is scoped to another relying party")
+	ErrUserVerified    = errors.New("webauthn: user was not verified")
+	ErrSignCount       = errors.New("webauthn: signature counter went backwards, the authenticator may be cloned")
+)
+
+// Base64URL is binary data that travels through JSON as unpadded base64url, the encoding of
+// every binary field in the WebAuthn JSON serialization.
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return ErrInvalidResponse
+	}
+	*b = decoded
+	return nil
+}
+
+// RelyingParty describes this service to authenticators. ID is the domain credentials are
+// scoped to and Origins lists the exact origins the browser may report.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+	Timeout time.Duration
+}
+
+type RPEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+type UserEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+type CredentialDescriptor struct {
+	Type       string    `json:"type"`
+	ID         Base64URL `json:"id"`
+	Transports []string  `json:"transports,omitempty"`
+}
+type AuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// CreationOptions is the publicKey argument of navigator.credentials.create().
+type CreationOptions struct {
+	Challenge              Base64URL              `json:"challenge"`
+	RP                     RPEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is the publicKey argument of navigator.credentials.get(). AllowCredentials
+// stays empty: passkeys are discoverable, so the authenticator offers the matching accounts.
+type RequestOptions struct {
+	Challenge        Base64URL `json:"challenge"`
+	Timeout          int64     `json:"timeout,omitempty"`
+	RPID             string    `json:"rpId"`
+	UserVerification string    `json:"userVerification"`
+}
+
+// RegistrationResponse is the JSON form of the credential returned by navigator.credentials.create().
+type RegistrationResponse struct {
+	ID       string              `json:"id"`
+	RawID    Base64URL           `json:"rawId"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+type AttestationResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON"`
+	AttestationObject Base64URL `json:"attestationObject"`
+	Transports        []string  `json:"transports,omitempty"`
+}
+
+// AuthenticationResponse is the JSON form of the credential returned by navigator.credentials.get().
+type AuthenticationResponse struct {
+	ID       string            `json:"id"`
+	RawID    Base64URL         `json:"rawId"`
+	Type     string            `json:"type"`
+	Response AssertionResponse `json:"response"`
+}
+type AssertionResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON"`
+	AuthenticatorData Base64URL `json:"authenticatorData"`
+	Signature         Base64URL `json:"signature"`
+	UserHandle        Base64URL `json:"userHandle,omitempty"`
+}
+
+// Credential is what a successful registration leaves to store: the credential id, its COSE
+// encoded public key and the signature counter.
+type Credential struct {
+	ID         []byte
+	PublicKey  []byte
+	SignCount  uint32
+	Transports []string
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+// NewChallenge returns a random challenge for one ceremony.
+func NewChallenge() (Base64URL, error) {
+	challenge := make([]byte, challengeBytes)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// Challenge returns the challenge the client signed, so the caller can find the ceremony the
+// response belongs to before verifying it.
+func Challenge(clientDataJSON []byte) (Base64URL, error) {
+	var data clientData
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return nil, ErrInvalidResponse
+	}
+	challenge, err := base64.RawURLEncoding.DecodeString(data.Challenge)
+	if err != nil || len(challenge) == 0 {
+		return nil, ErrInvalidResponse
+	}
+	return challenge, nil
+}
+
+func (rp RelyingParty) CreationOptions(challenge Base64URL, user UserEntity, exclude []CredentialDescriptor) CreationOptions {
+	return CreationOptions{
+		Challenge: challenge,
+		RP:        RPEntity{ID: rp.ID, Name: rp.Name},
+		User:      user,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            rp.Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}
+}
+
+func (rp RelyingParty) RequestOptions(challenge Base64URL) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.Timeout.Milliseconds(),
+		RPID:             rp.ID,
+		UserVerification: "required",
+	}
+}
+
+// FinishRegistration verifies the response to CreationOptions carrying challenge and returns
+// the new credential.
+func (rp RelyingParty) FinishRegistration(challenge Base64URL, response RegistrationResponse) (Credential, error) {
+	if response.Type != "public-key" {
+		return Credential{}, ErrInvalidResponse
+	}
+	if err := rp.verifyClientData(response.Response.ClientDataJSON, typeCreate, challenge); err != nil {
+		return Credential{}, err
+	}
+	decoded, n, err := decodeCBOR(response.Response.AttestationObject)
+	if err != nil || n != len(response.Response.AttestationObject) {
+		return Credential{}, ErrInvalidResponse
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return Credential{}, ErrInvalidResponse
+	}
+	// The statement itself is not checked: "none" conveyance was requested, and a client that
+	// still sends one does not make the authenticator any more trusted here.
+	if _, ok := attestation["fmt"].(string); !ok {
+		return Credential{}, ErrInvalidResponse
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return Credential{}, ErrInvalidResponse
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if err := rp.verifyAuthenticatorData(authData); err != nil {
+		return Credential{}, err
+	}
+	if authData.flags&flagAttestedData == 0 {
+		return Credential{}, ErrInvalidResponse
+	}
+	if len(response.RawID) > 0 && !bytes.Equal(response.RawID, authData.credentialID) {
+		return Credential{}, ErrInvalidResponse
+	}
+	if _, err := parsePublicKey(authData.publicKey); err != nil {
+		return Credential{}, err
+	}
+	return Credential{
+		ID:         authData.credentialID,
+		PublicKey:  authData.publicKey,
+		SignCount:  authData.signCount,
+		Transports: response.Response.Transports,
+	}, nil
+}
+
+// FinishLogin verifies the response to RequestOptions carrying challenge against the stored
+// credential and returns the new signature counter to store.
+func (rp RelyingParty) FinishLogin(challenge Base64URL, credential Credential, response AuthenticationResponse) (uint32, error) {
+	if response.Type != "public-key" || !bytes.Equal(response.RawID, credential.ID) {
+		return 0, ErrInvalidResponse
+	}
+	if err := rp.verifyClientData(response.Response.ClientDataJSON, typeGet, challenge); err != nil {
+		return 0, err
+	}
+	authData, err := parseAuthenticatorData(response.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorData(authData); err != nil {
+		return 0, err
+	}
+	key, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), response.Response.AuthenticatorData...), clientDataHash[:]...)
+	if err := key.verify(signed, response.Response.Signature); err != nil {
+		return 0, err
+	}
+	// Authenticators without a counter always report zero; any other value has to grow.
+	if (authData.signCount != 0 || credential.SignCount != 0) && authData.signCount <= credential.SignCount {
+		return 0, ErrSignCount
+	}
+	return authData.signCount, nil
+}
+
+func (rp RelyingParty) verifyClientData(raw []byte, ceremony string, challenge Base64URL) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return ErrInvalidResponse
+	}
+	if data.Type != ceremony {
+		return ErrInvalidResponse
+	}
+	signed, err := base64.RawURLEncoding.DecodeString(data.Challenge)
+	if err != nil || len(challenge) == 0 || subtle.ConstantTimeCompare(signed, challenge) != 1 {
+		return ErrChallenge
+	}
+	if data.CrossOrigin {
+		return ErrOrigin
+	}
+	for _, origin := range rp.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return ErrOrigin
+}
+
+func (rp RelyingParty) verifyAuthenticatorData(data authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(data.rpIDHash, rpIDHash[:]) != 1 {
+		return ErrRelyingParty
+	}
+	// User verification is required by the options, which also implies presence.
+	if data.flags&flagUserPresent == 0 || data.flags&flagUserVerified == 0 {
+		return ErrUserVerified
+	}
+	return nil
+}
+
+// parseAuthenticatorData splits the authenticator data: the rp id hash, flags and counter,
+// then the attested credential and the extensions when their flags are set.
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	if len(data) < 37 {
+		return authenticatorData{}, ErrInvalidResponse
+	}
+	parsed := authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+	if parsed.flags&flagAttestedData != 0 {
+		// 16 bytes of AAGUID, then the length of the credential id.
+		if len(rest) < 18 {
+			return authenticatorData{}, ErrInvalidResponse
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > maxCredentialIDLen || len(rest) < idLen {
+			return authenticatorData{}, ErrInvalidResponse
+		}
+		parsed.credentialID = rest[:idLen]
+		rest = rest[idLen:]
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, ErrInvalidResponse
+		}
+		parsed.publicKey = rest[:n]
+		rest = rest[n:]
+	}
+	if parsed.flags&flagExtensionData != 0 {
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, ErrInvalidResponse
+		}
+		rest = rest[n:]
+	}
+	if len(rest) != 0 {
+		return authenticatorData{}, ErrInvalidResponse
+	}
+	return parsed, nil
+}
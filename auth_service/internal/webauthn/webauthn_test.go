@@ -0,0 +1,99 @@
+package webauthn_test
+
+import (
+	"auth_service/internal/webauthn"
+	"auth_service/internal/webauthn/webauthntest"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rp = webauthn.RelyingParty{ID: "example.com", Name: "Example", Origins: []string{"https://example.com"}, Timeout: time.Minute}
+
+func register(t *testing.T, authenticator *webauthntest.Authenticator) webauthn.Credential {
+	challenge, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+	options := rp.CreationOptions(challenge, webauthn.UserEntity{ID: []byte("user-1"), Name: "tester@example.com", DisplayName: "tester"}, nil)
+	credential, err := rp.FinishRegistration(challenge, authenticator.Register(options))
+	require.NoError(t, err)
+	return credential
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	authenticator := webauthntest.New("example.com", "https://example.com")
+	credential := register(t, authenticator)
+	assert.Equal(t, authenticator.CredentialID, credential.ID, "Id credential должен совпадать")
+	assert.Equal(t, []string{"internal"}, credential.Transports)
+
+	challenge, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+	response := authenticator.Login(rp.RequestOptions(challenge))
+	signed, err := webauthn.Challenge(response.Response.ClientDataJSON)
+	require.NoError(t, err)
+	assert.Equal(t, challenge, signed, "Challenge должен извлекаться из clientDataJSON")
+
+	signCount, err := rp.FinishLogin(challenge, credential, response)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), signCount, "Счетчик подписей должен совпадать")
+
+	credential.SignCount = signCount
+	_, err = rp.FinishLogin(challenge, credential, response)
+	assert.ErrorIs(t, err, webauthn.ErrSignCount, "Повтор ответа не должен приниматься")
+}
+
+func TestFinishLogin_Rejects(t *testing.T) {
+	authenticator := webauthntest.New("example.com", "https://example.com")
+	credential := register(t, authenticator)
+	challenge, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+	other, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+
+	_, err = rp.FinishLogin(other, credential, authenticator.Login(rp.RequestOptions(challenge)))
+	assert.ErrorIs(t, err, webauthn.ErrChallenge, "Чужой challenge не должен приниматься")
+
+	authenticator.Origin = "https://evil.example"
+	_, err = rp.FinishLogin(challenge, credential, authenticator.Login(rp.RequestOptions(challenge)))
+	assert.ErrorIs(t, err, webauthn.ErrOrigin, "Чужой origin не должен приниматься")
+
+	authenticator.Origin, authenticator.RPID = "https://example.com", "evil.example"
+	_, err = rp.FinishLogin(challenge, credential, authenticator.Login(rp.RequestOptions(challenge)))
+	assert.ErrorIs(t, err, webauthn.ErrRelyingParty, "Credential другого RP не должен приниматься")
+
+	authenticator.RPID = "example.com"
+	response := authenticator.Login(rp.RequestOptions(challenge))
+	response.Response.Signature[len(response.Response.Signature)-1] ^= 0xff
+	_, err = rp.FinishLogin(challenge, credential, response)
+	assert.Error(t, err, "Испорченная подпись не должна приниматься")
+
+	stranger := webauthntest.New("example.com", "https://example.com")
+	_, err = rp.FinishLogin(challenge, credential, stranger.Login(rp.RequestOptions(challenge)))
+	assert.ErrorIs(t, err, webauthn.ErrInvalidResponse, "Ответ другого credential не должен приниматься")
+}
+
+func TestFinishRegistration_WrongCeremony(t *testing.T) {
+	authenticator := webauthntest.New("example.com", "https://example.com")
+	challenge, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+	login := authenticator.Login(rp.RequestOptions(challenge))
+	response := webauthn.RegistrationResponse{
+		Type:     "public-key",
+		Response: webauthn.AttestationResponse{ClientDataJSON: login.Response.ClientDataJSON, AttestationObject: []byte{0xa0}},
+	}
+	_, err = rp.FinishRegistration(challenge, response)
+	assert.ErrorIs(t, err, webauthn.ErrInvalidResponse, "Ответ webauthn.get не должен приниматься при регистрации")
+}
+
+func TestBase64URL_JSON(t *testing.T) {
+	encoded, err := json.Marshal(webauthn.Base64URL{0xfb, 0xff})
+	require.NoError(t, err)
+	assert.Equal(t, `"-_8"`, string(encoded), "Кодирование должно быть base64url без padding")
+
+	var decoded webauthn.Base64URL
+	require.NoError(t, json.Unmarshal([]byte(`"-_8="`), &decoded))
+	assert.Equal(t, webauthn.Base64URL{0xfb, 0xff}, decoded, "Padding должен допускаться")
+	assert.Error(t, json.Unmarshal([]byte(`"not base64!"`), &decoded))
+}
@@ -0,0 +1,176 @@
+// Package webauthntest provides a software authenticator that answers WebAuthn ceremonies the
+// way a browser and a platform passkey would, for use in tests.
+package webauthntest
+
+import (
+	"auth_service/internal/webauthn"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// Authenticator holds one ES256 credential. Origin is reported in the client data and RPID is
+// hashed into the authenticator data; both can be changed to produce responses for the wrong
+// relying party.
+type Authenticator struct {
+	Origin       string
+	RPID         string
+	SignCount    uint32
+	CredentialID []byte
+	UserHandle   []byte
+
+	key *ecdsa.PrivateKey
+}
+
+func New(rpID, origin string) *Authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return &Authenticator{Origin: origin, RPID: rpID, CredentialID: id, key: key}
+}
+
+// Register answers navigator.credentials.create() with options.
+func (a *Authenticator) Register(options webauthn.CreationOptions) webauthn.RegistrationResponse {
+	a.UserHandle = options.User.ID
+	clientData := a.clientData("webauthn.create", options.Challenge)
+
+	var credential []byte
+	credential = append(credential, make([]byte, 16)...)
+	credential = binary.BigEndian.AppendUint16(credential, uint16(len(a.CredentialID)))
+	credential = append(credential, a.CredentialID...)
+	credential = append(credential, a.coseKey()...)
+	authData := append(a.authData(flagUserPresent|flagUserVerified|flagAttestedData), credential...)
+
+	attestation := encode(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": authData,
+	})
+	return webauthn.RegistrationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		RawID: a.CredentialID,
+		Type:  "public-key",
+		Response: webauthn.AttestationResponse{
+			ClientDataJSON:    clientData,
+			AttestationObject: attestation,
+			Transports:        []string{"internal"},
+		},
+	}
+}
+
+// Login answers navigator.credentials.get() with options, counting the signature.
+func (a *Authenticator) Login(options webauthn.RequestOptions) webauthn.AuthenticationResponse {
+	a.SignCount++
+	clientData := a.clientData("webauthn.get", options.Challenge)
+	authData := a.authData(flagUserPresent | flagUserVerified)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return webauthn.AuthenticationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		RawID: a.CredentialID,
+		Type:  "public-key",
+		Response: webauthn.AssertionResponse{
+			ClientDataJSON:    clientData,
+			AuthenticatorData: authData,
+			Signature:         signature,
+			UserHandle:        a.UserHandle,
+		},
+	}
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) []byte {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (a *Authenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+func (a *Authenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	return encode(map[interface{}]interface{}{
+		int64(1):  int64(2),
+		int64(3):  int64(webauthn.AlgES256),
+		int64(-1): int64(1),
+		int64(-2): x,
+		int64(-3): y,
+	})
+}
+
+// encode writes the few CBOR types the responses need, with map keys in a fixed order.
+func encode(value interface{}) []byte {
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return header(1, uint64(-1-v))
+		}
+		return header(0, uint64(v))
+	case []byte:
+		return append(header(2, uint64(len(v))), v...)
+	case string:
+		return append(header(3, uint64(len(v))), v...)
+	case map[interface{}]interface{}:
+		keys := make([][]byte, 0, len(v))
+		values := make(map[string][]byte, len(v))
+		for key, item := range v {
+			encoded := encode(key)
+			keys = append(keys, encoded)
+			values[string(encoded)] = encode(item)
+		}
+		sort.Slice(keys, func(i, j int) bool { return string(keys[i]) < string(keys[j]) })
+		out := header(5, uint64(len(v)))
+		for _, key := range keys {
+			out = append(append(out, key...), values[string(key)]...)
+		}
+		return out
+	}
+	panic("webauthntest: unsupported CBOR value")
+}
+
+func header(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, arg)
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TypeUserRegistered    = "user.registered"
+	TypeUserAuthenticated = "user.authenticated"
+	TypeUserLoggedOut     = "user.logged_out"
+	TypeUserDeleted       = "user.deleted"
+	TypeUserLockedOut     = "user.locked_out"
+
+	TypeUserVerificationRequested  = "user.verification_requested"
+	TypeUserPasswordResetRequested = "user.password_reset_requested"
+	TypeUserPasswordChanged        = "user.password_changed"
+	TypeUserUpdated                = "user.updated"
+)
+
+var (
+	ErrInvalidEnvelope = errors.New("invalid event envelope")
+	ErrUnknownSchema   = errors.New("unknown event type or schema version")
+	ErrInvalidPayload  = errors.New("invalid event payload")
+)
+
+// Envelope is the wire format of every event published by the services.
+type Envelope struct {
+	EventID       uuid.UUID       `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	SchemaVersion int             `json:"schema_version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Producer      string          `json:"producer"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// Payload is implemented by every typed event body. A payload type is bound to exactly
+// one event type and schema version; a breaking change needs a new version, while added
+// fields are ignored by older consumers.
+type Payload interface {
+	EventType() string
+	SchemaVersion() int
+	Validate() error
+}
+
+// New wraps payload into an envelope after validating it against its registered schema.
+func New(payload Payload, producer string, occurredAt time.Time) (Envelope, error) {
+	if err := payload.Validate(); err != nil {
+		return Envelope{}, fmt.Errorf("%w: %s: %v", ErrInvalidPayload, payload.EventType(), err)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	envelope := Envelope{
+		EventID:       uuid.New(),
+		EventType:     payload.EventType(),
+		SchemaVersion: payload.SchemaVersion(),
+		OccurredAt:    occurredAt.UTC(),
+		Producer:      producer,
+		Payload:       body,
+	}
+	if _, err = envelope.Decode(); err != nil {
+		return Envelope{}, err
+	}
+	return envelope, nil
+}
+
+// Marshal builds an envelope for payload and encodes it as JSON.
+func Marshal(payload Payload, producer string, occurredAt time.Time) ([]byte, Envelope, error) {
+	envelope, err := New(payload, producer, occurredAt)
+	if err != nil {
+		return nil, Envelope{}, err
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, Envelope{}, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	return data, envelope, nil
+}
+
+// Unmarshal parses raw message bytes into a validated envelope and its typed payload.
+func Unmarshal(data []byte) (Envelope, Payload, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Envelope{}, nil, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	payload, err := envelope.Decode()
+	if err != nil {
+		return Envelope{}, nil, err
+	}
+	return envelope, payload, nil
+}
+
+// Decode validates the envelope fields and decodes the payload with the schema registered
+// for its event type and version. Unknown fields are ignored, incompatible changes bump the
+// schema version.
+func (e Envelope) Decode() (Payload, error) {
+	switch {
+	case e.EventID == uuid.Nil:
+		return nil, fmt.Errorf("%w: event_id is empty", ErrInvalidEnvelope)
+	case e.EventType == "":
+		return nil, fmt.Errorf("%w: event_type is empty", ErrInvalidEnvelope)
+	case e.SchemaVersion <= 0:
+		return nil, fmt.Errorf("%w: schema_version must be positive", ErrInvalidEnvelope)
+	case e.OccurredAt.IsZero():
+		return nil, fmt.Errorf("%w: occurred_at is empty", ErrInvalidEnvelope)
+	case e.Producer == "":
+		return nil, fmt.Errorf("%w: producer is empty", ErrInvalidEnvelope)
+	case len(e.Payload) == 0:
+		return nil, fmt.Errorf("%w: payload is empty", ErrInvalidEnvelope)
+	}
+	factory, ok := registry[schemaKey{eventType: e.EventType, version: e.SchemaVersion}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s v%d", ErrUnknownSchema, e.EventType, e.SchemaVersion)
+	}
+	payload := factory()
+	if err := json.Unmarshal(e.Payload, payload); err != nil {
+		return nil, fmt.Errorf("%w: %s v%d: %v", ErrInvalidPayload, e.EventType, e.SchemaVersion, err)
+	}
+	if err := payload.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s v%d: %v", ErrInvalidPayload, e.EventType, e.SchemaVersion, err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,125 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Every registered schema must keep decoding the fixture recorded for it. A renamed, removed
+// or retyped field breaks this test and has to ship as a new schema version instead.
+func TestRegisteredSchemasMatchFixtures(t *testing.T) {
+	for eventType, versions := range Schemas() {
+		for _, version := range versions {
+			name := fmt.Sprintf("%s.v%d.json", eventType, version)
+			t.Run(name, func(t *testing.T) {
+				data, err := os.ReadFile(filepath.Join("testdata", name))
+				require.NoError(t, err, "Для каждой схемы должна быть фикстура")
+
+				envelope, payload, err := Unmarshal(data)
+				require.NoError(t, err)
+				assert.Equal(t, eventType, envelope.EventType)
+				assert.Equal(t, version, payload.SchemaVersion())
+
+				encoded, err := json.Marshal(payload)
+				require.NoError(t, err)
+				assert.JSONEq(t, string(envelope.Payload), string(encoded), "Payload должен кодироваться так же, как в фикстуре")
+			})
+		}
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	userID := uuid.New()
+	occurredAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	data, envelope, err := Marshal(&UserLoggedOut{UserID: userID}, "auth_service", occurredAt)
+	require.NoError(t, err)
+
+	decoded, payload, err := Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, envelope.EventID, decoded.EventID)
+	assert.Equal(t, TypeUserLoggedOut, decoded.EventType)
+	assert.Equal(t, occurredAt, decoded.OccurredAt)
+	gotUserID, ok := UserIDOf(payload)
+	assert.True(t, ok)
+	assert.Equal(t, userID, gotUserID)
+}
+
+func TestUnmarshalRejectsIncompatibleEvents(t *testing.T) {
+	valid := map[string]interface{}{
+		"event_id":       uuid.New().String(),
+		"event_type":     TypeUserDeleted,
+		"schema_version": 1,
+		"occurred_at":    "2025-03-01T12:00:00Z",
+		"producer":       "auth_service",
+		"payload":        map[string]interface{}{"user_id": uuid.New().String()},
+	}
+	testCases := []struct {
+		name          string
+		mutate        func(event map[string]interface{})
+		expectedError error
+	}{
+		{name: "Unknown Version", mutate: func(e map[string]interface{}) { e["schema_version"] = 2 }, expectedError: ErrUnknownSchema},
+		{name: "Unknown Type", mutate: func(e map[string]interface{}) { e["event_type"] = "user.renamed" }, expectedError: ErrUnknownSchema},
+		{name: "Missing User ID", mutate: func(e map[string]interface{}) { e["payload"] = map[string]interface{}{} }, expectedError: ErrInvalidPayload},
+		{name: "Missing Event ID", mutate: func(e map[string]interface{}) { delete(e, "event_id") }, expectedError: ErrInvalidEnvelope},
+		{name: "Legacy Event Without Envelope", mutate: func(e map[string]interface{}) {
+			for key := range e {
+				delete(e, key)
+			}
+			e["user_id"] = uuid.New().String()
+			e["last_update"] = "2025-03-01T12:00:00Z"
+		}, expectedError: ErrInvalidEnvelope},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := make(map[string]interface{})
+			for key, value := range valid {
+				event[key] = value
+			}
+			tc.mutate(event)
+			data, err := json.Marshal(event)
+			require.NoError(t, err)
+
+			_, _, err = Unmarshal(data)
+			assert.True(t, errors.Is(err, tc.expectedError), "ожидалась %v, получена %v", tc.expectedError, err)
+		})
+	}
+}
+
+// Added fields are not a breaking change, consumers built against the current schema keep
+// reading events from newer producers.
+func TestUnmarshalIgnoresUnknownFields(t *testing.T) {
+	userID := uuid.New()
+	data, err := json.Marshal(map[string]interface{}{
+		"event_id":       uuid.New().String(),
+		"event_type":     TypeUserDeleted,
+		"schema_version": 1,
+		"occurred_at":    "2025-03-01T12:00:00Z",
+		"producer":       "auth_service",
+		"trace_id":       "abc",
+		"payload":        map[string]interface{}{"user_id": userID.String(), "reason": "requested"},
+	})
+	require.NoError(t, err)
+
+	envelope, payload, err := Unmarshal(data)
+	require.NoError(t, err, "Неизвестные поля не должны ломать разбор события")
+	assert.Equal(t, TypeUserDeleted, envelope.EventType)
+	gotUserID, ok := UserIDOf(payload)
+	assert.True(t, ok)
+	assert.Equal(t, userID, gotUserID)
+}
+
+func TestNewRejectsInvalidPayload(t *testing.T) {
+	_, err := New(&UserRegistered{}, "auth_service", time.Now())
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+}
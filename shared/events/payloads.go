@@ -0,0 +1,218 @@
+package events
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errEmptyUserID = errors.New("user_id is empty")
+
+type UserRegistered struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (p *UserRegistered) EventType() string  { return TypeUserRegistered }
+func (p *UserRegistered) SchemaVersion() int { return 1 }
+func (p *UserRegistered) Validate() error {
+	if p.UserID == uuid.Nil {
+		return errEmptyUserID
+	}
+	return nil
+}
+
+type UserAuthenticated struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (p *UserAuthenticated) EventType() string  { return TypeUserAuthenticated }
+func (p *UserAuthenticated) SchemaVersion() int { return 1 }
+func (p *UserAuthenticated) Validate() error {
+	if p.UserID == uuid.Nil {
+		return errEmptyUserID
+	}
+	return nil
+}
+
+type UserLoggedOut struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (p *UserLoggedOut) EventType() string  { return TypeUserLoggedOut }
+func (p *UserLoggedOut) SchemaVersion() int { return 1 }
+func (p *UserLoggedOut) Validate() error {
+	if p.UserID == uuid.Nil {
+		return errEmptyUserID
+	}
+	return nil
+}
+
+type UserDeleted struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (p *UserDeleted) EventType() string  { return TypeUserDeleted }
+func (p *UserDeleted) SchemaVersion() int { return 1 }
+func (p *UserDeleted) Validate() error {
+	if p.UserID == uuid.Nil {
+		return errEmptyUserID
+	}
+	return nil
+}
+
+const (
+	LockoutScopeAccount = "account"
+	LockoutScopeIP      = "ip"
+)
+
+// UserLockedOut is published when repeated failed logins lock an account or a client IP.
+// Account lockouts of unknown emails are not published, there is no user to refer to.
+type UserLockedOut struct {
+	Scope          string     `json:"scope"`
+	UserID         *uuid.UUID `json:"user_id,omitempty"`
+	IP             string     `json:"ip,omitempty"`
+	FailedAttempts int        `json:"failed_attempts"`
+	LockedUntil    time.Time  `json:"locked_until"`
+}
+
+func (p *UserLockedOut) EventType() string  { return TypeUserLockedOut }
+func (p *UserLockedOut) SchemaVersion() int { return 1 }
+func (p *UserLockedOut) Validate() error {
+	switch p.Scope {
+	case LockoutScopeAccount:
+		if p.UserID == nil || *p.UserID == uuid.Nil {
+			return errEmptyUserID
+		}
+	case LockoutScopeIP:
+		if p.IP == "" {
+			return errors.New("ip is empty")
+		}
+	default:
+		return errors.New("unknown lockout scope")
+	}
+	if p.LockedUntil.IsZero() {
+		return errors.New("locked_until is empty")
+	}
+	return nil
+}
+
+// UserVerificationRequested asks the mail sender to deliver a verification link. Token is the
+// one-time secret of the link, so the topic must only be readable by the mail sender, and
+// producers must not keep the event once it is published.
+type UserVerificationRequested struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (p *UserVerificationRequested) EventType() string  { return TypeUserVerificationRequested }
+func (p *UserVerificationRequested) SchemaVersion() int { return 1 }
+func (p *UserVerificationRequested) Validate() error {
+	if p.UserID == uuid.Nil {
+		return errEmptyUserID
+	}
+	if p.Email == "" || p.Token == "" {
+		return errors.New("email and token are required")
+	}
+	if p.ExpiresAt.IsZero() {
+		return errors.New("expires_at is empty")
+	}
+	return nil
+}
+
+// UserPasswordResetRequested asks the mail sender to deliver a password reset link. Like the
+// verification event it carries a one-time secret.
+type UserPasswordResetRequested struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Token     string    `json:"token"`
+	Link      string    `json:"link"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (p *UserPasswordResetRequested) EventType() string  { return TypeUserPasswordResetRequested }
+func (p *UserPasswordResetRequested) SchemaVersion() int { return 1 }
+func (p *UserPasswordResetRequested) Validate() error {
+	if p.UserID == uuid.Nil {
+		return errEmptyUserID
+	}
+	if p.Email == "" || p.Token == "" || p.Link == "" {
+		return errors.New("email, token and link are required")
+	}
+	if p.ExpiresAt.IsZero() {
+		return errors.New("expires_at is empty")
+	}
+	return nil
+}
+
+type UserPasswordChanged struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (p *UserPasswordChanged) EventType() string  { return TypeUserPasswordChanged }
+func (p *UserPasswordChanged) SchemaVersion() int { return 1 }
+func (p *UserPasswordChanged) Validate() error {
+	if p.UserID == uuid.Nil {
+		return errEmptyUserID
+	}
+	return nil
+}
+
+const (
+	UserFieldName  = "name"
+	UserFieldEmail = "email"
+)
+
+// UserUpdated carries the profile after a change. Fields lists what the change touched; a changed
+// email arrives with EmailVerified false until the new address is confirmed.
+type UserUpdated struct {
+	UserID        uuid.UUID `json:"user_id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Fields        []string  `json:"fields"`
+}
+
+func (p *UserUpdated) EventType() string  { return TypeUserUpdated }
+func (p *UserUpdated) SchemaVersion() int { return 1 }
+func (p *UserUpdated) Validate() error {
+	if p.UserID == uuid.Nil {
+		return errEmptyUserID
+	}
+	if p.Name == "" || p.Email == "" {
+		return errors.New("name and email are required")
+	}
+	if len(p.Fields) == 0 {
+		return errors.New("fields are empty")
+	}
+	return nil
+}
+
+// UserIDOf returns the user a payload refers to. Every user lifecycle event carries one.
+func UserIDOf(payload Payload) (uuid.UUID, bool) {
+	switch p := payload.(type) {
+	case *UserRegistered:
+		return p.UserID, true
+	case *UserAuthenticated:
+		return p.UserID, true
+	case *UserLoggedOut:
+		return p.UserID, true
+	case *UserDeleted:
+		return p.UserID, true
+	case *UserVerificationRequested:
+		return p.UserID, true
+	case *UserPasswordResetRequested:
+		return p.UserID, true
+	case *UserPasswordChanged:
+		return p.UserID, true
+	case *UserUpdated:
+		return p.UserID, true
+	case *UserLockedOut:
+		if p.UserID != nil {
+			return *p.UserID, true
+		}
+	}
+	return uuid.Nil, false
+}
@@ -0,0 +1,42 @@
+package events
+
+import "fmt"
+
+type schemaKey struct {
+	eventType string
+	version   int
+}
+
+var registry = map[schemaKey]func() Payload{}
+
+// Register makes a payload schema known to Decode. It panics on duplicates so that
+// two payload types can never claim the same event type and version.
+func Register(factory func() Payload) {
+	sample := factory()
+	key := schemaKey{eventType: sample.EventType(), version: sample.SchemaVersion()}
+	if _, ok := registry[key]; ok {
+		panic(fmt.Sprintf("events: schema %s v%d registered twice", key.eventType, key.version))
+	}
+	registry[key] = factory
+}
+
+// Schemas lists the registered event types with their versions.
+func Schemas() map[string][]int {
+	schemas := make(map[string][]int)
+	for key := range registry {
+		schemas[key.eventType] = append(schemas[key.eventType], key.version)
+	}
+	return schemas
+}
+
+func init() {
+	Register(func() Payload { return &UserRegistered{} })
+	Register(func() Payload { return &UserAuthenticated{} })
+	Register(func() Payload { return &UserLoggedOut{} })
+	Register(func() Payload { return &UserDeleted{} })
+	Register(func() Payload { return &UserLockedOut{} })
+	Register(func() Payload { return &UserVerificationRequested{} })
+	Register(func() Payload { return &UserPasswordResetRequested{} })
+	Register(func() Payload { return &UserPasswordChanged{} })
+	Register(func() Payload { return &UserUpdated{} })
+}
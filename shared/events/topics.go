@@ -0,0 +1,62 @@
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Topics names the Kafka topic of every event. Both services read it from kafka.topics of
+// their config, so a topic is named the same way on the producing and the consuming side.
+type Topics struct {
+	UserAuthenticate           string `mapstructure:"user_authenticate"`
+	UserRegistered             string `mapstructure:"user_registered"`
+	UserLoggedOut              string `mapstructure:"user_logged_out"`
+	UserDelete                 string `mapstructure:"user_delete"`
+	UserLockedOut              string `mapstructure:"user_locked_out"`
+	UserVerificationRequested  string `mapstructure:"user_verification_requested"`
+	UserPasswordResetRequested string `mapstructure:"user_password_reset_requested"`
+	UserPasswordChanged        string `mapstructure:"user_password_changed"`
+	UserUpdated                string `mapstructure:"user_updated"`
+}
+
+// Names returns every topic keyed by its config key.
+func (t Topics) Names() map[string]string {
+	return map[string]string{
+		"user_authenticate":             t.UserAuthenticate,
+		"user_registered":               t.UserRegistered,
+		"user_logged_out":               t.UserLoggedOut,
+		"user_delete":                   t.UserDelete,
+		"user_locked_out":               t.UserLockedOut,
+		"user_verification_requested":   t.UserVerificationRequested,
+		"user_password_reset_requested": t.UserPasswordResetRequested,
+		"user_password_changed":         t.UserPasswordChanged,
+		"user_updated":                  t.UserUpdated,
+	}
+}
+
+// Validate checks that the topics with the given config keys are named, or every topic when no
+// key is given; a consumer passes the keys of the topics it subscribes to.
+func (t Topics) Validate(keys ...string) error {
+	names := t.Names()
+	if len(keys) == 0 {
+		for key := range names {
+			keys = append(keys, key)
+		}
+	}
+	var missing []string
+	for _, key := range keys {
+		name, ok := names[key]
+		if !ok {
+			return fmt.Errorf("unknown Kafka topic kafka.topics.%s", key)
+		}
+		if strings.TrimSpace(name) == "" {
+			missing = append(missing, "kafka.topics."+key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing Kafka topic names: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
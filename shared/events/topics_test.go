@@ -0,0 +1,31 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopics_Validate(t *testing.T) {
+	topics := Topics{
+		UserAuthenticate:           "dev-user-authenticate-topic",
+		UserRegistered:             "dev-user-registered-topic",
+		UserLoggedOut:              "dev-user-logged-out-topic",
+		UserDelete:                 "dev-user-delete-topic",
+		UserLockedOut:              "dev-user-locked-out-topic",
+		UserVerificationRequested:  "dev-user-verification-requested-topic",
+		UserPasswordResetRequested: "dev-user-password-reset-requested-topic",
+		UserPasswordChanged:        "dev-user-password-changed-topic",
+		UserUpdated:                "dev-user-updated-topic",
+	}
+	assert.NoError(t, topics.Validate())
+
+	topics.UserLoggedOut = ""
+	topics.UserDelete = " "
+	assert.EqualError(t, topics.Validate(), "missing Kafka topic names: kafka.topics.user_delete, kafka.topics.user_logged_out")
+
+	consumed := Topics{UserAuthenticate: "user-authenticate-topic", UserDelete: "user-delete-topic"}
+	assert.NoError(t, consumed.Validate("user_authenticate", "user_delete"), "Должны проверяться только переданные темы")
+	assert.EqualError(t, consumed.Validate("user_authenticate", "user_logged_out"), "missing Kafka topic names: kafka.topics.user_logged_out")
+	assert.EqualError(t, consumed.Validate("user_logout"), "unknown Kafka topic kafka.topics.user_logout")
+}
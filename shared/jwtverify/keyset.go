@@ -0,0 +1,93 @@
+package jwtverify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StaticKeySet serves keys from a fixed document, mostly useful in tests.
+type StaticKeySet JWKS
+
+func (s StaticKeySet) Key(ctx context.Context, kid string) (JWK, error) {
+	key, ok := JWKS(s).Find(kid)
+	if !ok {
+		return JWK{}, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+const (
+	DefaultCacheTTL        = 10 * time.Minute
+	DefaultMinRefreshDelay = 30 * time.Second
+)
+
+// RemoteKeySet fetches and caches a JWKS document. An unknown kid triggers a refetch so a
+// freshly rotated key is picked up immediately, but refetches are throttled by minRefreshDelay
+// to keep forged kids from turning into a request flood against the issuer.
+type RemoteKeySet struct {
+	url             string
+	client          *http.Client
+	cacheTTL        time.Duration
+	minRefreshDelay time.Duration
+
+	mu        sync.Mutex
+	keys      JWKS
+	fetchedAt time.Time
+}
+
+func NewRemoteKeySet(url string, client *http.Client) *RemoteKeySet {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &RemoteKeySet{url: url, client: client, cacheTTL: DefaultCacheTTL, minRefreshDelay: DefaultMinRefreshDelay}
+}
+
+func (r *RemoteKeySet) Key(ctx context.Context, kid string) (JWK, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	since := time.Since(r.fetchedAt)
+	if key, ok := r.keys.Find(kid); ok && since < r.cacheTTL {
+		return key, nil
+	}
+	if r.fetchedAt.IsZero() || since >= r.minRefreshDelay {
+		if err := r.refresh(ctx); err != nil {
+			// Serve the stale copy rather than rejecting every request while the issuer is down.
+			if key, ok := r.keys.Find(kid); ok {
+				return key, nil
+			}
+			return JWK{}, err
+		}
+	}
+	key, ok := r.keys.Find(kid)
+	if !ok {
+		return JWK{}, fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+func (r *RemoteKeySet) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+	var keys JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return fmt.Errorf("decode jwks: %w", err)
+	}
+	r.keys = keys
+	r.fetchedAt = time.Now()
+	return nil
+}
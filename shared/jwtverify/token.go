@@ -0,0 +1,144 @@
+package jwtverify
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrMalformedToken       = errors.New("malformed token")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrInvalidKey           = errors.New("invalid key")
+	ErrUnknownKey           = errors.New("unknown signing key")
+	ErrInvalidSignature     = errors.New("invalid token signature")
+	ErrTokenExpired         = errors.New("token expired")
+	ErrInvalidClaims        = errors.New("invalid token claims")
+)
+
+// Claims carried by access tokens issued by auth_service.
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// UserID parses the subject of the token.
+func (c Claims) UserID() (uuid.UUID, error) {
+	return uuid.Parse(c.Subject)
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid"`
+}
+
+// Sign produces a compact JWS for claims. It is used by the issuer; verifiers only need Verifier.
+func Sign(claims Claims, kid string, key crypto.Signer) (string, error) {
+	return SignPayload(claims, kid, key)
+}
+
+// SignPayload signs any JSON-encodable claim set, such as the ID tokens of an OpenID provider.
+func SignPayload(payload interface{}, kid string, key crypto.Signer) (string, error) {
+	var alg string
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		alg = AlgRS256
+	case ed25519.PublicKey:
+		alg = AlgEdDSA
+	default:
+		return "", fmt.Errorf("%w: key type %T", ErrUnsupportedAlgorithm, key.Public())
+	}
+	headerJSON, err := json.Marshal(header{Alg: alg, Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var signature []byte
+	if alg == AlgRS256 {
+		digest := sha256.Sum256([]byte(signingInput))
+		signature, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	} else {
+		signature, err = key.Sign(rand.Reader, []byte(signingInput), crypto.Hash(0))
+	}
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+type parsedToken struct {
+	header       header
+	claims       Claims
+	payload      []byte
+	signingInput string
+	signature    []byte
+}
+
+func parse(token string) (parsedToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return parsedToken{}, ErrMalformedToken
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return parsedToken{}, fmt.Errorf("%w: header: %v", ErrMalformedToken, err)
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return parsedToken{}, fmt.Errorf("%w: claims: %v", ErrMalformedToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return parsedToken{}, fmt.Errorf("%w: signature: %v", ErrMalformedToken, err)
+	}
+	var parsed parsedToken
+	if err := json.Unmarshal(headerJSON, &parsed.header); err != nil {
+		return parsedToken{}, fmt.Errorf("%w: header: %v", ErrMalformedToken, err)
+	}
+	if err := json.Unmarshal(claimsJSON, &parsed.claims); err != nil {
+		return parsedToken{}, fmt.Errorf("%w: claims: %v", ErrMalformedToken, err)
+	}
+	parsed.payload = claimsJSON
+	parsed.signingInput = parts[0] + "." + parts[1]
+	parsed.signature = signature
+	return parsed, nil
+}
+
+func verifySignature(key JWK, signingInput string, signature []byte) error {
+	pub, err := key.PublicKey()
+	if err != nil {
+		return err
+	}
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, []byte(signingInput), signature) {
+			return ErrInvalidSignature
+		}
+	default:
+		return ErrUnsupportedAlgorithm
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Package jwtverify verifies access tokens issued by auth_service without a round trip to it.
+// Services point a RemoteKeySet at auth_service's /.well-known/jwks.json and wrap their
+// routes with Middleware.
+package jwtverify
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const DefaultLeeway = 30 * time.Second
+
+// KeySource resolves the verification key for a key id.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (JWK, error)
+}
+
+type Verifier struct {
+	keys   KeySource
+	issuer string
+	leeway time.Duration
+	now    func() time.Time
+}
+
+// NewVerifier checks tokens against keys. An empty issuer disables the iss check.
+func NewVerifier(keys KeySource, issuer string) *Verifier {
+	return &Verifier{keys: keys, issuer: issuer, leeway: DefaultLeeway, now: time.Now}
+}
+
+// Verify validates signature, algorithm, issuer and expiry and returns the claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parsed, err := v.verifySigned(ctx, token)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	claims := parsed.claims
+	now := v.now()
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.leeway)) {
+		return Claims{}, ErrTokenExpired
+	}
+	if claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(v.leeway)) {
+		return Claims{}, fmt.Errorf("%w: issued in the future", ErrInvalidClaims)
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return Claims{}, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidClaims, claims.Issuer)
+	}
+	if _, err := claims.UserID(); err != nil {
+		return Claims{}, fmt.Errorf("%w: subject is not a user id", ErrInvalidClaims)
+	}
+	return claims, nil
+}
+
+// VerifySignature checks only the algorithm and signature and returns the raw claim set. It is
+// meant for tokens whose claims are not auth_service's own, like the ID tokens of an external
+// OpenID provider; the caller validates the claims.
+func (v *Verifier) VerifySignature(ctx context.Context, token string) ([]byte, error) {
+	parsed, err := v.verifySigned(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return parsed.payload, nil
+}
+
+func (v *Verifier) verifySigned(ctx context.Context, token string) (parsedToken, error) {
+	parsed, err := parse(token)
+	if err != nil {
+		return parsedToken{}, err
+	}
+	if parsed.header.Alg != AlgRS256 && parsed.header.Alg != AlgEdDSA {
+		return parsedToken{}, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, parsed.header.Alg)
+	}
+	key, err := v.keys.Key(ctx, parsed.header.Kid)
+	if err != nil {
+		return parsedToken{}, err
+	}
+	// The algorithm is taken from the published key, never trusted from the token header alone.
+	if key.Alg != parsed.header.Alg {
+		return parsedToken{}, fmt.Errorf("%w: token alg %s does not match key %s", ErrUnsupportedAlgorithm, parsed.header.Alg, key.Kid)
+	}
+	if err := verifySignature(key, parsed.signingInput, parsed.signature); err != nil {
+		return parsedToken{}, err
+	}
+	return parsed, nil
+}
@@ -0,0 +1,176 @@
+package jwtverify
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKeys(t *testing.T) (crypto.Signer, crypto.Signer) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return rsaKey, edKey
+}
+
+func keySet(t *testing.T, keys map[string]crypto.Signer) JWKS {
+	var set JWKS
+	for kid, key := range keys {
+		jwk, err := NewJWK(kid, key.Public())
+		require.NoError(t, err)
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	rsaKey, edKey := newKeys(t)
+	_, foreignKey := newKeys(t)
+	keys := StaticKeySet(keySet(t, map[string]crypto.Signer{"rsa": rsaKey, "ed": edKey}))
+	verifier := NewVerifier(keys, "auth_service")
+
+	now := time.Now()
+	valid := Claims{Issuer: "auth_service", Subject: uuid.NewString(), SessionID: "session", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+	expired := valid
+	expired.ExpiresAt = now.Add(-time.Hour).Unix()
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "someone-else"
+
+	testCases := []struct {
+		name          string
+		claims        Claims
+		kid           string
+		key           crypto.Signer
+		tamper        func(token string) string
+		expectedError error
+	}{
+		{name: "RS256", claims: valid, kid: "rsa", key: rsaKey},
+		{name: "EdDSA", claims: valid, kid: "ed", key: edKey},
+		{name: "Expired", claims: expired, kid: "ed", key: edKey, expectedError: ErrTokenExpired},
+		{name: "Wrong Issuer", claims: wrongIssuer, kid: "ed", key: edKey, expectedError: ErrInvalidClaims},
+		{name: "Unknown Kid", claims: valid, kid: "missing", key: edKey, expectedError: ErrUnknownKey},
+		{name: "Foreign Key", claims: valid, kid: "ed", key: foreignKey, expectedError: ErrInvalidSignature},
+		{name: "Algorithm Mismatch", claims: valid, kid: "rsa", key: edKey, expectedError: ErrUnsupportedAlgorithm},
+		{name: "Tampered Claims", claims: valid, kid: "ed", key: edKey, expectedError: ErrInvalidSignature, tamper: func(token string) string {
+			parts := strings.Split(token, ".")
+			forged, _ := json.Marshal(Claims{Issuer: "auth_service", Subject: uuid.NewString(), ExpiresAt: now.Add(time.Hour).Unix()})
+			return parts[0] + "." + base64.RawURLEncoding.EncodeToString(forged) + "." + parts[2]
+		}},
+		{name: "Malformed", claims: valid, kid: "ed", key: edKey, expectedError: ErrMalformedToken, tamper: func(string) string { return "not-a-token" }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := Sign(tc.claims, tc.kid, tc.key)
+			require.NoError(t, err)
+			if tc.tamper != nil {
+				token = tc.tamper(token)
+			}
+
+			claims, err := verifier.Verify(context.Background(), token)
+
+			if tc.expectedError != nil {
+				assert.True(t, errors.Is(err, tc.expectedError), "Тип ошибки должен совпадать: %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.claims, claims, "Claims должны совпадать")
+		})
+	}
+}
+
+func TestVerifier_VerifySignature(t *testing.T) {
+	_, edKey := newKeys(t)
+	_, foreignKey := newKeys(t)
+	verifier := NewVerifier(StaticKeySet(keySet(t, map[string]crypto.Signer{"ed": edKey})), "auth_service")
+	payload := map[string]interface{}{"iss": "https://accounts.example", "sub": "external-subject", "nonce": "n-1"}
+
+	token, err := SignPayload(payload, "ed", edKey)
+	require.NoError(t, err)
+	raw, err := verifier.VerifySignature(context.Background(), token)
+	require.NoError(t, err, "Чужие claims не должны мешать проверке подписи")
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	assert.Equal(t, payload, decoded, "Claims должны возвращаться без изменений")
+
+	_, err = verifier.Verify(context.Background(), token)
+	assert.ErrorIs(t, err, ErrTokenExpired, "Verify по-прежнему проверяет claims")
+
+	forged, err := SignPayload(payload, "ed", foreignKey)
+	require.NoError(t, err)
+	_, err = verifier.VerifySignature(context.Background(), forged)
+	assert.ErrorIs(t, err, ErrInvalidSignature, "Подпись чужим ключом не должна приниматься")
+}
+
+func TestRemoteKeySet_PicksUpRotatedKey(t *testing.T) {
+	oldKey, newKey := newKeys(t)
+	var published atomic.Value
+	published.Store(keySet(t, map[string]crypto.Signer{"old": oldKey}))
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(published.Load())
+	}))
+	defer server.Close()
+
+	remote := NewRemoteKeySet(server.URL, server.Client())
+	remote.minRefreshDelay = 0
+	verifier := NewVerifier(remote, "")
+	claims := Claims{Subject: uuid.NewString(), ExpiresAt: time.Now().Add(time.Minute).Unix()}
+
+	token, err := Sign(claims, "old", oldKey)
+	require.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), token)
+	require.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load(), "Известный ключ должен браться из кэша")
+
+	published.Store(keySet(t, map[string]crypto.Signer{"old": oldKey, "new": newKey}))
+	token, err = Sign(claims, "new", newKey)
+	require.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), token)
+	require.NoError(t, err, "Новый ключ должен подтягиваться после ротации")
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestMiddleware(t *testing.T) {
+	_, edKey := newKeys(t)
+	verifier := NewVerifier(StaticKeySet(keySet(t, map[string]crypto.Signer{"ed": edKey})), "")
+	userID := uuid.New()
+	handler := Middleware(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		require.True(t, ok)
+		id, err := claims.UserID()
+		require.NoError(t, err)
+		assert.Equal(t, userID, id)
+	}))
+	token, err := Sign(Claims{Subject: userID.String(), ExpiresAt: time.Now().Add(time.Minute).Unix()}, "ed", edKey)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "Без токена запрос должен отклоняться")
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,396 @@
+// Package memkafka is an in-process stand-in for a Kafka cluster. Topics are partitioned logs
+// kept in memory, consumer groups commit offsets and share partitions, and the method sets
+// match kafka.Writer and kafka.Reader of github.com/segmentio/kafka-go, so producers and
+// consumers of both services run against it in tests and local development without a broker.
+//
+// Messages committed by every group subscribed to a topic are dropped, so a process that both
+// produces and consumes keeps only its backlog in memory. Topics without a group keep everything;
+// nothing else bounds them, so a producer must not use the broker unless something consumes.
+package memkafka
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const DefaultPartitions = 3
+
+var (
+	ErrTopicExists = errors.New("memkafka: topic already exists")
+	ErrNoTopic     = errors.New("memkafka: message has no topic")
+	ErrNoGroup     = errors.New("memkafka: committing offsets requires a consumer group")
+)
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+type group struct {
+	generation int
+	members    []*Reader
+	committed  map[partitionKey]int64
+	// topics are those any member ever subscribed to; their messages are kept until the group commits them.
+	topics map[string]bool
+}
+
+// partitionLog holds the retained messages of a partition; those before base were dropped
+// after every group committed them, so offsets keep growing across trims.
+type partitionLog struct {
+	base     int64
+	messages []kafka.Message
+}
+
+func (p *partitionLog) end() int64 {
+	return p.base + int64(len(p.messages))
+}
+
+type Broker struct {
+	partitions int
+	balancer   kafka.Balancer
+	now        func() time.Time
+
+	mu sync.Mutex
+	// changed is closed and replaced whenever a message is written or a group changes, which
+	// wakes up the readers waiting for either.
+	changed chan struct{}
+	topics  map[string][]*partitionLog
+	groups  map[string]*group
+}
+
+// NewBroker creates an empty broker; topics are created on first use with the given number of
+// partitions, or DefaultPartitions when it is not positive.
+func NewBroker(partitions int) *Broker {
+	if partitions <= 0 {
+		partitions = DefaultPartitions
+	}
+	return &Broker{
+		partitions: partitions,
+		// The same balancer as the real writers, so a key lands on the same partition number.
+		balancer: &kafka.Hash{},
+		now:      time.Now,
+		changed:  make(chan struct{}),
+		topics:   make(map[string][]*partitionLog),
+		groups:   make(map[string]*group),
+	}
+}
+
+// CreateTopic creates a topic with its own partition count.
+func (b *Broker) CreateTopic(topic string, partitions int) error {
+	if partitions <= 0 {
+		return fmt.Errorf("memkafka: topic %s needs at least one partition", topic)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.topics[topic]; ok {
+		return fmt.Errorf("%w: %s", ErrTopicExists, topic)
+	}
+	b.topics[topic] = newPartitionLogs(partitions)
+	return nil
+}
+
+func newPartitionLogs(partitions int) []*partitionLog {
+	logs := make([]*partitionLog, partitions)
+	for i := range logs {
+		logs[i] = &partitionLog{}
+	}
+	return logs
+}
+
+func (b *Broker) topicLocked(topic string) []*partitionLog {
+	logs, ok := b.topics[topic]
+	if !ok {
+		logs = newPartitionLogs(b.partitions)
+		b.topics[topic] = logs
+	}
+	return logs
+}
+
+func (b *Broker) notifyLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// WriteMessages appends the messages to their topics. The partition is chosen by key, and the
+// partition, offset and, when unset, the time of each stored message are filled in.
+func (b *Broker) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if msg.Topic == "" {
+			return ErrNoTopic
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, msg := range msgs {
+		logs := b.topicLocked(msg.Topic)
+		ids := make([]int, len(logs))
+		for i := range ids {
+			ids[i] = i
+		}
+		stored := cloneMessage(msg)
+		stored.Partition = b.balancer.Balance(msg, ids...)
+		partition := logs[stored.Partition]
+		stored.Offset = partition.end()
+		if stored.Time.IsZero() {
+			stored.Time = b.now()
+		}
+		partition.messages = append(partition.messages, stored)
+	}
+	b.notifyLocked()
+	return nil
+}
+
+// Messages returns the retained messages of the topic ordered by partition and offset.
+func (b *Broker) Messages(topic string) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var msgs []kafka.Message
+	for _, log := range b.topics[topic] {
+		for _, msg := range log.messages {
+			msgs = append(msgs, cloneMessage(msg))
+		}
+	}
+	return msgs
+}
+
+// Lag is the number of messages of the topic the group has not committed yet.
+func (b *Broker) Lag(groupID, topic string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var committed map[partitionKey]int64
+	if g, ok := b.groups[groupID]; ok {
+		committed = g.committed
+	}
+	var lag int64
+	for partition, log := range b.topics[topic] {
+		lag += log.end() - max(committed[partitionKey{topic: topic, partition: partition}], log.base)
+	}
+	return lag
+}
+
+// trimLocked drops the messages of the partition that every group subscribed to its topic has
+// committed.
+func (b *Broker) trimLocked(key partitionKey) {
+	low := int64(-1)
+	for _, g := range b.groups {
+		if !g.topics[key.topic] {
+			continue
+		}
+		if committed := g.committed[key]; low < 0 || committed < low {
+			low = committed
+		}
+	}
+	log := b.topics[key.topic][key.partition]
+	low = min(low, log.end())
+	if low <= log.base {
+		return
+	}
+	dropped := int(low - log.base)
+	// Clear the dropped messages so their payloads can be collected before the array is reallocated.
+	clear(log.messages[:dropped])
+	log.messages = log.messages[dropped:]
+	log.base = low
+}
+
+// Writer returns a producer handle; closing it does not affect the broker.
+func (b *Broker) Writer() *Writer {
+	return &Writer{broker: b}
+}
+
+// Reader subscribes to the topics. Readers of one group split the partitions between them and
+// start from the group's committed offsets; without a group a reader sees every partition from
+// the first offset and cannot commit.
+func (b *Broker) Reader(groupID string, topics ...string) *Reader {
+	r := &Reader{broker: b, groupID: groupID, topics: slices.Clone(topics), generation: -1}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, topic := range topics {
+		b.topicLocked(topic)
+	}
+	if groupID != "" {
+		g, ok := b.groups[groupID]
+		if !ok {
+			g = &group{committed: make(map[partitionKey]int64), topics: make(map[string]bool)}
+			b.groups[groupID] = g
+		}
+		for _, topic := range topics {
+			g.topics[topic] = true
+		}
+		g.members = append(g.members, r)
+		g.generation++
+		b.notifyLocked()
+	}
+	return r
+}
+
+type Writer struct {
+	broker *Broker
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (w *Writer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return io.ErrClosedPipe
+	}
+	return w.broker.WriteMessages(ctx, msgs...)
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+// Reader state is guarded by the broker's mutex.
+type Reader struct {
+	broker  *Broker
+	groupID string
+	topics  []string
+
+	closed     bool
+	generation int
+	assigned   []partitionKey
+	positions  map[partitionKey]int64
+	next       int
+}
+
+// FetchMessage returns the next message of the assigned partitions, waiting for one to be
+// written. Fetched messages are delivered again after a rebalance unless they were committed.
+func (r *Reader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	b := r.broker
+	for {
+		b.mu.Lock()
+		if r.closed {
+			b.mu.Unlock()
+			return kafka.Message{}, io.EOF
+		}
+		r.syncLocked()
+		msg, ok := r.nextLocked()
+		changed := b.changed
+		b.mu.Unlock()
+		if ok {
+			return msg, nil
+		}
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// CommitMessages stores for the group the offset after each message.
+func (r *Reader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if r.groupID == "" {
+		return ErrNoGroup
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b := r.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.closed {
+		return io.EOF
+	}
+	committed := b.groups[r.groupID].committed
+	for _, msg := range msgs {
+		key := partitionKey{topic: msg.Topic, partition: msg.Partition}
+		committed[key] = max(committed[key], msg.Offset+1)
+		b.trimLocked(key)
+	}
+	return nil
+}
+
+// Close leaves the group, handing the reader's partitions to the other members.
+func (r *Reader) Close() error {
+	b := r.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if g, ok := b.groups[r.groupID]; ok {
+		g.members = slices.DeleteFunc(g.members, func(member *Reader) bool { return member == r })
+		g.generation++
+	}
+	b.notifyLocked()
+	return nil
+}
+
+// syncLocked picks up the partition assignment after the group changed.
+func (r *Reader) syncLocked() {
+	b := r.broker
+	g := b.groups[r.groupID]
+	if r.positions != nil && (g == nil || r.generation == g.generation) {
+		return
+	}
+	r.assigned = r.assigned[:0]
+	r.positions = make(map[partitionKey]int64)
+	r.next = 0
+	for _, topic := range r.topics {
+		members := []*Reader{r}
+		if g != nil {
+			members = slices.DeleteFunc(slices.Clone(g.members), func(member *Reader) bool {
+				return !slices.Contains(member.topics, topic)
+			})
+		}
+		index := slices.Index(members, r)
+		for partition := range b.topics[topic] {
+			if partition%len(members) != index {
+				continue
+			}
+			key := partitionKey{topic: topic, partition: partition}
+			r.assigned = append(r.assigned, key)
+			if g != nil {
+				r.positions[key] = g.committed[key]
+			}
+		}
+	}
+	if g != nil {
+		r.generation = g.generation
+	}
+}
+
+// nextLocked takes the next unread message, going round the assigned partitions so that one
+// busy partition does not hold back the others.
+func (r *Reader) nextLocked() (kafka.Message, bool) {
+	for i := range r.assigned {
+		index := (r.next + i) % len(r.assigned)
+		key := r.assigned[index]
+		log := r.broker.topics[key.topic][key.partition]
+		// A reader behind the retained messages, such as a new group, starts from the oldest one.
+		position := max(r.positions[key], log.base)
+		if position < log.end() {
+			r.positions[key] = position + 1
+			r.next = index + 1
+			return cloneMessage(log.messages[position-log.base]), true
+		}
+	}
+	return kafka.Message{}, false
+}
+
+func cloneMessage(msg kafka.Message) kafka.Message {
+	msg.Key = bytes.Clone(msg.Key)
+	msg.Value = bytes.Clone(msg.Value)
+	msg.Headers = slices.Clone(msg.Headers)
+	return msg
+}
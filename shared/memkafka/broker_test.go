@@ -0,0 +1,182 @@
+package memkafka
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fetch(t *testing.T, r *Reader) kafka.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := r.FetchMessage(ctx)
+	require.NoError(t, err)
+	return msg
+}
+
+func TestBroker_PartitionsByKey(t *testing.T) {
+	b := NewBroker(4)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.WriteMessages(ctx,
+			kafka.Message{Topic: "events", Key: []byte("user-a"), Value: []byte(fmt.Sprint(i))},
+			kafka.Message{Topic: "events", Key: []byte("user-b"), Value: []byte(fmt.Sprint(i))},
+		))
+	}
+	assert.ErrorIs(t, b.WriteMessages(ctx, kafka.Message{Value: []byte("x")}), ErrNoTopic)
+
+	msgs := b.Messages("events")
+	require.Len(t, msgs, 6)
+	partitions := make(map[string]int)
+	offsets := make(map[int]int64)
+	for _, msg := range msgs {
+		if partition, ok := partitions[string(msg.Key)]; ok {
+			assert.Equal(t, partition, msg.Partition, "Сообщения одного ключа должны попадать в одну партицию")
+		}
+		partitions[string(msg.Key)] = msg.Partition
+		assert.Equal(t, offsets[msg.Partition], msg.Offset, "Offset должен расти внутри партиции")
+		offsets[msg.Partition]++
+		assert.False(t, msg.Time.IsZero(), "Время сообщения должно заполняться")
+	}
+	assert.Equal(t, (&kafka.Hash{}).Balance(kafka.Message{Key: []byte("user-a")}, 0, 1, 2, 3), partitions["user-a"],
+		"Партиция должна совпадать с балансировщиком kafka-go")
+}
+
+func TestReader_GroupCommitsAndResumes(t *testing.T) {
+	b := NewBroker(1)
+	ctx := context.Background()
+	require.NoError(t, b.WriteMessages(ctx,
+		kafka.Message{Topic: "events", Value: []byte("1")},
+		kafka.Message{Topic: "events", Value: []byte("2")},
+		kafka.Message{Topic: "events", Value: []byte("3")},
+	))
+
+	r := b.Reader("group", "events")
+	// Subscribed before the commit, so the messages are kept for it.
+	other := b.Reader("other-group", "events")
+	defer other.Close()
+	first := fetch(t, r)
+	assert.Equal(t, []byte("1"), first.Value)
+	require.NoError(t, r.CommitMessages(ctx, first))
+	assert.Equal(t, []byte("2"), fetch(t, r).Value)
+	assert.Equal(t, int64(2), b.Lag("group", "events"))
+	require.NoError(t, r.Close())
+	_, err := r.FetchMessage(ctx)
+	assert.ErrorIs(t, err, io.EOF, "Закрытый reader должен возвращать EOF")
+
+	r = b.Reader("group", "events")
+	defer r.Close()
+	assert.Equal(t, []byte("2"), fetch(t, r).Value, "Незакоммиченное сообщение должно доставляться повторно")
+
+	assert.Equal(t, []byte("1"), fetch(t, other).Value, "Другая группа должна читать с начала")
+
+	plain := b.Reader("", "events")
+	defer plain.Close()
+	assert.ErrorIs(t, plain.CommitMessages(ctx, first), ErrNoGroup)
+}
+
+func TestBroker_TrimsConsumedMessages(t *testing.T) {
+	b := NewBroker(1)
+	ctx := context.Background()
+	fast := b.Reader("fast", "events")
+	defer fast.Close()
+	slow := b.Reader("slow", "events")
+	defer slow.Close()
+	require.NoError(t, b.WriteMessages(ctx,
+		kafka.Message{Topic: "events", Value: []byte("1")},
+		kafka.Message{Topic: "events", Value: []byte("2")},
+		kafka.Message{Topic: "events", Value: []byte("3")},
+	))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, fast.CommitMessages(ctx, fetch(t, fast)))
+	}
+	assert.Len(t, b.Messages("events"), 3, "Сообщения хранятся, пока их не прочитали все группы")
+
+	first := fetch(t, slow)
+	require.NoError(t, slow.CommitMessages(ctx, first, fetch(t, slow)))
+	retained := b.Messages("events")
+	require.Len(t, retained, 1, "Прочитанные всеми группами сообщения должны удаляться")
+	assert.Equal(t, int64(2), retained[0].Offset, "Смещения не должны меняться после удаления")
+
+	require.NoError(t, b.WriteMessages(ctx, kafka.Message{Topic: "events", Value: []byte("4")}))
+	assert.Equal(t, int64(3), b.Messages("events")[1].Offset)
+	assert.Equal(t, int64(2), b.Lag("slow", "events"))
+
+	late := b.Reader("late", "events")
+	defer late.Close()
+	assert.Equal(t, []byte("3"), fetch(t, late).Value, "Новая группа должна начинать с самого старого сохранённого сообщения")
+	assert.Equal(t, int64(2), b.Lag("late", "events"))
+	assert.Len(t, b.Messages("events"), 2, "Новая группа должна удерживать непрочитанные ею сообщения")
+}
+
+func TestReader_GroupSplitsPartitions(t *testing.T) {
+	b := NewBroker(2)
+	ctx := context.Background()
+	first := b.Reader("group", "events")
+	second := b.Reader("group", "events")
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, b.WriteMessages(ctx, kafka.Message{Topic: "events", Key: []byte(fmt.Sprint(i))}))
+	}
+	total := len(b.Messages("events"))
+	seen := make(map[int]string)
+	read := 0
+	for _, r := range []*Reader{first, second} {
+		name := fmt.Sprintf("%p", r)
+		for {
+			fetchCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			msg, err := r.FetchMessage(fetchCtx)
+			cancel()
+			if err != nil {
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+				break
+			}
+			read++
+			if owner, ok := seen[msg.Partition]; ok {
+				assert.Equal(t, owner, name, "Партиция должна принадлежать одному участнику группы")
+			}
+			seen[msg.Partition] = name
+		}
+	}
+	assert.Equal(t, total, read, "Группа должна прочитать все сообщения ровно один раз")
+	assert.Len(t, seen, 2)
+
+	// After the first reader leaves, its uncommitted partition goes to the second.
+	require.NoError(t, first.Close())
+	before := read
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		_, err := second.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			break
+		}
+		read++
+	}
+	assert.Equal(t, total, read-before, "После ребалансировки незакоммиченные сообщения читаются заново")
+	require.NoError(t, second.Close())
+}
+
+func TestReader_WaitsForMessages(t *testing.T) {
+	b := NewBroker(1)
+	r := b.Reader("group", "events")
+	defer r.Close()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, b.Writer().WriteMessages(context.Background(), kafka.Message{Topic: "events", Value: []byte("late")}))
+	}()
+	assert.Equal(t, []byte("late"), fetch(t, r).Value, "Reader должен дожидаться новых сообщений")
+
+	w := b.Writer()
+	require.NoError(t, w.Close())
+	assert.ErrorIs(t, w.WriteMessages(context.Background(), kafka.Message{Topic: "events"}), io.ErrClosedPipe)
+}
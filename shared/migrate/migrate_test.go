@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFS = fstest.MapFS{
+	"migrations/0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INT);")},
+	"migrations/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	"migrations/0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+	"migrations/0002_add_email.down.sql":    {Data: []byte("ALTER TABLE users DROP COLUMN email;")},
+	"migrations/README.md":                  {Data: []byte("ignored")},
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := load(testFS, "migrations")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "create_users", migrations[0].Name)
+	assert.Equal(t, "ALTER TABLE users DROP COLUMN email;", migrations[1].Down)
+
+	_, err = load(fstest.MapFS{"m/0001_a.up.sql": {Data: []byte("SELECT 1")}}, "m")
+	assert.Error(t, err, "Миграция без down-файла должна отклоняться")
+
+	_, err = load(fstest.MapFS{"m/notes.txt": {Data: []byte("")}}, "m")
+	assert.ErrorIs(t, err, ErrNoMigrations)
+}
+
+func TestMigrator_Up(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(lockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("ALTER TABLE users ADD COLUMN email TEXT;").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(2, "add_email").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	m, err := New(db, testFS, "migrations")
+	require.NoError(t, err)
+	done, err := m.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.Equal(t, 2, done[0].Version)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_DownSkipsWorkDoneByAnotherReplica(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(lockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	m, err := New(db, testFS, "migrations")
+	require.NoError(t, err)
+	done, err := m.Down(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunCommand_Status(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	appliedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+
+	m, err := New(db, testFS, "migrations")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, RunCommand(context.Background(), m, []string{"status"}, &out))
+	assert.Contains(t, out.String(), "create_users  2025-03-01T12:00:00Z")
+	assert.Contains(t, out.String(), "add_email     pending")
+
+	assert.Error(t, RunCommand(context.Background(), m, []string{"sideways"}, &out))
+}
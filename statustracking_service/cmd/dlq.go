@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"statustracking_service/internal/kafka"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const dlqUsage = "usage: dlq list [-limit n] [-wait d] | replay [-limit n] [-wait d]"
+
+// runDLQCommand inspects the dead-letter topic and replays it onto the topics the messages
+// were originally published to. The DLQ is read with its own consumer group: list leaves the
+// offsets alone, replay commits every message it has published again, so list only shows the
+// messages that were not replayed yet. Both stop after limit messages or when no message
+// arrives for wait.
+func runDLQCommand(ctx context.Context, consumer kafka.KafkaConsumer, producer kafka.KafkaProducer, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf(dlqUsage)
+	}
+	flags := flag.NewFlagSet("dlq "+args[0], flag.ContinueOnError)
+	flags.SetOutput(out)
+	limit := flags.Int("limit", 0, "stop after this many messages, 0 reads the whole topic")
+	wait := flags.Duration("wait", 10*time.Second, "stop when no message arrives for this long")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf(dlqUsage)
+	}
+	switch args[0] {
+	case "list":
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PARTITION\tOFFSET\tORIGINAL TOPIC\tKEY\tATTEMPTS\tFAILED AT\tERROR")
+		count, err := readDeadLetters(ctx, consumer, *limit, *wait, func(msg kafkago.Message) error {
+			failedAt, _ := kafka.Header(msg, kafka.HeaderFailedAt)
+			cause, _ := kafka.Header(msg, kafka.HeaderError)
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%d\t%s\t%s\n", msg.Partition, msg.Offset, kafka.OriginalTopic(msg), msg.Key,
+				kafka.Attempts(msg), failedAt, strings.ReplaceAll(cause, "\n", "; "))
+			return nil
+		})
+		if flushErr := w.Flush(); err == nil {
+			err = flushErr
+		}
+		fmt.Fprintf(out, "%d message(s) in the dead-letter topic\n", count)
+		return err
+	case "replay":
+		count, err := readDeadLetters(ctx, consumer, *limit, *wait, func(msg kafkago.Message) error {
+			if err := producer.SendMessage(ctx, kafka.ReplayMessage(msg)); err != nil {
+				return err
+			}
+			return consumer.CommitMessages(ctx, msg)
+		})
+		fmt.Fprintf(out, "replayed %d message(s)\n", count)
+		return err
+	}
+	return fmt.Errorf("unknown dlq command %q; %s", args[0], dlqUsage)
+}
+
+// readDeadLetters passes messages to handle until limit is reached or none arrives for wait.
+func readDeadLetters(ctx context.Context, consumer kafka.KafkaConsumer, limit int, wait time.Duration, handle func(kafkago.Message) error) (int, error) {
+	count := 0
+	for limit <= 0 || count < limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, wait)
+		msg, err := consumer.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				return count, nil
+			}
+			return count, err
+		}
+		if err := handle(msg); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
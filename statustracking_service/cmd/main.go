@@ -1,15 +1,34 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"shared/jwtverify"
+	"shared/migrate"
 	"statustracking_service/configs"
+	"statustracking_service/internal/api"
+	"statustracking_service/internal/kafka"
+	"statustracking_service/internal/repository"
+	"statustracking_service/internal/server"
+	"statustracking_service/internal/service"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 func main() {
+	migrateOnStart := flag.Bool("migrate", false, "apply pending database migrations before starting the service")
+	flag.Parse()
 
 	_, filename, _, ok := runtime.Caller(0)
 	if !ok {
@@ -38,60 +57,169 @@ func main() {
 		log.Fatalf("Unable to decode into struct, %v", err)
 	}
 
-	/*db, dbInterface, err := repository.ConnectToDb(config)
+	db, dbInterface, err := repository.ConnectToDb(config)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 		return
 	}
 	defer dbInterface.Close(db)
-	rdb, redisInterface, err := repository.ConnectToRedis(config)
+	migrator, err := migrate.New(db, repository.Migrations, repository.MigrationsDir)
 	if err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		log.Fatalf("Failed to load migrations: %v", err)
 		return
 	}
-	defer redisInterface.Close(rdb)
-
-	repositories := repository.NewRepository(db, rdb)
+	if flag.Arg(0) == "migrate" {
+		if err := migrate.RunCommand(context.Background(), migrator, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatalf("Migration command failed: %v", err)
+		}
+		return
+	}
+	if *migrateOnStart {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			log.Fatalf("Failed to apply migrations: %v", err)
+			return
+		}
+	}
 
-	service := service.NewService(repositories)
-	handlers := api.NewHandler(service)
+	err = config.Kafka.Topics.Validate(configs.ConsumedTopics...)
+	if err != nil {
+		log.Fatalf("Invalid Kafka configuration: %v", err)
+	}
+	err = config.Kafka.Retry.Validate()
+	if err != nil {
+		log.Fatalf("Invalid Kafka retry configuration: %v", err)
+	}
+	brokersString := config.Kafka.BootstrapServers
+	brokers := strings.Split(brokersString, ",")
+	kafkaProducer, err := kafka.NewKafkaProducer(brokers)
+	if err != nil {
+		log.Fatalf("Failed to create Kafka producer: %v", err)
+		return
+	}
+	defer kafkaProducer.Close()
+	if flag.Arg(0) == "dlq" {
+		dlqConsumer, err := kafka.NewKafkaConsumer(brokers, config.Kafka.GroupID+"-dlq", []string{config.Kafka.Retry.DeadLetterTopic})
+		if err != nil {
+			log.Fatalf("Failed to create Kafka consumer: %v", err)
+		}
+		defer dlqConsumer.Close()
+		if err := runDLQCommand(context.Background(), dlqConsumer, kafkaProducer, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatalf("DLQ command failed: %v", err)
+		}
+		return
+	}
+	topics := []string{
+		config.Kafka.Topics.UserRegistered,
+		config.Kafka.Topics.UserAuthenticate,
+		config.Kafka.Topics.UserLoggedOut,
+		config.Kafka.Topics.UserDelete,
+	}
+	kafkaConsumer, err := kafka.NewKafkaConsumer(brokers, config.Kafka.GroupID, topics)
+	if err != nil {
+		log.Fatalf("Failed to create Kafka consumer: %v", err)
+		return
+	}
+	defer kafkaConsumer.Close()
+	repositories := repository.NewRepository(db)
+	cleaner := service.NewProcessedEventsCleaner(repositories.DBStatusRepos, service.ProcessedEventsCleanerConfig{
+		Retention:       config.ProcessedEvents.Retention,
+		CleanupInterval: config.ProcessedEvents.CleanupInterval,
+		BatchSize:       config.ProcessedEvents.BatchSize,
+	})
+
+	service := service.NewService(repositories, config.Kafka.Topics)
+	router := kafka.NewRetryRouter(kafkaProducer, config.Kafka.Retry)
+	listeners := []*kafka.EventListener{kafka.NewEventListener(kafkaConsumer, service, router)}
+	for i, retryTopic := range config.Kafka.Retry.Topics {
+		retryConsumer, err := kafka.NewKafkaConsumer(brokers, config.Kafka.GroupID+"-"+retryTopic.Name, []string{retryTopic.Name})
+		if err != nil {
+			log.Fatalf("Failed to create Kafka consumer: %v", err)
+			return
+		}
+		defer retryConsumer.Close()
+		listeners = append(listeners, kafka.NewRetryListener(retryConsumer, service, router, i+1))
+	}
+	var verifier *jwtverify.Verifier
+	if config.Auth.JWKSURL != "" {
+		verifier = jwtverify.NewVerifier(jwtverify.NewRemoteKeySet(config.Auth.JWKSURL, nil), config.Auth.Issuer)
+	} else {
+		log.Printf("auth.jwks_url is not set, the status API is served without access token checks")
+	}
+	handlers := api.NewHandler(service, verifier)
 	srv := &server.Server{}
 
 	port := viper.GetString("server.port")
 	if port == "" {
 		port = "8080"
 	}
-	fmt.Printf("Starting auth-server on port: %s\n", port)
+	fmt.Printf("Starting statustracking-server on port: %s\n", port)
 	serverError := make(chan error, 1)
 	go func() {
 
-		if err := srv.Run(port, handlers.InitRoutes()); err != nil {
+		if err := srv.Run(port, handlers.InitRoutes()); err != nil && err != http.ErrServerClosed {
 			serverError <- fmt.Errorf("server run failed: %w", err)
 			return
 		}
 		close(serverError)
 	}()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cleaner.Run(ctx)
+	fmt.Printf("Starting statustracking consumer for topics: %v\n", topics)
+	listenerError := make(chan error, len(listeners))
+	var listenersDone sync.WaitGroup
+	for _, listener := range listeners {
+		listenersDone.Add(1)
+		go func() {
+			defer listenersDone.Done()
+			if err := listener.Run(ctx); err != nil {
+				listenerError <- fmt.Errorf("listener run failed: %w", err)
+				return
+			}
+			listenerError <- nil
+		}()
+	}
+	listenersStopped := make(chan struct{})
+	go func() {
+		listenersDone.Wait()
+		close(listenersStopped)
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 
 	select {
 	case sig := <-quit:
 		log.Printf("Service shutting down with signal: %v", sig)
+	case err := <-listenerError:
+		if err != nil {
+			log.Fatalf("Service startup failed: %v", err)
+		}
+		log.Printf("Listener stopped, service shutting down")
 	case err := <-serverError:
-		log.Fatalf("Service startup failed: %v", err)
+		if err != nil {
+			log.Fatalf("Service startup failed: %v", err)
+		}
+		log.Printf("Server stopped, service shutting down")
 	}
 
 	shutdownTimeout := 5 * time.Second
-	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
-	defer cancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
 
 	log.Println("Service is shutting down...")
 
-	if err := srv.Shutdown(ctx); err != nil {
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Printf("Server shutdown error: %v", err)
 	}
+	cancel()
+	select {
+	case <-listenersStopped:
+	case <-shutdownCtx.Done():
+		log.Printf("Listener did not stop within %v", shutdownTimeout)
+	}
 
 	log.Println("Service has shutted down successfully")
-	*/
+
 }
@@ -1,8 +1,18 @@
 package configs
 
+import (
+	"fmt"
+	"shared/events"
+	"strings"
+	"time"
+)
+
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
+	Server          ServerConfig          `mapstructure:"server"`
+	Database        DatabaseConfig        `mapstructure:"database"`
+	Kafka           KafkaConfig           `mapstructure:"kafka"`
+	Auth            AuthConfig            `mapstructure:"auth"`
+	ProcessedEvents ProcessedEventsConfig `mapstructure:"processed_events"`
 }
 
 type ServerConfig struct {
@@ -17,3 +27,57 @@ type DatabaseConfig struct {
 	Name     string `mapstructure:"name"`
 	SSLMode  string `mapstructure:"sslmode"`
 }
+
+// ProcessedEventsConfig: ids of processed events are deleted once they are older than Retention,
+// checked every CleanupInterval, BatchSize rows at a time.
+type ProcessedEventsConfig struct {
+	Retention       time.Duration `mapstructure:"retention"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+	BatchSize       int           `mapstructure:"batch_size"`
+}
+
+// AuthConfig enables access token checks on the HTTP API. An empty JWKSURL leaves the API open.
+type AuthConfig struct {
+	JWKSURL string `mapstructure:"jwks_url"`
+	Issuer  string `mapstructure:"issuer"`
+}
+
+// ConsumedTopics are the keys of kafka.topics the service subscribes to; the topics only
+// auth_service cares about may be left out.
+var ConsumedTopics = []string{"user_authenticate", "user_registered", "user_logged_out", "user_delete"}
+
+type KafkaConfig struct {
+	BootstrapServers string        `mapstructure:"bootstrap_servers"`
+	Topics           events.Topics `mapstructure:"topics"`
+	GroupID          string        `mapstructure:"group_id"`
+	Retry            RetryConfig   `mapstructure:"retry"`
+}
+
+// RetryConfig keeps failed messages from blocking a partition. A message that cannot be
+// processed moves to the first retry topic, is processed again once its Delay has passed and
+// on another failure moves on to the next one. After the last retry topic, and right away for
+// messages that can never succeed, it lands on DeadLetterTopic.
+type RetryConfig struct {
+	Topics          []RetryTopic `mapstructure:"topics"`
+	DeadLetterTopic string       `mapstructure:"dead_letter_topic"`
+}
+
+type RetryTopic struct {
+	Name  string        `mapstructure:"name"`
+	Delay time.Duration `mapstructure:"delay"`
+}
+
+func (c RetryConfig) Validate() error {
+	if strings.TrimSpace(c.DeadLetterTopic) == "" {
+		return fmt.Errorf("kafka.retry.dead_letter_topic is required")
+	}
+	for i, topic := range c.Topics {
+		if strings.TrimSpace(topic.Name) == "" {
+			return fmt.Errorf("kafka.retry.topics[%d].name is required", i)
+		}
+		if topic.Delay <= 0 {
+			return fmt.Errorf("kafka.retry.topics[%d].delay must be positive", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"shared/jwtverify"
+	"statustracking_service/internal/model"
+	"statustracking_service/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	jsonResponseType = "application/json"
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+type Handler struct {
+	services *service.Service
+	verifier *jwtverify.Verifier
+}
+type HTTPResponse struct {
+	Success bool              `json:"success"`
+	Errors  map[string]string `json:"errors"`
+	Data    interface{}       `json:"data"`
+}
+type StatusPage struct {
+	Statuses []model.UserStatus `json:"statuses"`
+	Total    int                `json:"total"`
+	Limit    int                `json:"limit"`
+	Offset   int                `json:"offset"`
+}
+
+// NewHandler builds the API; a nil verifier serves the routes without access token checks.
+func NewHandler(services *service.Service, verifier *jwtverify.Verifier) *Handler {
+	return &Handler{services: services, verifier: verifier}
+}
+func (h *Handler) InitRoutes() *mux.Router {
+	m := mux.NewRouter()
+	if h.verifier != nil {
+		m.Use(jwtverify.Middleware(h.verifier))
+	}
+	m.HandleFunc("/status/online", h.OnlineStatuses).Methods("GET")
+	m.HandleFunc("/status/batch", h.BatchStatuses).Methods("POST")
+	m.HandleFunc("/status/{userID}", h.Status).Methods("GET")
+	return m
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"statustracking_service/internal/erro"
+	"statustracking_service/internal/model"
+	"statustracking_service/internal/service"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type BatchRequest struct {
+	UserIDs []uuid.UUID `json:"user_ids"`
+}
+
+func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	if r.Method != http.MethodGet {
+		log.Printf("Invalid request method(expected Get but it was sent %v)", r.Method)
+		maparesponse["Method"] = erro.ErrorNotGet.Error()
+		badResponse(w, maparesponse, http.StatusMethodNotAllowed)
+
+		return
+	}
+	userID, err := uuid.Parse(mux.Vars(r)["userID"])
+	if err != nil {
+		log.Printf("UUID-parse error: %v", err)
+		maparesponse["UserId"] = erro.ErrorInvalidUserID.Error()
+		badResponse(w, maparesponse, http.StatusBadRequest)
+
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.GetStatus(ctx, userID)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		badResponse(w, stringMap, statusCodeFromErrors(response))
+
+		return
+	}
+	goodResponse(w, model.UserStatus{
+		UserID:     response.UserId,
+		Status:     response.Status,
+		LastUpdate: response.LastUpdate,
+	})
+}
+func (h *Handler) BatchStatuses(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	if r.Method != http.MethodPost {
+		log.Printf("Invalid request method(expected Post but it was sent %v)", r.Method)
+		maparesponse["Method"] = erro.ErrorNotPost.Error()
+		badResponse(w, maparesponse, http.StatusMethodNotAllowed)
+
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("ReadAll Error: %v", err)
+		maparesponse["ReadAll"] = erro.ErrorReadAll.Error()
+		badResponse(w, maparesponse, http.StatusInternalServerError)
+
+		return
+	}
+	var request BatchRequest
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		log.Printf("Unmarshal Error: %v", err)
+		maparesponse["Unmarshal"] = erro.ErrorUnmarshal.Error()
+		badResponse(w, maparesponse, http.StatusBadRequest)
+
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.GetStatuses(ctx, request.UserIDs)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		badResponse(w, stringMap, statusCodeFromErrors(response))
+
+		return
+	}
+	goodResponse(w, StatusPage{
+		Statuses: response.Statuses,
+		Total:    response.Total,
+		Limit:    len(request.UserIDs),
+	})
+}
+func (h *Handler) OnlineStatuses(w http.ResponseWriter, r *http.Request) {
+	maparesponse := make(map[string]string)
+	if r.Method != http.MethodGet {
+		log.Printf("Invalid request method(expected Get but it was sent %v)", r.Method)
+		maparesponse["Method"] = erro.ErrorNotGet.Error()
+		badResponse(w, maparesponse, http.StatusMethodNotAllowed)
+
+		return
+	}
+	limit, offset, err := parsePaging(r)
+	if err != nil {
+		log.Printf("Paging error: %v", err)
+		maparesponse["Paging"] = erro.ErrorInvalidPaging.Error()
+		badResponse(w, maparesponse, http.StatusBadRequest)
+
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	response := h.services.GetOnlineStatuses(ctx, limit, offset)
+	if !response.Success {
+		stringMap := convertErrorToString(response)
+		badResponse(w, stringMap, statusCodeFromErrors(response))
+
+		return
+	}
+	goodResponse(w, StatusPage{
+		Statuses: response.Statuses,
+		Total:    response.Total,
+		Limit:    limit,
+		Offset:   offset,
+	})
+}
+func parsePaging(r *http.Request) (int, int, error) {
+	limit := defaultPageLimit
+	offset := 0
+	query := r.URL.Query()
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d, got %q", maxPageLimit, value)
+		}
+		limit = parsed
+	}
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative number, got %q", value)
+		}
+		offset = parsed
+	}
+	return limit, offset, nil
+}
+func statusCodeFromErrors(response *service.ServiceResponse) int {
+	for _, err := range response.Errors {
+		switch {
+		case errors.Is(err, erro.ErrorStatusNotFound):
+			return http.StatusNotFound
+		case errors.Is(err, erro.ErrorEmptyBatch), errors.Is(err, erro.ErrorBatchTooLarge):
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusInternalServerError
+}
+func goodResponse(w http.ResponseWriter, data interface{}) {
+	sucresponse := HTTPResponse{
+		Success: true,
+		Data:    data,
+	}
+	jsonResponse, err := json.Marshal(sucresponse)
+	if err != nil {
+		log.Printf("Marshal Error: %v", err)
+		badResponse(w, map[string]string{"Marshal": erro.ErrorMarshal.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", jsonResponseType)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, string(jsonResponse))
+}
+func badResponse(w http.ResponseWriter, vc map[string]string, statusCode int) {
+	response := HTTPResponse{
+		Success: false,
+		Errors:  vc,
+	}
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Marshal Error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", jsonResponseType)
+	w.WriteHeader(statusCode)
+
+	_, err = w.Write(jsonResponse)
+	if err != nil {
+		log.Printf("Write Error: %v", err)
+		return
+	}
+}
+func convertErrorToString(mapa *service.ServiceResponse) map[string]string {
+	stringMap := make(map[string]string)
+	for key, err := range mapa.Errors {
+		if err != nil {
+			stringMap[key] = err.Error()
+		} else {
+			stringMap[key] = ""
+		}
+	}
+	return stringMap
+}
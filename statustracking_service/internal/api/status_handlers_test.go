@@ -0,0 +1,187 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"shared/events"
+	"statustracking_service/internal/api"
+	"statustracking_service/internal/erro"
+	"statustracking_service/internal/model"
+	"statustracking_service/internal/repository"
+	"statustracking_service/internal/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStatusRepo serves the statuses it holds and remembers the paging it was asked for.
+type fakeStatusRepo struct {
+	statuses map[uuid.UUID]model.UserStatus
+	limit    int
+	offset   int
+}
+
+func (f *fakeStatusRepo) SetStatus(ctx context.Context, event model.StatusEvent) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{Status: event.Status, Outcome: repository.OutcomeApplied}}
+}
+func (f *fakeStatusRepo) GetStatus(ctx context.Context, userID uuid.UUID) *repository.RepositoryResponse {
+	status, ok := f.statuses[userID]
+	if !ok {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorStatusNotFound}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{Status: status}}
+}
+func (f *fakeStatusRepo) GetStatuses(ctx context.Context, userIDs []uuid.UUID) *repository.RepositoryResponse {
+	var statuses []model.UserStatus
+	for _, userID := range userIDs {
+		if status, ok := f.statuses[userID]; ok {
+			statuses = append(statuses, status)
+		}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryListResponseData{Statuses: statuses, Total: len(statuses)}}
+}
+func (f *fakeStatusRepo) GetOnlineStatuses(ctx context.Context, limit, offset int) *repository.RepositoryResponse {
+	f.limit, f.offset = limit, offset
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryListResponseData{Total: len(f.statuses)}}
+}
+func (f *fakeStatusRepo) DeleteProcessedEvents(ctx context.Context, processedBefore time.Time, limit int) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBCleanupResponseData{}}
+}
+
+func newTestRouter(repo *fakeStatusRepo) http.Handler {
+	services := service.NewService(&repository.Repository{DBStatusRepos: repo}, events.Topics{})
+	return api.NewHandler(services, nil).InitRoutes()
+}
+
+func serve(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) api.HTTPResponse {
+	t.Helper()
+	var response api.HTTPResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	return response
+}
+
+func TestStatus(t *testing.T) {
+	userID := uuid.New()
+	repo := &fakeStatusRepo{statuses: map[uuid.UUID]model.UserStatus{
+		userID: {UserID: userID, Status: model.StatusOnline, LastUpdate: time.Now()},
+	}}
+	router := newTestRouter(repo)
+
+	testCases := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Known User", target: "/status/" + userID.String(), expectedStatus: http.StatusOK},
+		{name: "Unknown User", target: "/status/" + uuid.NewString(), expectedStatus: http.StatusNotFound, expectedError: "GetStatusError"},
+		{name: "Invalid UserID", target: "/status/not-a-uuid", expectedStatus: http.StatusBadRequest, expectedError: "UserId"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(router, http.MethodGet, tc.target, "")
+			assert.Equal(t, tc.expectedStatus, rec.Code, "Код ответа должен совпадать")
+			response := decode(t, rec)
+			if tc.expectedError != "" {
+				assert.False(t, response.Success)
+				assert.Contains(t, response.Errors, tc.expectedError, "Ошибка должна возвращаться под своим ключом")
+				return
+			}
+			assert.True(t, response.Success)
+		})
+	}
+}
+
+func TestOnlineStatuses_Paging(t *testing.T) {
+	testCases := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedLimit  int
+		expectedOffset int
+	}{
+		{name: "Defaults", query: "", expectedStatus: http.StatusOK, expectedLimit: 50},
+		{name: "Explicit Page", query: "?limit=10&offset=20", expectedStatus: http.StatusOK, expectedLimit: 10, expectedOffset: 20},
+		{name: "Largest Limit", query: "?limit=500", expectedStatus: http.StatusOK, expectedLimit: 500},
+		{name: "Limit Too Large", query: "?limit=501", expectedStatus: http.StatusBadRequest},
+		{name: "Zero Limit", query: "?limit=0", expectedStatus: http.StatusBadRequest},
+		{name: "Limit Not A Number", query: "?limit=ten", expectedStatus: http.StatusBadRequest},
+		{name: "Negative Offset", query: "?offset=-1", expectedStatus: http.StatusBadRequest},
+		{name: "Offset Not A Number", query: "?offset=first", expectedStatus: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &fakeStatusRepo{}
+			rec := serve(newTestRouter(repo), http.MethodGet, "/status/online"+tc.query, "")
+			assert.Equal(t, tc.expectedStatus, rec.Code, "Код ответа должен совпадать")
+			response := decode(t, rec)
+			if tc.expectedStatus != http.StatusOK {
+				assert.Equal(t, erro.ErrorInvalidPaging.Error(), response.Errors["Paging"], "Тип ошибки должен совпадать")
+				assert.Zero(t, repo.limit, "Некорректный запрос не должен доходить до базы")
+				return
+			}
+			assert.Equal(t, tc.expectedLimit, repo.limit, "Limit должен передаваться в репозиторий")
+			assert.Equal(t, tc.expectedOffset, repo.offset, "Offset должен передаваться в репозиторий")
+		})
+	}
+}
+
+func TestBatchStatuses(t *testing.T) {
+	known := uuid.New()
+	repo := &fakeStatusRepo{statuses: map[uuid.UUID]model.UserStatus{
+		known: {UserID: known, Status: model.StatusOffline, LastUpdate: time.Now()},
+	}}
+	router := newTestRouter(repo)
+	batch := func(size int) string {
+		ids := make([]string, size)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("%q", uuid.NewString())
+		}
+		if size > 0 {
+			ids[0] = fmt.Sprintf("%q", known.String())
+		}
+		return `{"user_ids":[` + strings.Join(ids, ",") + `]}`
+	}
+
+	testCases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Largest Batch", body: batch(service.MaxBatchSize), expectedStatus: http.StatusOK},
+		{name: "Batch Too Large", body: batch(service.MaxBatchSize + 1), expectedStatus: http.StatusBadRequest, expectedError: erro.ErrorBatchTooLarge.Error()},
+		{name: "Empty Batch", body: batch(0), expectedStatus: http.StatusBadRequest, expectedError: erro.ErrorEmptyBatch.Error()},
+		{name: "Invalid JSON", body: `{"user_ids":["not-a-uuid"]}`, expectedStatus: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(router, http.MethodPost, "/status/batch", tc.body)
+			assert.Equal(t, tc.expectedStatus, rec.Code, "Код ответа должен совпадать")
+			response := decode(t, rec)
+			if tc.expectedStatus != http.StatusOK {
+				assert.False(t, response.Success)
+				if tc.expectedError != "" {
+					assert.Equal(t, tc.expectedError, response.Errors["UserIds"], "Тип ошибки должен совпадать")
+				}
+				return
+			}
+			page := response.Data.(map[string]interface{})
+			assert.Equal(t, float64(1), page["total"], "Неизвестные пользователи не должны попадать в ответ")
+			assert.Equal(t, float64(service.MaxBatchSize), page["limit"])
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package erro
+
+import "errors"
+
+var (
+	ErrorInternalServer    = errors.New("Internal Server Error")
+	ErrorUnmarshal         = errors.New("Unmarshal error")
+	ErrorInvalidEvent      = errors.New("Invalid event payload")
+	ErrorUnknownTopic      = errors.New("Unknown event topic")
+	ErrorSetStatus         = errors.New("Error set user status")
+	ErrorGetStatus         = errors.New("Error get user status")
+	ErrorStatusNotFound    = errors.New("User status not found")
+	ErrorUnexpectedData    = errors.New("Unexpected data type")
+	ErrorContextTimeout    = errors.New("The timeout context has expired")
+	ErrorCommitKafkaOffset = errors.New("Error commit Kafka offset")
+	ErrorNotGet            = errors.New("Method is not GET")
+	ErrorNotPost           = errors.New("Method is not POST")
+	ErrorReadAll           = errors.New("ReadAll error")
+	ErrorMarshal           = errors.New("Marshal error")
+	ErrorInvalidUserID     = errors.New("Invalid user id")
+	ErrorInvalidPaging     = errors.New("Invalid paging parameters")
+	ErrorEmptyBatch        = errors.New("The list of user ids is empty")
+	ErrorBatchTooLarge     = errors.New("Too many user ids in one request")
+
+	ErrorDeleteProcessedEvents = errors.New("Error delete processed events")
+)
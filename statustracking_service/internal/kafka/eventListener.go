@@ -0,0 +1,129 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"statustracking_service/internal/erro"
+	"statustracking_service/internal/service"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// routeRetryDelay is how long to wait before publishing a failed message again when the
+// retry or dead-letter topic could not be written.
+const routeRetryDelay = 2 * time.Second
+
+type EventListener struct {
+	consumer KafkaConsumer
+	services *service.Service
+	router   *RetryRouter
+	stage    int
+}
+
+// NewEventListener consumes the main topics.
+func NewEventListener(consumer KafkaConsumer, services *service.Service, router *RetryRouter) *EventListener {
+	return &EventListener{consumer: consumer, services: services, router: router}
+}
+
+// NewRetryListener consumes the stage-th retry topic, counted from 1.
+func NewRetryListener(consumer KafkaConsumer, services *service.Service, router *RetryRouter, stage int) *EventListener {
+	return &EventListener{consumer: consumer, services: services, router: router, stage: stage}
+}
+
+// Run reads events until ctx is cancelled. A message is committed once the status was stored
+// or the message was handed to a retry or the dead-letter topic, so a failing message never
+// stalls its partition.
+func (el *EventListener) Run(ctx context.Context) error {
+	for {
+		msg, err := el.consumer.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if !el.waitUntilDue(ctx, msg) {
+			return nil
+		}
+		response := el.services.UpdateStatus(ctx, OriginalTopic(msg), msg.Value)
+		if !response.Success {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !el.route(ctx, msg, response.Errors) {
+				return nil
+			}
+		}
+		err = el.consumer.CommitMessages(ctx, msg)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("Commit Error: %v", err)
+			return errors.Join(erro.ErrorCommitKafkaOffset, err)
+		}
+	}
+}
+
+// waitUntilDue holds a message read from a retry topic until its delay has passed. Messages of
+// one retry topic share the delay, so the ones behind it are not due earlier.
+func (el *EventListener) waitUntilDue(ctx context.Context, msg kafka.Message) bool {
+	wait := time.Until(RetryAt(msg))
+	if el.stage == 0 || wait <= 0 {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(wait):
+		return true
+	}
+}
+
+// route hands a failed message on, retrying until the producer accepts it or ctx is cancelled.
+func (el *EventListener) route(ctx context.Context, msg kafka.Message, errs map[string]error) bool {
+	cause := joinErrors(errs)
+	permanent := isPermanent(errs)
+	for {
+		topic, err := el.router.Route(ctx, msg, el.stage, cause, permanent)
+		if err == nil {
+			log.Printf("Message %s/%d/%d failed (attempt %d) and was moved to %s: %v", msg.Topic, msg.Partition, msg.Offset, Attempts(msg)+1, topic, cause)
+			return true
+		}
+		log.Printf("Failed to route message %s/%d/%d, retrying in %v: %v", msg.Topic, msg.Partition, msg.Offset, routeRetryDelay, err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(routeRetryDelay):
+		}
+	}
+}
+
+// isPermanent reports failures that no retry can fix, such messages go straight to the dead-letter topic.
+func isPermanent(errs map[string]error) bool {
+	for _, err := range errs {
+		if errors.Is(err, erro.ErrorInvalidEvent) || errors.Is(err, erro.ErrorUnknownTopic) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinErrors(errs map[string]error) error {
+	keys := make([]string, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	joined := make([]error, 0, len(keys))
+	for _, key := range keys {
+		joined = append(joined, errs[key])
+	}
+	if err := errors.Join(joined...); err != nil {
+		return err
+	}
+	return erro.ErrorInternalServer
+}
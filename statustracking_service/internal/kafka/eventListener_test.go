@@ -0,0 +1,303 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"shared/events"
+	"shared/memkafka"
+	"statustracking_service/configs"
+	"statustracking_service/internal/erro"
+	"statustracking_service/internal/model"
+	"statustracking_service/internal/repository"
+	"statustracking_service/internal/service"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConsumer struct {
+	messages  []kafka.Message
+	committed []kafka.Message
+}
+
+func (f *fakeConsumer) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(f.messages) == 0 {
+		return kafka.Message{}, io.EOF
+	}
+	msg := f.messages[0]
+	f.messages = f.messages[1:]
+	return msg, nil
+}
+func (f *fakeConsumer) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.committed = append(f.committed, msgs...)
+	return nil
+}
+func (f *fakeConsumer) Close() error { return nil }
+
+type fakeProducer struct {
+	sent []kafka.Message
+}
+
+func (f *fakeProducer) SendMessage(ctx context.Context, msg kafka.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+func (f *fakeProducer) Close() error { return nil }
+
+// fakeStatusRepo fails to store the status of failing users.
+type fakeStatusRepo struct {
+	failing  map[uuid.UUID]bool
+	statuses []model.UserStatus
+}
+
+func (f *fakeStatusRepo) SetStatus(ctx context.Context, event model.StatusEvent) *repository.RepositoryResponse {
+	status := event.Status
+	if f.failing[status.UserID] {
+		return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorSetStatus}
+	}
+	f.statuses = append(f.statuses, status)
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{Status: status, Outcome: repository.OutcomeApplied}}
+}
+func (f *fakeStatusRepo) GetStatus(ctx context.Context, userID uuid.UUID) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorStatusNotFound}
+}
+func (f *fakeStatusRepo) GetStatuses(ctx context.Context, userIDs []uuid.UUID) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryListResponseData{}}
+}
+func (f *fakeStatusRepo) GetOnlineStatuses(ctx context.Context, limit, offset int) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryListResponseData{}}
+}
+func (f *fakeStatusRepo) DeleteProcessedEvents(ctx context.Context, processedBefore time.Time, limit int) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBCleanupResponseData{}}
+}
+
+var (
+	testTopics = events.Topics{
+		UserAuthenticate: "test-user-authenticate-topic",
+		UserRegistered:   "test-user-registered-topic",
+		UserLoggedOut:    "test-user-logged-out-topic",
+		UserDelete:       "test-user-delete-topic",
+	}
+	testRetry = configs.RetryConfig{
+		Topics:          []configs.RetryTopic{{Name: "retry-1", Delay: time.Minute}, {Name: "retry-2", Delay: time.Hour}},
+		DeadLetterTopic: "dlq",
+	}
+	testNow = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func newTestRouter(producer KafkaProducer) *RetryRouter {
+	router := NewRetryRouter(producer, testRetry)
+	router.now = func() time.Time { return testNow }
+	return router
+}
+
+func authenticated(t *testing.T, userID uuid.UUID) []byte {
+	data, _, err := events.Marshal(&events.UserAuthenticated{UserID: userID}, "auth_service", testNow)
+	require.NoError(t, err)
+	return data
+}
+
+func TestEventListener_RoutesFailures(t *testing.T) {
+	healthy, failing := uuid.New(), uuid.New()
+	repo := &fakeStatusRepo{failing: map[uuid.UUID]bool{failing: true}}
+	services := service.NewService(&repository.Repository{DBStatusRepos: repo}, testTopics)
+	traceHeader := kafka.Header{Key: "trace-id", Value: []byte("abc")}
+	consumer := &fakeConsumer{messages: []kafka.Message{
+		{Topic: testTopics.UserAuthenticate, Partition: 1, Offset: 10, Key: []byte(healthy.String()), Value: authenticated(t, healthy)},
+		{Topic: testTopics.UserAuthenticate, Partition: 1, Offset: 11, Key: []byte("broken"), Value: []byte("{not json"), Headers: []kafka.Header{traceHeader}},
+		{Topic: testTopics.UserAuthenticate, Partition: 2, Offset: 5, Key: []byte(failing.String()), Value: authenticated(t, failing)},
+	}}
+	producer := &fakeProducer{}
+
+	err := NewEventListener(consumer, services, newTestRouter(producer)).Run(context.Background())
+
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Len(t, consumer.committed, 3, "Все сообщения должны быть закоммичены, партиция не должна блокироваться")
+	require.Len(t, repo.statuses, 1)
+	assert.Equal(t, healthy, repo.statuses[0].UserID)
+	require.Len(t, producer.sent, 2)
+
+	dead := producer.sent[0]
+	assert.Equal(t, "dlq", dead.Topic, "Битое сообщение должно сразу уходить в DLQ")
+	assert.Equal(t, []byte("{not json"), dead.Value)
+	assert.Contains(t, dead.Headers, traceHeader, "Исходные заголовки должны сохраняться")
+	assertHeader(t, dead, HeaderOriginalTopic, testTopics.UserAuthenticate)
+	assertHeader(t, dead, HeaderOriginalPartition, "1")
+	assertHeader(t, dead, HeaderOriginalOffset, "11")
+	assertHeader(t, dead, HeaderAttempts, "1")
+	assertHeader(t, dead, HeaderError, erro.ErrorInvalidEvent.Error())
+	assertHeader(t, dead, HeaderFailedAt, testNow.Format(time.RFC3339Nano))
+	_, ok := Header(dead, HeaderRetryAt)
+	assert.False(t, ok, "У сообщения в DLQ не должно быть времени повтора")
+
+	retry := producer.sent[1]
+	assert.Equal(t, "retry-1", retry.Topic, "Ошибка БД должна уходить на повтор")
+	assert.Equal(t, []byte(failing.String()), retry.Key, "Ключ должен сохраняться")
+	assertHeader(t, retry, HeaderError, erro.ErrorSetStatus.Error())
+	assert.Equal(t, testNow.Add(time.Minute), RetryAt(retry))
+}
+
+func TestEventListener_RetryStages(t *testing.T) {
+	userID := uuid.New()
+	repo := &fakeStatusRepo{failing: map[uuid.UUID]bool{userID: true}}
+	services := service.NewService(&repository.Repository{DBStatusRepos: repo}, testTopics)
+	producer := &fakeProducer{}
+	router := newTestRouter(producer)
+
+	first, err := router.Route(context.Background(), kafka.Message{Topic: testTopics.UserAuthenticate, Partition: 3, Offset: 7, Key: []byte("k"), Value: authenticated(t, userID)}, 0, erro.ErrorSetStatus, false)
+	require.NoError(t, err)
+	assert.Equal(t, "retry-1", first)
+
+	// The retry listener processes the message from retry-1 with the topic it was first published to.
+	consumer := &fakeConsumer{messages: []kafka.Message{withTopic(producer.sent[0], "retry-1")}}
+	assert.ErrorIs(t, NewRetryListener(consumer, services, router, 1).Run(context.Background()), io.EOF)
+	require.Len(t, producer.sent, 2)
+	second := producer.sent[1]
+	assert.Equal(t, "retry-2", second.Topic, "Следующая ошибка должна уходить на следующий повтор")
+	assertHeader(t, second, HeaderAttempts, "2")
+	assertHeader(t, second, HeaderOriginalTopic, testTopics.UserAuthenticate)
+	assertHeader(t, second, HeaderOriginalOffset, "7")
+	assert.Equal(t, testNow.Add(time.Hour), RetryAt(second))
+
+	consumer = &fakeConsumer{messages: []kafka.Message{withTopic(second, "retry-2")}}
+	assert.ErrorIs(t, NewRetryListener(consumer, services, router, 2).Run(context.Background()), io.EOF)
+	require.Len(t, producer.sent, 3)
+	dead := producer.sent[2]
+	assert.Equal(t, "dlq", dead.Topic, "После последнего повтора сообщение должно уходить в DLQ")
+	assertHeader(t, dead, HeaderAttempts, "3")
+	assert.Len(t, consumer.committed, 1)
+
+	replay := ReplayMessage(dead)
+	assert.Equal(t, testTopics.UserAuthenticate, replay.Topic, "Повтор должен публиковаться в исходный топик")
+	assert.Equal(t, []byte("k"), replay.Key)
+	assert.Empty(t, replay.Headers, "Служебные заголовки не должны попадать в исходный топик")
+
+	delete(repo.failing, userID)
+	consumer = &fakeConsumer{messages: []kafka.Message{withTopic(producer.sent[0], "retry-1")}}
+	assert.ErrorIs(t, NewRetryListener(consumer, services, router, 1).Run(context.Background()), io.EOF)
+	assert.Len(t, repo.statuses, 1, "Успешный повтор должен сохранять статус")
+	assert.Len(t, producer.sent, 3)
+}
+
+func withTopic(msg kafka.Message, topic string) kafka.Message {
+	msg.Topic = topic
+	return msg
+}
+
+func assertHeader(t *testing.T, msg kafka.Message, key, expected string) {
+	t.Helper()
+	value, ok := Header(msg, key)
+	assert.True(t, ok, "Заголовок %s должен быть", key)
+	assert.Equal(t, expected, value, "Заголовок %s должен совпадать", key)
+}
+
+func TestEventListener_MemoryBroker(t *testing.T) {
+	healthy, failing := uuid.New(), uuid.New()
+	repo := &fakeStatusRepo{failing: map[uuid.UUID]bool{failing: true}}
+	services := service.NewService(&repository.Repository{DBStatusRepos: repo}, testTopics)
+	broker := memkafka.NewBroker(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	loggedOut, _, err := events.Marshal(&events.UserLoggedOut{UserID: healthy}, "auth_service", testNow.Add(time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, broker.WriteMessages(ctx,
+		kafka.Message{Topic: testTopics.UserAuthenticate, Key: []byte(healthy.String()), Value: authenticated(t, healthy)},
+		kafka.Message{Topic: testTopics.UserLoggedOut, Key: []byte(healthy.String()), Value: loggedOut},
+		kafka.Message{Topic: testTopics.UserAuthenticate, Key: []byte(failing.String()), Value: authenticated(t, failing)},
+	))
+
+	consumer, err := NewMemoryConsumer(broker, "statustracking", []string{testTopics.UserAuthenticate, testTopics.UserLoggedOut})
+	require.NoError(t, err)
+	defer consumer.Close()
+	listener := NewEventListener(consumer, services, newTestRouter(NewMemoryProducer(broker)))
+	stopped := make(chan error, 1)
+	go func() { stopped <- listener.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		return broker.Lag("statustracking", testTopics.UserAuthenticate) == 0 && broker.Lag("statustracking", testTopics.UserLoggedOut) == 0
+	}, time.Second, time.Millisecond, "Все сообщения должны быть закоммичены")
+	cancel()
+	assert.NoError(t, <-stopped, "Остановка по контексту не должна быть ошибкой")
+
+	require.Len(t, repo.statuses, 2)
+	retries := broker.Messages("retry-1")
+	require.Len(t, retries, 1, "Сообщение с ошибкой должно уйти на повтор")
+	assert.Equal(t, []byte(failing.String()), retries[0].Key)
+	assertHeader(t, retries[0], HeaderOriginalTopic, testTopics.UserAuthenticate)
+}
+
+// storedStatusRepo keeps the newest status of every user, as the last_update guard of the
+// Postgres repository does.
+type storedStatusRepo struct {
+	fakeStatusRepo
+	stored map[uuid.UUID]model.UserStatus
+}
+
+func (f *storedStatusRepo) SetStatus(ctx context.Context, event model.StatusEvent) *repository.RepositoryResponse {
+	status := event.Status
+	if current, ok := f.stored[status.UserID]; ok && !status.LastUpdate.After(current.LastUpdate) {
+		return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{Status: current, Outcome: repository.OutcomeStale}}
+	}
+	f.stored[status.UserID] = status
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{Status: status, Outcome: repository.OutcomeApplied}}
+}
+
+// The stream is what auth_service publishes for a login, logout, login and account deletion;
+// auth_service checks its outbox relay against the same file.
+func TestEventListener_AuthServiceStream(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "shared", "events", "testdata", "auth_service.stream.json"))
+	require.NoError(t, err)
+	var stream []struct {
+		Topic string          `json:"topic"`
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}
+	require.NoError(t, json.Unmarshal(data, &stream))
+
+	broker := memkafka.NewBroker(4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	names := testTopics.Names()
+	for _, msg := range stream {
+		require.NoError(t, broker.WriteMessages(ctx, kafka.Message{Topic: names[msg.Topic], Key: []byte(msg.Key), Value: msg.Value}))
+	}
+
+	repo := &storedStatusRepo{stored: make(map[uuid.UUID]model.UserStatus)}
+	services := service.NewService(&repository.Repository{DBStatusRepos: repo}, testTopics)
+	var topics []string
+	for _, key := range configs.ConsumedTopics {
+		topics = append(topics, names[key])
+	}
+	consumer, err := NewMemoryConsumer(broker, "statustracking", topics)
+	require.NoError(t, err)
+	defer consumer.Close()
+	listener := NewEventListener(consumer, services, newTestRouter(NewMemoryProducer(broker)))
+	stopped := make(chan error, 1)
+	go func() { stopped <- listener.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		for _, topic := range topics {
+			if broker.Lag("statustracking", topic) != 0 {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond, "Все сообщения должны быть закоммичены")
+	cancel()
+	assert.NoError(t, <-stopped)
+
+	userID := uuid.MustParse(stream[0].Key)
+	require.Contains(t, repo.stored, userID)
+	assert.Equal(t, model.StatusDeleted, repo.stored[userID].Status, "Последним должно сохраняться удаление аккаунта")
+	assert.Equal(t, time.Date(2025, 3, 1, 12, 15, 0, 0, time.UTC), repo.stored[userID].LastUpdate.UTC())
+	assert.Empty(t, broker.Messages("retry-1"), "События auth_service должны обрабатываться без повторов")
+	assert.Empty(t, broker.Messages("dlq"))
+}
@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"shared/memkafka"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type KafkaConsumer interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// messageReader is the part of kafka.Reader the consumer uses.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type kafkaConsumer struct {
+	reader messageReader
+}
+
+func NewKafkaConsumer(brokers []string, groupID string, topics []string) (KafkaConsumer, error) {
+	if groupID == "" {
+		return nil, fmt.Errorf("consumer group id is empty")
+	}
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		GroupID:     groupID, // Offsets are committed per consumer group
+		GroupTopics: topics,
+		MinBytes:    1,
+		MaxBytes:    10e6,
+		StartOffset: kafka.FirstOffset,
+	})
+
+	return &kafkaConsumer{reader: r}, nil
+}
+
+// NewMemoryConsumer reads from an in-process broker, for tests and running without Kafka.
+func NewMemoryConsumer(broker *memkafka.Broker, groupID string, topics []string) (KafkaConsumer, error) {
+	if groupID == "" {
+		return nil, fmt.Errorf("consumer group id is empty")
+	}
+	return &kafkaConsumer{reader: broker.Reader(groupID, topics...)}, nil
+}
+
+func (kc *kafkaConsumer) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	msg, err := kc.reader.FetchMessage(ctx)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("failed to fetch message: %w", err)
+	}
+	return msg, nil
+}
+
+func (kc *kafkaConsumer) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	err := kc.reader.CommitMessages(ctx, msgs...)
+	if err != nil {
+		return fmt.Errorf("failed to commit messages: %w", err)
+	}
+	return nil
+}
+
+func (kc *kafkaConsumer) Close() error {
+	err := kc.reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close reader: %w", err)
+	}
+	return nil
+}
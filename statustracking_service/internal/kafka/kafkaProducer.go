@@ -0,0 +1,58 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"shared/memkafka"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type KafkaProducer interface {
+	SendMessage(ctx context.Context, msg kafka.Message) error
+	Close() error
+}
+
+// messageWriter is the part of kafka.Writer the producer uses.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type kafkaProducer struct {
+	writer messageWriter
+}
+
+func NewKafkaProducer(brokers []string) (KafkaProducer, error) {
+	w := &kafka.Writer{
+		Addr: kafka.TCP(brokers...),
+		// A message keeps its key, so retries of one user stay on one partition.
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: time.Millisecond,
+		WriteTimeout: 10 * time.Second,
+		RequiredAcks: kafka.RequireAll,
+	}
+	return &kafkaProducer{writer: w}, nil
+}
+
+// NewMemoryProducer publishes to an in-process broker, for tests and running without Kafka.
+func NewMemoryProducer(broker *memkafka.Broker) KafkaProducer {
+	return &kafkaProducer{writer: broker.Writer()}
+}
+
+func (kp *kafkaProducer) SendMessage(ctx context.Context, msg kafka.Message) error {
+	err := kp.writer.WriteMessages(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("failed to write messages: %w", err)
+	}
+	return nil
+}
+
+func (kp *kafkaProducer) Close() error {
+	err := kp.writer.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+	return nil
+}
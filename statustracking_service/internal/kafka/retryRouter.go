@@ -0,0 +1,135 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"statustracking_service/configs"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers added to a message that failed processing. The original headers are kept.
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderAttempts          = "x-attempts"
+	HeaderError             = "x-error"
+	HeaderFailedAt          = "x-failed-at"
+	HeaderRetryAt           = "x-retry-at"
+)
+
+var failureHeaders = []string{HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset, HeaderAttempts, HeaderError, HeaderFailedAt, HeaderRetryAt}
+
+// RetryRouter moves messages that failed processing to the next retry topic or to the
+// dead-letter topic.
+type RetryRouter struct {
+	producer KafkaProducer
+	retry    configs.RetryConfig
+	now      func() time.Time
+}
+
+func NewRetryRouter(producer KafkaProducer, retry configs.RetryConfig) *RetryRouter {
+	return &RetryRouter{producer: producer, retry: retry, now: time.Now}
+}
+
+// Route publishes msg, which failed with cause after being read at stage (0 for the main
+// topics, n for the n-th retry topic). Permanent failures skip the remaining retry topics.
+func (rr *RetryRouter) Route(ctx context.Context, msg kafka.Message, stage int, cause error, permanent bool) (string, error) {
+	now := rr.now().UTC()
+	headers := msg.Headers
+	if stage == 0 {
+		headers = setHeader(headers, HeaderOriginalTopic, msg.Topic)
+		headers = setHeader(headers, HeaderOriginalPartition, strconv.Itoa(msg.Partition))
+		headers = setHeader(headers, HeaderOriginalOffset, strconv.FormatInt(msg.Offset, 10))
+	}
+	headers = setHeader(headers, HeaderAttempts, strconv.Itoa(Attempts(msg)+1))
+	headers = setHeader(headers, HeaderError, cause.Error())
+	headers = setHeader(headers, HeaderFailedAt, now.Format(time.RFC3339Nano))
+
+	topic := rr.retry.DeadLetterTopic
+	if !permanent && stage < len(rr.retry.Topics) {
+		next := rr.retry.Topics[stage]
+		topic = next.Name
+		headers = setHeader(headers, HeaderRetryAt, now.Add(next.Delay).Format(time.RFC3339Nano))
+	} else {
+		headers = removeHeader(headers, HeaderRetryAt)
+	}
+	err := rr.producer.SendMessage(ctx, kafka.Message{Topic: topic, Key: msg.Key, Value: msg.Value, Headers: headers})
+	if err != nil {
+		return "", fmt.Errorf("failed to route message to %s: %w", topic, err)
+	}
+	return topic, nil
+}
+
+// OriginalTopic is the topic msg was first published to.
+func OriginalTopic(msg kafka.Message) string {
+	if topic, ok := Header(msg, HeaderOriginalTopic); ok {
+		return topic
+	}
+	return msg.Topic
+}
+
+// Attempts is the number of failed processing attempts recorded on msg.
+func Attempts(msg kafka.Message) int {
+	value, _ := Header(msg, HeaderAttempts)
+	attempts, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return attempts
+}
+
+// RetryAt is when a message on a retry topic becomes due, zero when it is due right away.
+func RetryAt(msg kafka.Message) time.Time {
+	value, _ := Header(msg, HeaderRetryAt)
+	retryAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return retryAt
+}
+
+// ReplayMessage restores a dead-lettered message as it was originally published: original
+// topic, key, value and headers without the failure metadata.
+func ReplayMessage(msg kafka.Message) kafka.Message {
+	headers := make([]kafka.Header, 0, len(msg.Headers))
+	for _, header := range msg.Headers {
+		if !isFailureHeader(header.Key) {
+			headers = append(headers, header)
+		}
+	}
+	return kafka.Message{Topic: OriginalTopic(msg), Key: msg.Key, Value: msg.Value, Headers: headers}
+}
+
+func Header(msg kafka.Message, key string) (string, bool) {
+	for _, header := range msg.Headers {
+		if header.Key == key {
+			return string(header.Value), true
+		}
+	}
+	return "", false
+}
+
+// setHeader returns a copy of headers with key set to value, the message being routed keeps its own.
+func setHeader(headers []kafka.Header, key, value string) []kafka.Header {
+	updated := removeHeader(headers, key)
+	return append(updated, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func removeHeader(headers []kafka.Header, key string) []kafka.Header {
+	updated := make([]kafka.Header, 0, len(headers)+1)
+	for _, header := range headers {
+		if header.Key != key {
+			updated = append(updated, header)
+		}
+	}
+	return updated
+}
+
+func isFailureHeader(key string) bool {
+	return slices.Contains(failureHeaders, key)
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StatusRegistered = "registered"
+	StatusOnline     = "online"
+	StatusOffline    = "offline"
+	StatusDeleted    = "deleted"
+)
+
+type UserStatus struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Status     string    `json:"status"`
+	LastUpdate time.Time `json:"last_update"`
+}
+
+// StatusEvent is a status change caused by the event EventID.
+type StatusEvent struct {
+	EventID   uuid.UUID
+	EventType string
+	Status    UserStatus
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS UserStatus (
+    userid      UUID PRIMARY KEY,
+    status      VARCHAR(16) NOT NULL CHECK (status IN ('registered', 'online', 'offline', 'deleted')),
+    last_update TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS userstatus_status_last_update_idx ON UserStatus (status, last_update DESC, userid);
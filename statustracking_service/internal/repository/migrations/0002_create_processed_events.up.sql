@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS ProcessedEvents (
+    event_id     UUID PRIMARY KEY,
+    userid       UUID NOT NULL,
+    event_type   VARCHAR(64) NOT NULL,
+    occurred_at  TIMESTAMPTZ NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS processedevents_processed_at_idx ON ProcessedEvents (processed_at);
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"statustracking_service/internal/erro"
+	"statustracking_service/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type StatusPostgres struct {
+	Db *sql.DB
+}
+
+// SetStatus records the event and applies its status in one transaction. An event seen before
+// is skipped as a duplicate, and an event older than the stored status is recorded but not
+// applied, so redelivered or reordered events never move a user back to an earlier state.
+func (repoap *StatusPostgres) SetStatus(ctx context.Context, event model.StatusEvent) *RepositoryResponse {
+	status := event.Status
+	tx, err := repoap.Db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("SetStatus BeginTx Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetStatus}
+	}
+	defer func() {
+		if rErr := tx.Rollback(); rErr != nil && !errors.Is(rErr, sql.ErrTxDone) {
+			log.Printf("SetStatus Rollback Error: %v", rErr)
+		}
+	}()
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO ProcessedEvents (event_id, userid, event_type, occurred_at) values ($1, $2, $3, $4) ON CONFLICT (event_id) DO NOTHING;",
+		event.EventID, status.UserID, event.EventType, status.LastUpdate)
+	if err != nil {
+		log.Printf("SetStatus ProcessedEvents Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetStatus}
+	}
+	outcome := OutcomeApplied
+	if rows, err := result.RowsAffected(); err != nil {
+		log.Printf("SetStatus RowsAffected Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetStatus}
+	} else if rows == 0 {
+		outcome = OutcomeDuplicate
+	}
+
+	if outcome == OutcomeApplied {
+		// Events of one user share a partition key, so equal timestamps are applied in arrival order.
+		result, err = tx.ExecContext(ctx,
+			"INSERT INTO UserStatus (userid, status, last_update) values ($1, $2, $3) ON CONFLICT (userid) DO UPDATE SET status = EXCLUDED.status, last_update = EXCLUDED.last_update WHERE UserStatus.last_update <= EXCLUDED.last_update;",
+			status.UserID, status.Status, status.LastUpdate)
+		if err != nil {
+			log.Printf("SetStatus Error: %v", err)
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorSetStatus}
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			log.Printf("SetStatus RowsAffected Error: %v", err)
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorSetStatus}
+		}
+		if rows == 0 {
+			outcome = OutcomeStale
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("SetStatus Commit Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorSetStatus}
+	}
+	responseData := DBRepositoryResponseData{
+		Status:  status,
+		Outcome: outcome,
+	}
+	log.Printf("Event %v for user %v: %s status %s", event.EventID, status.UserID, outcome, status.Status)
+	return &RepositoryResponse{Success: true, Data: responseData, Errors: nil}
+}
+func (repoap *StatusPostgres) GetStatus(ctx context.Context, userID uuid.UUID) *RepositoryResponse {
+	var status model.UserStatus
+	err := repoap.Db.QueryRowContext(ctx, "SELECT userid, status, last_update FROM UserStatus WHERE userid = $1", userID).
+		Scan(&status.UserID, &status.Status, &status.LastUpdate)
+	if err != nil {
+		log.Printf("GetStatus Error: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &RepositoryResponse{Success: false, Errors: erro.ErrorStatusNotFound}
+		}
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetStatus}
+	}
+	responseData := DBRepositoryResponseData{
+		Status: status,
+	}
+	log.Println("Successful get status!")
+	return &RepositoryResponse{Success: true, Data: responseData, Errors: nil}
+}
+func (repoap *StatusPostgres) GetStatuses(ctx context.Context, userIDs []uuid.UUID) *RepositoryResponse {
+	ids := make(pq.StringArray, 0, len(userIDs))
+	for _, id := range userIDs {
+		ids = append(ids, id.String())
+	}
+	rows, err := repoap.Db.QueryContext(ctx, "SELECT userid, status, last_update FROM UserStatus WHERE userid = ANY($1::uuid[])", ids)
+	if err != nil {
+		log.Printf("GetStatuses Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetStatus}
+	}
+	defer rows.Close()
+	statuses, err := scanStatuses(rows)
+	if err != nil {
+		log.Printf("GetStatuses Scan Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetStatus}
+	}
+	responseData := DBRepositoryListResponseData{
+		Statuses: statuses,
+		Total:    len(statuses),
+	}
+	log.Printf("Successful get %d statuses!", len(statuses))
+	return &RepositoryResponse{Success: true, Data: responseData, Errors: nil}
+}
+func (repoap *StatusPostgres) GetOnlineStatuses(ctx context.Context, limit, offset int) *RepositoryResponse {
+	var total int
+	err := repoap.Db.QueryRowContext(ctx, "SELECT count(*) FROM UserStatus WHERE status = $1", model.StatusOnline).Scan(&total)
+	if err != nil {
+		log.Printf("GetOnlineStatuses Count Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetStatus}
+	}
+	rows, err := repoap.Db.QueryContext(ctx,
+		"SELECT userid, status, last_update FROM UserStatus WHERE status = $1 ORDER BY last_update DESC, userid LIMIT $2 OFFSET $3",
+		model.StatusOnline, limit, offset)
+	if err != nil {
+		log.Printf("GetOnlineStatuses Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetStatus}
+	}
+	defer rows.Close()
+	statuses, err := scanStatuses(rows)
+	if err != nil {
+		log.Printf("GetOnlineStatuses Scan Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorGetStatus}
+	}
+	responseData := DBRepositoryListResponseData{
+		Statuses: statuses,
+		Total:    total,
+	}
+	log.Printf("Successful get %d online statuses!", len(statuses))
+	return &RepositoryResponse{Success: true, Data: responseData, Errors: nil}
+}
+func scanStatuses(rows *sql.Rows) ([]model.UserStatus, error) {
+	statuses := make([]model.UserStatus, 0)
+	for rows.Next() {
+		var status model.UserStatus
+		if err := rows.Scan(&status.UserID, &status.Status, &status.LastUpdate); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+// DeleteProcessedEvents removes at most limit event ids processed before processedBefore. A
+// redelivery older than that is no longer recognised as a duplicate, but the last_update guard
+// still keeps it from moving the user back to an earlier state.
+func (repoap *StatusPostgres) DeleteProcessedEvents(ctx context.Context, processedBefore time.Time, limit int) *RepositoryResponse {
+	result, err := repoap.Db.ExecContext(ctx, `
+		DELETE FROM ProcessedEvents WHERE event_id IN (
+			SELECT event_id FROM ProcessedEvents WHERE processed_at < $1 ORDER BY processed_at LIMIT $2
+		)`, processedBefore, limit)
+	if err != nil {
+		log.Printf("DeleteProcessedEvents Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorDeleteProcessedEvents}
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("DeleteProcessedEvents RowsAffected Error: %v", err)
+		return &RepositoryResponse{Success: false, Errors: erro.ErrorDeleteProcessedEvents}
+	}
+	return &RepositoryResponse{Success: true, Data: DBCleanupResponseData{Deleted: deleted}}
+}
+func NewStatusPostgres(db *sql.DB) *StatusPostgres {
+	return &StatusPostgres{Db: db}
+}
@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"shared/events"
+	"statustracking_service/internal/erro"
+	"statustracking_service/internal/model"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusPostgres_SetStatus(t *testing.T) {
+	type testCase struct {
+		name            string
+		mockSetup       func(mock sqlmock.Sqlmock, event model.StatusEvent)
+		expectedSuccess bool
+		expectedError   error
+		expectedOutcome string
+	}
+
+	event := model.StatusEvent{
+		EventID:   uuid.New(),
+		EventType: events.TypeUserAuthenticated,
+		Status: model.UserStatus{
+			UserID:     uuid.New(),
+			Status:     model.StatusOnline,
+			LastUpdate: time.Now(),
+		},
+	}
+	expectProcessed := func(mock sqlmock.Sqlmock, event model.StatusEvent) *sqlmock.ExpectedExec {
+		return mock.ExpectExec("INSERT INTO ProcessedEvents").
+			WithArgs(event.EventID, event.Status.UserID, event.EventType, event.Status.LastUpdate)
+	}
+	expectUpsert := func(mock sqlmock.Sqlmock, event model.StatusEvent) *sqlmock.ExpectedExec {
+		return mock.ExpectExec("INSERT INTO UserStatus .* WHERE UserStatus.last_update <= EXCLUDED.last_update").
+			WithArgs(event.Status.UserID, event.Status.Status, event.Status.LastUpdate)
+	}
+
+	testCases := []testCase{
+		{
+			name: "Successful SetStatus",
+			mockSetup: func(mock sqlmock.Sqlmock, event model.StatusEvent) {
+				mock.ExpectBegin()
+				expectProcessed(mock, event).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectUpsert(mock, event).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedSuccess: true,
+			expectedOutcome: OutcomeApplied,
+		},
+		{
+			name: "Duplicate Event",
+			mockSetup: func(mock sqlmock.Sqlmock, event model.StatusEvent) {
+				mock.ExpectBegin()
+				expectProcessed(mock, event).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expectedSuccess: true,
+			expectedOutcome: OutcomeDuplicate,
+		},
+		{
+			name: "Stale Event",
+			mockSetup: func(mock sqlmock.Sqlmock, event model.StatusEvent) {
+				mock.ExpectBegin()
+				expectProcessed(mock, event).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectUpsert(mock, event).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			expectedSuccess: true,
+			expectedOutcome: OutcomeStale,
+		},
+		{
+			name: "General Error",
+			mockSetup: func(mock sqlmock.Sqlmock, event model.StatusEvent) {
+				mock.ExpectBegin()
+				expectProcessed(mock, event).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectUpsert(mock, event).WillReturnError(errors.New("general database error"))
+				mock.ExpectRollback()
+			},
+			expectedSuccess: false,
+			expectedError:   erro.ErrorSetStatus,
+		},
+		{
+			name: "Commit Error",
+			mockSetup: func(mock sqlmock.Sqlmock, event model.StatusEvent) {
+				mock.ExpectBegin()
+				expectProcessed(mock, event).WillReturnResult(sqlmock.NewResult(0, 1))
+				expectUpsert(mock, event).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit().WillReturnError(errors.New("commit failed"))
+			},
+			expectedSuccess: false,
+			expectedError:   erro.ErrorSetStatus,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			repo := NewStatusPostgres(db)
+
+			tc.mockSetup(mock, event)
+
+			response := repo.SetStatus(context.Background(), event)
+
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+			if tc.expectedSuccess {
+				dataCasted, ok := response.Data.(DBRepositoryResponseData)
+				assert.True(t, ok, "Data должен быть типа DBRepositoryResponseData")
+				assert.Equal(t, event.Status, dataCasted.Status, "Status должен совпадать")
+				assert.Equal(t, tc.expectedOutcome, dataCasted.Outcome, "Outcome должен совпадать")
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+func TestStatusPostgres_GetStatus(t *testing.T) {
+	type testCase struct {
+		name            string
+		mockSetup       func(mock sqlmock.Sqlmock, status model.UserStatus)
+		expectedSuccess bool
+		expectedError   error
+	}
+
+	status := model.UserStatus{
+		UserID:     uuid.New(),
+		Status:     model.StatusOffline,
+		LastUpdate: time.Now().UTC(),
+	}
+
+	testCases := []testCase{
+		{
+			name: "Successful GetStatus",
+			mockSetup: func(mock sqlmock.Sqlmock, status model.UserStatus) {
+				mock.ExpectQuery("SELECT userid, status, last_update FROM UserStatus WHERE userid =").
+					WithArgs(status.UserID).
+					WillReturnRows(sqlmock.NewRows([]string{"userid", "status", "last_update"}).AddRow(status.UserID, status.Status, status.LastUpdate))
+			},
+			expectedSuccess: true,
+			expectedError:   nil,
+		},
+		{
+			name: "Status Not Found",
+			mockSetup: func(mock sqlmock.Sqlmock, status model.UserStatus) {
+				mock.ExpectQuery("SELECT userid, status, last_update FROM UserStatus WHERE userid =").
+					WithArgs(status.UserID).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedSuccess: false,
+			expectedError:   erro.ErrorStatusNotFound,
+		},
+		{
+			name: "General Error",
+			mockSetup: func(mock sqlmock.Sqlmock, status model.UserStatus) {
+				mock.ExpectQuery("SELECT userid, status, last_update FROM UserStatus WHERE userid =").
+					WithArgs(status.UserID).
+					WillReturnError(errors.New("general database error"))
+			},
+			expectedSuccess: false,
+			expectedError:   erro.ErrorGetStatus,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("Failed to create sqlmock: %v", err)
+			}
+			defer db.Close()
+
+			repo := NewStatusPostgres(db)
+
+			tc.mockSetup(mock, status)
+
+			response := repo.GetStatus(context.Background(), status.UserID)
+
+			assert.Equal(t, tc.expectedSuccess, response.Success, "Success должен совпадать")
+			assert.Equal(t, tc.expectedError, response.Errors, "Тип ошибки должен совпадать")
+			if tc.expectedSuccess {
+				dataCasted, ok := response.Data.(DBRepositoryResponseData)
+				assert.True(t, ok, "Data должен быть типа DBRepositoryResponseData")
+				assert.Equal(t, status, dataCasted.Status, "Status должен совпадать")
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("There were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+func TestStatusPostgres_GetOnlineStatuses(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create sqlmock: %v", err)
+	}
+	defer db.Close()
+
+	first := model.UserStatus{UserID: uuid.New(), Status: model.StatusOnline, LastUpdate: time.Now().UTC()}
+	second := model.UserStatus{UserID: uuid.New(), Status: model.StatusOnline, LastUpdate: first.LastUpdate.Add(-time.Minute)}
+
+	mock.ExpectQuery("SELECT count").
+		WithArgs(model.StatusOnline).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+	mock.ExpectQuery("SELECT userid, status, last_update FROM UserStatus WHERE status =").
+		WithArgs(model.StatusOnline, 2, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"userid", "status", "last_update"}).
+			AddRow(first.UserID, first.Status, first.LastUpdate).
+			AddRow(second.UserID, second.Status, second.LastUpdate))
+
+	repo := NewStatusPostgres(db)
+	response := repo.GetOnlineStatuses(context.Background(), 2, 4)
+
+	assert.True(t, response.Success, "Success должен быть true")
+	dataCasted, ok := response.Data.(DBRepositoryListResponseData)
+	assert.True(t, ok, "Data должен быть типа DBRepositoryListResponseData")
+	assert.Equal(t, 7, dataCasted.Total, "Total должен совпадать")
+	assert.Equal(t, []model.UserStatus{first, second}, dataCasted.Statuses, "Statuses должны совпадать")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("There were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStatusPostgres_DeleteProcessedEvents(t *testing.T) {
+	processedBefore := time.Now().Add(-7 * 24 * time.Hour)
+
+	t.Run("Deletes Processed Events", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec(`DELETE FROM ProcessedEvents WHERE event_id IN \(\s+SELECT event_id FROM ProcessedEvents WHERE processed_at < \$1`).
+			WithArgs(processedBefore, 1000).
+			WillReturnResult(sqlmock.NewResult(0, 42))
+
+		repo := NewStatusPostgres(db)
+		response := repo.DeleteProcessedEvents(context.Background(), processedBefore, 1000)
+		assert.True(t, response.Success, "Success должен быть true")
+		data, ok := response.Data.(DBCleanupResponseData)
+		assert.True(t, ok, "Data должен быть типа DBCleanupResponseData")
+		assert.Equal(t, int64(42), data.Deleted)
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+	t.Run("Delete Error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("Failed to create sqlmock: %v", err)
+		}
+		defer db.Close()
+
+		mock.ExpectExec("DELETE FROM ProcessedEvents").
+			WithArgs(processedBefore, 1000).
+			WillReturnError(errors.New("general database error"))
+
+		repo := NewStatusPostgres(db)
+		response := repo.DeleteProcessedEvents(context.Background(), processedBefore, 1000)
+		assert.False(t, response.Success, "Success должен быть false")
+		assert.Equal(t, erro.ErrorDeleteProcessedEvents, response.Errors, "Тип ошибки должен совпадать")
+
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("There were unfulfilled expectations: %s", err)
+		}
+	})
+}
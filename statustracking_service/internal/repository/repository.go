@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"statustracking_service/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//go:generate mockgen -source=repository.go -destination=mocks/mock.go
+type DBStatusRepos interface {
+	SetStatus(ctx context.Context, event model.StatusEvent) *RepositoryResponse
+	GetStatus(ctx context.Context, userID uuid.UUID) *RepositoryResponse
+	GetStatuses(ctx context.Context, userIDs []uuid.UUID) *RepositoryResponse
+	GetOnlineStatuses(ctx context.Context, limit, offset int) *RepositoryResponse
+	DeleteProcessedEvents(ctx context.Context, processedBefore time.Time, limit int) *RepositoryResponse
+}
+type Repository struct {
+	DBStatusRepos
+}
+type RepositoryResponse struct {
+	Success bool
+	Data    interface{}
+	Errors  error
+}
+
+type DBRepositoryResponseData struct {
+	Status model.UserStatus
+	// Outcome is set by SetStatus and tells whether the event changed the stored status.
+	Outcome string
+}
+
+const (
+	OutcomeApplied   = "applied"
+	OutcomeDuplicate = "duplicate"
+	OutcomeStale     = "stale"
+)
+
+type DBRepositoryListResponseData struct {
+	Statuses []model.UserStatus
+	Total    int
+}
+
+type DBCleanupResponseData struct {
+	// Deleted is the number of rows removed by DeleteProcessedEvents.
+	Deleted int64
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{
+		DBStatusRepos: NewStatusPostgres(db),
+	}
+}
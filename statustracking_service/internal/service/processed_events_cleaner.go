@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"log"
+	"statustracking_service/internal/repository"
+	"time"
+)
+
+type ProcessedEventsCleanerConfig struct {
+	// Retention is how long the id of a processed event is kept to detect redeliveries.
+	Retention       time.Duration
+	CleanupInterval time.Duration
+	BatchSize       int
+}
+
+// ProcessedEventsCleaner keeps the ProcessedEvents table from growing forever by deleting the
+// ids of events processed longer than Retention ago.
+type ProcessedEventsCleaner struct {
+	dbrepo repository.DBStatusRepos
+	config ProcessedEventsCleanerConfig
+}
+
+func NewProcessedEventsCleaner(repo repository.DBStatusRepos, config ProcessedEventsCleanerConfig) *ProcessedEventsCleaner {
+	if config.Retention <= 0 {
+		config.Retention = 7 * 24 * time.Hour
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = time.Hour
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+	return &ProcessedEventsCleaner{dbrepo: repo, config: config}
+}
+
+func (pc *ProcessedEventsCleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(pc.config.CleanupInterval)
+	defer ticker.Stop()
+	pc.deleteProcessedEvents(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Processed events cleaner stopped")
+			return
+		case <-ticker.C:
+			pc.deleteProcessedEvents(ctx)
+		}
+	}
+}
+
+// deleteProcessedEvents removes the event ids older than Retention, in batches so that a large
+// backlog does not hold one long transaction.
+func (pc *ProcessedEventsCleaner) deleteProcessedEvents(ctx context.Context) int64 {
+	processedBefore := time.Now().Add(-pc.config.Retention)
+	var total int64
+	for ctx.Err() == nil {
+		response := pc.dbrepo.DeleteProcessedEvents(ctx, processedBefore, pc.config.BatchSize)
+		if !response.Success {
+			log.Printf("Error when deleting processed events: %v", response.Errors)
+			break
+		}
+		data, ok := response.Data.(repository.DBCleanupResponseData)
+		if !ok {
+			log.Printf("Unexpected data type from repository: %T", response.Data)
+			break
+		}
+		total += data.Deleted
+		if data.Deleted < int64(pc.config.BatchSize) {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("%d processed events older than %v have been deleted", total, pc.config.Retention)
+	}
+	return total
+}
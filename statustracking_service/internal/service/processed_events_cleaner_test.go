@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessedEventsCleaner_DeleteProcessedEvents(t *testing.T) {
+	now := time.Now()
+	recent := uuid.New()
+	repo := &fakeStatusRepo{processedAt: map[uuid.UUID]time.Time{
+		uuid.New(): now.Add(-30 * 24 * time.Hour),
+		uuid.New(): now.Add(-8 * 24 * time.Hour),
+		uuid.New(): now.Add(-8 * 24 * time.Hour),
+		recent:     now.Add(-time.Hour),
+	}}
+	cleaner := NewProcessedEventsCleaner(repo, ProcessedEventsCleanerConfig{BatchSize: 2, Retention: 7 * 24 * time.Hour})
+
+	deleted := cleaner.deleteProcessedEvents(context.Background())
+
+	assert.Equal(t, int64(3), deleted, "Должны удаляться все события старше срока хранения, пачками")
+	assert.Equal(t, map[uuid.UUID]time.Time{recent: now.Add(-time.Hour)}, repo.processedAt, "Недавние события должны сохраняться")
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"shared/events"
+	"statustracking_service/internal/model"
+	"statustracking_service/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//go:generate mockgen -source=service.go -destination=mocks/mock.go
+type StatusTracking interface {
+	UpdateStatus(ctx context.Context, topic string, payload []byte) *ServiceResponse
+	GetStatus(ctx context.Context, userID uuid.UUID) *ServiceResponse
+	GetStatuses(ctx context.Context, userIDs []uuid.UUID) *ServiceResponse
+	GetOnlineStatuses(ctx context.Context, limit, offset int) *ServiceResponse
+}
+type Service struct {
+	StatusTracking
+}
+type ServiceResponse struct {
+	Success    bool
+	UserId     uuid.UUID
+	Status     string
+	LastUpdate time.Time
+	Statuses   []model.UserStatus
+	Total      int
+	Applied    bool // false when UpdateStatus skipped a duplicate or out-of-order event
+	Errors     map[string]error
+}
+
+func NewService(repos *repository.Repository, topics events.Topics) *Service {
+
+	return &Service{
+
+		StatusTracking: NewStatusService(repos.DBStatusRepos, topics),
+	}
+}
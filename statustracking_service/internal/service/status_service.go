@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"log"
+	"shared/events"
+	"statustracking_service/internal/erro"
+	"statustracking_service/internal/model"
+	"statustracking_service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var eventStatus = map[string]string{
+	events.TypeUserRegistered:    model.StatusRegistered,
+	events.TypeUserAuthenticated: model.StatusOnline,
+	events.TypeUserLoggedOut:     model.StatusOffline,
+	events.TypeUserDeleted:       model.StatusDeleted,
+}
+
+type StatusService struct {
+	dbrepo      repository.DBStatusRepos
+	topicEvents map[string]string
+}
+
+func NewStatusService(repo repository.DBStatusRepos, topics events.Topics) *StatusService {
+	topicEvents := map[string]string{
+		topics.UserRegistered:   events.TypeUserRegistered,
+		topics.UserAuthenticate: events.TypeUserAuthenticated,
+		topics.UserLoggedOut:    events.TypeUserLoggedOut,
+		topics.UserDelete:       events.TypeUserDeleted,
+	}
+	return &StatusService{dbrepo: repo, topicEvents: topicEvents}
+}
+
+func (ss *StatusService) UpdateStatus(ctx context.Context, topic string, payload []byte) *ServiceResponse {
+	updateMap := make(map[string]error)
+
+	expectedType, ok := ss.topicEvents[topic]
+	if !ok {
+		log.Printf("Received event from unknown topic %s", topic)
+		updateMap["Topic"] = erro.ErrorUnknownTopic
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+
+	envelope, eventPayload, err := events.Unmarshal(payload)
+	if err != nil {
+		log.Printf("Event validation error on topic %s: %v", topic, err)
+		updateMap["Event"] = erro.ErrorInvalidEvent
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	if envelope.EventType != expectedType {
+		log.Printf("Event %s of type %s does not belong to topic %s", envelope.EventID, envelope.EventType, topic)
+		updateMap["Event"] = erro.ErrorInvalidEvent
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	userID, ok := events.UserIDOf(eventPayload)
+	if !ok {
+		log.Printf("Event %s of type %s carries no user id", envelope.EventID, envelope.EventType)
+		updateMap["Event"] = erro.ErrorInvalidEvent
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("UpdateStatus: Context cancelled before SetStatus: %v", ctx.Err())
+		updateMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+
+	userStatus := model.UserStatus{
+		UserID:     userID,
+		Status:     eventStatus[envelope.EventType],
+		LastUpdate: envelope.OccurredAt,
+	}
+	response := ss.dbrepo.SetStatus(ctx, model.StatusEvent{EventID: envelope.EventID, EventType: envelope.EventType, Status: userStatus})
+	if !response.Success {
+		log.Printf("Error when setting user status in the database %v", response.Errors)
+		updateMap["SetStatusError"] = response.Errors
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+	dbData, ok := response.Data.(repository.DBRepositoryResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		updateMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: updateMap}
+	}
+
+	switch dbData.Outcome {
+	case repository.OutcomeDuplicate:
+		log.Printf("Event %s was already processed, skipping", envelope.EventID)
+	case repository.OutcomeStale:
+		log.Printf("Event %s for user %v is older than the stored status, skipping", envelope.EventID, userStatus.UserID)
+	default:
+		log.Printf("User %v is now %s", userStatus.UserID, userStatus.Status)
+	}
+	return &ServiceResponse{
+		Success:    true,
+		UserId:     userStatus.UserID,
+		Status:     userStatus.Status,
+		LastUpdate: userStatus.LastUpdate,
+		Applied:    dbData.Outcome == repository.OutcomeApplied,
+	}
+}
+
+const MaxBatchSize = 100
+
+func (ss *StatusService) GetStatus(ctx context.Context, userID uuid.UUID) *ServiceResponse {
+	getMap := make(map[string]error)
+	if ctx.Err() != nil {
+		log.Printf("GetStatus: Context cancelled before GetStatus: %v", ctx.Err())
+		getMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: getMap}
+	}
+	response := ss.dbrepo.GetStatus(ctx, userID)
+	if !response.Success {
+		log.Printf("Error when getting user status from the database %v", response.Errors)
+		getMap["GetStatusError"] = response.Errors
+		return &ServiceResponse{Success: false, Errors: getMap}
+	}
+	dbData, ok := response.Data.(repository.DBRepositoryResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		getMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: getMap}
+	}
+	return &ServiceResponse{
+		Success:    true,
+		UserId:     dbData.Status.UserID,
+		Status:     dbData.Status.Status,
+		LastUpdate: dbData.Status.LastUpdate,
+	}
+}
+
+func (ss *StatusService) GetStatuses(ctx context.Context, userIDs []uuid.UUID) *ServiceResponse {
+	batchMap := make(map[string]error)
+	if len(userIDs) == 0 {
+		batchMap["UserIds"] = erro.ErrorEmptyBatch
+		return &ServiceResponse{Success: false, Errors: batchMap}
+	}
+	if len(userIDs) > MaxBatchSize {
+		batchMap["UserIds"] = erro.ErrorBatchTooLarge
+		return &ServiceResponse{Success: false, Errors: batchMap}
+	}
+	if ctx.Err() != nil {
+		log.Printf("GetStatuses: Context cancelled before GetStatuses: %v", ctx.Err())
+		batchMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: batchMap}
+	}
+	return ss.listResponse(ss.dbrepo.GetStatuses(ctx, userIDs), batchMap)
+}
+
+func (ss *StatusService) GetOnlineStatuses(ctx context.Context, limit, offset int) *ServiceResponse {
+	onlineMap := make(map[string]error)
+	if ctx.Err() != nil {
+		log.Printf("GetOnlineStatuses: Context cancelled before GetOnlineStatuses: %v", ctx.Err())
+		onlineMap["ContextError"] = erro.ErrorContextTimeout
+		return &ServiceResponse{Success: false, Errors: onlineMap}
+	}
+	return ss.listResponse(ss.dbrepo.GetOnlineStatuses(ctx, limit, offset), onlineMap)
+}
+
+func (ss *StatusService) listResponse(response *repository.RepositoryResponse, errorMap map[string]error) *ServiceResponse {
+	if !response.Success {
+		log.Printf("Error when getting user statuses from the database %v", response.Errors)
+		errorMap["GetStatusError"] = response.Errors
+		return &ServiceResponse{Success: false, Errors: errorMap}
+	}
+	dbData, ok := response.Data.(repository.DBRepositoryListResponseData)
+	if !ok {
+		log.Printf("Unexpected data type from repository: %T", response.Data)
+		errorMap["UnexpectedData"] = erro.ErrorUnexpectedData
+		return &ServiceResponse{Success: false, Errors: errorMap}
+	}
+	return &ServiceResponse{
+		Success:  true,
+		Statuses: dbData.Statuses,
+		Total:    dbData.Total,
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"shared/events"
+	"statustracking_service/internal/erro"
+	"statustracking_service/internal/model"
+	"statustracking_service/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStatusRepo deduplicates and orders events the way StatusPostgres does.
+type fakeStatusRepo struct {
+	statuses  []model.UserStatus
+	processed map[uuid.UUID]bool
+	current   map[uuid.UUID]model.UserStatus
+	// processedAt holds the processing time of events for DeleteProcessedEvents.
+	processedAt map[uuid.UUID]time.Time
+}
+
+func (f *fakeStatusRepo) SetStatus(ctx context.Context, event model.StatusEvent) *repository.RepositoryResponse {
+	if f.processed == nil {
+		f.processed = make(map[uuid.UUID]bool)
+		f.current = make(map[uuid.UUID]model.UserStatus)
+	}
+	status := event.Status
+	outcome := repository.OutcomeApplied
+	if f.processed[event.EventID] {
+		outcome = repository.OutcomeDuplicate
+	} else if current, ok := f.current[status.UserID]; ok && current.LastUpdate.After(status.LastUpdate) {
+		outcome = repository.OutcomeStale
+	}
+	f.processed[event.EventID] = true
+	if outcome == repository.OutcomeApplied {
+		f.current[status.UserID] = status
+		f.statuses = append(f.statuses, status)
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryResponseData{Status: status, Outcome: outcome}}
+}
+func (f *fakeStatusRepo) GetStatus(ctx context.Context, userID uuid.UUID) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: false, Errors: erro.ErrorStatusNotFound}
+}
+func (f *fakeStatusRepo) GetStatuses(ctx context.Context, userIDs []uuid.UUID) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryListResponseData{}}
+}
+func (f *fakeStatusRepo) GetOnlineStatuses(ctx context.Context, limit, offset int) *repository.RepositoryResponse {
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBRepositoryListResponseData{}}
+}
+func (f *fakeStatusRepo) DeleteProcessedEvents(ctx context.Context, processedBefore time.Time, limit int) *repository.RepositoryResponse {
+	var deleted int64
+	for eventID, processedAt := range f.processedAt {
+		if deleted == int64(limit) {
+			break
+		}
+		if processedAt.Before(processedBefore) {
+			delete(f.processedAt, eventID)
+			delete(f.processed, eventID)
+			deleted++
+		}
+	}
+	return &repository.RepositoryResponse{Success: true, Data: repository.DBCleanupResponseData{Deleted: deleted}}
+}
+
+var testTopics = events.Topics{
+	UserAuthenticate: "test-user-authenticate-topic",
+	UserRegistered:   "test-user-registered-topic",
+	UserLoggedOut:    "test-user-logged-out-topic",
+	UserDelete:       "test-user-delete-topic",
+}
+
+func TestStatusService_UpdateStatus(t *testing.T) {
+	userID := uuid.New()
+	occurredAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	envelope := func(payload events.Payload) []byte {
+		data, _, err := events.Marshal(payload, "auth_service", occurredAt)
+		require.NoError(t, err)
+		return data
+	}
+
+	testCases := []struct {
+		name           string
+		topic          string
+		payload        []byte
+		expectedStatus string
+		expectedError  error
+	}{
+		{name: "Registered", topic: testTopics.UserRegistered, payload: envelope(&events.UserRegistered{UserID: userID}), expectedStatus: model.StatusRegistered},
+		{name: "Authenticated", topic: testTopics.UserAuthenticate, payload: envelope(&events.UserAuthenticated{UserID: userID}), expectedStatus: model.StatusOnline},
+		{name: "Logged Out", topic: testTopics.UserLoggedOut, payload: envelope(&events.UserLoggedOut{UserID: userID}), expectedStatus: model.StatusOffline},
+		{name: "Deleted", topic: testTopics.UserDelete, payload: envelope(&events.UserDeleted{UserID: userID}), expectedStatus: model.StatusDeleted},
+		{name: "Event On Wrong Topic", topic: testTopics.UserLoggedOut, payload: envelope(&events.UserAuthenticated{UserID: userID}), expectedError: erro.ErrorInvalidEvent},
+		{name: "Legacy Payload", topic: testTopics.UserAuthenticate, payload: []byte(`{"user_id":"` + userID.String() + `","last_update":"2025-03-01T12:00:00Z"}`), expectedError: erro.ErrorInvalidEvent},
+		{name: "Unknown Topic", topic: "unknown-topic", payload: envelope(&events.UserRegistered{UserID: userID}), expectedError: erro.ErrorUnknownTopic},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &fakeStatusRepo{}
+			ss := NewStatusService(repo, testTopics)
+
+			response := ss.UpdateStatus(context.Background(), tc.topic, tc.payload)
+
+			if tc.expectedError != nil {
+				assert.False(t, response.Success, "Success должен быть false")
+				for _, err := range response.Errors {
+					assert.Equal(t, tc.expectedError, err, "Тип ошибки должен совпадать")
+				}
+				assert.Empty(t, repo.statuses, "Статус не должен сохраняться")
+				return
+			}
+			require.True(t, response.Success, "Success должен быть true: %v", response.Errors)
+			require.Len(t, repo.statuses, 1)
+			assert.Equal(t, model.UserStatus{UserID: userID, Status: tc.expectedStatus, LastUpdate: occurredAt}, repo.statuses[0])
+		})
+	}
+}
+
+func TestStatusService_UpdateStatusIsIdempotent(t *testing.T) {
+	userID := uuid.New()
+	loggedIn := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	authenticated, _, err := events.Marshal(&events.UserAuthenticated{UserID: userID}, "auth_service", loggedIn)
+	require.NoError(t, err)
+	loggedOut, _, err := events.Marshal(&events.UserLoggedOut{UserID: userID}, "auth_service", loggedIn.Add(time.Hour))
+	require.NoError(t, err)
+
+	repo := &fakeStatusRepo{}
+	ss := NewStatusService(repo, testTopics)
+	ctx := context.Background()
+
+	response := ss.UpdateStatus(ctx, testTopics.UserAuthenticate, authenticated)
+	require.True(t, response.Success)
+	assert.True(t, response.Applied, "Первое событие должно применяться")
+
+	response = ss.UpdateStatus(ctx, testTopics.UserAuthenticate, authenticated)
+	require.True(t, response.Success, "Повтор не должен считаться ошибкой")
+	assert.False(t, response.Applied, "Повтор события не должен применяться")
+
+	// A resent authentication event must not bring the user back online after the logout.
+	resent, _, err := events.Marshal(&events.UserAuthenticated{UserID: userID}, "auth_service", loggedIn)
+	require.NoError(t, err)
+	require.True(t, ss.UpdateStatus(ctx, testTopics.UserLoggedOut, loggedOut).Applied)
+	response = ss.UpdateStatus(ctx, testTopics.UserAuthenticate, resent)
+	require.True(t, response.Success)
+	assert.False(t, response.Applied, "Устаревшее событие не должно применяться")
+
+	require.Len(t, repo.statuses, 2)
+	assert.Equal(t, model.StatusOffline, repo.current[userID].Status, "Пользователь должен остаться offline")
+}